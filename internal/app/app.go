@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/handlers"
+	"go-url-shortener/internal/health"
+	"go-url-shortener/internal/logger"
+	"go-url-shortener/internal/metrics"
+	modelsService "go-url-shortener/internal/models/service"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"go-url-shortener/internal/shortdomains"
+	"go-url-shortener/internal/storage/storageshortlink"
+	"go-url-shortener/internal/workspaces"
+	"net/http"
+)
+
+// Параметры создания приложения
+// Обязательна только конфигурация, незаданные зависимости создаются по ней
+type Options struct {
+	// конфигурация приложения
+	Config config.ConfigTypeInterface
+	// логер, по умолчанию общий логер процесса
+	Logger *logger.TypeAppLogger
+	// соединение с БД, по умолчанию открывается по строке подключения из конфигурации
+	DBHandler *dbconn.DBHandler
+	// хранилище ссылок, по умолчанию создается по настройке STORAGE_BACKEND
+	Storage modelsStorage.StorageShortInterface
+	// домены коротких ссылок, по умолчанию из настроек BASE_URL и SHORT_DOMAINS
+	ShortDomains *shortdomains.Registry
+	// рабочие пространства, по умолчанию из файла WORKSPACES_FILE
+	Workspaces *workspaces.Registry
+}
+
+// Приложение: владеет конфигурацией, логером, соединением с БД, хранилищем, сервисом,
+// доменами, рабочими пространствами, метриками и признаком остановки
+// В одном процессе можно собрать несколько приложений с разными настройками.
+type App struct {
+	config    config.ConfigTypeInterface
+	logger    *logger.TypeAppLogger
+	dbHandler *dbconn.DBHandler
+	storage   modelsStorage.StorageShortInterface
+	// домены, рабочие пространства и метрики, общие для сервиса и обработчиков приложения
+	deps service.Dependencies
+	// признак остановки сервера приложения
+	health *health.Status
+	// подписки созданного приложением хранилища на перезагрузку конфигурации и на изменения в БД
+	subscriptions *storageshortlink.Subscriptions
+	service       modelsService.ServiceShortInterface
+	handler       http.Handler
+	// обработчик служебного сервера с метриками
+	adminHandler http.Handler
+
+	// соединение с БД создано приложением, значит и закрывается им
+	isOwnDBHandler bool
+}
+
+// Сборка приложения из явно переданных зависимостей
+func New(options Options) (app *App, err error) {
+
+	if options.Config == nil {
+		return nil, errors.New("ошибка: для создания приложения нужна конфигурация")
+	}
+
+	app = &App{
+		config:    options.Config,
+		logger:    options.Logger,
+		dbHandler: options.DBHandler,
+		storage:   options.Storage,
+	}
+	if app.logger == nil {
+		appLogger := logger.GetLogger()
+		app.logger = &appLogger
+	}
+
+	// с ошибкой в доменах или в файле рабочих пространств приложение не создается
+	app.deps = service.Dependencies{
+		Config:       app.config,
+		ShortDomains: options.ShortDomains,
+		Workspaces:   options.Workspaces,
+		Metrics:      metrics.NewAppMetrics(),
+	}
+	if app.deps.ShortDomains == nil {
+		app.deps.ShortDomains, err = shortdomains.NewRegistryFromConfig(app.config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if app.deps.Workspaces == nil {
+		app.deps.Workspaces, err = workspaces.LoadRegistryFromConfig(app.config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	app.health = health.NewStatus()
+
+	if app.dbHandler == nil {
+		app.dbHandler = dbconn.NewDBHandler(app.config)
+		app.isOwnDBHandler = true
+	}
+	if app.storage == nil {
+		app.subscriptions = &storageshortlink.Subscriptions{}
+		app.storage, err = storageshortlink.NewStorageShortsByBackend(app.config.GetStorageBackend(), storageshortlink.Dependencies{
+			Config:        app.config,
+			DBHandler:     app.dbHandler,
+			Subscriptions: app.subscriptions,
+		})
+		if err != nil {
+			app.closeDBHandler()
+			return nil, fmt.Errorf("ошибка: не удалось создать хранилище ссылок: %w", err)
+		}
+	}
+	app.logger.Debugf("Используется хранилище ссылок: %s", storageshortlink.GetStorageBackendName(app.storage))
+
+	app.service = service.NewServiceShortLink(app.storage, app.deps)
+	depsHandlers := handlers.Dependencies{
+		Dependencies: app.deps,
+		DBHandler:    app.dbHandler,
+		Health:       app.health,
+	}
+	app.handler = handlers.NewRouterHandler(app.service, depsHandlers)
+	app.adminHandler = handlers.NewAdminRouterHandler(app.service, depsHandlers)
+	return app, nil
+}
+
+// Конфигурация приложения
+func (app *App) GetConfig() config.ConfigTypeInterface {
+	return app.config
+}
+
+// Логер приложения
+func (app *App) GetLogger() *logger.TypeAppLogger {
+	return app.logger
+}
+
+// Соединение с БД приложения
+func (app *App) GetDBHandler() *dbconn.DBHandler {
+	return app.dbHandler
+}
+
+// Хранилище ссылок приложения
+func (app *App) GetStorage() modelsStorage.StorageShortInterface {
+	return app.storage
+}
+
+// Сервис коротких ссылок приложения
+func (app *App) GetService() modelsService.ServiceShortInterface {
+	return app.service
+}
+
+// Обработчик запросов приложения
+func (app *App) GetHandler() http.Handler {
+	return app.handler
+}
+
+// Обработчик запросов служебного сервера
+func (app *App) GetAdminHandler() http.Handler {
+	return app.adminHandler
+}
+
+// Метрики приложения
+func (app *App) GetMetrics() *metrics.AppMetrics {
+	return app.deps.Metrics
+}
+
+// Признак остановки сервера приложения, после отметки проверка готовности не проходит
+func (app *App) GetHealth() *health.Status {
+	return app.health
+}
+
+// Остановка приложения: отменяются подписки хранилища, хранилище сохраняет данные, соединение с БД закрывается
+// Переданное снаружи соединение с БД не закрывается, им владеет вызывающий
+func (app *App) Close(ctx context.Context) (err error) {
+
+	if app.subscriptions != nil {
+		app.subscriptions.Close()
+	}
+
+	if storageCloser, ok := app.storage.(modelsStorage.StorageCloserInterface); ok {
+		err = storageCloser.Close(ctx)
+		if err != nil {
+			err = fmt.Errorf("ошибка остановки хранилища: %w", err)
+		}
+	}
+	app.closeDBHandler()
+	return
+}
+
+func (app *App) closeDBHandler() {
+	if app.isOwnDBHandler {
+		app.dbHandler.Close()
+	}
+}
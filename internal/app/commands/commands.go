@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"errors"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+)
+
+// Обработчик команды приложения, получает аргументы после названия команды
+type commandHandler func(configApp config.ConfigTypeInterface, args []string) (err error)
+
+// список команд приложения
+var listCommands = map[string]commandHandler{
+	"reencrypt": runReEncrypt,
+	"migrate":   runMigrate,
+	"config":    runConfig,
+}
+
+// Выполнение команды, переданной после флагов запуска
+// isCommand = false означает, что команда не передана и надо запускать сервер
+func Run(configApp config.ConfigTypeInterface, commandArgs []string) (isCommand bool, err error) {
+
+	if len(commandArgs) == 0 {
+		return false, nil
+	}
+
+	nameCommand := commandArgs[0]
+	handler, ok := listCommands[nameCommand]
+	if !ok {
+		return true, errors.New("ошибка: неизвестная команда приложения: " + nameCommand)
+	}
+
+	logger.GetLogger().Debugf("Выполняем команду приложения: %s", nameCommand)
+	err = handler(configApp, commandArgs[1:])
+	return true, err
+}
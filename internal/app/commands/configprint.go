@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-url-shortener/internal/config"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Работа с конфигурацией приложения
+// config print [yaml|json] - показать действующие настройки, секреты скрыты (по умолчанию yaml)
+// config schema - показать JSON схему файла конфигурации
+func runConfig(configApp config.ConfigTypeInterface, args []string) (err error) {
+
+	action := "print"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "print":
+		format := "yaml"
+		if len(args) > 1 {
+			format = args[1]
+		}
+		return printConfigValue(config.GetPrintableConfig(configApp), format)
+
+	case "schema":
+		return printConfigValue(config.GetSchemaFileConfig(), "json")
+
+	default:
+		return fmt.Errorf("ошибка: неизвестное действие с конфигурацией %s, допустимы: print [yaml|json], schema", action)
+	}
+}
+
+// Вывод значения в выбранном формате в стандартный вывод
+func printConfigValue(value any, format string) (err error) {
+
+	switch format {
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		err = encoder.Encode(value)
+		if err == nil {
+			err = encoder.Close()
+		}
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(value)
+	default:
+		return fmt.Errorf("ошибка: неизвестный формат вывода конфигурации %s, допустимы: yaml, json", format)
+	}
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось вывести конфигурацию: %w", err)
+	}
+	return
+}
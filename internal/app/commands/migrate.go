@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/database/migrations"
+	"strconv"
+)
+
+// Миграции таблицы коротких ссылок в БД
+// migrate up - применить новые миграции (по умолчанию)
+// migrate down [N] - откатить N последних миграций, по умолчанию одну
+// migrate status - показать состояние миграций
+func runMigrate(configApp config.ConfigTypeInterface, args []string) (err error) {
+
+	dbHandler := dbconn.NewDBHandler(configApp)
+	defer dbHandler.Close()
+	err = dbHandler.GetErrSetup()
+	if err != nil {
+		return fmt.Errorf("ошибка: для миграций нужно подключение к базе данных: %w", err)
+	}
+
+	nameTable := configApp.GetNameTableRestorer()
+	migrator, err := migrations.NewMigrator(dbHandler.GetPool(), nameTable)
+	if err != nil {
+		return
+	}
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		countApplied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Применено миграций к таблице %s: %d\n", nameTable, countApplied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("ошибка: некорректное количество откатываемых миграций %s: %w", args[1], err)
+			}
+		}
+		countRolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Откачено миграций у таблицы %s: %d\n", nameTable, countRolledBack)
+
+	case "status":
+		listStatus, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Миграции таблицы %s:\n", nameTable)
+		for _, status := range listStatus {
+			strApplied := "не применена"
+			if status.IsApplied {
+				strApplied = "применена " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %04d_%s: %s\n", status.Version, status.Name, strApplied)
+		}
+
+	default:
+		return errors.New("ошибка: неизвестное действие миграций " + action + ", допустимые значения: up, down, status")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
+)
+
+// Перешифровать файл хранилища активным ключом
+// Для ротации ключа новый ключ ставится первым в файле ключей, старый остается ниже,
+// после выполнения команды старый ключ можно удалить
+// Сервер на время перешифрования должен быть остановлен: файл переписывается целиком,
+// и строки, дописанные сервером во время перезаписи, потерялись бы.
+// Работающий сервер держит блокировку файла хранилища, и команда с ним не запускается
+func runReEncrypt(configApp config.ConfigTypeInterface, args []string) (err error) {
+
+	pathFileStorage := configApp.GetFileStoragePath()
+	restorer, err := fileRestorer.NewFileRestorer(pathFileStorage, configApp)
+	if err != nil {
+		return
+	}
+
+	fileLock, err := fileRestorer.TryLockExclusive(pathFileStorage)
+	if errors.Is(err, fileRestorer.ErrFileLocked) {
+		return fmt.Errorf("%w: перед перешифрованием %s остановите сервер", err, pathFileStorage)
+	}
+	if err != nil {
+		return
+	}
+	defer fileLock.Unlock()
+
+	countRows, err := restorer.ReEncrypt()
+	if err != nil {
+		return fmt.Errorf("ошибка перешифрования файла хранилища %s: %w", pathFileStorage, err)
+	}
+
+	logger.GetLogger().Infof("Перешифровали записей файла хранилища: %d", countRows)
+	fmt.Printf("Перешифровано записей: %d\n", countRows)
+	return
+}
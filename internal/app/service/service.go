@@ -1,270 +1,512 @@
-package service
-
-import (
-	"context"
-	"go-url-shortener/internal/config"
-	"go-url-shortener/internal/logger"
-	"time"
-
-	"errors"
-
-	modelsStorage "go-url-shortener/internal/models/storageshortlink"
-	"math/rand"
-	"strings"
-
-	modelsService "go-url-shortener/internal/models/service"
-)
-
-func getPackageError(textError string) error {
-	textModuleError := "service: " + textError
-	return errors.New(textModuleError)
-}
-
-// создание сервис коротких ссылок
-func NewServiceShortLink(storage modelsStorage.StorageShortInterface, configApp config.ConfigTypeInterface) modelsService.ServiceShortInterface {
-	return &ServiceShortLink{
-		configApp:       configApp,
-		storage:         storage,
-		lengthShortLink: 8,
-	}
-}
-
-type ServiceShortLink struct {
-	storage         modelsStorage.StorageShortInterface
-	lengthShortLink int
-	configApp       config.ConfigTypeInterface
-}
-
-func (service *ServiceShortLink) SetLength(length int) {
-	service.lengthShortLink = length
-}
-
-// Получаем рандомную строку
-func (service *ServiceShortLink) getRandString(length int) string {
-	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz" +
-		"0123456789")
-
-	nanoTimeNow := time.Now().UnixNano()
-	sourceRand := rand.NewSource(nanoTimeNow)
-	randEntity := rand.New(sourceRand)
-
-	var b strings.Builder
-	for i := 0; i < length; i++ {
-		b.WriteRune(chars[randEntity.Intn(len(chars))])
-	}
-	result := b.String()
-	return result
-}
-
-// Получаем хост открытия коротких ссылок
-func (service *ServiceShortLink) getHostShortLink() string {
-	host := service.configApp.GetHostShortLink()
-	return host
-}
-
-// Получаем слайс данных коротких ссылок из хранилища
-// listFullURL - это слайс полных ссылок, для которых мы получаем списко коротких ссылок
-func (service *ServiceShortLink) GetDataShortLinks(ctx context.Context, listFullURL any) (shortLinks modelsService.ListShortLinks, err error) {
-
-	isFilterFullURL := false
-	sliceListFullURL, ok := listFullURL.([]string)
-	if ok {
-		isFilterFullURL = true
-	}
-
-	listAllLinks, err := service.storage.GetShortLinks(ctx, nil)
-	if err != nil {
-		return
-	}
-
-	for _, rowData := range listAllLinks {
-
-		isOkRow := true
-		if isFilterFullURL {
-			isOkRow = false
-			if len(sliceListFullURL) > 0 {
-				for _, fullURL := range sliceListFullURL {
-					if fullURL == rowData.FullURL {
-						isOkRow = true
-					}
-				}
-			}
-		}
-
-		if isOkRow {
-			shortLink := rowData.ShortLink
-			shortLink, _ = service.getShortLinkWithHost(shortLink)
-
-			shortLinks = append(shortLinks, modelsService.RowShortLink{
-				ShortURL:    shortLink,
-				OriginalURL: rowData.FullURL,
-			})
-		}
-	}
-
-	return
-}
-
-// Формируем короткую ссылку с хостом по Url-адресу
-func (service *ServiceShortLink) getShortLinkWithHost(shortLink string) (shortLinkWithHost string, err error) {
-	hostService := service.getHostShortLink()
-	shortLinkWithHost = hostService + "/" + shortLink
-	return
-}
-
-// Добавляем новый Url-адресу и получаем его короткую ссылку
-// Если в хранилище уже есть такой URL, то ошибка
-func (service *ServiceShortLink) AddNewFullURL(ctx context.Context, fullURL string) (serviceLink string, err error) {
-
-	shortLink, err := service.addNewFullURL(ctx, fullURL)
-	// если это ошибка дублирования записи, то получаем существующую короткую ссылку
-	isErrExist := errors.Is(err, modelsStorage.ErrExistFullURL)
-	if err == nil || isErrExist {
-		if shortLink != "" {
-			serviceLink, _ = service.getShortLinkWithHost(shortLink)
-		}
-	}
-
-	return
-}
-
-func (service *ServiceShortLink) addNewFullURL(ctx context.Context, fullURL string) (shortLink string, err error) {
-
-	//генерируем новую ссылку
-	lengthShort := service.lengthShortLink
-	shortLink = service.getRandString(lengthShort)
-	logger.GetLogger().Debugf("Создали новый случайный короткий код: %s", shortLink)
-
-	// добавим короткую ссылку в хранилище
-	err = service.storage.AddShortLinkForURL(ctx, fullURL, shortLink)
-	if err != nil {
-
-		// если это ошибка дублирования записи, то получаем существующую короткую ссылку
-		isErrExist := errors.Is(err, modelsStorage.ErrExistFullURL)
-		if isErrExist {
-			shortLinkExist, errGet := service.storage.GetShortLinkByURL(ctx, fullURL)
-			if errGet != nil {
-				err = errGet
-			} else {
-				// присваиваем существующую ссыку в переменную результата
-				shortLink = shortLinkExist
-			}
-		}
-	}
-
-	return
-}
-
-// Получаем короткую ссылку по Url-адресу
-// Если ссылки не существует, то без ошибок добавляем ее
-func (service *ServiceShortLink) GetServiceLinkByURL(ctx context.Context, fullURL string) (serviceLink string, err error) {
-
-	shortLink, err := service.getShortLinkByURL(ctx, fullURL)
-	if err == nil {
-		if shortLink != "" {
-			serviceLink, _ = service.getShortLinkWithHost(shortLink)
-		}
-	}
-
-	return
-}
-
-// Получаем короткую ссылку по Url-адресу
-func (service *ServiceShortLink) getShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
-
-	shortLink, err = service.storage.GetShortLinkByURL(ctx, fullURL)
-	if err == nil {
-		if shortLink != "" {
-			return
-		} else {
-
-			//такой url еще не приходил, генерируем новую ссылку
-			lengthShort := service.lengthShortLink
-			shortLink = service.getRandString(lengthShort)
-
-			// добавим короткую ссылку в хранилище
-			err = service.storage.AddShortLinkForURL(ctx, fullURL, shortLink)
-			logger.GetLogger().Debugf("Содержание storage %+v", service.storage)
-		}
-	}
-
-	logger.GetLogger().Debugf("Сформировали короткий код: %s", shortLink)
-
-	return
-}
-
-// Получаем Url-адрес по короткой ссылке
-func (service *ServiceShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
-
-	fullURL, err = service.storage.GetFullLinkByShort(ctx, shortLink)
-	if err != nil {
-		logger.GetLogger().Errorf("Ошибка при получении полной ссылки: %s", err.Error())
-		// должны показать ошибку
-		err = getPackageError("Короткая ссылка " + shortLink + " не зарегистрирована")
-	}
-	return
-}
-
-// получение коротких ссылок группой
-func (service *ServiceShortLink) GetBatchShortLink(ctx context.Context, listFullURL []string) (resultBatch modelsService.BatchShortLinks, err error) {
-
-	// Из списка запрашиваемых ссылок получим те, которые есть в хранилище
-	// Остальные это новые ссылки, сгенерируем для них короткие ссылки
-
-	options := &modelsStorage.OptionsQuery{
-		Filter: modelsStorage.FilterOptionsQuery{
-			ListFullURL: listFullURL,
-		},
-	}
-	rowsExists, err := service.storage.GetShortLinks(ctx, options)
-	if err != nil {
-		return
-	}
-
-	// создадим map с ключом раынм оригинальному URL , чтобы поиск сделать O(1)
-	mapFullURLs := make(modelsStorage.DataStorageShortLink, len(listFullURL))
-	for _, dataRow := range rowsExists {
-		fullURL := dataRow.FullURL
-		mapFullURLs[fullURL] = dataRow
-	}
-
-	// инициализируем результирующие данные
-	resultBatch = modelsService.BatchShortLinks{}
-
-	listBatchUnknowFullURLs := modelsStorage.DataStorageShortLink{}
-	for _, fullURL := range listFullURL {
-
-		dataRow, ok := mapFullURLs[fullURL]
-		if !ok {
-
-			// создаем новую короткую ссылку
-			lengthShort := service.lengthShortLink
-			shortLink := service.getRandString(lengthShort)
-			listBatchUnknowFullURLs[shortLink] = modelsStorage.RowStorageShortLink{
-				ShortLink: shortLink,
-				FullURL:   fullURL,
-			}
-
-			// добавляем в итоговый результат
-			shortLink, _ = service.getShortLinkWithHost(shortLink)
-			resultBatch[fullURL] = shortLink
-
-		} else {
-			// берем короткую ссылку из хранилища
-			shortLink := dataRow.ShortLink
-
-			// добавляем в итоговый результат
-			shortLink, _ = service.getShortLinkWithHost(shortLink)
-			resultBatch[fullURL] = shortLink
-		}
-	}
-
-	// добавим группу коротких ссылок
-	err = service.storage.AddBatchShortLinks(ctx, listBatchUnknowFullURLs)
-	if err != nil {
-		return nil, err
-	}
-
-	return
-}
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	"go-url-shortener/internal/metrics"
+	modelsWorkspace "go-url-shortener/internal/models/workspace"
+	"go-url-shortener/internal/shortdomains"
+	"go-url-shortener/internal/workspaces"
+	"net/url"
+	"time"
+
+	"errors"
+
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"math/rand"
+	"strings"
+
+	modelsService "go-url-shortener/internal/models/service"
+)
+
+// сколько раз пробуем новую случайную короткую ссылку, если сгенерированная уже занята
+const countAttemptsShortLink = 5
+
+func getPackageError(textError string) error {
+	textModuleError := "service: " + textError
+	return errors.New(textModuleError)
+}
+
+// Зависимости сервиса, у каждого приложения свои
+type Dependencies struct {
+	// конфигурация приложения
+	Config config.ConfigTypeInterface
+	// домены коротких ссылок
+	ShortDomains *shortdomains.Registry
+	// рабочие пространства
+	Workspaces *workspaces.Registry
+	// метрики приложения, их же пишут обработчики запросов
+	Metrics *metrics.AppMetrics
+}
+
+// создание сервис коротких ссылок
+func NewServiceShortLink(storage modelsStorage.StorageShortInterface, deps Dependencies) modelsService.ServiceShortInterface {
+	return &ServiceShortLink{
+		configApp:       deps.Config,
+		shortDomains:    deps.ShortDomains,
+		workspaces:      deps.Workspaces,
+		metrics:         deps.Metrics,
+		storage:         storage,
+		lengthShortLink: 8,
+	}
+}
+
+type ServiceShortLink struct {
+	storage         modelsStorage.StorageShortInterface
+	lengthShortLink int
+	configApp       config.ConfigTypeInterface
+	shortDomains    *shortdomains.Registry
+	workspaces      *workspaces.Registry
+	metrics         *metrics.AppMetrics
+}
+
+func (service *ServiceShortLink) SetLength(length int) {
+	service.lengthShortLink = length
+}
+
+func (service *ServiceShortLink) GetStorage() modelsStorage.StorageShortInterface {
+	return service.storage
+}
+
+// Получаем рандомную строку
+func (service *ServiceShortLink) getRandString(length int) string {
+	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz" +
+		"0123456789")
+
+	nanoTimeNow := time.Now().UnixNano()
+	sourceRand := rand.NewSource(nanoTimeNow)
+	randEntity := rand.New(sourceRand)
+
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		b.WriteRune(chars[randEntity.Intn(len(chars))])
+	}
+	result := b.String()
+	return result
+}
+
+// Получаем хост открытия коротких ссылок
+func (service *ServiceShortLink) getHostShortLink() string {
+	host := service.configApp.GetHostShortLink()
+	return host
+}
+
+// Получаем слайс данных коротких ссылок из хранилища
+// listFullURL - это слайс полных ссылок, для которых мы получаем списко коротких ссылок
+func (service *ServiceShortLink) GetDataShortLinks(ctx context.Context, listFullURL any) (shortLinks modelsService.ListShortLinks, err error) {
+
+	isFilterFullURL := false
+	sliceListFullURL, ok := listFullURL.([]string)
+	if ok {
+		isFilterFullURL = true
+	}
+
+	// ссылки пользователя и рабочего пространства собираем со всех доменов коротких ссылок
+	for _, domain := range service.shortDomains.GetList() {
+		ctxDomain := modelsStorage.WithShortDomain(ctx, domain)
+		listDomainLinks, errDomain := service.getDataShortLinks(ctxDomain, isFilterFullURL, sliceListFullURL)
+		if errDomain != nil {
+			return nil, errDomain
+		}
+		shortLinks = append(shortLinks, listDomainLinks...)
+	}
+
+	return
+}
+
+// Данные коротких ссылок на домене из контекста
+func (service *ServiceShortLink) getDataShortLinks(ctx context.Context, isFilterFullURL bool, sliceListFullURL []string) (shortLinks modelsService.ListShortLinks, err error) {
+
+	start := time.Now()
+	listAllLinks, err := service.storage.GetShortLinks(ctx, nil)
+	service.observeStorage("get_short_links", start)
+	if err != nil {
+		return
+	}
+
+	for _, rowData := range listAllLinks {
+
+		isOkRow := true
+		if isFilterFullURL {
+			isOkRow = false
+			if len(sliceListFullURL) > 0 {
+				for _, fullURL := range sliceListFullURL {
+					if fullURL == rowData.FullURL {
+						isOkRow = true
+					}
+				}
+			}
+		}
+
+		if isOkRow {
+			shortLink := rowData.ShortLink
+			shortLink, _ = service.getShortLinkWithHost(ctx, shortLink)
+
+			shortLinks = append(shortLinks, modelsService.RowShortLink{
+				ShortURL:    shortLink,
+				OriginalURL: rowData.FullURL,
+			})
+		}
+	}
+
+	return
+}
+
+// Формируем короткую ссылку с хостом по Url-адресу
+// Ссылка открывается на своем домене коротких ссылок, у рабочего пространства свой домен или префикс пути
+func (service *ServiceShortLink) getShortLinkWithHost(ctx context.Context, shortLink string) (shortLinkWithHost string, err error) {
+	hostService := service.getHostShortLink()
+	if baseURL, ok := service.shortDomains.GetBaseURL(modelsStorage.GetShortDomain(ctx)); ok {
+		hostService = baseURL
+	}
+
+	workspaceID := modelsWorkspace.GetWorkspaceID(ctx)
+	if ws, ok := service.workspaces.Get(workspaceID); ok {
+		if ws.Domain != "" {
+			scheme := "http"
+			if urlHost, errParse := url.Parse(hostService); errParse == nil && urlHost.Scheme != "" {
+				scheme = urlHost.Scheme
+			}
+			// на домене рабочего пространства ссылки открываются под тем же префиксом пути
+			hostService = scheme + "://" + ws.Domain + config.GetBasePath(hostService)
+		} else {
+			hostService += ws.PathPrefix
+		}
+	}
+
+	shortLinkWithHost = hostService + "/" + shortLink
+	return
+}
+
+// Добавляем новый Url-адресу и получаем его короткую ссылку
+// Если в хранилище уже есть такой URL, то ошибка
+func (service *ServiceShortLink) AddNewFullURL(ctx context.Context, fullURL string) (serviceLink string, err error) {
+
+	shortLink, err := service.addNewFullURL(ctx, fullURL)
+	// если это ошибка дублирования записи, то получаем существующую короткую ссылку
+	isErrExist := errors.Is(err, modelsStorage.ErrExistFullURL)
+	if err == nil || isErrExist {
+		if shortLink != "" {
+			serviceLink, _ = service.getShortLinkWithHost(ctx, shortLink)
+		}
+	}
+
+	return
+}
+
+func (service *ServiceShortLink) addNewFullURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+
+	shortLink, err = service.addShortLink(ctx, fullURL)
+	if err != nil {
+
+		// если это ошибка дублирования записи, то получаем существующую короткую ссылку
+		isErrExist := errors.Is(err, modelsStorage.ErrExistFullURL)
+		if isErrExist {
+			start := time.Now()
+			shortLinkExist, errGet := service.storage.GetShortLinkByURL(ctx, fullURL)
+			service.observeStorage("get_short_link", start)
+			if errGet != nil {
+				err = errGet
+			} else {
+				// присваиваем существующую ссыку в переменную результата
+				shortLink = shortLinkExist
+			}
+		}
+	}
+
+	return
+}
+
+// Получаем короткую ссылку по Url-адресу
+// Если ссылки не существует, то без ошибок добавляем ее
+func (service *ServiceShortLink) GetServiceLinkByURL(ctx context.Context, fullURL string) (serviceLink string, err error) {
+
+	shortLink, err := service.getShortLinkByURL(ctx, fullURL)
+	if err == nil {
+		if shortLink != "" {
+			serviceLink, _ = service.getShortLinkWithHost(ctx, shortLink)
+		}
+	}
+
+	return
+}
+
+// Получаем короткую ссылку по Url-адресу
+func (service *ServiceShortLink) getShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+
+	start := time.Now()
+	shortLink, err = service.storage.GetShortLinkByURL(ctx, fullURL)
+	service.observeStorage("get_short_link", start)
+	if err == nil {
+		if shortLink != "" {
+			return
+		} else {
+
+			//такой url еще не приходил, генерируем новую ссылку
+			shortLink, err = service.addShortLink(ctx, fullURL)
+			logger.FromContext(ctx).Debugf("Содержание storage %+v", service.storage)
+		}
+	}
+
+	logger.FromContext(ctx).Debugf("Сформировали короткий код: %s", shortLink)
+
+	return
+}
+
+// Добавление полной ссылки с новой случайной короткой ссылкой
+// Если короткая ссылка уже занята другим адресом, то пробуем другую, но не больше countAttemptsShortLink раз
+func (service *ServiceShortLink) addShortLink(ctx context.Context, fullURL string) (shortLink string, err error) {
+
+	lengthShort := service.lengthShortLink
+	for attempt := 0; attempt < countAttemptsShortLink; attempt++ {
+
+		//генерируем новую ссылку
+		shortLink = service.getRandString(lengthShort)
+		logger.FromContext(ctx).Debugf("Создали новый случайный короткий код: %s", shortLink)
+
+		// добавим короткую ссылку в хранилище
+		start := time.Now()
+		err = service.storage.AddShortLinkForURL(ctx, fullURL, shortLink)
+		service.observeStorage("add_short_link", start)
+		if err == nil {
+			service.metrics.AddLinksCreated(1)
+			return
+		}
+		if !errors.Is(err, modelsStorage.ErrExistShortLink) {
+			return
+		}
+		logger.FromContext(ctx).Warnf("Короткий код %s уже занят, генерируем другой", shortLink)
+	}
+
+	return "", err
+}
+
+// Учет времени операции хранилища в метриках, тип хранилища берется на момент операции,
+// потому что хранилище с переключением может работать то с БД, то с локальными данными
+func (service *ServiceShortLink) observeStorage(operation string, start time.Time) {
+	backend := "unknown"
+	if storageDescriber, ok := service.storage.(modelsStorage.StorageDescriberInterface); ok {
+		backend = storageDescriber.GetBackendName()
+	}
+	service.metrics.ObserveStorageOperation(backend, operation, time.Since(start))
+}
+
+// Получаем Url-адрес по короткой ссылке
+func (service *ServiceShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
+
+	start := time.Now()
+	fullURL, err = service.storage.GetFullLinkByShort(ctx, shortLink)
+	service.observeStorage("get_full_link", start)
+	if errors.Is(err, modelsStorage.ErrDisabledShortLink) {
+		// отключенная ссылка остается в хранилище, но не открывается
+		logger.FromContext(ctx).Infof("Запрошена отключенная короткая ссылка: %s", shortLink)
+		return "", fmt.Errorf("%w: %s", modelsService.ErrDisabledShortLink, shortLink)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Ошибка при получении полной ссылки: %s", err.Error())
+		// должны показать ошибку
+		err = getPackageError("Короткая ссылка " + shortLink + " не зарегистрирована")
+	}
+	return
+}
+
+// Отключение или включение короткой ссылки, отключить можно только зарегистрированную ссылку
+// Признак отключения хранится в хранилище, поэтому его видят все экземпляры сервиса и он переживает перезапуск
+func (service *ServiceShortLink) SetLinkDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error) {
+
+	storageDisabler, ok := service.storage.(modelsStorage.StorageDisablerInterface)
+	if !ok {
+		return modelsStorage.ErrNotSupported
+	}
+
+	start := time.Now()
+	err = storageDisabler.SetDisabled(ctx, shortLink, isDisabled)
+	service.observeStorage("set_disabled", start)
+	return
+}
+
+// получение коротких ссылок группой
+func (service *ServiceShortLink) GetBatchShortLink(ctx context.Context, listFullURL []string) (resultBatch modelsService.BatchShortLinks, err error) {
+
+	service.metrics.ObserveBatchSize(len(listFullURL))
+
+	// если хранилище умеет за один запрос добавить группу и вернуть существующие ссылки
+	if storageReturning, ok := service.storage.(modelsStorage.StorageBatchReturningInterface); ok {
+		return service.getBatchShortLinkReturning(ctx, storageReturning, listFullURL)
+	}
+
+	// Из списка запрашиваемых ссылок получим те, которые есть в хранилище
+	// Остальные это новые ссылки, сгенерируем для них короткие ссылки
+
+	options := &modelsStorage.OptionsQuery{
+		Filter: modelsStorage.FilterOptionsQuery{
+			ListFullURL: listFullURL,
+		},
+	}
+	start := time.Now()
+	rowsExists, err := service.storage.GetShortLinks(ctx, options)
+	service.observeStorage("get_short_links", start)
+	if err != nil {
+		return
+	}
+
+	// создадим map с ключом раынм оригинальному URL , чтобы поиск сделать O(1)
+	mapFullURLs := make(modelsStorage.DataStorageShortLink, len(listFullURL))
+	for _, dataRow := range rowsExists {
+		fullURL := dataRow.FullURL
+		mapFullURLs[fullURL] = dataRow
+	}
+
+	// инициализируем результирующие данные
+	resultBatch = modelsService.BatchShortLinks{}
+
+	listBatchUnknowFullURLs := modelsStorage.DataStorageShortLink{}
+	for _, fullURL := range listFullURL {
+
+		dataRow, ok := mapFullURLs[fullURL]
+		if !ok {
+
+			// создаем новую короткую ссылку
+			lengthShort := service.lengthShortLink
+			shortLink := service.getRandString(lengthShort)
+			listBatchUnknowFullURLs[shortLink] = modelsStorage.RowStorageShortLink{
+				ShortLink: shortLink,
+				FullURL:   fullURL,
+			}
+
+			// добавляем в итоговый результат
+			shortLink, _ = service.getShortLinkWithHost(ctx, shortLink)
+			resultBatch[fullURL] = shortLink
+
+		} else {
+			// берем короткую ссылку из хранилища
+			shortLink := dataRow.ShortLink
+
+			// добавляем в итоговый результат
+			shortLink, _ = service.getShortLinkWithHost(ctx, shortLink)
+			resultBatch[fullURL] = shortLink
+		}
+	}
+
+	// добавим группу коротких ссылок
+	start = time.Now()
+	err = service.storage.AddBatchShortLinks(ctx, listBatchUnknowFullURLs)
+	service.observeStorage("add_batch", start)
+	if err != nil {
+		return nil, err
+	}
+	if len(listBatchUnknowFullURLs) == 0 {
+		return
+	}
+
+	// строки с занятой короткой ссылкой хранилище пропускает, поэтому сверяем группу с хранилищем
+	listNewFullURL := make([]string, 0, len(listBatchUnknowFullURLs))
+	for _, dataRow := range listBatchUnknowFullURLs {
+		listNewFullURL = append(listNewFullURL, dataRow.FullURL)
+	}
+	options.Filter.ListFullURL = listNewFullURL
+	start = time.Now()
+	rowsAdded, err := service.storage.GetShortLinks(ctx, options)
+	service.observeStorage("get_short_links", start)
+	if err != nil {
+		return nil, err
+	}
+	mapAddedFullURLs := make(map[string]string, len(rowsAdded))
+	for _, dataRow := range rowsAdded {
+		mapAddedFullURLs[dataRow.FullURL] = dataRow.ShortLink
+	}
+
+	countCreated := 0
+	for shortLink, dataRow := range listBatchUnknowFullURLs {
+		shortLinkAdded, ok := mapAddedFullURLs[dataRow.FullURL]
+		if ok && shortLinkAdded == shortLink {
+			countCreated++
+			continue
+		}
+		if !ok {
+			// добавляем по одной с новой короткой ссылкой
+			shortLinkAdded, err = service.addNewFullURL(ctx, dataRow.FullURL)
+			if err != nil && !errors.Is(err, modelsStorage.ErrExistFullURL) {
+				return nil, err
+			}
+			err = nil
+		}
+		// ссылку параллельно добавил другой запрос или она добавлена повторно
+		shortLinkAdded, _ = service.getShortLinkWithHost(ctx, shortLinkAdded)
+		resultBatch[dataRow.FullURL] = shortLinkAdded
+	}
+	service.metrics.AddLinksCreated(countCreated)
+
+	return
+}
+
+// получение коротких ссылок группой через хранилище с групповым добавлением
+// Для всех ссылок генерируем короткие ссылки, а хранилище сообщает, какие полные ссылки уже были
+func (service *ServiceShortLink) getBatchShortLinkReturning(ctx context.Context, storage modelsStorage.StorageBatchReturningInterface, listFullURL []string) (resultBatch modelsService.BatchShortLinks, err error) {
+
+	lengthShort := service.lengthShortLink
+	listBatchFullURLs := make(modelsStorage.DataStorageShortLink, len(listFullURL))
+	isAddedFullURL := make(map[string]bool, len(listFullURL))
+	for _, fullURL := range listFullURL {
+		// одинаковые полные ссылки в группе получают одну короткую ссылку
+		if isAddedFullURL[fullURL] {
+			continue
+		}
+		isAddedFullURL[fullURL] = true
+
+		// случайные короткие ссылки внутри группы не должны совпасть
+		shortLink := service.getRandString(lengthShort)
+		for _, ok := listBatchFullURLs[shortLink]; ok; _, ok = listBatchFullURLs[shortLink] {
+			shortLink = service.getRandString(lengthShort)
+		}
+		listBatchFullURLs[shortLink] = modelsStorage.RowStorageShortLink{
+			ShortLink: shortLink,
+			FullURL:   fullURL,
+		}
+	}
+
+	start := time.Now()
+	dataBatch, err := storage.AddBatchShortLinksReturning(ctx, listBatchFullURLs)
+	service.observeStorage("add_batch", start)
+	if err != nil {
+		return nil, err
+	}
+	countCreated := 0
+	for _, dataRow := range dataBatch {
+		if !dataRow.IsExisted {
+			countCreated++
+		}
+	}
+	service.metrics.AddLinksCreated(countCreated)
+
+	// инициализируем результирующие данные
+	resultBatch = modelsService.BatchShortLinks{}
+	for _, fullURL := range listFullURL {
+		if _, ok := resultBatch[fullURL]; ok {
+			continue
+		}
+
+		shortLink := ""
+		if dataRow, ok := dataBatch[fullURL]; ok {
+			shortLink = dataRow.ShortLink
+		} else {
+			// короткая ссылка оказалась занята, добавляем по одной с новой короткой ссылкой
+			shortLink, err = service.addNewFullURL(ctx, fullURL)
+			if err != nil && !errors.Is(err, modelsStorage.ErrExistFullURL) {
+				return nil, err
+			}
+			err = nil
+		}
+		if shortLink == "" {
+			err = getPackageError("хранилище не вернуло короткую ссылку для " + fullURL)
+			return nil, err
+		}
+		shortLink, _ = service.getShortLinkWithHost(ctx, shortLink)
+		resultBatch[fullURL] = shortLink
+	}
+
+	return
+}
@@ -1,5 +1,18 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
 type ConfigTypeInterface interface {
 	GetAddrServer() string
 	GetHostShortLink() string
@@ -7,6 +20,9 @@ type ConfigTypeInterface interface {
 	SetAddrServer(string)
 	SetHostShortLink(string)
 
+	// префикс пути из BASE_URL
+	GetBasePath() string
+
 	GetFileStoragePath() string
 	SetFileStoragePath(string)
 
@@ -16,17 +32,107 @@ type ConfigTypeInterface interface {
 	GetNameTableRestorer() string
 	SetNameTableRestorer(string)
 
+	// для шифрования файла хранилища
+	GetFileStorageKey() string
+	SetFileStorageKey(string)
+	GetFileStorageKeyFile() string
+	SetFileStorageKeyFile(string)
+
+	// выбор хранилища ссылок
+	GetStorageBackend() string
+	SetStorageBackend(string)
+	GetMemorySnapshotPath() string
+	SetMemorySnapshotPath(string)
+	GetMemorySnapshotInterval() time.Duration
+	SetMemorySnapshotInterval(time.Duration)
+	GetFailoverJournalPath() string
+	SetFailoverJournalPath(string)
+
+	// кеш перед хранилищем ссылок
+	GetCacheSize() int
+	SetCacheSize(int)
+	GetCacheTTL() time.Duration
+	SetCacheTTL(time.Duration)
+	GetCacheNegativeTTL() time.Duration
+	SetCacheNegativeTTL(time.Duration)
+
+	// пул соединений с БД
+	GetDBMaxConns() int
+	SetDBMaxConns(int)
+	GetDBMinConns() int
+	SetDBMinConns(int)
+	GetDBMaxConnLifetime() time.Duration
+	SetDBMaxConnLifetime(time.Duration)
+	GetDBHealthCheckPeriod() time.Duration
+	SetDBHealthCheckPeriod(time.Duration)
+	GetDBStatementCacheMode() string
+	SetDBStatementCacheMode(string)
+	GetDBPingTimeout() time.Duration
+	SetDBPingTimeout(time.Duration)
+
+	// повторы запросов к БД и автоматический выключатель
+	GetDBRetryAttempts() int
+	SetDBRetryAttempts(int)
+	GetDBRetryDelay() time.Duration
+	SetDBRetryDelay(time.Duration)
+	GetDBRetryMaxDelay() time.Duration
+	SetDBRetryMaxDelay(time.Duration)
+	GetDBBreakerThreshold() int
+	SetDBBreakerThreshold(int)
+	GetDBBreakerTimeout() time.Duration
+	SetDBBreakerTimeout(time.Duration)
+
+	// максимальная длина полной ссылки в запросе
+	GetMaxURLLength() int
+	SetMaxURLLength(int)
+
+	// рабочие пространства со своими короткими ссылками
+	GetWorkspacesFile() string
+	SetWorkspacesFile(string)
+
+	// дополнительные домены коротких ссылок
+	GetShortDomains() string
+	SetShortDomains(string)
+
 	// для логирования
 	GetLogsPath() string
 	SetLogsPath(string)
 	GetLevelLogs() int
 	SetLevelLogs(int)
 	GetUserHomePath() string
+	GetLogOutput() string
+	SetLogOutput(string)
+	GetLogFormat() string
+	SetLogFormat(string)
+	GetLogMaxSize() int
+	SetLogMaxSize(int)
+	GetLogMaxAge() time.Duration
+	SetLogMaxAge(time.Duration)
+	GetLogMaxBackups() int
+	SetLogMaxBackups(int)
+
+	// токен служебных запросов
+	GetAdminToken() string
+	SetAdminToken(string)
+	// адрес служебного сервера с метриками
+	GetAdminAddress() string
+	SetAdminAddress(string)
+
+	// ключ подписи куки с идентификатором пользователя
+	GetUserIDSecret() string
+	SetUserIDSecret(string)
+
+	// перезагрузка настроек без перезапуска и подписка на нее
+	Reload() (listChanged []string, listWarnings []string, err error)
+	Subscribe(subscriber ReloadSubscriber) (unsubscribe func())
 }
 
 // Тип для хранения конфигурации приложения
 // Экспортируемая - для мокирования
 type ConfigType struct {
+	// настройки можно перечитать при работе сервиса, поэтому доступ к ним под блокировкой
+	mutex sync.RWMutex
+
 	addrServer      string
 	hostShortLink   string
 	fileStoragePath string
@@ -36,110 +142,807 @@ type ConfigType struct {
 	levelLogs         int
 	userHomePath      string
 	nameTableRestorer string
+
+	logOutput     string
+	logFormat     string
+	logMaxSize    int
+	logMaxAge     time.Duration
+	logMaxBackups int
+
+	fileStorageKey     logger.Secret
+	fileStorageKeyFile string
+
+	storageBackend         string
+	memorySnapshotPath     string
+	memorySnapshotInterval time.Duration
+	failoverJournalPath    string
+
+	cacheSize        int
+	cacheTTL         time.Duration
+	cacheNegativeTTL time.Duration
+
+	maxURLLength int
+
+	workspacesFile string
+	shortDomains   string
+
+	dbMaxConns           int
+	dbMinConns           int
+	dbMaxConnLifetime    time.Duration
+	dbHealthCheckPeriod  time.Duration
+	dbStatementCacheMode string
+	dbPingTimeout        time.Duration
+
+	dbRetryAttempts    int
+	dbRetryDelay       time.Duration
+	dbRetryMaxDelay    time.Duration
+	dbBreakerThreshold int
+	dbBreakerTimeout   time.Duration
+
+	adminToken   logger.Secret
+	adminAddress string
+
+	userIDSecret logger.Secret
+
+	// подписчики перезагрузки конфигурации
+	subscribers reloadSubscribers
 }
 
 func (ct *ConfigType) SetAddrServer(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.addrServer = value
 }
 
 func (ct *ConfigType) GetAddrServer() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.addrServer
 }
 
 func (ct *ConfigType) SetHostShortLink(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.hostShortLink = value
 }
 
 func (ct *ConfigType) GetHostShortLink() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.hostShortLink
 }
 
+// Префикс пути базового адреса коротких ссылок, по нему смонтированы все маршруты сервера
+func (ct *ConfigType) GetBasePath() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return GetBasePath(ct.hostShortLink)
+}
+
 func (ct *ConfigType) SetLogsPath(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.logsPath = value
 }
 
 func (ct *ConfigType) GetLogsPath() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.logsPath
 }
 
 func (ct *ConfigType) SetLevelLogs(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.levelLogs = value
 }
 
 func (ct *ConfigType) GetLevelLogs() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.levelLogs
 }
 
 func (ct *ConfigType) SetFileStoragePath(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.fileStoragePath = value
 }
 
 func (ct *ConfigType) GetFileStoragePath() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.fileStoragePath
 }
 
 func (ct *ConfigType) SetNameTableRestorer(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.nameTableRestorer = value
 }
 
 func (ct *ConfigType) GetNameTableRestorer() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.nameTableRestorer
 }
 
 func (ct *ConfigType) GetUserHomePath() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.userHomePath
 }
 
 func (ct *ConfigType) SetDatabaseDsn(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
 	ct.databaseDsn = value
 }
 
 func (ct *ConfigType) GetDatabaseDsn() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
 	return ct.databaseDsn
 }
 
+func (ct *ConfigType) SetFileStorageKey(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.fileStorageKey = logger.Secret(value)
+}
+
+func (ct *ConfigType) GetFileStorageKey() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.fileStorageKey.Reveal()
+}
+
+func (ct *ConfigType) SetFileStorageKeyFile(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.fileStorageKeyFile = value
+}
+
+func (ct *ConfigType) GetFileStorageKeyFile() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.fileStorageKeyFile
+}
+
+func (ct *ConfigType) SetStorageBackend(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.storageBackend = value
+}
+
+func (ct *ConfigType) GetStorageBackend() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.storageBackend
+}
+
+func (ct *ConfigType) SetMemorySnapshotPath(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.memorySnapshotPath = value
+}
+
+func (ct *ConfigType) GetMemorySnapshotPath() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.memorySnapshotPath
+}
+
+func (ct *ConfigType) SetMemorySnapshotInterval(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.memorySnapshotInterval = value
+}
+
+func (ct *ConfigType) GetMemorySnapshotInterval() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.memorySnapshotInterval
+}
+
+func (ct *ConfigType) SetFailoverJournalPath(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.failoverJournalPath = value
+}
+
+// Путь до журнала записей, сделанных во время недоступности БД
+// По умолчанию журнал лежит рядом с файлом хранилища
+func (ct *ConfigType) GetFailoverJournalPath() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	if ct.failoverJournalPath == "" && ct.fileStoragePath != "" {
+		return ct.fileStoragePath + ".journal"
+	}
+	return ct.failoverJournalPath
+}
+
+func (ct *ConfigType) SetCacheSize(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.cacheSize = value
+}
+
+// Размер кеша перед хранилищем ссылок, 0 - кеш выключен
+func (ct *ConfigType) GetCacheSize() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.cacheSize
+}
+
+func (ct *ConfigType) SetCacheTTL(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.cacheTTL = value
+}
+
+func (ct *ConfigType) GetCacheTTL() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.cacheTTL
+}
+
+func (ct *ConfigType) SetCacheNegativeTTL(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.cacheNegativeTTL = value
+}
+
+func (ct *ConfigType) GetCacheNegativeTTL() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.cacheNegativeTTL
+}
+
+func (ct *ConfigType) SetMaxURLLength(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.maxURLLength = value
+}
+
+// Максимальная длина полной ссылки в байтах, 0 - без ограничения
+func (ct *ConfigType) GetMaxURLLength() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.maxURLLength
+}
+
+func (ct *ConfigType) SetWorkspacesFile(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.workspacesFile = value
+}
+
+// Путь до JSON файла с описанием рабочих пространств, пусто - только общее пространство
+func (ct *ConfigType) GetWorkspacesFile() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.workspacesFile
+}
+
+func (ct *ConfigType) SetShortDomains(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.shortDomains = value
+}
+
+// Дополнительные домены коротких ссылок через запятую, например https://brand.io,https://go.brand.io
+// Домен из BASE_URL используется по умолчанию
+func (ct *ConfigType) GetShortDomains() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.shortDomains
+}
+
+func (ct *ConfigType) SetDBMaxConns(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbMaxConns = value
+}
+
+// Максимальное количество соединений в пуле БД, 0 - по умолчанию драйвера
+func (ct *ConfigType) GetDBMaxConns() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbMaxConns
+}
+
+func (ct *ConfigType) SetDBMinConns(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbMinConns = value
+}
+
+// Минимальное количество открытых соединений в пуле БД
+func (ct *ConfigType) GetDBMinConns() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbMinConns
+}
+
+func (ct *ConfigType) SetDBMaxConnLifetime(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbMaxConnLifetime = value
+}
+
+// Время жизни соединения в пуле БД, после него соединение закрывается
+func (ct *ConfigType) GetDBMaxConnLifetime() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbMaxConnLifetime
+}
+
+func (ct *ConfigType) SetDBHealthCheckPeriod(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbHealthCheckPeriod = value
+}
+
+// Период проверки простаивающих соединений пула БД
+func (ct *ConfigType) GetDBHealthCheckPeriod() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbHealthCheckPeriod
+}
+
+func (ct *ConfigType) SetDBStatementCacheMode(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbStatementCacheMode = value
+}
+
+// Режим выполнения запросов и кеширования подготовленных выражений
+func (ct *ConfigType) GetDBStatementCacheMode() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbStatementCacheMode
+}
+
+func (ct *ConfigType) SetDBPingTimeout(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbPingTimeout = value
+}
+
+func (ct *ConfigType) GetDBPingTimeout() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbPingTimeout
+}
+
+func (ct *ConfigType) SetDBRetryAttempts(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbRetryAttempts = value
+}
+
+// Сколько раз повторяем запрос к БД после временной ошибки, 0 - без повторов
+func (ct *ConfigType) GetDBRetryAttempts() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbRetryAttempts
+}
+
+func (ct *ConfigType) SetDBRetryDelay(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbRetryDelay = value
+}
+
+func (ct *ConfigType) GetDBRetryDelay() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbRetryDelay
+}
+
+func (ct *ConfigType) SetDBRetryMaxDelay(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbRetryMaxDelay = value
+}
+
+func (ct *ConfigType) GetDBRetryMaxDelay() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbRetryMaxDelay
+}
+
+func (ct *ConfigType) SetDBBreakerThreshold(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbBreakerThreshold = value
+}
+
+// После скольких подряд ошибок соединения перестаем обращаться к БД, 0 - без выключателя
+func (ct *ConfigType) GetDBBreakerThreshold() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbBreakerThreshold
+}
+
+func (ct *ConfigType) SetDBBreakerTimeout(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.dbBreakerTimeout = value
+}
+
+func (ct *ConfigType) GetDBBreakerTimeout() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.dbBreakerTimeout
+}
+
+// Значение настройки по старшинству: по умолчанию < файл конфигурации < переменная окружения < флаг
+// Значение флага по умолчанию используется, если настройка нигде не указана
+func mergeValue[T any](flagValue T, isFlag bool, fileValue *T, envValue T, isEnv bool) T {
+	if isFlag {
+		return flagValue
+	}
+	if isEnv {
+		return envValue
+	}
+	if fileValue != nil {
+		return *fileValue
+	}
+	return flagValue
+}
+
+// Путь до файла конфигурации: флаг -config, затем переменная окружения CONFIG
+func getPathFileConfig(flags FlagConfigType, envVars EnviromentConfigType) string {
+	if flags.SetFlags["config"] {
+		return flags.ConfigFile
+	}
+	return envVars.ConfigFile
+}
+
+// Куда пишутся логи: stdout, stderr, file или несколько через запятую
+func (ct *ConfigType) GetLogOutput() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.logOutput
+}
+
+func (ct *ConfigType) SetLogOutput(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.logOutput = value
+}
+
+// Формат записей лога: text, json или logfmt
+func (ct *ConfigType) GetLogFormat() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.logFormat
+}
+
+func (ct *ConfigType) SetLogFormat(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.logFormat = value
+}
+
+// Размер файла лога в мегабайтах, после которого он ротируется, 0 - без ротации по размеру
+func (ct *ConfigType) GetLogMaxSize() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.logMaxSize
+}
+
+func (ct *ConfigType) SetLogMaxSize(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.logMaxSize = value
+}
+
+// Возраст файла лога, после которого он ротируется, 0 - без ротации по времени
+func (ct *ConfigType) GetLogMaxAge() time.Duration {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.logMaxAge
+}
+
+func (ct *ConfigType) SetLogMaxAge(value time.Duration) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.logMaxAge = value
+}
+
+// Сколько ротированных файлов лога хранить, 0 - хранить все
+func (ct *ConfigType) GetLogMaxBackups() int {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.logMaxBackups
+}
+
+func (ct *ConfigType) SetLogMaxBackups(value int) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.logMaxBackups = value
+}
+
+func (ct *ConfigType) SetAdminToken(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.adminToken = logger.Secret(value)
+}
+
+// Токен служебных запросов, пусто - служебные запросы выключены
+func (ct *ConfigType) GetAdminToken() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.adminToken.Reveal()
+}
+
+func (ct *ConfigType) SetUserIDSecret(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.userIDSecret = logger.Secret(value)
+}
+
+// Ключ подписи куки с идентификатором пользователя, пусто - случайный ключ до перезапуска процесса
+func (ct *ConfigType) GetUserIDSecret() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.userIDSecret.Reveal()
+}
+
+func (ct *ConfigType) SetAdminAddress(value string) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	ct.adminAddress = value
+}
+
+// Адрес служебного сервера, пусто - служебный сервер не запускается
+func (ct *ConfigType) GetAdminAddress() string {
+	ct.mutex.RLock()
+	defer ct.mutex.RUnlock()
+	return ct.adminAddress
+}
+
 func (ct *ConfigType) installConfig() {
+	errSetupConfig = ct.loadConfig()
+}
+
+// Чтение настроек из файла конфигурации, окружения и флагов с проверкой значений
+func (ct *ConfigType) loadConfig() (err error) {
 
 	envVars := GetEnviromentConfig()
 	flags := GetFlagConfig()
+	isFlag := flags.SetFlags
+
+	fileConfig := FileConfigType{}
+	listErrors := []error{errEnviroment}
+	if pathFileConfig := getPathFileConfig(flags, envVars); pathFileConfig != "" {
+		fileConfig, err = LoadFileConfig(pathFileConfig)
+		if err != nil {
+			listErrors = append(listErrors, err)
+		}
+	}
+
+	ct.addrServer = mergeValue(flags.AddressServer.String(), isFlag["a"], fileConfig.AddressServer, envVars.AddressServer, envVars.AddressServer != "")
+	ct.hostShortLink = mergeValue(flags.HostShortLink.String(), isFlag["b"], fileConfig.HostShortLink, envVars.HostShortLink, envVars.HostShortLink != "")
+	ct.fileStoragePath = mergeValue(flags.FileStoragePath, isFlag["f"], fileConfig.FileStoragePath, envVars.FileStoragePath, envVars.FileStoragePath != "")
+	ct.databaseDsn = mergeValue(flags.DatabaseDsn, isFlag["d"], fileConfig.DatabaseDsn, envVars.DatabaseDsn, envVars.DatabaseDsn != "")
+	ct.nameTableRestorer = mergeValue(flags.NameTableRestorer, isFlag["tr"], fileConfig.NameTableRestorer, envVars.NameTableRestorer, envVars.NameTableRestorer != "")
+	ct.levelLogs = mergeValue(flags.LevelLogs, isFlag["logLevel"], fileConfig.LevelLogs, envVars.LevelLogs, envVars.LevelLogs != -1)
+
+	// пути без флагов, по умолчанию домашняя папка пользователя
+	ct.userHomePath = mergeValue("", false, fileConfig.UserHomePath, envVars.UserHomePath, envVars.UserHomePath != "")
+	if ct.userHomePath == "" {
+		ct.userHomePath = getDefaultUserHomePath()
+	}
+	ct.logsPath = mergeValue("", false, fileConfig.LogsPath, envVars.LogsPath, envVars.LogsPath != "")
+	if ct.logsPath == "" {
+		ct.logsPath = getDefaultUserHomePath()
+	}
+	ct.logOutput = mergeValue(flags.LogOutput, isFlag["lo"], fileConfig.LogOutput, envVars.LogOutput, envVars.LogOutput != "")
+	ct.logFormat = mergeValue(flags.LogFormat, isFlag["lf"], fileConfig.LogFormat, envVars.LogFormat, envVars.LogFormat != "")
+	ct.logMaxSize = mergeValue(flags.LogMaxSize, isFlag["lms"], fileConfig.LogMaxSize, envVars.LogMaxSize, envVars.LogMaxSize != -1)
+	ct.logMaxAge = mergeValue(flags.LogMaxAge, isFlag["lma"], fileDuration(fileConfig.LogMaxAge), envVars.LogMaxAge, envVars.LogMaxAge != 0)
+	ct.logMaxBackups = mergeValue(flags.LogMaxBackups, isFlag["lmb"], fileConfig.LogMaxBackups, envVars.LogMaxBackups, envVars.LogMaxBackups != -1)
+
+	// ключ шифрования не передаем флагом, чтобы он не светился в списке процессов
+	ct.fileStorageKey = logger.Secret(mergeValue("", false, fileConfig.FileStorageKey, envVars.FileStorageKey, envVars.FileStorageKey != ""))
+	ct.fileStorageKeyFile = mergeValue(flags.FileStorageKeyFile, isFlag["fkf"], fileConfig.FileStorageKeyFile, envVars.FileStorageKeyFile, envVars.FileStorageKeyFile != "")
+
+	ct.storageBackend = mergeValue(flags.StorageBackend, isFlag["sb"], fileConfig.StorageBackend, envVars.StorageBackend, envVars.StorageBackend != "")
+	ct.memorySnapshotPath = mergeValue(flags.MemorySnapshotPath, isFlag["msp"], fileConfig.MemorySnapshotPath, envVars.MemorySnapshotPath, envVars.MemorySnapshotPath != "")
+	ct.memorySnapshotInterval = mergeValue(flags.MemorySnapshotInterval, isFlag["msi"], fileDuration(fileConfig.MemorySnapshotInterval), envVars.MemorySnapshotInterval, envVars.MemorySnapshotInterval != 0)
+	ct.failoverJournalPath = mergeValue(flags.FailoverJournalPath, isFlag["fjp"], fileConfig.FailoverJournalPath, envVars.FailoverJournalPath, envVars.FailoverJournalPath != "")
+
+	ct.cacheSize = mergeValue(flags.CacheSize, isFlag["cs"], fileConfig.CacheSize, envVars.CacheSize, envVars.CacheSize != -1)
+	ct.cacheTTL = mergeValue(flags.CacheTTL, isFlag["ct"], fileDuration(fileConfig.CacheTTL), envVars.CacheTTL, envVars.CacheTTL != 0)
+	ct.cacheNegativeTTL = mergeValue(flags.CacheNegativeTTL, isFlag["cnt"], fileDuration(fileConfig.CacheNegativeTTL), envVars.CacheNegativeTTL, envVars.CacheNegativeTTL != 0)
+
+	ct.maxURLLength = mergeValue(flags.MaxURLLength, isFlag["mul"], fileConfig.MaxURLLength, envVars.MaxURLLength, envVars.MaxURLLength != -1)
+
+	ct.workspacesFile = mergeValue(flags.WorkspacesFile, isFlag["wsf"], fileConfig.WorkspacesFile, envVars.WorkspacesFile, envVars.WorkspacesFile != "")
+	ct.shortDomains = mergeValue(flags.ShortDomains, isFlag["sd"], fileConfig.ShortDomains, envVars.ShortDomains, envVars.ShortDomains != "")
+
+	ct.dbMaxConns = mergeValue(flags.DBMaxConns, isFlag["dbmc"], fileConfig.DBMaxConns, envVars.DBMaxConns, envVars.DBMaxConns != -1)
+	ct.dbMinConns = mergeValue(flags.DBMinConns, isFlag["dbmnc"], fileConfig.DBMinConns, envVars.DBMinConns, envVars.DBMinConns != -1)
+	ct.dbMaxConnLifetime = mergeValue(flags.DBMaxConnLifetime, isFlag["dbcl"], fileDuration(fileConfig.DBMaxConnLifetime), envVars.DBMaxConnLifetime, envVars.DBMaxConnLifetime != 0)
+	ct.dbHealthCheckPeriod = mergeValue(flags.DBHealthCheckPeriod, isFlag["dbhc"], fileDuration(fileConfig.DBHealthCheckPeriod), envVars.DBHealthCheckPeriod, envVars.DBHealthCheckPeriod != 0)
+	ct.dbStatementCacheMode = mergeValue(flags.DBStatementCacheMode, isFlag["dbscm"], fileConfig.DBStatementCacheMode, envVars.DBStatementCacheMode, envVars.DBStatementCacheMode != "")
+	ct.dbPingTimeout = mergeValue(flags.DBPingTimeout, isFlag["dbpt"], fileDuration(fileConfig.DBPingTimeout), envVars.DBPingTimeout, envVars.DBPingTimeout != 0)
+
+	ct.dbRetryAttempts = mergeValue(flags.DBRetryAttempts, isFlag["dbra"], fileConfig.DBRetryAttempts, envVars.DBRetryAttempts, envVars.DBRetryAttempts != -1)
+	ct.dbRetryDelay = mergeValue(flags.DBRetryDelay, isFlag["dbrd"], fileDuration(fileConfig.DBRetryDelay), envVars.DBRetryDelay, envVars.DBRetryDelay != 0)
+	ct.dbRetryMaxDelay = mergeValue(flags.DBRetryMaxDelay, isFlag["dbrmd"], fileDuration(fileConfig.DBRetryMaxDelay), envVars.DBRetryMaxDelay, envVars.DBRetryMaxDelay != 0)
+	ct.dbBreakerThreshold = mergeValue(flags.DBBreakerThreshold, isFlag["dbbt"], fileConfig.DBBreakerThreshold, envVars.DBBreakerThreshold, envVars.DBBreakerThreshold != -1)
+	ct.dbBreakerTimeout = mergeValue(flags.DBBreakerTimeout, isFlag["dbbto"], fileDuration(fileConfig.DBBreakerTimeout), envVars.DBBreakerTimeout, envVars.DBBreakerTimeout != 0)
+
+	// токен, как и ключ шифрования, флагом не передаем
+	ct.adminToken = logger.Secret(mergeValue("", false, fileConfig.AdminToken, envVars.AdminToken, envVars.AdminToken != ""))
+	ct.adminAddress = mergeValue(flags.AdminAddress, isFlag["aa"], fileConfig.AdminAddress, envVars.AdminAddress, envVars.AdminAddress != "")
+	ct.userIDSecret = logger.Secret(mergeValue("", false, fileConfig.UserIDSecret, envVars.UserIDSecret, envVars.UserIDSecret != ""))
+
+	listErrors = append(listErrors, ct.Validate()...)
+	return errors.Join(listErrors...)
+}
+
+// Проверка всех настроек, адреса приводятся к единому виду
+// Возвращаются все найденные ошибки, чтобы их можно было исправить за один раз
+func (ct *ConfigType) Validate() (listErrors []error) {
+
+	addError := func(name string, err error) {
+		listErrors = append(listErrors, fmt.Errorf("ошибка: некорректная настройка %s: %w", name, err))
+	}
+
+	serverAddress := addressServer{}
+	if err := serverAddress.Set(ct.addrServer); err != nil {
+		addError("server_address", err)
+	} else {
+		ct.addrServer = serverAddress.String()
+	}
+
+	if ct.adminAddress != "" {
+		adminAddress := addressServer{}
+		if err := adminAddress.Set(ct.adminAddress); err != nil {
+			addError("admin_address", err)
+		} else if adminAddress.String() == ct.addrServer {
+			addError("admin_address", errors.New("служебный сервер должен слушать другой адрес, чем основной"))
+		} else {
+			ct.adminAddress = adminAddress.String()
+		}
+	}
 
-	ct.addrServer = flags.AddressServer.String()
-	if envVars.AddressServer != "" {
-		ct.addrServer = envVars.AddressServer
+	baseURL := hostShortLink{}
+	if err := baseURL.Set(ct.hostShortLink); err != nil {
+		addError("base_url", err)
+	} else {
+		ct.hostShortLink = baseURL.String()
 	}
 
-	ct.hostShortLink = flags.HostShortLink.String()
-	if envVars.HostShortLink != "" {
-		ct.hostShortLink = envVars.HostShortLink
+	for _, baseURLDomain := range strings.Split(ct.shortDomains, ",") {
+		baseURLDomain = strings.TrimSpace(baseURLDomain)
+		if baseURLDomain == "" {
+			continue
+		}
+		if _, err := ParseBaseURL(baseURLDomain); err != nil {
+			addError("short_domains", err)
+		}
 	}
 
-	ct.logsPath = envVars.LogsPath
+	if ct.levelLogs < int(logrus.PanicLevel) || ct.levelLogs > int(logrus.TraceLevel) {
+		addError("level_logs", fmt.Errorf("уровень %d вне диапазона от %d до %d", ct.levelLogs, logrus.PanicLevel, logrus.TraceLevel))
+	}
+
+	listOutputs := logger.ParseLogOutputs(ct.logOutput)
+	if len(listOutputs) == 0 {
+		addError("log_output", errors.New("не указано, куда писать логи"))
+	}
+	for _, output := range listOutputs {
+		if !containsValue(listLogOutputs, output) {
+			addError("log_output", fmt.Errorf("неизвестный вывод логов %q, допустимы: %s", output, strings.Join(listLogOutputs, ", ")))
+		}
+	}
+	if !containsValue(listLogFormats, ct.logFormat) {
+		addError("log_format", fmt.Errorf("неизвестный формат логов %q, допустимы: %s", ct.logFormat, strings.Join(listLogFormats, ", ")))
+	}
 
-	ct.fileStoragePath = flags.FileStoragePath
-	if envVars.FileStoragePath != "" {
-		ct.fileStoragePath = envVars.FileStoragePath
+	if ct.nameTableRestorer == "" {
+		addError("name_table_restorer", errors.New("не указано название таблицы"))
 	}
 
-	ct.databaseDsn = flags.DatabaseDsn
-	if envVars.DatabaseDsn != "" {
-		ct.databaseDsn = envVars.DatabaseDsn
+	if !containsValue(listStorageBackends, ct.storageBackend) {
+		addError("storage_backend", fmt.Errorf("неизвестный тип хранилища %q, допустимы: %s", ct.storageBackend, strings.Join(listStorageBackends, ", ")))
+	}
+	if !containsValue(listStatementCacheModes, ct.dbStatementCacheMode) {
+		addError("db_statement_cache_mode", fmt.Errorf("неизвестный режим %q, допустимы: %s", ct.dbStatementCacheMode, strings.Join(listStatementCacheModes, ", ")))
 	}
 
-	ct.nameTableRestorer = flags.NameTableRestorer
-	if envVars.NameTableRestorer != "" {
-		ct.nameTableRestorer = envVars.NameTableRestorer
+	for name, value := range map[string]int{
+		"cache_size":           ct.cacheSize,
+		"max_url_length":       ct.maxURLLength,
+		"db_max_conns":         ct.dbMaxConns,
+		"db_min_conns":         ct.dbMinConns,
+		"db_retry_attempts":    ct.dbRetryAttempts,
+		"db_breaker_threshold": ct.dbBreakerThreshold,
+		"log_max_size":         ct.logMaxSize,
+		"log_max_backups":      ct.logMaxBackups,
+	} {
+		if value < 0 {
+			addError(name, fmt.Errorf("значение %d меньше нуля", value))
+		}
+	}
+	if ct.dbMaxConns > 0 && ct.dbMinConns > ct.dbMaxConns {
+		addError("db_min_conns", fmt.Errorf("значение %d больше db_max_conns %d", ct.dbMinConns, ct.dbMaxConns))
 	}
 
-	ct.levelLogs = flags.LevelLogs
-	if envVars.LevelLogs != -1 {
-		ct.levelLogs = envVars.LevelLogs
+	for name, value := range map[string]time.Duration{
+		"memory_snapshot_interval": ct.memorySnapshotInterval,
+		"cache_ttl":                ct.cacheTTL,
+		"cache_negative_ttl":       ct.cacheNegativeTTL,
+		"db_max_conn_lifetime":     ct.dbMaxConnLifetime,
+		"db_health_check_period":   ct.dbHealthCheckPeriod,
+		"db_ping_timeout":          ct.dbPingTimeout,
+		"db_retry_delay":           ct.dbRetryDelay,
+		"db_retry_max_delay":       ct.dbRetryMaxDelay,
+		"db_breaker_timeout":       ct.dbBreakerTimeout,
+		"log_max_age":              ct.logMaxAge,
+	} {
+		if value < 0 {
+			addError(name, fmt.Errorf("длительность %s меньше нуля", value))
+		}
+	}
+	if ct.dbRetryMaxDelay > 0 && ct.dbRetryDelay > ct.dbRetryMaxDelay {
+		addError("db_retry_delay", fmt.Errorf("задержка %s больше db_retry_max_delay %s", ct.dbRetryDelay, ct.dbRetryMaxDelay))
 	}
 
-	ct.userHomePath = envVars.UserHomePath
+	// порядок ошибок не должен зависеть от обхода map
+	sort.SliceStable(listErrors, func(i, j int) bool {
+		return listErrors[i].Error() < listErrors[j].Error()
+	})
+	return
 }
 
+// допустимые типы хранилища ссылок
+// "auto" - автоматический выбор хранилища
+var listStorageBackends = []string{"auto", modelsStorage.StorageBackendPostgres, modelsStorage.StorageBackendPostgresCached,
+	modelsStorage.StorageBackendPostgresFailover, modelsStorage.StorageBackendFile, modelsStorage.StorageBackendMemory}
+
+// допустимые режимы выполнения запросов к БД
+var listStatementCacheModes = []string{"cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol"}
+
+// допустимые выводы логов
+var listLogOutputs = []string{"stdout", "stderr", "file"}
+
+// допустимые форматы логов
+var listLogFormats = []string{"text", "json", "logfmt"}
+
+func containsValue(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ошибки загрузки файла конфигурации и проверки настроек
+var errSetupConfig error
+
 var appConfig = &ConfigType{}
 
+// Общий логер процесса настраивается по общей конфигурации
+// и меняет уровень логирования при ее перезагрузке
+func init() {
+	logger.SetDefaultSettings(func() logger.Settings {
+		configApp := GetAppConfig()
+		Subscribe(func(configApp ConfigTypeInterface) {
+			logger.SetLevelLog(configApp.GetLevelLogs())
+		})
+		return configApp
+	})
+}
+
 // Маркер синглтона, что сущность, уже инициировали
 var setupAppConfig = false
 
@@ -155,4 +958,11 @@ func GetAppConfig() ConfigTypeInterface {
 func SetAppConfig(value *ConfigType) {
 	setupAppConfig = true
 	appConfig = value
+	errSetupConfig = nil
+}
+
+// Ошибки файла конфигурации и проверки настроек, с ними сервер не запускаем
+func GetErrSetup() error {
+	GetAppConfig()
+	return errSetupConfig
 }
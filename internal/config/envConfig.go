@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	env "github.com/caarlos0/env/v8"
 )
@@ -19,6 +20,52 @@ type EnviromentConfigType struct {
 	LevelLogs         int    `env:"LEVEL_LOGS_GOLANG"`
 	UserHomePath      string `env:"USER_HOME_PATH"`
 	NameTableRestorer string `env:"NAME_TABLE_RESTORER"`
+
+	LogOutput     string        `env:"LOG_OUTPUT"`
+	LogFormat     string        `env:"LOG_FORMAT"`
+	LogMaxSize    int           `env:"LOG_MAX_SIZE"`
+	LogMaxAge     time.Duration `env:"LOG_MAX_AGE"`
+	LogMaxBackups int           `env:"LOG_MAX_BACKUPS"`
+
+	FileStorageKey     string `env:"FILE_STORAGE_KEY"`
+	FileStorageKeyFile string `env:"FILE_STORAGE_KEY_FILE"`
+
+	StorageBackend         string        `env:"STORAGE_BACKEND"`
+	MemorySnapshotPath     string        `env:"MEMORY_SNAPSHOT_PATH"`
+	MemorySnapshotInterval time.Duration `env:"MEMORY_SNAPSHOT_INTERVAL"`
+	FailoverJournalPath    string        `env:"FAILOVER_JOURNAL_PATH"`
+
+	CacheSize        int           `env:"CACHE_SIZE"`
+	CacheTTL         time.Duration `env:"CACHE_TTL"`
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL"`
+
+	MaxURLLength int `env:"MAX_URL_LENGTH"`
+
+	WorkspacesFile string `env:"WORKSPACES_FILE"`
+	ShortDomains   string `env:"SHORT_DOMAINS"`
+
+	DBMaxConns           int           `env:"DB_MAX_CONNS"`
+	DBMinConns           int           `env:"DB_MIN_CONNS"`
+	DBMaxConnLifetime    time.Duration `env:"DB_MAX_CONN_LIFETIME"`
+	DBHealthCheckPeriod  time.Duration `env:"DB_HEALTH_CHECK_PERIOD"`
+	DBStatementCacheMode string        `env:"DB_STATEMENT_CACHE_MODE"`
+	DBPingTimeout        time.Duration `env:"DB_PING_TIMEOUT"`
+
+	DBRetryAttempts    int           `env:"DB_RETRY_ATTEMPTS"`
+	DBRetryDelay       time.Duration `env:"DB_RETRY_DELAY"`
+	DBRetryMaxDelay    time.Duration `env:"DB_RETRY_MAX_DELAY"`
+	DBBreakerThreshold int           `env:"DB_BREAKER_THRESHOLD"`
+	DBBreakerTimeout   time.Duration `env:"DB_BREAKER_TIMEOUT"`
+
+	// токен служебных запросов
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// адрес служебного сервера
+	AdminAddress string `env:"ADMIN_ADDRESS"`
+	// ключ подписи куки с идентификатором пользователя
+	UserIDSecret string `env:"USER_ID_SECRET"`
+
+	// файл конфигурации JSON или YAML
+	ConfigFile string `env:"CONFIG"`
 }
 
 // Глобальные переменные окружения
@@ -28,6 +75,9 @@ var enviromentConfig = EnviromentConfigType{}
 // Маркер синглтона, что сущность, уже инициировали
 var setupEnviroment = false
 
+// ошибка разбора переменных окружения, с ней конфигурация считается некорректной
+var errEnviroment error
+
 func GetEnviromentConfig() EnviromentConfigType {
 	if !setupEnviroment {
 		initEnviroment()
@@ -41,6 +91,14 @@ func SetEnviromentConfig(config EnviromentConfigType) {
 	enviromentConfig = config
 }
 
+// Повторное чтение переменных окружения при перезагрузке конфигурации
+func reloadEnviroment() {
+	enviromentConfig = EnviromentConfigType{}
+	errEnviroment = nil
+	initEnviroment()
+	setupEnviroment = true
+}
+
 // инициализация сущности
 func initEnviroment() {
 
@@ -48,6 +106,7 @@ func initEnviroment() {
 	if err != nil {
 		err = fmt.Errorf("ошибка: не смогли определить переменные окружения: %w", err)
 		log.Println(err.Error())
+		errEnviroment = err
 	}
 
 	// если значение не установлено, то устанавливаем сами
@@ -56,15 +115,46 @@ func initEnviroment() {
 		enviromentConfig.LevelLogs = -1
 	}
 
-	// путь до домашней директории пользователя по-умолчанию
-	defaultHomePath := getDefaultUserHomePath()
+	// 0 - допустимые значения: без ротации логов по размеру и без удаления старых логов
+	_, okLogMaxSize := os.LookupEnv("LOG_MAX_SIZE")
+	if !okLogMaxSize {
+		enviromentConfig.LogMaxSize = -1
+	}
+	_, okLogMaxBackups := os.LookupEnv("LOG_MAX_BACKUPS")
+	if !okLogMaxBackups {
+		enviromentConfig.LogMaxBackups = -1
+	}
 
-	if enviromentConfig.UserHomePath == "" {
-		enviromentConfig.UserHomePath = defaultHomePath
+	// 0 - допустимое значение размера кеша (кеш выключен), поэтому отличаем его от неустановленного
+	_, okCacheSize := os.LookupEnv("CACHE_SIZE")
+	if !okCacheSize {
+		enviromentConfig.CacheSize = -1
 	}
 
-	if enviromentConfig.LogsPath == "" {
-		enviromentConfig.LogsPath = defaultHomePath
+	// 0 - допустимое значение (без ограничения длины ссылки)
+	_, okMaxURLLength := os.LookupEnv("MAX_URL_LENGTH")
+	if !okMaxURLLength {
+		enviromentConfig.MaxURLLength = -1
+	}
+
+	// 0 - допустимые значения размеров пула соединений с БД
+	_, okDBMaxConns := os.LookupEnv("DB_MAX_CONNS")
+	if !okDBMaxConns {
+		enviromentConfig.DBMaxConns = -1
+	}
+	_, okDBMinConns := os.LookupEnv("DB_MIN_CONNS")
+	if !okDBMinConns {
+		enviromentConfig.DBMinConns = -1
+	}
+
+	// 0 - допустимые значения: без повторов запросов к БД и без выключателя
+	_, okDBRetryAttempts := os.LookupEnv("DB_RETRY_ATTEMPTS")
+	if !okDBRetryAttempts {
+		enviromentConfig.DBRetryAttempts = -1
+	}
+	_, okDBBreakerThreshold := os.LookupEnv("DB_BREAKER_THRESHOLD")
+	if !okDBBreakerThreshold {
+		enviromentConfig.DBBreakerThreshold = -1
 	}
 }
 
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Длительность в файле конфигурации задается строкой: 5m, 10s, 1h30m
+type DurationConfig time.Duration
+
+func (dc *DurationConfig) set(value string) (err error) {
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("некорректная длительность %q, пример: 5m или 10s: %w", value, err)
+	}
+	*dc = DurationConfig(duration)
+	return
+}
+
+func (dc *DurationConfig) UnmarshalJSON(data []byte) (err error) {
+	value := ""
+	if err = json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("длительность надо указать строкой, пример: \"5m\": %w", err)
+	}
+	return dc.set(value)
+}
+
+func (dc *DurationConfig) UnmarshalYAML(node *yaml.Node) (err error) {
+	value := ""
+	if err = node.Decode(&value); err != nil {
+		return
+	}
+	return dc.set(value)
+}
+
+func (dc DurationConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(dc).String())
+}
+
+func (dc DurationConfig) MarshalYAML() (any, error) {
+	return time.Duration(dc).String(), nil
+}
+
+// Тип для хранения настроек из файла конфигурации
+// nil - настройка в файле не указана
+// По тегам строятся схема файла (config schema) и вывод действующих настроек (config print)
+type FileConfigType struct {
+	AddressServer   *string `json:"server_address,omitempty" yaml:"server_address,omitempty" env:"SERVER_ADDRESS" flag:"a" description:"Адрес сервера: host:port, :port, [::1]:port или unix:/путь/до/сокета"`
+	HostShortLink   *string `json:"base_url,omitempty" yaml:"base_url,omitempty" env:"BASE_URL" flag:"b" description:"Базовый адрес коротких ссылок, может быть с префиксом пути: https://example.com/s"`
+	FileStoragePath *string `json:"file_storage_path,omitempty" yaml:"file_storage_path,omitempty" env:"FILE_STORAGE_PATH" flag:"f" description:"Путь до файла хранилища"`
+	DatabaseDsn     *string `json:"database_dsn,omitempty" yaml:"database_dsn,omitempty" env:"DATABASE_DSN" flag:"d" secret:"dsn" description:"Строка подключения к БД"`
+
+	LogsPath          *string `json:"logs_path,omitempty" yaml:"logs_path,omitempty" env:"LOGS_PATH_GOLANG" description:"Папка для логов, по умолчанию домашняя папка пользователя"`
+	LevelLogs         *int    `json:"level_logs,omitempty" yaml:"level_logs,omitempty" env:"LEVEL_LOGS_GOLANG" flag:"logLevel" minimum:"0" maximum:"6" description:"Уровень логирования от 0 (panic) до 6 (trace)"`
+	UserHomePath      *string `json:"user_home_path,omitempty" yaml:"user_home_path,omitempty" env:"USER_HOME_PATH" description:"Домашняя папка пользователя"`
+	NameTableRestorer *string `json:"name_table_restorer,omitempty" yaml:"name_table_restorer,omitempty" env:"NAME_TABLE_RESTORER" flag:"tr" description:"Таблица коротких ссылок в БД: table или schema.table"`
+
+	LogOutput     *string         `json:"log_output,omitempty" yaml:"log_output,omitempty" env:"LOG_OUTPUT" flag:"lo" description:"Куда писать логи: stdout, stderr, file или несколько через запятую"`
+	LogFormat     *string         `json:"log_format,omitempty" yaml:"log_format,omitempty" env:"LOG_FORMAT" flag:"lf" enum:"text,json,logfmt" description:"Формат логов"`
+	LogMaxSize    *int            `json:"log_max_size,omitempty" yaml:"log_max_size,omitempty" env:"LOG_MAX_SIZE" flag:"lms" minimum:"0" description:"Размер файла лога в мегабайтах, после которого он ротируется, 0 - без ротации по размеру"`
+	LogMaxAge     *DurationConfig `json:"log_max_age,omitempty" yaml:"log_max_age,omitempty" env:"LOG_MAX_AGE" flag:"lma" description:"Возраст файла лога, после которого он ротируется, 0 - без ротации по времени"`
+	LogMaxBackups *int            `json:"log_max_backups,omitempty" yaml:"log_max_backups,omitempty" env:"LOG_MAX_BACKUPS" flag:"lmb" minimum:"0" description:"Сколько ротированных файлов лога хранить, 0 - хранить все"`
+
+	FileStorageKey     *string `json:"file_storage_key,omitempty" yaml:"file_storage_key,omitempty" env:"FILE_STORAGE_KEY" secret:"value" description:"Ключ шифрования файла хранилища"`
+	FileStorageKeyFile *string `json:"file_storage_key_file,omitempty" yaml:"file_storage_key_file,omitempty" env:"FILE_STORAGE_KEY_FILE" flag:"fkf" description:"Путь до файла с ключами шифрования файла хранилища"`
+
+	StorageBackend         *string         `json:"storage_backend,omitempty" yaml:"storage_backend,omitempty" env:"STORAGE_BACKEND" flag:"sb" enum:"auto,postgres,postgres-cached,postgres-failover,file,memory" description:"Тип хранилища ссылок"`
+	MemorySnapshotPath     *string         `json:"memory_snapshot_path,omitempty" yaml:"memory_snapshot_path,omitempty" env:"MEMORY_SNAPSHOT_PATH" flag:"msp" description:"Путь до файла снимков хранилища в памяти, пусто - без снимков"`
+	MemorySnapshotInterval *DurationConfig `json:"memory_snapshot_interval,omitempty" yaml:"memory_snapshot_interval,omitempty" env:"MEMORY_SNAPSHOT_INTERVAL" flag:"msi" description:"Интервал снимков хранилища в памяти, 0 - только при остановке"`
+	FailoverJournalPath    *string         `json:"failover_journal_path,omitempty" yaml:"failover_journal_path,omitempty" env:"FAILOVER_JOURNAL_PATH" flag:"fjp" description:"Путь до журнала записей при недоступности БД"`
+
+	CacheSize        *int            `json:"cache_size,omitempty" yaml:"cache_size,omitempty" env:"CACHE_SIZE" flag:"cs" minimum:"0" description:"Размер кеша перед хранилищем ссылок, 0 - без кеша"`
+	CacheTTL         *DurationConfig `json:"cache_ttl,omitempty" yaml:"cache_ttl,omitempty" env:"CACHE_TTL" flag:"ct" description:"Время жизни ссылки в кеше"`
+	CacheNegativeTTL *DurationConfig `json:"cache_negative_ttl,omitempty" yaml:"cache_negative_ttl,omitempty" env:"CACHE_NEGATIVE_TTL" flag:"cnt" description:"Время жизни в кеше незарегистрированной ссылки, 0 - не кешировать"`
+
+	MaxURLLength *int `json:"max_url_length,omitempty" yaml:"max_url_length,omitempty" env:"MAX_URL_LENGTH" flag:"mul" minimum:"0" description:"Максимальная длина полной ссылки в байтах, 0 - без ограничения"`
+
+	WorkspacesFile *string `json:"workspaces_file,omitempty" yaml:"workspaces_file,omitempty" env:"WORKSPACES_FILE" flag:"wsf" description:"Путь до JSON файла с рабочими пространствами"`
+	ShortDomains   *string `json:"short_domains,omitempty" yaml:"short_domains,omitempty" env:"SHORT_DOMAINS" flag:"sd" description:"Дополнительные домены коротких ссылок через запятую"`
+
+	DBMaxConns           *int            `json:"db_max_conns,omitempty" yaml:"db_max_conns,omitempty" env:"DB_MAX_CONNS" flag:"dbmc" minimum:"0" description:"Максимальное количество соединений в пуле БД, 0 - по умолчанию драйвера"`
+	DBMinConns           *int            `json:"db_min_conns,omitempty" yaml:"db_min_conns,omitempty" env:"DB_MIN_CONNS" flag:"dbmnc" minimum:"0" description:"Минимальное количество открытых соединений в пуле БД"`
+	DBMaxConnLifetime    *DurationConfig `json:"db_max_conn_lifetime,omitempty" yaml:"db_max_conn_lifetime,omitempty" env:"DB_MAX_CONN_LIFETIME" flag:"dbcl" description:"Время жизни соединения в пуле БД"`
+	DBHealthCheckPeriod  *DurationConfig `json:"db_health_check_period,omitempty" yaml:"db_health_check_period,omitempty" env:"DB_HEALTH_CHECK_PERIOD" flag:"dbhc" description:"Период проверки соединений пула БД"`
+	DBStatementCacheMode *string         `json:"db_statement_cache_mode,omitempty" yaml:"db_statement_cache_mode,omitempty" env:"DB_STATEMENT_CACHE_MODE" flag:"dbscm" enum:"cache_statement,cache_describe,describe_exec,exec,simple_protocol" description:"Режим выполнения запросов к БД"`
+	DBPingTimeout        *DurationConfig `json:"db_ping_timeout,omitempty" yaml:"db_ping_timeout,omitempty" env:"DB_PING_TIMEOUT" flag:"dbpt" description:"Время ожидания ответа БД при проверке подключения"`
+
+	DBRetryAttempts    *int            `json:"db_retry_attempts,omitempty" yaml:"db_retry_attempts,omitempty" env:"DB_RETRY_ATTEMPTS" flag:"dbra" minimum:"0" description:"Сколько раз повторять запрос к БД после временной ошибки"`
+	DBRetryDelay       *DurationConfig `json:"db_retry_delay,omitempty" yaml:"db_retry_delay,omitempty" env:"DB_RETRY_DELAY" flag:"dbrd" description:"Начальная задержка повтора запроса к БД"`
+	DBRetryMaxDelay    *DurationConfig `json:"db_retry_max_delay,omitempty" yaml:"db_retry_max_delay,omitempty" env:"DB_RETRY_MAX_DELAY" flag:"dbrmd" description:"Максимальная задержка повтора запроса к БД"`
+	DBBreakerThreshold *int            `json:"db_breaker_threshold,omitempty" yaml:"db_breaker_threshold,omitempty" env:"DB_BREAKER_THRESHOLD" flag:"dbbt" minimum:"0" description:"После скольких подряд ошибок соединения не обращаться к БД, 0 - без выключателя"`
+	DBBreakerTimeout   *DurationConfig `json:"db_breaker_timeout,omitempty" yaml:"db_breaker_timeout,omitempty" env:"DB_BREAKER_TIMEOUT" flag:"dbbto" description:"Сколько времени не обращаться к БД после срабатывания выключателя"`
+
+	AdminToken   *string `json:"admin_token,omitempty" yaml:"admin_token,omitempty" env:"ADMIN_TOKEN" secret:"value" description:"Токен для служебных запросов, например перезагрузки конфигурации, пусто - служебные запросы выключены"`
+	AdminAddress *string `json:"admin_address,omitempty" yaml:"admin_address,omitempty" env:"ADMIN_ADDRESS" flag:"aa" description:"Адрес служебного сервера с метриками, пусто - служебный сервер не запускается"`
+
+	UserIDSecret *string `json:"user_id_secret,omitempty" yaml:"user_id_secret,omitempty" env:"USER_ID_SECRET" secret:"value" description:"Ключ подписи куки с идентификатором пользователя, по нему определяются роли в рабочих пространствах, пусто - случайный ключ до перезапуска"`
+}
+
+// Чтение файла конфигурации в формате JSON или YAML, формат определяется по расширению
+// Неизвестные ключи считаются ошибкой, чтобы опечатка в названии настройки не терялась молча
+func LoadFileConfig(pathFile string) (fileConfig FileConfigType, err error) {
+
+	dataFile, err := os.ReadFile(pathFile)
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось прочитать файл конфигурации: %w", err)
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(pathFile)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(dataFile))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&fileConfig)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(dataFile))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&fileConfig)
+	default:
+		err = fmt.Errorf("ошибка: неизвестный формат файла конфигурации %s, допустимы .json, .yaml и .yml", pathFile)
+		return
+	}
+	// пустой файл - это файл без настроек
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось разобрать файл конфигурации %s: %w", pathFile, err)
+	}
+	return
+}
+
+// JSON схема файла конфигурации, строится по тегам FileConfigType
+func GetSchemaFileConfig() map[string]any {
+
+	properties := map[string]any{}
+	typeConfig := reflect.TypeOf(FileConfigType{})
+	for i := 0; i < typeConfig.NumField(); i++ {
+		field := typeConfig.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		property := map[string]any{
+			"description": field.Tag.Get("description"),
+		}
+		if env := field.Tag.Get("env"); env != "" {
+			property["x-env"] = env
+		}
+		if flagName := field.Tag.Get("flag"); flagName != "" {
+			property["x-flag"] = "-" + flagName
+		}
+
+		switch field.Type.Elem().Kind() {
+		case reflect.Int:
+			property["type"] = "integer"
+			for _, bound := range []string{"minimum", "maximum"} {
+				if value := field.Tag.Get(bound); value != "" {
+					property[bound] = json.Number(value)
+				}
+			}
+		default:
+			property["type"] = "string"
+		}
+		if field.Type.Elem() == reflect.TypeOf(DurationConfig(0)) {
+			property["pattern"] = regexpDuration.String()
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = property
+	}
+
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Конфигурация go-url-shortener",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// длительность в формате time.ParseDuration
+var regexpDuration = regexp.MustCompile(`^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`)
+
+// Действующие настройки в виде файла конфигурации, секреты скрыты
+func GetPrintableConfig(configApp ConfigTypeInterface) FileConfigType {
+	return getSnapshotConfig(configApp, true)
+}
+
+// Настройки одной строкой JSON для логов, секреты скрыты
+// Выводится и при печати конфигурации через %v, %+v и %#v
+func (ct *ConfigType) String() string {
+	bytesConfig, err := json.Marshal(GetPrintableConfig(ct))
+	if err != nil {
+		return "ошибка: не удалось вывести конфигурацию: " + err.Error()
+	}
+	return string(bytesConfig)
+}
+
+func (ct *ConfigType) GoString() string {
+	return ct.String()
+}
+
+// Действующие настройки в виде файла конфигурации, isRedact - скрыть секреты
+func getSnapshotConfig(configApp ConfigTypeInterface, isRedact bool) FileConfigType {
+
+	databaseDsn := configApp.GetDatabaseDsn()
+	fileStorageKey := configApp.GetFileStorageKey()
+	adminToken := configApp.GetAdminToken()
+	userIDSecret := configApp.GetUserIDSecret()
+	if isRedact {
+		databaseDsn = logger.RedactDSN(databaseDsn)
+		fileStorageKey = logger.Secret(fileStorageKey).String()
+		adminToken = logger.Secret(adminToken).String()
+		userIDSecret = logger.Secret(userIDSecret).String()
+	}
+
+	return FileConfigType{
+		AddressServer:   valueString(configApp.GetAddrServer()),
+		HostShortLink:   valueString(configApp.GetHostShortLink()),
+		FileStoragePath: valueString(configApp.GetFileStoragePath()),
+		DatabaseDsn:     valueString(databaseDsn),
+
+		LogsPath:          valueString(configApp.GetLogsPath()),
+		LevelLogs:         valueInt(configApp.GetLevelLogs()),
+		UserHomePath:      valueString(configApp.GetUserHomePath()),
+		NameTableRestorer: valueString(configApp.GetNameTableRestorer()),
+
+		LogOutput:     valueString(configApp.GetLogOutput()),
+		LogFormat:     valueString(configApp.GetLogFormat()),
+		LogMaxSize:    valueInt(configApp.GetLogMaxSize()),
+		LogMaxAge:     valueDuration(configApp.GetLogMaxAge()),
+		LogMaxBackups: valueInt(configApp.GetLogMaxBackups()),
+
+		FileStorageKey:     valueString(fileStorageKey),
+		FileStorageKeyFile: valueString(configApp.GetFileStorageKeyFile()),
+
+		StorageBackend:         valueString(configApp.GetStorageBackend()),
+		MemorySnapshotPath:     valueString(configApp.GetMemorySnapshotPath()),
+		MemorySnapshotInterval: valueDuration(configApp.GetMemorySnapshotInterval()),
+		FailoverJournalPath:    valueString(configApp.GetFailoverJournalPath()),
+
+		CacheSize:        valueInt(configApp.GetCacheSize()),
+		CacheTTL:         valueDuration(configApp.GetCacheTTL()),
+		CacheNegativeTTL: valueDuration(configApp.GetCacheNegativeTTL()),
+
+		MaxURLLength: valueInt(configApp.GetMaxURLLength()),
+
+		WorkspacesFile: valueString(configApp.GetWorkspacesFile()),
+		ShortDomains:   valueString(configApp.GetShortDomains()),
+
+		DBMaxConns:           valueInt(configApp.GetDBMaxConns()),
+		DBMinConns:           valueInt(configApp.GetDBMinConns()),
+		DBMaxConnLifetime:    valueDuration(configApp.GetDBMaxConnLifetime()),
+		DBHealthCheckPeriod:  valueDuration(configApp.GetDBHealthCheckPeriod()),
+		DBStatementCacheMode: valueString(configApp.GetDBStatementCacheMode()),
+		DBPingTimeout:        valueDuration(configApp.GetDBPingTimeout()),
+
+		DBRetryAttempts:    valueInt(configApp.GetDBRetryAttempts()),
+		DBRetryDelay:       valueDuration(configApp.GetDBRetryDelay()),
+		DBRetryMaxDelay:    valueDuration(configApp.GetDBRetryMaxDelay()),
+		DBBreakerThreshold: valueInt(configApp.GetDBBreakerThreshold()),
+		DBBreakerTimeout:   valueDuration(configApp.GetDBBreakerTimeout()),
+
+		AdminToken:   valueString(adminToken),
+		AdminAddress: valueString(configApp.GetAdminAddress()),
+
+		UserIDSecret: valueString(userIDSecret),
+	}
+}
+
+func valueString(value string) *string {
+	return &value
+}
+
+func valueInt(value int) *int {
+	return &value
+}
+
+func valueDuration(value time.Duration) *DurationConfig {
+	durationConfig := DurationConfig(value)
+	return &durationConfig
+}
+
+// Значение длительности из файла конфигурации
+func fileDuration(value *DurationConfig) *time.Duration {
+	if value == nil {
+		return nil
+	}
+	duration := time.Duration(*value)
+	return &duration
+}
@@ -4,47 +4,145 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	//flag "github.com/spf13/pflag"
 )
 
-// Адрес запуска HTTP-сервера
-// Значение может быть таким: localhost:8888
-type addressServer struct {
-	host string
-	port int
+// порт сервера, если в адресе он не указан
+const defaultPortServer = "8080"
+
+// порты, которые не пишутся в базовом адресе коротких ссылок
+var defaultPortsScheme = map[string]string{
+	"http":  "80",
+	"https": "443",
 }
 
-func (as *addressServer) String() string {
-	port := as.port
-	strPort := strconv.Itoa(port)
-	return as.host + ":" + strPort
+// Разбор адреса запуска HTTP-сервера
+// Адрес TCP: localhost:8090, :8090, [::1]:8090 или без порта localhost, тогда порт 8080
+// Адрес unix сокета: unix:/run/shortener.sock
+func ParseAddressServer(value string) (network string, address string, err error) {
+
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		err = errors.New("передано пустое значение адреса сервера, пример: localhost:8090")
+		return
+	}
+
+	if strings.HasPrefix(value, "unix:") {
+		pathSocket := strings.TrimPrefix(strings.TrimPrefix(value, "unix:"), "//")
+		if pathSocket == "" {
+			err = errors.New("не указан путь до unix сокета сервера, пример: unix:/run/shortener.sock")
+			return
+		}
+		return "unix", pathSocket, nil
+	}
+
+	host, port, errSplit := net.SplitHostPort(value)
+	if errSplit != nil {
+		// адрес без порта, IPv6 адрес можно указать и без квадратных скобок
+		host = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+			err = fmt.Errorf("некорректное значение адреса сервера (%s): %w", value, errSplit)
+			return
+		}
+		port = defaultPortServer
+	}
+
+	intPort, errParse := strconv.Atoi(port)
+	if errParse != nil || intPort < 0 || intPort > 65535 {
+		err = fmt.Errorf("не удалось получить порт сервера (%s)", value)
+		return
+	}
+
+	return "tcp", net.JoinHostPort(host, port), nil
 }
 
-func (as *addressServer) Set(strValue string) (err error) {
+// Разбор базового адреса коротких ссылок
+// Порт по умолчанию для схемы отбрасывается, завершающий / у префикса пути тоже
+// Примеры: https://sho.rt, https://example.com/s, http://[::1]:8080
+func ParseBaseURL(value string) (baseURL string, err error) {
 
-	if len(strValue) == 0 {
-		err = errors.New("передано пустое значение адреса сервера в аргументах командной строки, пример: localhost:8090")
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		err = errors.New("передан пустой базовый адрес для формирования короткой ссылки, пример: http://localhost:8000")
 		return
 	}
 
-	patrsString := strings.Split(strValue, ":")
+	urlBase, err := url.Parse(value)
+	if err != nil {
+		err = fmt.Errorf("некорректный базовый адрес для формирования короткой ссылки (%s): %w", value, err)
+		return
+	}
+	if urlBase.Scheme != "http" && urlBase.Scheme != "https" {
+		err = fmt.Errorf("у базового адреса для формирования короткой ссылки (%s) должна быть схема http или https, пример: http://localhost:8000", value)
+		return
+	}
+	if urlBase.Hostname() == "" {
+		err = fmt.Errorf("в базовом адресе для формирования короткой ссылки (%s) не указан хост", value)
+		return
+	}
+	if urlBase.User != nil || urlBase.RawQuery != "" || urlBase.Fragment != "" {
+		err = fmt.Errorf("базовый адрес для формирования короткой ссылки (%s) не должен содержать пользователя, параметры и якорь", value)
+		return
+	}
 
-	if len(patrsString) > 1 {
-		as.host = patrsString[0]
-		intPort, errParse := strconv.ParseInt(patrsString[1], 0, 0)
-		if errParse != nil {
-			err = fmt.Errorf("не удалось получить порт сервера (%s): %w", strValue, errParse)
-		} else {
-			as.port = int(intPort)
+	host := strings.ToLower(urlBase.Hostname())
+	port := urlBase.Port()
+	if port != "" {
+		intPort, errParse := strconv.Atoi(port)
+		if errParse != nil || intPort < 1 || intPort > 65535 {
+			err = fmt.Errorf("не удалось получить порт базового адреса (%s)", value)
+			return
 		}
-	} else {
-		err = errors.New("некорректное значение адреса сервера в аргументах командной строки, пример: localhost:8090")
+	}
+	if port != "" && port != defaultPortsScheme[urlBase.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// IPv6 адрес без порта
+		host = "[" + host + "]"
 	}
 
+	basePath := strings.TrimSuffix(urlBase.EscapedPath(), "/")
+	return urlBase.Scheme + "://" + host + basePath, nil
+}
+
+// Префикс пути базового адреса коротких ссылок, пусто - ссылки в корне сайта
+func GetBasePath(baseURL string) string {
+	urlBase, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(urlBase.Path, "/")
+}
+
+// Адрес запуска HTTP-сервера
+// Значение может быть таким: localhost:8888, [::1]:8888 или unix:/run/shortener.sock
+type addressServer struct {
+	network string
+	address string
+}
+
+func (as *addressServer) String() string {
+	if as.network == "unix" {
+		return "unix:" + as.address
+	}
+	return as.address
+}
+
+func (as *addressServer) Set(strValue string) (err error) {
+
+	network, address, err := ParseAddressServer(strValue)
+	if err != nil {
+		return
+	}
+	as.network = network
+	as.address = address
 	return
 }
 
@@ -54,45 +152,22 @@ func (as *addressServer) Type() string {
 
 // Базовый адрес результирующего сокращённого URL
 // Значение: адрес сервера перед коротким URL, например http://localhost:8000/qsd54gFg
+// Адрес может быть с префиксом пути, тогда сервер отвечает только по этому префиксу
 type hostShortLink struct {
-	protocol string
-	host     string
-	port     int
+	baseURL string
 }
 
 func (hsl *hostShortLink) String() string {
-	protocol := hsl.protocol
-	port := hsl.port
-	strPort := strconv.Itoa(port)
-	return protocol + "://" + hsl.host + ":" + strPort
+	return hsl.baseURL
 }
 
 func (hsl *hostShortLink) Set(strValue string) (err error) {
 
-	if len(strValue) == 0 {
-		err = errors.New("передан пустой базовый адрес для формирования короткой ссылки, пример: http://localhost:8000")
+	baseURL, err := ParseBaseURL(strValue)
+	if err != nil {
 		return
 	}
-
-	patrsString := strings.Split(strValue, "://")
-	if len(patrsString) > 1 {
-		hsl.protocol = patrsString[0]
-
-		patrs2String := strings.Split(patrsString[1], ":")
-		if len(patrs2String) > 1 {
-			hsl.host = string(patrs2String[0])
-			intPort, errParse := strconv.ParseInt(patrs2String[1], 0, 0)
-			if errParse != nil {
-				err = fmt.Errorf(`не удалось получить порт сервера (%s): %w`, strValue, errParse)
-			} else {
-				hsl.port = int(intPort)
-			}
-		} else {
-			err = errors.New("некорректное значение адреса сервера в аргументах командной строки")
-		}
-	} else {
-		err = errors.New("некорректный базовый адрес для формирования короткой ссылки, пример: http://localhost:8000")
-	}
+	hsl.baseURL = baseURL
 	return
 }
 
@@ -108,19 +183,65 @@ type FlagConfigType struct {
 	DatabaseDsn       string
 	LevelLogs         int
 	NameTableRestorer string
+	// вывод, формат и ротация логов
+	LogOutput     string
+	LogFormat     string
+	LogMaxSize    int
+	LogMaxAge     time.Duration
+	LogMaxBackups int
+	// файл с ключами шифрования файла хранилища
+	FileStorageKeyFile string
+	// тип хранилища ссылок
+	StorageBackend string
+	// снимки хранилища в памяти
+	MemorySnapshotPath     string
+	MemorySnapshotInterval time.Duration
+	// журнал записей при недоступности БД
+	FailoverJournalPath string
+	// кеш перед хранилищем ссылок
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+	// максимальная длина полной ссылки
+	MaxURLLength int
+	// файл с описанием рабочих пространств
+	WorkspacesFile string
+	// дополнительные домены коротких ссылок
+	ShortDomains string
+	// пул соединений с БД
+	DBMaxConns           int
+	DBMinConns           int
+	DBMaxConnLifetime    time.Duration
+	DBHealthCheckPeriod  time.Duration
+	DBStatementCacheMode string
+	DBPingTimeout        time.Duration
+	// повторы запросов к БД и автоматический выключатель
+	DBRetryAttempts    int
+	DBRetryDelay       time.Duration
+	DBRetryMaxDelay    time.Duration
+	DBBreakerThreshold int
+	DBBreakerTimeout   time.Duration
+	// адрес служебного сервера
+	AdminAddress string
+
+	// файл конфигурации JSON или YAML
+	ConfigFile string
+	// явно указанные при запуске флаги, только они перекрывают файл конфигурации и окружение
+	SetFlags map[string]bool
+
+	// аргументы после флагов, по ним определяем команду приложения
+	CommandArgs []string
 }
 
 // Глобальные переменные окружения
 // Сделаем эспортируемыми, чтобы можно было управлять в тестах
 var flagConfig = FlagConfigType{
 	AddressServer: &addressServer{
-		host: "localhost",
-		port: 8080,
+		network: "tcp",
+		address: "localhost:8080",
 	},
 	HostShortLink: &hostShortLink{
-		protocol: "http",
-		host:     "localhost",
-		port:     8080,
+		baseURL: "http://localhost:8080",
 	},
 	FileStoragePath: "",
 	LevelLogs:       int(log.InfoLevel),
@@ -144,12 +265,50 @@ func SetFlagConfig(config FlagConfigType) {
 
 // инициализация сущности
 func initFlags() {
-	flag.Var(flagConfig.AddressServer, "a", "Адрес сервера")
-	flag.Var(flagConfig.HostShortLink, "b", "Базовый адрес для формирования короткой ссылки")
+	flag.Var(flagConfig.AddressServer, "a", "Адрес сервера: host:port, :port, [::1]:port или unix:/путь/до/сокета")
+	flag.Var(flagConfig.HostShortLink, "b", "Базовый адрес для формирования короткой ссылки, может быть с префиксом пути: https://example.com/s")
 	flag.StringVar(&flagConfig.FileStoragePath, "f", "/tmp/short-url-db.json", "Путь до файла хранилища")
-	flag.StringVar(&flagConfig.NameTableRestorer, "tr", "shortlinks", "Название таблицы в базе данных для хранения коротких ссылок")
+	flag.StringVar(&flagConfig.NameTableRestorer, "tr", "shortlinks", "Название таблицы в базе данных для хранения коротких ссылок: table или schema.table")
 	flag.IntVar(&flagConfig.LevelLogs, "logLevel", int(log.InfoLevel), "Уровень логирования")
+	flag.StringVar(&flagConfig.LogOutput, "lo", "file", "Куда писать логи: stdout, stderr, file или несколько через запятую")
+	flag.StringVar(&flagConfig.LogFormat, "lf", "text", "Формат логов: text, json или logfmt")
+	flag.IntVar(&flagConfig.LogMaxSize, "lms", 100, "Размер файла лога в мегабайтах, после которого он ротируется, 0 - без ротации по размеру")
+	flag.DurationVar(&flagConfig.LogMaxAge, "lma", 0, "Возраст файла лога, после которого он ротируется, 0 - без ротации по времени")
+	flag.IntVar(&flagConfig.LogMaxBackups, "lmb", 7, "Сколько ротированных файлов лога хранить, 0 - хранить все")
 	flag.StringVar(&flagConfig.DatabaseDsn, "d", "", "Название источника данных подключения к БД")
+	flag.StringVar(&flagConfig.FileStorageKeyFile, "fkf", "", "Путь до файла с ключами шифрования файла хранилища")
+	flag.StringVar(&flagConfig.StorageBackend, "sb", "auto", "Тип хранилища ссылок: postgres, postgres-cached, postgres-failover, file, memory или auto")
+	flag.StringVar(&flagConfig.MemorySnapshotPath, "msp", "", "Путь до файла снимков хранилища в памяти, пусто - без снимков")
+	flag.StringVar(&flagConfig.FailoverJournalPath, "fjp", "", "Путь до журнала записей при недоступности БД, по умолчанию рядом с файлом хранилища")
+	flag.DurationVar(&flagConfig.MemorySnapshotInterval, "msi", 0, "Интервал снимков хранилища в памяти, 0 - только при остановке")
+	flag.IntVar(&flagConfig.CacheSize, "cs", 10000, "Размер кеша перед хранилищем ссылок, 0 - без кеша")
+	flag.DurationVar(&flagConfig.CacheTTL, "ct", 5*time.Minute, "Время жизни ссылки в кеше")
+	flag.DurationVar(&flagConfig.CacheNegativeTTL, "cnt", 10*time.Second, "Время жизни в кеше незарегистрированной ссылки, 0 - не кешировать")
+	flag.IntVar(&flagConfig.MaxURLLength, "mul", 16384, "Максимальная длина полной ссылки в байтах, 0 - без ограничения")
+	flag.StringVar(&flagConfig.WorkspacesFile, "wsf", "", "Путь до JSON файла с рабочими пространствами, пусто - только общее пространство")
+	flag.StringVar(&flagConfig.ShortDomains, "sd", "", "Дополнительные домены коротких ссылок через запятую, например https://brand.io, по умолчанию домен из BASE_URL")
+
+	flag.IntVar(&flagConfig.DBMaxConns, "dbmc", 0, "Максимальное количество соединений в пуле БД, 0 - по умолчанию драйвера")
+	flag.IntVar(&flagConfig.DBMinConns, "dbmnc", 0, "Минимальное количество открытых соединений в пуле БД")
+	flag.DurationVar(&flagConfig.DBMaxConnLifetime, "dbcl", time.Hour, "Время жизни соединения в пуле БД")
+	flag.DurationVar(&flagConfig.DBHealthCheckPeriod, "dbhc", time.Minute, "Период проверки соединений пула БД")
+	flag.StringVar(&flagConfig.DBStatementCacheMode, "dbscm", "cache_statement", "Режим выполнения запросов к БД: cache_statement, cache_describe, describe_exec, exec или simple_protocol")
+	flag.DurationVar(&flagConfig.DBPingTimeout, "dbpt", 2*time.Second, "Время ожидания ответа БД при проверке подключения")
+	flag.IntVar(&flagConfig.DBRetryAttempts, "dbra", 3, "Сколько раз повторять запрос к БД после временной ошибки, 0 - без повторов")
+	flag.DurationVar(&flagConfig.DBRetryDelay, "dbrd", 50*time.Millisecond, "Начальная задержка повтора запроса к БД")
+	flag.DurationVar(&flagConfig.DBRetryMaxDelay, "dbrmd", time.Second, "Максимальная задержка повтора запроса к БД")
+	flag.IntVar(&flagConfig.DBBreakerThreshold, "dbbt", 5, "После скольких подряд ошибок соединения не обращаться к БД, 0 - без выключателя")
+	flag.DurationVar(&flagConfig.DBBreakerTimeout, "dbbto", 10*time.Second, "Сколько времени не обращаться к БД после срабатывания выключателя")
+	flag.StringVar(&flagConfig.AdminAddress, "aa", "", "Адрес служебного сервера с метриками: host:port или unix:/путь/до/сокета, пусто - не запускать")
+
+	flag.StringVar(&flagConfig.ConfigFile, "config", "", "Путь до файла конфигурации JSON или YAML, старшинство: по умолчанию < файл < окружение < флаги")
 
 	flag.Parse()
+
+	flagConfig.SetFlags = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		flagConfig.SetFlags[f.Name] = true
+	})
+
+	flagConfig.CommandArgs = flag.Args()
 }
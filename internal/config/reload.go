@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Обработчик перезагрузки конфигурации, получает уже обновленные настройки
+type ReloadSubscriber func(configApp ConfigTypeInterface)
+
+// Настройки, которые меняются без перезапуска сервиса
+// Ключ - название настройки в файле конфигурации, значение переносит настройку в работающую конфигурацию
+// и возвращает false, если именно такое изменение возможно только с перезапуском
+var listReloadable = map[string]func(target, source *ConfigType) bool{
+	"level_logs": func(target, source *ConfigType) bool {
+		target.levelLogs = source.levelLogs
+		return true
+	},
+	"max_url_length": func(target, source *ConfigType) bool {
+		target.maxURLLength = source.maxURLLength
+		return true
+	},
+	// размер работающего кеша меняется, но включить или выключить кеш можно только при запуске
+	"cache_size": func(target, source *ConfigType) bool {
+		if (target.cacheSize > 0) != (source.cacheSize > 0) {
+			return false
+		}
+		target.cacheSize = source.cacheSize
+		return true
+	},
+	"cache_ttl": func(target, source *ConfigType) bool {
+		target.cacheTTL = source.cacheTTL
+		return true
+	},
+	"cache_negative_ttl": func(target, source *ConfigType) bool {
+		target.cacheNegativeTTL = source.cacheNegativeTTL
+		return true
+	},
+	"admin_token": func(target, source *ConfigType) bool {
+		target.adminToken = source.adminToken
+		return true
+	},
+}
+
+// переменные окружения и флаги общие для процесса, поэтому конфигурации перечитываются по очереди
+var mutexReload sync.Mutex
+
+// Подписчики перезагрузки конфигурации в порядке подписки
+// По номеру подписки она отменяется
+type reloadSubscribers struct {
+	mutex  sync.Mutex
+	lastID int
+	list   []reloadSubscription
+}
+
+type reloadSubscription struct {
+	id         int
+	subscriber ReloadSubscriber
+}
+
+// Подписка на перезагрузку общей конфигурации приложения
+func Subscribe(subscriber ReloadSubscriber) (unsubscribe func()) {
+	GetAppConfig()
+	return appConfig.Subscribe(subscriber)
+}
+
+// Перезагрузка общей конфигурации приложения
+func Reload() (listChanged []string, listWarnings []string, err error) {
+	GetAppConfig()
+	return appConfig.Reload()
+}
+
+// Подписка на перезагрузку конфигурации
+// Подписчик вызывается, только если изменилась хотя бы одна настройка из перезагружаемых.
+// Возвращается функция отмены подписки, владелец подписчика вызывает ее при остановке.
+func (ct *ConfigType) Subscribe(subscriber ReloadSubscriber) (unsubscribe func()) {
+
+	ct.subscribers.mutex.Lock()
+	defer ct.subscribers.mutex.Unlock()
+
+	ct.subscribers.lastID++
+	id := ct.subscribers.lastID
+	ct.subscribers.list = append(ct.subscribers.list, reloadSubscription{id: id, subscriber: subscriber})
+
+	return func() {
+		ct.subscribers.mutex.Lock()
+		defer ct.subscribers.mutex.Unlock()
+		for i, subscription := range ct.subscribers.list {
+			if subscription.id == id {
+				ct.subscribers.list = append(ct.subscribers.list[:i:i], ct.subscribers.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Перезагрузка конфигурации: заново читаются файл конфигурации и переменные окружения
+// Если новые настройки не прошли проверку, то работающая конфигурация не меняется и возвращается ошибка.
+// Перезагружаемые настройки меняются разом, для остальных изменений возвращаются предупреждения,
+// такие настройки начнут действовать только после перезапуска.
+func (ct *ConfigType) Reload() (listChanged []string, listWarnings []string, err error) {
+
+	mutexReload.Lock()
+	defer mutexReload.Unlock()
+
+	reloadEnviroment()
+	newConfig := &ConfigType{}
+	err = newConfig.loadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка: новая конфигурация не применена: %w", err)
+	}
+
+	listChanged, listWarnings = ct.applyReload(newConfig)
+	if len(listChanged) == 0 {
+		return
+	}
+
+	// подписчиков вызываем без блокировки: подписчик может сам отменить подписку
+	ct.subscribers.mutex.Lock()
+	subscriptions := append([]reloadSubscription{}, ct.subscribers.list...)
+	ct.subscribers.mutex.Unlock()
+	for _, subscription := range subscriptions {
+		subscription.subscriber(ct)
+	}
+	return
+}
+
+// Перенос изменившихся перезагружаемых настроек под одной блокировкой
+// listChanged - примененные настройки, listWarnings - изменения, для которых нужен перезапуск
+func (ct *ConfigType) applyReload(newConfig *ConfigType) (listChanged []string, listWarnings []string) {
+
+	listDiff := getDiffConfig(getSnapshotConfig(ct, false), getSnapshotConfig(newConfig, false))
+
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	for _, name := range listDiff {
+		if applyValue, ok := listReloadable[name]; ok && applyValue(ct, newConfig) {
+			listChanged = append(listChanged, name)
+			continue
+		}
+		listWarnings = append(listWarnings, fmt.Sprintf("настройка %s изменена, но начнет действовать только после перезапуска", name))
+	}
+	return
+}
+
+// Названия настроек, значения которых отличаются
+func getDiffConfig(oldConfig, newConfig FileConfigType) (listDiff []string) {
+
+	valueOld := reflect.ValueOf(oldConfig)
+	valueNew := reflect.ValueOf(newConfig)
+	typeConfig := valueOld.Type()
+	for i := 0; i < typeConfig.NumField(); i++ {
+		fieldOld := valueOld.Field(i)
+		fieldNew := valueNew.Field(i)
+		if fieldOld.IsNil() == fieldNew.IsNil() && (fieldOld.IsNil() || fieldOld.Elem().Interface() == fieldNew.Elem().Interface()) {
+			continue
+		}
+		listDiff = append(listDiff, strings.Split(typeConfig.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return
+}
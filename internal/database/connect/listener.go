@@ -0,0 +1,135 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"go-url-shortener/internal/logger"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// начальная задержка переподключения слушателя, удваивается после каждой неудачи
+	listenerMinBackoff = 500 * time.Millisecond
+	// максимальная задержка переподключения слушателя
+	listenerMaxBackoff = 30 * time.Second
+)
+
+// обработчик уведомления из канала
+type HandlerNotification func(payload string)
+
+// обработчик (пере)подключения к каналу
+// Пока слушатель не был подключен, уведомления могли быть пропущены, поэтому данные надо перечитать полностью
+type HandlerResync func()
+
+// Слушатель канала уведомлений БД (LISTEN/NOTIFY)
+// Для прослушивания нужно отдельное соединение, поэтому слушатель не использует пул соединений DBHandler.
+type Listener struct {
+	databaseDsn string
+	channel     string
+
+	handlerNotification HandlerNotification
+	handlerResync       HandlerResync
+
+	cancel context.CancelFunc
+	wait   sync.WaitGroup
+	// соединение, при закрытии которого останавливается слушатель
+	dbHandler *DBHandler
+}
+
+// Начинаем слушать канал уведомлений в отдельной горутине
+// Слушатель останавливается при закрытии соединения с БД
+func (dbHandler *DBHandler) Listen(channel string, handlerNotification HandlerNotification, handlerResync HandlerResync) (listener *Listener, err error) {
+
+	if !dbHandler.isReady() {
+		err = errors.New("ошибка: нельзя слушать канал уведомлений, соединение с базой данных не установлено")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listener = &Listener{
+		databaseDsn:         dbHandler.databaseDsn,
+		channel:             channel,
+		handlerNotification: handlerNotification,
+		handlerResync:       handlerResync,
+		cancel:              cancel,
+		dbHandler:           dbHandler,
+	}
+
+	dbHandler.mutexListeners.Lock()
+	dbHandler.listeners = append(dbHandler.listeners, listener)
+	dbHandler.mutexListeners.Unlock()
+
+	listener.wait.Add(1)
+	go listener.run(ctx)
+
+	logger.GetLogger().Debug("Слушаем канал уведомлений БД: " + channel)
+	return listener, nil
+}
+
+// Остановка слушателя, остановленный слушатель соединению больше не нужен
+func (listener *Listener) Close() {
+	listener.cancel()
+	listener.wait.Wait()
+	listener.dbHandler.removeListener(listener)
+}
+
+// Прослушивание канала с переподключением
+func (listener *Listener) run(ctx context.Context) {
+	defer listener.wait.Done()
+
+	backoff := listenerMinBackoff
+	for {
+		err := listener.listen(ctx, func() {
+			backoff = listenerMinBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logger.GetLogger().Warnf("Потеряно соединение с каналом уведомлений БД %s, переподключение через %s: %s", listener.channel, backoff, err.Error())
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > listenerMaxBackoff {
+			backoff = listenerMaxBackoff
+		}
+	}
+}
+
+// Одно подключение к каналу, работает до ошибки соединения
+func (listener *Listener) listen(ctx context.Context, onConnected func()) (err error) {
+
+	conn, err := pgx.Connect(ctx, listener.databaseDsn)
+	if err != nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{listener.channel}.Sanitize())
+	if err != nil {
+		return
+	}
+	onConnected()
+
+	// уведомления до подключения пропущены
+	if listener.handlerResync != nil {
+		listener.handlerResync()
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		listener.handlerNotification(notification.Payload)
+	}
+}
@@ -0,0 +1,60 @@
+package connect
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+)
+
+// Название канала уведомлений об изменениях в таблице коротких ссылок
+// Для таблицы со схемой точка заменяется подчеркиванием: changes_schema_table
+func GetChannelChanges(tableName string) string {
+	return strings.ToLower("changes_" + strings.ReplaceAll(tableName, ".", "_"))
+}
+
+// уведомление в том виде, как его отправляет триггер
+type payloadChanges struct {
+	Operation    string `json:"op"`
+	ID           string `json:"id"`
+	ShortLink    string `json:"short_link"`
+	FullURL      string `json:"full_url"`
+	OldShortLink string `json:"old_short_link"`
+	WorkspaceID  string `json:"workspace_id"`
+	ShortDomain  string `json:"short_domain"`
+	Disabled     bool   `json:"disabled"`
+}
+
+// Разбор уведомления об изменении таблицы коротких ссылок
+func ParseNotificationChange(payload string) (change modelsStorage.ChangeShortLink, err error) {
+
+	dataPayload := payloadChanges{}
+	err = json.Unmarshal([]byte(payload), &dataPayload)
+	if err != nil {
+		err = fmt.Errorf("ошибка: не смогли разобрать уведомление об изменении коротких ссылок: %w", err)
+		return
+	}
+
+	switch dataPayload.Operation {
+	case modelsStorage.ChangeOperationInsert, modelsStorage.ChangeOperationUpdate,
+		modelsStorage.ChangeOperationDelete, modelsStorage.ChangeOperationResync:
+	default:
+		err = fmt.Errorf("ошибка: неизвестный вид изменения коротких ссылок: %s", dataPayload.Operation)
+		return
+	}
+
+	change = modelsStorage.ChangeShortLink{
+		Operation: dataPayload.Operation,
+		Row: modelsStorage.RowStorageShortLink{
+			ShortLink:   dataPayload.ShortLink,
+			FullURL:     dataPayload.FullURL,
+			UUID:        dataPayload.ID,
+			WorkspaceID: dataPayload.WorkspaceID,
+			Domain:      dataPayload.ShortDomain,
+			Disabled:    dataPayload.Disabled,
+		},
+		OldShortLink: dataPayload.OldShortLink,
+	}
+	return
+}
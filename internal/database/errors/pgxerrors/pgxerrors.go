@@ -1,33 +1,99 @@
-package pgxerrors
-
-import (
-	"errors"
-
-	"github.com/jackc/pgerrcode"
-	pgx "github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
-)
-
-func getCodeUniqueViolation() string {
-	return pgerrcode.UniqueViolation
-}
-
-func isEqualSQLErrorToCode(sqlErr error, errCode string) (isEqual bool, err error) {
-	if sqlErr != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(sqlErr, &pgErr) {
-			isEqual = pgErr.SQLState() == errCode
-			return
-		}
-	}
-	return
-}
-
-func IsUniqueViolation(sqlErr error) (isOk bool, err error) {
-	codeErr := getCodeUniqueViolation()
-	return isEqualSQLErrorToCode(sqlErr, codeErr)
-}
-
-func IsErrTxCommitRollback(sqlErr error) (isOk bool, err error) {
-	return errors.Is(sqlErr, pgx.ErrTxCommitRollback), nil
-}
+package pgxerrors
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/jackc/pgerrcode"
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func getCodeUniqueViolation() string {
+	return pgerrcode.UniqueViolation
+}
+
+func isEqualSQLErrorToCode(sqlErr error, errCode string) (isEqual bool, err error) {
+	if sqlErr != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(sqlErr, &pgErr) {
+			isEqual = pgErr.SQLState() == errCode
+			return
+		}
+	}
+	return
+}
+
+func IsUniqueViolation(sqlErr error) (isOk bool, err error) {
+	codeErr := getCodeUniqueViolation()
+	return isEqualSQLErrorToCode(sqlErr, codeErr)
+}
+
+// Имя ограничения или уникального индекса, который нарушил запрос
+// Пустая строка, если ошибка не от сервера БД
+func GetConstraintName(sqlErr error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(sqlErr, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+func IsErrTxCommitRollback(sqlErr error) (isOk bool, err error) {
+	return errors.Is(sqlErr, pgx.ErrTxCommitRollback), nil
+}
+
+// Ошибки, после которых запрос можно повторить: конфликт сериализации, взаимная блокировка
+// и потеря соединения с БД
+func IsRetryable(sqlErr error) (isOk bool, err error) {
+	if sqlErr == nil {
+		return
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(sqlErr, &pgErr) {
+		switch pgErr.SQLState() {
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected:
+			return true, nil
+		}
+	}
+
+	return IsConnectionError(sqlErr)
+}
+
+// Ошибки недоступности БД: разрыв или отказ в соединении, остановка сервера БД
+// По ним автоматический выключатель понимает, что БД не работает
+func IsConnectionError(sqlErr error) (isOk bool, err error) {
+	if sqlErr == nil {
+		return
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(sqlErr, &pgErr) {
+		codeErr := pgErr.SQLState()
+		isOk = pgerrcode.IsConnectionException(codeErr) ||
+			codeErr == pgerrcode.AdminShutdown ||
+			codeErr == pgerrcode.CrashShutdown ||
+			codeErr == pgerrcode.CannotConnectNow
+		return
+	}
+
+	// драйвер сам сообщает, что запрос не был отправлен на сервер
+	if pgconn.SafeToRetry(sqlErr) {
+		return true, nil
+	}
+
+	if errors.Is(sqlErr, io.EOF) || errors.Is(sqlErr, io.ErrUnexpectedEOF) ||
+		errors.Is(sqlErr, syscall.ECONNRESET) || errors.Is(sqlErr, syscall.ECONNREFUSED) ||
+		errors.Is(sqlErr, syscall.EPIPE) {
+		return true, nil
+	}
+
+	var netErr *net.OpError
+	if errors.As(sqlErr, &netErr) {
+		return true, nil
+	}
+	return
+}
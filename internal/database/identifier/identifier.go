@@ -0,0 +1,98 @@
+package identifier
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Максимальная длина идентификатора в Postgres, длинные имена сервер обрезает
+const MaxLengthIdentifier = 63
+
+// префикс канала уведомлений об изменениях таблицы, имя канала не должно обрезаться
+const prefixChannel = "changes"
+
+// допустимая часть имени таблицы: латиница, цифры и подчеркивание, не начинается с цифры
+var patternIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Имя таблицы, возможно со схемой: schema.table
+// Части имени приводятся к нижнему регистру, как Postgres делает с именами без кавычек,
+// поэтому таблицы, созданные раньше без кавычек, находятся по тому же имени.
+type TableName struct {
+	Schema string
+	Name   string
+}
+
+// Разбор и проверка имени таблицы из конфигурации
+func ParseTableName(value string) (tableName TableName, err error) {
+
+	parts := strings.Split(value, ".")
+	if len(parts) > 2 {
+		err = fmt.Errorf("ошибка: некорректное имя таблицы %q, допустимый вид: table или schema.table", value)
+		return
+	}
+
+	for _, part := range parts {
+		if !patternIdentifier.MatchString(part) {
+			err = fmt.Errorf("ошибка: некорректное имя таблицы %q, допустимы латинские буквы, цифры и подчеркивание, имя не должно начинаться с цифры", value)
+			return
+		}
+		if len(part) > MaxLengthIdentifier {
+			err = fmt.Errorf("ошибка: некорректное имя таблицы %q, часть имени длиннее %d символов", value, MaxLengthIdentifier)
+			return
+		}
+	}
+
+	if len(parts) == 2 {
+		tableName.Schema = strings.ToLower(parts[0])
+	}
+	tableName.Name = strings.ToLower(parts[len(parts)-1])
+
+	// по имени таблицы строится имя канала уведомлений
+	if len(tableName.JoinName(prefixChannel)) > MaxLengthIdentifier {
+		err = fmt.Errorf("ошибка: некорректное имя таблицы %q, слишком длинное имя", value)
+		return
+	}
+	return
+}
+
+// Имя таблицы в виде schema.table или table
+func (tableName TableName) String() string {
+	if tableName.Schema == "" {
+		return tableName.Name
+	}
+	return tableName.Schema + "." + tableName.Name
+}
+
+// Имя таблицы в кавычках для подстановки в SQL
+func (tableName TableName) Sanitize() string {
+	return pgx.Identifier(tableName.getParts()).Sanitize()
+}
+
+// Имя объекта, который относится к таблице: prefix_schema_table или prefix_table
+// Имя состоит только из допустимых символов, поэтому его можно использовать без кавычек
+func (tableName TableName) JoinName(prefix string) string {
+	return strings.ToLower(strings.Join(append([]string{prefix}, tableName.getParts()...), "_"))
+}
+
+// Имя объекта таблицы в кавычках без схемы, например для CREATE INDEX
+func (tableName TableName) SanitizeObject(prefix string) string {
+	return pgx.Identifier{tableName.JoinName(prefix)}.Sanitize()
+}
+
+// Имя объекта таблицы в кавычках в схеме таблицы, например для DROP INDEX
+func (tableName TableName) SanitizeQualifiedObject(prefix string) string {
+	if tableName.Schema == "" {
+		return tableName.SanitizeObject(prefix)
+	}
+	return pgx.Identifier{tableName.Schema, tableName.JoinName(prefix)}.Sanitize()
+}
+
+func (tableName TableName) getParts() []string {
+	if tableName.Schema == "" {
+		return []string{tableName.Name}
+	}
+	return []string{tableName.Schema, tableName.Name}
+}
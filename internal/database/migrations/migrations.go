@@ -0,0 +1,445 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/database/identifier"
+	"go-url-shortener/internal/logger"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SQL файлы миграций вида <версия>_<название>.up.sql и <версия>_<название>.down.sql
+// В тексте доступны подстановки {{.Table}} - таблица коротких ссылок в кавычках, {{.Channel}} - канал уведомлений,
+// {{.Function}} - функция уведомлений, {{.Index "префикс"}} и {{.QualifiedIndex "префикс"}} - индексы таблицы
+//
+// Добавленная миграция не меняется: в базах, где она уже применена, она повторно не выполняется.
+// Схема меняется только новой миграцией со следующим номером. Общих частей у миграций нет:
+// например, функция уведомлений полностью записана в каждой миграции, которая ее меняет,
+// чтобы правка одной миграции не меняла текст уже примененных. Это проверяют контрольные суммы в тестах миграций.
+// Исключение - переход 0001-0003 на подстановки {{.Index}} и {{.Function}} вместо имен из {{.Table}}:
+// для имен таблиц, которые работали раньше, они дают те же индексы и функцию в нижнем регистре.
+// И 0004 вместо переименования дублей коротких ссылок завершается ошибкой: в базах,
+// где она уже применена, дублей нет, а без дублей она работает как прежде.
+//
+//go:embed sql/*.sql
+var filesMigrations embed.FS
+
+// таблица с примененными миграциями, общая для всех таблиц коротких ссылок
+const nameTableMigrations = "schema_migrations"
+
+// ключ блокировки, чтобы несколько экземпляров сервиса не выполняли миграции одновременно
+const keyAdvisoryLock = "go-url-shortener-migrations"
+
+// Префиксы уникальных индексов таблицы коротких ссылок после последней миграции
+// По имени нарушенного индекса хранилища отличают дубль полной ссылки от занятой короткой ссылки
+const (
+	PrefixIndexFullURL   = "DOMAIN_FULL_URL_HASH_index"
+	PrefixIndexShortLink = "DOMAIN_SHORT_LINK_index"
+)
+
+// Префиксы всех индексов, которые создают и удаляют миграции
+// Подстановка {{.Index}} с другим префиксом - ошибка, чтобы длина имен индексов всегда проверялась
+var listPrefixesIndex = []string{
+	"SHORT_LINK_index",
+	"FULL_URL_index",
+	"FULL_URL_HASH_index",
+	"WORKSPACE_SHORT_LINK_index",
+	"WORKSPACE_FULL_URL_HASH_index",
+	PrefixIndexShortLink,
+	PrefixIndexFullURL,
+}
+
+// Одна миграция
+type Migration struct {
+	Version int
+	Name    string
+	UpSQL   string
+	DownSQL string
+}
+
+// Состояние миграции для таблицы
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	IsApplied bool
+	AppliedAt time.Time
+}
+
+// Выполнение миграций для одной таблицы коротких ссылок
+type Migrator struct {
+	db *pgxpool.Pool
+	// имя таблицы schema.table или table, под ним миграции отмечаются в таблице миграций
+	nameTable  string
+	migrations []Migration
+}
+
+// Подстановки в SQL файлы миграций
+type dataTemplate struct {
+	tableName identifier.TableName
+
+	Table    string
+	Channel  string
+	Function string
+}
+
+// Индекс таблицы без схемы, для CREATE INDEX
+func (data dataTemplate) Index(prefix string) (string, error) {
+	err := checkPrefixIndex(prefix)
+	if err != nil {
+		return "", err
+	}
+	return data.tableName.SanitizeObject(prefix), nil
+}
+
+// Индекс таблицы в ее схеме, для DROP INDEX
+func (data dataTemplate) QualifiedIndex(prefix string) (string, error) {
+	err := checkPrefixIndex(prefix)
+	if err != nil {
+		return "", err
+	}
+	return data.tableName.SanitizeQualifiedObject(prefix), nil
+}
+
+func checkPrefixIndex(prefix string) error {
+	for _, prefixIndex := range listPrefixesIndex {
+		if prefix == prefixIndex {
+			return nil
+		}
+	}
+	return fmt.Errorf("ошибка: префикс индекса %q не указан в listPrefixesIndex", prefix)
+}
+
+// Разбор имени таблицы коротких ссылок с проверкой имен, которые миграции строят по нему:
+// индексов, канала, функции и триггеров уведомлений.
+// Postgres обрезает имена длиннее 63 символов, тогда миграции не выполняются,
+// а хранилища не узнают нарушенный уникальный индекс по имени.
+func ParseTableName(value string) (tableName identifier.TableName, err error) {
+
+	tableName, err = identifier.ParseTableName(value)
+	if err != nil {
+		return
+	}
+
+	channel := dbconn.GetChannelChanges(tableName.String())
+	listNames := []string{channel, getNameFunction(channel), getNameFunction(channel) + "_truncate"}
+	for _, prefix := range listPrefixesIndex {
+		listNames = append(listNames, tableName.JoinName(prefix))
+	}
+	for _, name := range listNames {
+		if len(name) > identifier.MaxLengthIdentifier {
+			return tableName, fmt.Errorf("ошибка: некорректное имя таблицы %q, слишком длинное имя: по нему строится %s длиннее %d символов", value, name, identifier.MaxLengthIdentifier)
+		}
+	}
+	return
+}
+
+// Функция уведомлений, триггеры уведомлений называются так же
+func getNameFunction(channel string) string {
+	return "notify_" + channel
+}
+
+// Создание объекта миграций для таблицы коротких ссылок
+func NewMigrator(db *pgxpool.Pool, nameTable string) (migrator *Migrator, err error) {
+
+	if db == nil {
+		return nil, errors.New("ошибка: не установлено соединение с базой данных для миграций")
+	}
+
+	tableName, err := ParseTableName(nameTable)
+	if err != nil {
+		return nil, err
+	}
+
+	listMigrations, err := loadMigrations(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator = &Migrator{
+		db:         db,
+		nameTable:  tableName.String(),
+		migrations: listMigrations,
+	}
+	return migrator, nil
+}
+
+// Применяем все миграции таблицы через переданный пул соединений с БД
+// Вызывается при создании хранилищ, работающих с БД
+func MigrateTable(db *pgxpool.Pool, nameTable string) (err error) {
+
+	migrator, err := NewMigrator(db, nameTable)
+	if err != nil {
+		return
+	}
+	_, err = migrator.Up(context.TODO())
+	return
+}
+
+// Загрузка миграций из встроенных файлов
+func loadMigrations(tableName identifier.TableName) (listMigrations []Migration, err error) {
+
+	channel := dbconn.GetChannelChanges(tableName.String())
+	dataMigration := dataTemplate{
+		tableName: tableName,
+		Table:     tableName.Sanitize(),
+		Channel:   channel,
+		Function:  pgx.Identifier{tableName.Schema, getNameFunction(channel)}.Sanitize(),
+	}
+	if tableName.Schema == "" {
+		dataMigration.Function = pgx.Identifier{getNameFunction(channel)}.Sanitize()
+	}
+
+	listFiles, err := fs.Glob(filesMigrations, "sql/*.sql")
+	if err != nil {
+		return
+	}
+
+	mapMigrations := make(map[int]*Migration)
+	for _, pathFile := range listFiles {
+
+		nameFile := path.Base(pathFile)
+		isUp := strings.HasSuffix(nameFile, ".up.sql")
+		isDown := strings.HasSuffix(nameFile, ".down.sql")
+		if !isUp && !isDown {
+			return nil, fmt.Errorf("ошибка: файл миграции %s должен заканчиваться на .up.sql или .down.sql", nameFile)
+		}
+
+		nameMigration := strings.TrimSuffix(strings.TrimSuffix(nameFile, ".up.sql"), ".down.sql")
+		partsName := strings.SplitN(nameMigration, "_", 2)
+		version, errParse := strconv.Atoi(partsName[0])
+		if errParse != nil || len(partsName) < 2 {
+			return nil, fmt.Errorf("ошибка: файл миграции %s должен называться <версия>_<название>", nameFile)
+		}
+
+		dataFile, errRead := filesMigrations.ReadFile(pathFile)
+		if errRead != nil {
+			return nil, errRead
+		}
+		textTemplate, errParse := template.New(nameFile).Parse(string(dataFile))
+		if errParse != nil {
+			return nil, fmt.Errorf("ошибка разбора файла миграции %s: %w", nameFile, errParse)
+		}
+		textSQL := bytes.Buffer{}
+		err = textTemplate.Execute(&textSQL, dataMigration)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка подстановки в файл миграции %s: %w", nameFile, err)
+		}
+
+		migration, ok := mapMigrations[version]
+		if !ok {
+			migration = &Migration{
+				Version: version,
+				Name:    partsName[1],
+			}
+			mapMigrations[version] = migration
+		}
+		if isUp {
+			migration.UpSQL = textSQL.String()
+		} else {
+			migration.DownSQL = textSQL.String()
+		}
+	}
+
+	for _, migration := range mapMigrations {
+		if migration.UpSQL == "" || migration.DownSQL == "" {
+			return nil, fmt.Errorf("ошибка: у миграции %d_%s должны быть файлы .up.sql и .down.sql", migration.Version, migration.Name)
+		}
+		listMigrations = append(listMigrations, *migration)
+	}
+	sort.Slice(listMigrations, func(i, j int) bool {
+		return listMigrations[i].Version < listMigrations[j].Version
+	})
+	return
+}
+
+// Список встроенных миграций
+func (migrator *Migrator) GetMigrations() []Migration {
+	return migrator.migrations
+}
+
+// Выполнение функции под блокировкой миграций
+// Блокировка действует в пределах сессии, поэтому держим одно соединение до конца работы
+func (migrator *Migrator) withLock(ctx context.Context, handler func(conn *pgxpool.Conn) error) (err error) {
+
+	conn, err := migrator.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка: не смогли получить соединение для миграций: %w", err)
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock(hashtext($1))", keyAdvisoryLock)
+	if err != nil {
+		return fmt.Errorf("ошибка: не смогли получить блокировку миграций: %w", err)
+	}
+	defer func() {
+		_, errUnlock := conn.Exec(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", keyAdvisoryLock)
+		if errUnlock != nil {
+			logger.GetLogger().Error("ошибка: не смогли снять блокировку миграций: " + errUnlock.Error())
+		}
+	}()
+
+	sqlCreateTable := "" +
+		"CREATE TABLE IF NOT EXISTS " + nameTableMigrations + " (" +
+		"	TABLE_NAME varchar(255) NOT NULL," +
+		"	VERSION integer NOT NULL," +
+		"	NAME varchar(255) NOT NULL," +
+		"	APPLIED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP," +
+		"	PRIMARY KEY (TABLE_NAME, VERSION)" +
+		")"
+	_, err = conn.Exec(ctx, sqlCreateTable)
+	if err != nil {
+		return fmt.Errorf("ошибка: не смогли создать таблицу миграций: %w", err)
+	}
+
+	return handler(conn)
+}
+
+// Примененные миграции таблицы: версия -> время применения
+func (migrator *Migrator) readApplied(ctx context.Context, conn *pgxpool.Conn) (applied map[int]time.Time, err error) {
+
+	sqlSelect := "SELECT VERSION, APPLIED_AT FROM " + nameTableMigrations + " WHERE TABLE_NAME=$1"
+	rows, err := conn.Query(ctx, sqlSelect, migrator.nameTable)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	applied = make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return
+		}
+		applied[version] = appliedAt
+	}
+	err = rows.Err()
+	return
+}
+
+// Выполнение одной миграции в транзакции вместе с отметкой в таблице миграций
+func (migrator *Migrator) execMigration(ctx context.Context, conn *pgxpool.Conn, textSQL, sqlMark string, argsMark ...any) (err error) {
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.GetLogger().Error("ошибка: не смогли открыть транзакцию: " + err.Error())
+		return
+	}
+
+	_, err = tx.Exec(ctx, textSQL)
+	if err == nil {
+		_, err = tx.Exec(ctx, sqlMark, argsMark...)
+	}
+	if err != nil {
+		errRoll := tx.Rollback(ctx)
+		if errRoll != nil {
+			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
+		}
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.GetLogger().Error("ошибка: не смогли сделать commit транзакции: " + err.Error())
+	}
+	return
+}
+
+// Применение всех новых миграций
+func (migrator *Migrator) Up(ctx context.Context) (countApplied int, err error) {
+
+	err = migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+
+		applied, err := migrator.readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			sqlMark := "INSERT INTO " + nameTableMigrations + " (TABLE_NAME, VERSION, NAME) VALUES ($1, $2, $3)"
+			err = migrator.execMigration(ctx, conn, migration.UpSQL, sqlMark, migrator.nameTable, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("ошибка применения миграции %d_%s к таблице %s: %w", migration.Version, migration.Name, migrator.nameTable, err)
+			}
+			logger.GetLogger().Infof("Применена миграция %d_%s к таблице %s", migration.Version, migration.Name, migrator.nameTable)
+			countApplied++
+		}
+		return nil
+	})
+	return
+}
+
+// Откат последних примененных миграций
+// steps - сколько миграций откатить
+func (migrator *Migrator) Down(ctx context.Context, steps int) (countRolledBack int, err error) {
+
+	if steps <= 0 {
+		return 0, errors.New("ошибка: количество откатываемых миграций должно быть больше нуля")
+	}
+
+	err = migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+
+		applied, err := migrator.readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrator.migrations) - 1; i >= 0 && countRolledBack < steps; i-- {
+			migration := migrator.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			sqlMark := "DELETE FROM " + nameTableMigrations + " WHERE TABLE_NAME=$1 AND VERSION=$2"
+			err = migrator.execMigration(ctx, conn, migration.DownSQL, sqlMark, migrator.nameTable, migration.Version)
+			if err != nil {
+				return fmt.Errorf("ошибка отката миграции %d_%s у таблицы %s: %w", migration.Version, migration.Name, migrator.nameTable, err)
+			}
+			logger.GetLogger().Infof("Откачена миграция %d_%s у таблицы %s", migration.Version, migration.Name, migrator.nameTable)
+			countRolledBack++
+		}
+		return nil
+	})
+	return
+}
+
+// Состояние всех миграций таблицы
+func (migrator *Migrator) Status(ctx context.Context) (listStatus []MigrationStatus, err error) {
+
+	err = migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+
+		applied, err := migrator.readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			appliedAt, isApplied := applied[migration.Version]
+			listStatus = append(listStatus, MigrationStatus{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				IsApplied: isApplied,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+	return
+}
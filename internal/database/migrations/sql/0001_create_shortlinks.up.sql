@@ -0,0 +1,13 @@
+-- таблица коротких ссылок
+CREATE TABLE IF NOT EXISTS {{.Table}} (
+	ID SERIAL PRIMARY KEY,
+	FULL_URL varchar(255),
+	SHORT_LINK varchar(255),
+	CREATED TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- индекс для быстрого поиска полной ссылки по короткой ссылке
+CREATE INDEX IF NOT EXISTS {{.Index "SHORT_LINK_index"}} ON {{.Table}} (SHORT_LINK);
+
+-- уникальный индекс поля FULL_URL как ограничение для целостности данных
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "FULL_URL_index"}} ON {{.Table}} (FULL_URL);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS notify_{{.Channel}}_truncate ON {{.Table}};
+DROP TRIGGER IF EXISTS notify_{{.Channel}} ON {{.Table}};
+DROP FUNCTION IF EXISTS {{.Function}}();
@@ -0,0 +1,33 @@
+-- уведомления об изменениях таблицы для других экземпляров сервиса
+-- максимальный размер уведомления pg_notify - 8000 байт, если изменение не помещается,
+-- то отправляем только просьбу о полной синхронизации
+CREATE OR REPLACE FUNCTION {{.Function}}() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'TRUNCATE' THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	ELSIF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', TG_OP, 'old_short_link', OLD.SHORT_LINK)::text;
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL, 'old_short_link', OLD.SHORT_LINK)::text;
+	ELSE
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL)::text;
+	END IF;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	END IF;
+	PERFORM pg_notify('{{.Channel}}', payload);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS notify_{{.Channel}} ON {{.Table}};
+CREATE TRIGGER notify_{{.Channel}} AFTER INSERT OR UPDATE OR DELETE ON {{.Table}}
+	FOR EACH ROW EXECUTE PROCEDURE {{.Function}}();
+
+DROP TRIGGER IF EXISTS notify_{{.Channel}}_truncate ON {{.Table}};
+CREATE TRIGGER notify_{{.Channel}}_truncate AFTER TRUNCATE ON {{.Table}}
+	FOR EACH STATEMENT EXECUTE PROCEDURE {{.Function}}();
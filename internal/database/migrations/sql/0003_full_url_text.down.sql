@@ -0,0 +1,5 @@
+-- откат невозможен, если в таблице уже есть ссылки длиннее 255 символов
+DROP INDEX IF EXISTS {{.QualifiedIndex "FULL_URL_HASH_index"}};
+ALTER TABLE {{.Table}} DROP COLUMN IF EXISTS FULL_URL_HASH;
+ALTER TABLE {{.Table}} ALTER COLUMN FULL_URL TYPE varchar(255);
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "FULL_URL_index"}} ON {{.Table}} (FULL_URL);
@@ -0,0 +1,10 @@
+-- полные ссылки могут быть длиннее 255 символов
+ALTER TABLE {{.Table}} ALTER COLUMN FULL_URL TYPE text;
+
+-- уникальный индекс по самому FULL_URL не подходит для длинных строк (ограничение размера строки btree),
+-- поэтому уникальность проверяем по хешу ссылки
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS FULL_URL_HASH bytea
+	GENERATED ALWAYS AS (sha256(convert_to(FULL_URL, 'UTF8'))) STORED;
+
+DROP INDEX IF EXISTS {{.QualifiedIndex "FULL_URL_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "FULL_URL_HASH_index"}} ON {{.Table}} (FULL_URL_HASH);
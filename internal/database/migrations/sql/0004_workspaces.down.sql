@@ -0,0 +1,31 @@
+-- откат невозможен, если одна и та же ссылка уже есть в нескольких рабочих пространствах
+CREATE OR REPLACE FUNCTION {{.Function}}() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'TRUNCATE' THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	ELSIF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', TG_OP, 'old_short_link', OLD.SHORT_LINK)::text;
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL, 'old_short_link', OLD.SHORT_LINK)::text;
+	ELSE
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL)::text;
+	END IF;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	END IF;
+	PERFORM pg_notify('{{.Channel}}', payload);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS {{.QualifiedIndex "WORKSPACE_SHORT_LINK_index"}};
+CREATE INDEX IF NOT EXISTS {{.Index "SHORT_LINK_index"}} ON {{.Table}} (SHORT_LINK);
+
+DROP INDEX IF EXISTS {{.QualifiedIndex "WORKSPACE_FULL_URL_HASH_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "FULL_URL_HASH_index"}} ON {{.Table}} (FULL_URL_HASH);
+
+ALTER TABLE {{.Table}} DROP COLUMN IF EXISTS WORKSPACE_ID;
@@ -0,0 +1,56 @@
+-- рабочие пространства: у каждого свой набор коротких ссылок
+-- пустая строка - общее рабочее пространство, в нем остаются уже существующие ссылки
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS WORKSPACE_ID varchar(64) NOT NULL DEFAULT '';
+
+-- полная ссылка уникальна в пределах рабочего пространства
+DROP INDEX IF EXISTS {{.QualifiedIndex "FULL_URL_HASH_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "WORKSPACE_FULL_URL_HASH_index"}} ON {{.Table}} (WORKSPACE_ID, FULL_URL_HASH);
+
+-- до этой миграции короткая ссылка не была уникальной, дубли не дадут создать уникальный индекс
+-- переименовывать их нельзя: уже выданные короткие ссылки перестанут работать,
+-- поэтому миграция завершается ошибкой со списком дублей, их нужно разобрать вручную
+DO $$
+DECLARE
+	countDuplicates integer;
+	listDuplicates text;
+BEGIN
+	SELECT count(*), string_agg(format('%s (ID: %s)', D.SHORT_LINK, D.LIST_ID), ', ')
+	INTO countDuplicates, listDuplicates
+	FROM (SELECT SHORT_LINK, string_agg(ID::text, ', ' ORDER BY ID) AS LIST_ID
+		FROM {{.Table}} GROUP BY SHORT_LINK HAVING count(*) > 1 ORDER BY SHORT_LINK LIMIT 100) AS D;
+	IF countDuplicates > 0 THEN
+		RAISE EXCEPTION 'повторяющиеся SHORT_LINK, оставьте по одной строке на короткую ссылку: %', listDuplicates;
+	END IF;
+END;
+$$;
+
+-- короткая ссылка уникальна в пределах рабочего пространства и ищется вместе с ним
+DROP INDEX IF EXISTS {{.QualifiedIndex "SHORT_LINK_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "WORKSPACE_SHORT_LINK_index"}} ON {{.Table}} (WORKSPACE_ID, SHORT_LINK);
+
+-- в уведомлениях передаем рабочее пространство ссылки
+CREATE OR REPLACE FUNCTION {{.Function}}() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'TRUNCATE' THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	ELSIF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', TG_OP, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', OLD.WORKSPACE_ID)::text;
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', NEW.WORKSPACE_ID)::text;
+	ELSE
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL,
+			'workspace_id', NEW.WORKSPACE_ID)::text;
+	END IF;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	END IF;
+	PERFORM pg_notify('{{.Channel}}', payload);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
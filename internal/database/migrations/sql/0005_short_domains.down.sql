@@ -0,0 +1,34 @@
+-- откат невозможен, если одна и та же ссылка уже есть на нескольких доменах
+CREATE OR REPLACE FUNCTION {{.Function}}() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'TRUNCATE' THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	ELSIF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', TG_OP, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', OLD.WORKSPACE_ID)::text;
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', NEW.WORKSPACE_ID)::text;
+	ELSE
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL,
+			'workspace_id', NEW.WORKSPACE_ID)::text;
+	END IF;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	END IF;
+	PERFORM pg_notify('{{.Channel}}', payload);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS {{.QualifiedIndex "DOMAIN_SHORT_LINK_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "WORKSPACE_SHORT_LINK_index"}} ON {{.Table}} (WORKSPACE_ID, SHORT_LINK);
+
+DROP INDEX IF EXISTS {{.QualifiedIndex "DOMAIN_FULL_URL_HASH_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "WORKSPACE_FULL_URL_HASH_index"}} ON {{.Table}} (WORKSPACE_ID, FULL_URL_HASH);
+
+ALTER TABLE {{.Table}} DROP COLUMN IF EXISTS SHORT_DOMAIN;
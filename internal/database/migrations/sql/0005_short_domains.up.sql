@@ -0,0 +1,41 @@
+-- несколько доменов коротких ссылок: у каждого домена свой набор коротких ссылок
+-- пустая строка - домен из BASE_URL, на нем остаются уже существующие ссылки
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS SHORT_DOMAIN varchar(255) NOT NULL DEFAULT '';
+
+-- полная ссылка уникальна в пределах рабочего пространства и домена
+DROP INDEX IF EXISTS {{.QualifiedIndex "WORKSPACE_FULL_URL_HASH_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "DOMAIN_FULL_URL_HASH_index"}} ON {{.Table}} (WORKSPACE_ID, SHORT_DOMAIN, FULL_URL_HASH);
+
+-- короткая ссылка уникальна в пределах рабочего пространства и домена и ищется вместе с ними
+DROP INDEX IF EXISTS {{.QualifiedIndex "WORKSPACE_SHORT_LINK_index"}};
+CREATE UNIQUE INDEX IF NOT EXISTS {{.Index "DOMAIN_SHORT_LINK_index"}} ON {{.Table}} (WORKSPACE_ID, SHORT_DOMAIN, SHORT_LINK);
+
+-- в уведомлениях передаем домен ссылки
+CREATE OR REPLACE FUNCTION {{.Function}}() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'TRUNCATE' THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	ELSIF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', TG_OP, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', OLD.WORKSPACE_ID,
+			'short_domain', OLD.SHORT_DOMAIN)::text;
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', NEW.WORKSPACE_ID,
+			'short_domain', NEW.SHORT_DOMAIN)::text;
+	ELSE
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL,
+			'workspace_id', NEW.WORKSPACE_ID,
+			'short_domain', NEW.SHORT_DOMAIN)::text;
+	END IF;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	END IF;
+	PERFORM pg_notify('{{.Channel}}', payload);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
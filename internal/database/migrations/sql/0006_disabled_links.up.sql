@@ -0,0 +1,35 @@
+-- отключение коротких ссылок администратором: ссылка остается в таблице, но не открывается
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS DISABLED boolean NOT NULL DEFAULT false;
+
+-- в уведомлениях передаем признак отключения, чтобы его применили остальные экземпляры сервиса
+CREATE OR REPLACE FUNCTION {{.Function}}() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'TRUNCATE' THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	ELSIF TG_OP = 'DELETE' THEN
+		payload := json_build_object('op', TG_OP, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', OLD.WORKSPACE_ID,
+			'short_domain', OLD.SHORT_DOMAIN,
+			'disabled', OLD.DISABLED)::text;
+	ELSIF TG_OP = 'UPDATE' THEN
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL, 'old_short_link', OLD.SHORT_LINK,
+			'workspace_id', NEW.WORKSPACE_ID,
+			'short_domain', NEW.SHORT_DOMAIN,
+			'disabled', NEW.DISABLED)::text;
+	ELSE
+		payload := json_build_object('op', TG_OP, 'id', NEW.ID::text, 'short_link', NEW.SHORT_LINK,
+			'full_url', NEW.FULL_URL,
+			'workspace_id', NEW.WORKSPACE_ID,
+			'short_domain', NEW.SHORT_DOMAIN,
+			'disabled', NEW.DISABLED)::text;
+	END IF;
+	IF octet_length(payload) > 7900 THEN
+		payload := json_build_object('op', 'RESYNC')::text;
+	END IF;
+	PERFORM pg_notify('{{.Channel}}', payload);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,119 @@
+package retry
+
+import (
+	"errors"
+	"go-url-shortener/internal/logger"
+	"sync"
+	"time"
+)
+
+// ошибка, когда выключатель не пускает запросы в БД
+var ErrCircuitOpen = errors.New("ошибка: база данных недоступна, запросы временно не выполняются")
+
+// Состояния выключателя
+const (
+	// запросы идут в БД
+	StateClosed = "closed"
+	// запросы сразу завершаются ошибкой
+	StateOpen = "open"
+	// пробный запрос, по его результату выключатель закрывается или снова открывается
+	StateHalfOpen = "half-open"
+)
+
+// Автоматический выключатель запросов к БД
+// После threshold подряд ошибок соединения запросы timeout времени не выполняются,
+// затем пропускается один пробный запрос.
+type Breaker struct {
+	threshold int
+	timeout   time.Duration
+
+	mutex            sync.Mutex
+	state            string
+	countFailures    int
+	openedAt         time.Time
+	isTrialRunning   bool
+	countOpenedTotal int
+}
+
+// Создание выключателя
+func NewBreaker(threshold int, timeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		timeout:   timeout,
+		state:     StateClosed,
+	}
+}
+
+// Текущее состояние выключателя
+func (breaker *Breaker) GetState() string {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.state
+}
+
+// Сколько раз выключатель срабатывал
+func (breaker *Breaker) GetCountOpened() int {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.countOpenedTotal
+}
+
+// Можно ли выполнить запрос
+func (breaker *Breaker) Allow() (err error) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	switch breaker.state {
+	case StateOpen:
+		if time.Since(breaker.openedAt) < breaker.timeout {
+			return ErrCircuitOpen
+		}
+		breaker.state = StateHalfOpen
+		breaker.isTrialRunning = true
+		logger.GetLogger().Info("Выключатель запросов к БД пропускает пробный запрос")
+	case StateHalfOpen:
+		// пока идет пробный запрос, остальные не пускаем
+		if breaker.isTrialRunning {
+			return ErrCircuitOpen
+		}
+		breaker.isTrialRunning = true
+	}
+	return nil
+}
+
+// Результат запроса: isConnErr - запрос завершился ошибкой соединения с БД,
+// startedAt - когда запрос был пропущен выключателем
+func (breaker *Breaker) Done(isConnErr bool, startedAt time.Time) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	// запрос начался до срабатывания выключателя, его результат уже ничего не говорит о БД
+	if startedAt.Before(breaker.openedAt) {
+		return
+	}
+
+	if !isConnErr {
+		switch breaker.state {
+		case StateHalfOpen:
+			// закрываем выключатель только по успешному пробному запросу
+			logger.GetLogger().Info("Выключатель запросов к БД закрыт, база данных снова доступна")
+			breaker.state = StateClosed
+			breaker.countFailures = 0
+			breaker.isTrialRunning = false
+		case StateClosed:
+			breaker.countFailures = 0
+		}
+		return
+	}
+
+	breaker.countFailures++
+	if breaker.state == StateHalfOpen || breaker.countFailures >= breaker.threshold {
+		if breaker.state != StateOpen {
+			breaker.countOpenedTotal++
+			logger.GetLogger().Errorf("Выключатель запросов к БД открыт на %s после %d ошибок соединения", breaker.timeout, breaker.countFailures)
+		}
+		breaker.state = StateOpen
+		breaker.openedAt = time.Now()
+		breaker.isTrialRunning = false
+	}
+}
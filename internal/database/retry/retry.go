@@ -0,0 +1,127 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	errDriver "go-url-shortener/internal/database/errors/pgxerrors"
+	"go-url-shortener/internal/logger"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Операция с БД, которую можно повторить
+type Operation func(ctx context.Context) (err error)
+
+// Настройки повторов операций
+type Options struct {
+	// сколько раз повторяем операцию после первой неудачной попытки, 0 - без повторов
+	Attempts int
+	// начальная задержка перед повтором, удваивается после каждой неудачи
+	Delay time.Duration
+	// максимальная задержка перед повтором, 0 - без ограничения
+	MaxDelay time.Duration
+	// после скольких подряд ошибок соединения перестаем обращаться к БД, 0 - выключатель не используется
+	BreakerThreshold int
+	// сколько времени не обращаемся к БД после срабатывания выключателя
+	BreakerTimeout time.Duration
+}
+
+// Повтор операций с БД при временных ошибках
+// Повторяются только ошибки, после которых запрос можно безопасно выполнить снова,
+// остальные ошибки сразу возвращаются вызывающему.
+type Retrier struct {
+	options Options
+	breaker *Breaker
+
+	mutexRand sync.Mutex
+	randDelay *rand.Rand
+}
+
+// Создание объекта повторов
+func NewRetrier(options Options) (retrier *Retrier) {
+
+	retrier = &Retrier{
+		options:   options,
+		randDelay: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if options.BreakerThreshold > 0 {
+		retrier.breaker = NewBreaker(options.BreakerThreshold, options.BreakerTimeout)
+	}
+	return
+}
+
+// Автоматический выключатель, nil если он не используется
+func (retrier *Retrier) GetBreaker() *Breaker {
+	return retrier.breaker
+}
+
+// Выполнение идемпотентной операции с повторами при временных ошибках
+func (retrier *Retrier) Do(ctx context.Context, operation Operation) (err error) {
+	return retrier.do(ctx, operation, retrier.options.Attempts)
+}
+
+// Выполнение операции без повторов, например вставки, которую нельзя выполнить дважды
+// Выключатель все равно учитывает результат операции
+func (retrier *Retrier) DoOnce(ctx context.Context, operation Operation) (err error) {
+	return retrier.do(ctx, operation, 0)
+}
+
+func (retrier *Retrier) do(ctx context.Context, operation Operation, attempts int) (err error) {
+
+	for attempt := 0; ; attempt++ {
+
+		startedAt := time.Now()
+		if retrier.breaker != nil {
+			err = retrier.breaker.Allow()
+			if err != nil {
+				return
+			}
+		}
+
+		err = operation(ctx)
+
+		isConnErr, _ := errDriver.IsConnectionError(err)
+		if retrier.breaker != nil {
+			retrier.breaker.Done(isConnErr, startedAt)
+		}
+
+		isRetryable, _ := errDriver.IsRetryable(err)
+		if err == nil || !isRetryable || attempt >= attempts {
+			return
+		}
+
+		delay := retrier.getDelay(attempt)
+		logger.GetLogger().Debugf("Временная ошибка БД, повтор %d через %s: %s", attempt+1, delay, err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (повтор отменен: %s)", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Задержка перед повтором: случайная величина до экспоненциально растущей границы,
+// чтобы экземпляры сервиса не повторяли запросы одновременно
+func (retrier *Retrier) getDelay(attempt int) time.Duration {
+
+	maxDelay := retrier.options.Delay
+	// при MaxDelay 0 граница растет без ограничения, но не до переполнения
+	for i := 0; i < attempt && (retrier.options.MaxDelay <= 0 || maxDelay < retrier.options.MaxDelay) && maxDelay <= math.MaxInt64/2; i++ {
+		maxDelay *= 2
+	}
+	if retrier.options.MaxDelay > 0 && maxDelay > retrier.options.MaxDelay {
+		maxDelay = retrier.options.MaxDelay
+	}
+	if maxDelay <= 0 {
+		return 0
+	}
+
+	retrier.mutexRand.Lock()
+	defer retrier.mutexRand.Unlock()
+	return maxDelay/2 + time.Duration(retrier.randDelay.Int63n(int64(maxDelay/2)+1))
+}
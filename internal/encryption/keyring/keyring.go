@@ -0,0 +1,183 @@
+package keyring
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// идентификатор ключа, если он не указан явно
+const DefaultKeyID = "default"
+
+func getPackageError(textError string) error {
+	textModuleError := "keyring: " + textError
+	return errors.New(textModuleError)
+}
+
+// Набор ключей шифрования AES-GCM
+// Первый добавленный ключ является активным: им шифруются новые записи,
+// остальные ключи нужны для чтения записей, зашифрованных до ротации
+type KeyRing struct {
+	activeKeyID string
+	ciphers     map[string]cipher.AEAD
+}
+
+// Создание набора ключей из значения переменной окружения и файла с ключами
+// Формат ключа: "<id ключа>:<ключ в base64>" или просто "<ключ в base64>"
+// В файле каждый ключ на отдельной строке, строки с # пропускаются
+// Если ключи не переданы, то возвращаем nil - шифрование выключено
+func NewKeyRing(envKey string, pathKeyFile string) (keyRing *KeyRing, err error) {
+
+	listKeys := []string{}
+
+	envKey = strings.TrimSpace(envKey)
+	if envKey != "" {
+		listKeys = append(listKeys, envKey)
+	}
+
+	if pathKeyFile != "" {
+		keysFromFile, errRead := readKeyFile(pathKeyFile)
+		if errRead != nil {
+			return nil, errRead
+		}
+		listKeys = append(listKeys, keysFromFile...)
+	}
+
+	if len(listKeys) == 0 {
+		return nil, nil
+	}
+
+	keyRing = &KeyRing{
+		ciphers: make(map[string]cipher.AEAD, len(listKeys)),
+	}
+	for _, strKey := range listKeys {
+		err = keyRing.AddKey(strKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keyRing, nil
+}
+
+// Чтение ключей из файла
+func readKeyFile(pathKeyFile string) (listKeys []string, err error) {
+
+	file, err := os.Open(pathKeyFile)
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось открыть файл с ключами шифрования: %w", err)
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		listKeys = append(listKeys, line)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось прочитать файл с ключами шифрования: %w", err)
+	}
+	return
+}
+
+// Добавление ключа в набор
+// Первый добавленный ключ становится активным
+func (keyRing *KeyRing) AddKey(strKey string) (err error) {
+
+	keyID := DefaultKeyID
+	encodedKey := strKey
+	if idx := strings.Index(strKey, ":"); idx >= 0 {
+		keyID = strings.TrimSpace(strKey[:idx])
+		encodedKey = strings.TrimSpace(strKey[idx+1:])
+	}
+
+	if keyID == "" {
+		return getPackageError("передан пустой идентификатор ключа шифрования")
+	}
+
+	if _, ok := keyRing.ciphers[keyID]; ok {
+		return getPackageError("ключ шифрования с идентификатором " + keyID + " передан несколько раз")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return fmt.Errorf("ошибка: ключ шифрования %s должен быть в base64: %w", keyID, err)
+	}
+
+	// длина ключа определяет AES-128, AES-192 или AES-256
+	blockCipher, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("ошибка: некорректный ключ шифрования %s: %w", keyID, err)
+	}
+
+	aead, err := cipher.NewGCM(blockCipher)
+	if err != nil {
+		return fmt.Errorf("ошибка: не удалось создать шифр для ключа %s: %w", keyID, err)
+	}
+
+	keyRing.ciphers[keyID] = aead
+	if keyRing.activeKeyID == "" {
+		keyRing.activeKeyID = keyID
+	}
+	return
+}
+
+// Идентификатор ключа, которым шифруются новые данные
+func (keyRing *KeyRing) GetActiveKeyID() string {
+	return keyRing.activeKeyID
+}
+
+// Шифрование данных активным ключом
+// Идентификатор ключа добавляется в проверяемые данные,
+// чтобы запись нельзя было подменить на запись другого ключа
+func (keyRing *KeyRing) Encrypt(plainData []byte) (keyID string, nonce []byte, encryptedData []byte, err error) {
+
+	keyID = keyRing.activeKeyID
+	aead, ok := keyRing.ciphers[keyID]
+	if !ok {
+		err = getPackageError("не задан активный ключ шифрования")
+		return
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		err = fmt.Errorf("ошибка: не удалось сгенерировать nonce: %w", err)
+		return
+	}
+
+	encryptedData = aead.Seal(nil, nonce, plainData, []byte(keyID))
+	return
+}
+
+// Расшифровка данных ключом с указанным идентификатором
+func (keyRing *KeyRing) Decrypt(keyID string, nonce []byte, encryptedData []byte) (plainData []byte, err error) {
+
+	aead, ok := keyRing.ciphers[keyID]
+	if !ok {
+		err = getPackageError("неизвестный идентификатор ключа шифрования: " + keyID)
+		return
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		err = getPackageError("некорректная длина nonce у записи с ключом " + keyID)
+		return
+	}
+
+	plainData, err = aead.Open(nil, nonce, encryptedData, []byte(keyID))
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось расшифровать запись ключом %s: %w", keyID, err)
+	}
+	return
+}
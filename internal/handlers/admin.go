@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-url-shortener/internal/logger"
+	"go-url-shortener/internal/metrics"
+	middlewareAdminToken "go-url-shortener/internal/middlewares/admintoken"
+	modelsRequests "go-url-shortener/internal/models/requests"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsService "go-url-shortener/internal/models/service"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// создание обработчика запросов служебного сервера
+// Служебный сервер слушает отдельный адрес из ADMIN_ADDRESS, его запросы не попадают в метрики запросов основного сервера.
+// Метрики без ADMIN_TOKEN открыты, а профилирование и служебные операции доступны только с токеном
+func NewAdminRouterHandler(serviceShortLink modelsService.ServiceShortInterface, deps Dependencies) http.Handler {
+
+	var dataHandler = newDataHandler(serviceShortLink, deps)
+	configApp := deps.Config
+
+	router := chi.NewRouter()
+	router.With(middlewareAdminToken.OptionalAdminToken(configApp)).
+		Handle("/metrics", dataHandler.metrics.NewHandler(dataHandler.collectDBPool, dataHandler.collectCache))
+
+	router.Group(func(router chi.Router) {
+		router.Use(middlewareAdminToken.RequireAdminToken(configApp))
+
+		// профилирование, дамп горутин: /debug/pprof/goroutine?debug=2
+		router.HandleFunc("/debug/pprof/*", pprof.Index)
+		router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+		router.Get("/admin/diagnostics", dataHandler.getDiagnostics)
+		router.Get("/admin/goroutines", dataHandler.getGoroutines)
+		router.Get("/admin/runtime", dataHandler.getRuntimeStats)
+		router.Get("/admin/log/level", dataHandler.getLogLevel)
+		router.Put("/admin/log/level", dataHandler.setLogLevel)
+		router.Post("/admin/cache/flush", dataHandler.flushCache)
+		router.Post("/admin/storage/compact", dataHandler.compactStorage)
+		router.Post("/admin/links/{shortLink}/disable", dataHandler.disableLink)
+		router.Post("/admin/links/{shortLink}/enable", dataHandler.enableLink)
+		router.Post("/admin/config/reload", dataHandler.reloadConfig)
+	})
+
+	funcNotFoundMethod := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte("Вызываемый адрес не существует"))
+	})
+	router.NotFound(funcNotFoundMethod)
+	router.MethodNotAllowed(funcNotFoundMethod)
+
+	return router
+}
+
+// Диагностика сервиса: какое хранилище ссылок используется, статистика кеша и пула соединений с БД
+func (dh dataHandler) getDiagnostics(res http.ResponseWriter, req *http.Request) {
+
+	activeBackend := "unknown"
+	storage := dh.service.GetStorage()
+	if storageDescriber, ok := storage.(modelsStorage.StorageDescriberInterface); ok {
+		activeBackend = storageDescriber.GetBackendName()
+	}
+
+	dataResponse := modelsResponses.ResponseDiagnostics{
+		Storage: modelsResponses.ResponseDiagnosticsStorage{
+			ConfigBackend: dh.configApp.GetStorageBackend(),
+			ActiveBackend: activeBackend,
+		},
+	}
+	if storageCache, ok := storage.(modelsStorage.StorageCacheStatsInterface); ok {
+		cacheStats := storageCache.GetCacheStats()
+		dataResponse.Cache = &modelsResponses.ResponseDiagnosticsCache{
+			Hits:         cacheStats.Hits,
+			Misses:       cacheStats.Misses,
+			NegativeHits: cacheStats.NegativeHits,
+			Evictions:    cacheStats.Evictions,
+			Size:         cacheStats.Size,
+			Capacity:     cacheStats.Capacity,
+		}
+	}
+	// пул соединений смотрим только у хранилищ в БД, чтобы не подключаться к БД лишний раз
+	if strings.HasPrefix(activeBackend, modelsStorage.StorageBackendPostgres) {
+		dbHandler := dh.dbHandler
+		if dbHandler.GetErrSetup() == nil {
+			poolStats := dbHandler.GetPoolStats()
+			dataResponse.DBPool = &modelsResponses.ResponseDiagnosticsDBPool{
+				AcquiredConns:        poolStats.AcquiredConns,
+				IdleConns:            poolStats.IdleConns,
+				TotalConns:           poolStats.TotalConns,
+				MaxConns:             poolStats.MaxConns,
+				AcquireCount:         poolStats.AcquireCount,
+				EmptyAcquireCount:    poolStats.EmptyAcquireCount,
+				CanceledAcquireCount: poolStats.CanceledAcquireCount,
+				AcquireWaitMs:        poolStats.AcquireDuration.Milliseconds(),
+			}
+		}
+	}
+	bytesResult, _ := json.Marshal(&dataResponse)
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(bytesResult)
+}
+
+// Метрики пула соединений с БД, без соединения с БД не выводятся
+func (dh dataHandler) collectDBPool(writer *metrics.Writer) {
+
+	dbHandler := dh.dbHandler
+	if dbHandler == nil || dbHandler.GetErrSetup() != nil || dbHandler.GetPool() == nil {
+		return
+	}
+
+	poolStats := dbHandler.GetPoolStats()
+	writer.WriteGauge("shortener_db_pool_acquired_conns", "Занятые соединения пула БД", float64(poolStats.AcquiredConns))
+	writer.WriteGauge("shortener_db_pool_idle_conns", "Свободные соединения пула БД", float64(poolStats.IdleConns))
+	writer.WriteGauge("shortener_db_pool_total_conns", "Все соединения пула БД", float64(poolStats.TotalConns))
+	writer.WriteGauge("shortener_db_pool_max_conns", "Максимальное количество соединений пула БД", float64(poolStats.MaxConns))
+	writer.WriteCounter("shortener_db_pool_acquire_total", "Количество получений соединения из пула БД", float64(poolStats.AcquireCount))
+	writer.WriteCounter("shortener_db_pool_empty_acquire_total", "Количество получений соединения с ожиданием свободного", float64(poolStats.EmptyAcquireCount))
+	writer.WriteCounter("shortener_db_pool_canceled_acquire_total", "Количество отмененных получений соединения", float64(poolStats.CanceledAcquireCount))
+	writer.WriteCounter("shortener_db_pool_acquire_duration_seconds_total", "Суммарное время получения соединений из пула БД", poolStats.AcquireDuration.Seconds())
+}
+
+// Метрики кеша перед хранилищем, без кеша не выводятся
+func (dh dataHandler) collectCache(writer *metrics.Writer) {
+
+	storageCache, ok := dh.service.GetStorage().(modelsStorage.StorageCacheStatsInterface)
+	if !ok {
+		return
+	}
+
+	cacheStats := storageCache.GetCacheStats()
+	writer.WriteCounter("shortener_cache_hits_total", "Найдено в кеше", float64(cacheStats.Hits))
+	writer.WriteCounter("shortener_cache_misses_total", "Не найдено в кеше, запрос ушел в хранилище", float64(cacheStats.Misses))
+	writer.WriteCounter("shortener_cache_negative_hits_total", "Найдено в кеше как несуществующая короткая ссылка", float64(cacheStats.NegativeHits))
+	writer.WriteCounter("shortener_cache_evictions_total", "Вытеснено из кеша при переполнении", float64(cacheStats.Evictions))
+	writer.WriteGauge("shortener_cache_size", "Текущее количество записей в кеше", float64(cacheStats.Size))
+	writer.WriteGauge("shortener_cache_capacity", "Максимальное количество записей в кеше", float64(cacheStats.Capacity))
+
+	// доля попаданий в кеш с запуска, без запросов к кешу - 0
+	hitRatio := 0.0
+	if total := cacheStats.Hits + cacheStats.NegativeHits + cacheStats.Misses; total > 0 {
+		hitRatio = float64(cacheStats.Hits+cacheStats.NegativeHits) / float64(total)
+	}
+	writer.WriteGauge("shortener_cache_hit_ratio", "Доля запросов, на которые ответил кеш", hitRatio)
+}
+
+// Ответ служебного запроса в JSON
+func writeAdminJSON(res http.ResponseWriter, statusCode int, dataResponse any) {
+	bytesResult, _ := json.Marshal(dataResponse)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(statusCode)
+	res.Write(bytesResult)
+}
+
+// Ошибка служебного запроса в текстовом виде
+func writeAdminError(res http.ResponseWriter, statusCode int, err error) {
+	strError := err.Error()
+	logger.GetLogger().Errorf("Ошибка служебного запроса: %s", strError)
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(statusCode)
+	res.Write([]byte(strError))
+}
+
+// Дамп стеков всех горутин в текстовом виде
+func (dh dataHandler) getGoroutines(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	rpprof.Lookup("goroutine").WriteTo(res, 2)
+}
+
+// Состояние рантайма Go: горутины, память и сборщик мусора
+func (dh dataHandler) getRuntimeStats(res http.ResponseWriter, req *http.Request) {
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	dataResponse := modelsResponses.ResponseRuntime{
+		GoVersion:      runtime.Version(),
+		UptimeSeconds:  time.Since(metrics.GetTimeStart()).Seconds(),
+		Goroutines:     runtime.NumGoroutine(),
+		NumCPU:         runtime.NumCPU(),
+		MaxProcs:       runtime.GOMAXPROCS(0),
+		HeapAllocBytes: memStats.HeapAlloc,
+		HeapObjects:    memStats.HeapObjects,
+		SysBytes:       memStats.Sys,
+		NumGC:          memStats.NumGC,
+		GCPauseMs:      float64(memStats.PauseTotalNs) / float64(time.Millisecond),
+	}
+	if memStats.LastGC > 0 {
+		dataResponse.LastGCUnixS = time.Unix(0, int64(memStats.LastGC)).Unix()
+	}
+	writeAdminJSON(res, http.StatusOK, &dataResponse)
+}
+
+// Текущий уровень логирования
+func (dh dataHandler) getLogLevel(res http.ResponseWriter, req *http.Request) {
+	level := logger.GetLevelLog()
+	writeAdminJSON(res, http.StatusOK, &modelsResponses.ResponseLogLevel{
+		Level:       logger.GetLevelLogName(level),
+		LevelNumber: level,
+	})
+}
+
+// Смена уровня логирования без перезапуска
+// Уровень действует до перезапуска или до перезагрузки конфигурации с другим level_logs
+func (dh dataHandler) setLogLevel(res http.ResponseWriter, req *http.Request) {
+
+	var dataRequest modelsRequests.RequestLogLevel
+	err := json.NewDecoder(req.Body).Decode(&dataRequest)
+	if err != nil {
+		writeAdminError(res, http.StatusBadRequest, errors.New("ошибка: ожидается JSON вида {\"level\":\"debug\"}: "+err.Error()))
+		return
+	}
+	level, err := logger.ParseLevelLog(strings.TrimSpace(dataRequest.Level))
+	if err != nil {
+		writeAdminError(res, http.StatusBadRequest, err)
+		return
+	}
+
+	logger.SetLevelLog(level)
+	logger.GetLogger().Warnf("Администратор изменил уровень логирования: %s", logger.GetLevelLogName(level))
+
+	writeAdminJSON(res, http.StatusOK, &modelsResponses.ResponseLogLevel{
+		Level:       logger.GetLevelLogName(level),
+		LevelNumber: level,
+	})
+}
+
+// Очистка кеша перед хранилищем, без кеша - ошибка
+func (dh dataHandler) flushCache(res http.ResponseWriter, req *http.Request) {
+
+	storage := dh.service.GetStorage()
+	storagePurger, ok := storage.(modelsStorage.StorageCachePurgerInterface)
+	if !ok {
+		writeAdminError(res, http.StatusBadRequest, errors.New("ошибка: кеш перед хранилищем выключен"))
+		return
+	}
+
+	dataResponse := modelsResponses.ResponseCacheFlush{}
+	if storageCache, ok := storage.(modelsStorage.StorageCacheStatsInterface); ok {
+		dataResponse.Flushed = storageCache.GetCacheStats().Size
+	}
+	storagePurger.Purge()
+	logger.GetLogger().Warnf("Администратор очистил кеш хранилища, записей: %d", dataResponse.Flushed)
+
+	writeAdminJSON(res, http.StatusOK, &dataResponse)
+}
+
+// Сжатие хранилища: файл хранилища или снимок переписывается только действующими ссылками
+func (dh dataHandler) compactStorage(res http.ResponseWriter, req *http.Request) {
+
+	storage := dh.service.GetStorage()
+	dataResponse := modelsResponses.ResponseStorageCompact{
+		Backend: "unknown",
+	}
+	if storageDescriber, ok := storage.(modelsStorage.StorageDescriberInterface); ok {
+		dataResponse.Backend = storageDescriber.GetBackendName()
+	}
+
+	err := modelsStorage.ErrNotSupported
+	if storageCompacter, ok := storage.(modelsStorage.StorageCompacterInterface); ok {
+		dataResponse.Rows, err = storageCompacter.Compact(req.Context())
+	}
+	if errors.Is(err, modelsStorage.ErrNotSupported) {
+		writeAdminError(res, http.StatusBadRequest, errors.New(err.Error()+": сжатие хранилища "+dataResponse.Backend))
+		return
+	}
+	if err != nil {
+		writeAdminError(res, http.StatusInternalServerError, err)
+		return
+	}
+	logger.GetLogger().Warnf("Администратор сжал хранилище %s, ссылок: %d", dataResponse.Backend, dataResponse.Rows)
+
+	writeAdminJSON(res, http.StatusOK, &dataResponse)
+}
+
+// Отключение короткой ссылки: ссылка остается в хранилище, но переход по ней отвечает 410
+func (dh dataHandler) disableLink(res http.ResponseWriter, req *http.Request) {
+	dh.switchLink(res, req, true)
+}
+
+// Включение отключенной короткой ссылки
+func (dh dataHandler) enableLink(res http.ResponseWriter, req *http.Request) {
+	dh.switchLink(res, req, false)
+}
+
+// Отключение или включение короткой ссылки
+// Пространство имен ссылки задается параметрами workspace и domain, по умолчанию общее пространство домена BASE_URL
+func (dh dataHandler) switchLink(res http.ResponseWriter, req *http.Request, isDisabled bool) {
+
+	dataResponse := modelsResponses.ResponseLinkSwitch{
+		ShortLink:   strings.TrimSpace(chi.URLParam(req, "shortLink")),
+		WorkspaceID: req.URL.Query().Get("workspace"),
+		Domain:      req.URL.Query().Get("domain"),
+		Disabled:    isDisabled,
+	}
+	ctx := modelsStorage.WithNamespace(context.TODO(), modelsStorage.Namespace{
+		WorkspaceID: dataResponse.WorkspaceID,
+		Domain:      dataResponse.Domain,
+	})
+
+	err := dh.service.SetLinkDisabled(ctx, dataResponse.ShortLink, isDisabled)
+	if errors.Is(err, modelsStorage.ErrNotFoundShortLink) {
+		writeAdminError(res, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, modelsStorage.ErrNotSupported) {
+		writeAdminError(res, http.StatusBadRequest, errors.New(err.Error()+": отключение коротких ссылок"))
+		return
+	}
+	if err != nil {
+		writeAdminError(res, http.StatusInternalServerError, err)
+		return
+	}
+	logger.GetLogger().Warnf("Администратор изменил короткую ссылку %s, отключена: %t", dataResponse.ShortLink, isDisabled)
+
+	writeAdminJSON(res, http.StatusOK, &dataResponse)
+}
+
+// Перезагрузка конфигурации, как по сигналу SIGHUP
+func (dh dataHandler) reloadConfig(res http.ResponseWriter, req *http.Request) {
+
+	listChanged, listWarnings, err := dh.configApp.Reload()
+	if err != nil {
+		strError := err.Error()
+		logger.FromContext(req.Context()).Errorf("%s", strError)
+
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte(strError))
+		return
+	}
+	logConfigReload(listChanged, listWarnings)
+
+	dataResponse := modelsResponses.ResponseConfigReload{
+		Changed:  append([]string{}, listChanged...),
+		Warnings: append([]string{}, listWarnings...),
+	}
+	bytesResult, _ := json.Marshal(&dataResponse)
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(bytesResult)
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	storagecache "go-url-shortener/internal/storage/storageshortlink/storagecache"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты служебных операций служебного сервера
+func TestAdminHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	defer configApp.SetAdminToken("")
+	defer logger.SetLevelLog(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	const adminToken = "admin-token"
+
+	doRequest := func(handler http.Handler, method, path, body, token string) (statusCode int, bodyResponse string) {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(bytesBody)
+	}
+
+	storageMemory, _ := storagememory.NewStorageShorts("", 0, configApp)
+	storageCached, err := storagecache.NewStorageShorts(storageMemory, 100, time.Minute, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	serviceShortLink := service.NewServiceShortLink(storageCached, deps.Dependencies)
+	handler := NewRouterHandler(serviceShortLink, deps)
+	handlerAdmin := NewAdminRouterHandler(serviceShortLink, deps)
+
+	statusCode, shortURL := doRequest(handler, http.MethodPost, "/", "https://admin-test.com", "")
+	assert.Equal(t, http.StatusCreated, statusCode)
+	pathShort := strings.TrimPrefix(shortURL, configApp.GetHostShortLink())
+
+	nameMyTest := "admin token"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		// без настроенного токена служебные операции выключены, а метрики открыты
+		configApp.SetAdminToken("")
+		statusCode, _ := doRequest(handlerAdmin, http.MethodGet, "/admin/runtime", "", "")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, _ = doRequest(handlerAdmin, http.MethodGet, "/debug/pprof/", "", "")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, _ = doRequest(handlerAdmin, http.MethodGet, "/metrics", "", "")
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		configApp.SetAdminToken(adminToken)
+		statusCode, _ = doRequest(handlerAdmin, http.MethodGet, "/admin/runtime", "", "")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+		statusCode, _ = doRequest(handlerAdmin, http.MethodGet, "/admin/runtime", "", "wrong-token")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+
+		// на основном сервере служебных операций нет
+		statusCode, _ = doRequest(handler, http.MethodGet, "/admin/runtime", "", adminToken)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, _ = doRequest(handler, http.MethodGet, "/debug/pprof/", "", adminToken)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "pprof and runtime stats"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		configApp.SetAdminToken(adminToken)
+
+		statusCode, body := doRequest(handlerAdmin, http.MethodGet, "/debug/pprof/", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, true, strings.Contains(body, "goroutine"))
+
+		statusCode, body = doRequest(handlerAdmin, http.MethodGet, "/debug/pprof/goroutine?debug=2", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, true, strings.Contains(body, "goroutine "))
+
+		statusCode, body = doRequest(handlerAdmin, http.MethodGet, "/admin/goroutines", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, true, strings.Contains(body, "TestAdminHandler"))
+
+		statusCode, body = doRequest(handlerAdmin, http.MethodGet, "/admin/runtime", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		var dataRuntime modelsResponses.ResponseRuntime
+		assert.NoError(t, json.Unmarshal([]byte(body), &dataRuntime))
+		assert.Greater(t, dataRuntime.Goroutines, 0)
+		assert.Greater(t, dataRuntime.HeapAllocBytes, uint64(0))
+		assert.NotEqual(t, "", dataRuntime.GoVersion)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "log level"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		configApp.SetAdminToken(adminToken)
+		logger.SetLevelLog(6)
+
+		statusCode, body := doRequest(handlerAdmin, http.MethodGet, "/admin/log/level", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `{"level":"trace","level_number":6}`, body)
+
+		statusCode, body = doRequest(handlerAdmin, http.MethodPut, "/admin/log/level", `{"level":"warning"}`, adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `{"level":"warning","level_number":3}`, body)
+		assert.Equal(t, 3, logger.GetLevelLog())
+
+		statusCode, _ = doRequest(handlerAdmin, http.MethodPut, "/admin/log/level", `{"level":"verbose"}`, adminToken)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, _ = doRequest(handlerAdmin, http.MethodPut, "/admin/log/level", `debug`, adminToken)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		assert.Equal(t, 3, logger.GetLevelLog())
+
+		logger.SetLevelLog(6)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "cache flush"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		configApp.SetAdminToken(adminToken)
+
+		// переход по ссылке кладет ее в кеш
+		statusCode, _ := doRequest(handler, http.MethodGet, pathShort, "", "")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		assert.Greater(t, storageCached.GetCacheStats().Size, 0)
+
+		statusCode, body := doRequest(handlerAdmin, http.MethodPost, "/admin/cache/flush", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		var dataFlush modelsResponses.ResponseCacheFlush
+		assert.NoError(t, json.Unmarshal([]byte(body), &dataFlush))
+		assert.Greater(t, dataFlush.Flushed, 0)
+		assert.Equal(t, 0, storageCached.GetCacheStats().Size)
+
+		// ссылка после очистки кеша по-прежнему открывается
+		statusCode, _ = doRequest(handler, http.MethodGet, pathShort, "", "")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+
+		// без кеша очищать нечего
+		handlerAdminMemory := NewAdminRouterHandler(service.NewServiceShortLink(storageMemory, deps.Dependencies), deps)
+		statusCode, _ = doRequest(handlerAdminMemory, http.MethodPost, "/admin/cache/flush", "", adminToken)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "storage compaction"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		configApp.SetAdminToken(adminToken)
+
+		// в файле повтор ссылки, в памяти он один
+		pathFile := filepath.Join(t.TempDir(), "storage.json")
+		row := `{"ShortLink":"compact1","FullURL":"https://compact.com","UUID":"1"}` + "\n"
+		assert.NoError(t, os.WriteFile(pathFile, []byte(row+row), 0600))
+
+		storageFile, err := storagerestorer.NewStorageShortsFromFileStorage(pathFile, configApp)
+		if !assert.NoError(t, err) {
+			return
+		}
+		handlerAdminFile := NewAdminRouterHandler(service.NewServiceShortLink(storageFile, deps.Dependencies), deps)
+
+		statusCode, body := doRequest(handlerAdminFile, http.MethodPost, "/admin/storage/compact", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `{"backend":"file","rows":1}`, body)
+
+		dataFile, err := os.ReadFile(pathFile)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(dataFile), "compact1"))
+
+		// хранилище в памяти без снимков сжимать нечего
+		statusCode, _ = doRequest(handlerAdmin, http.MethodPost, "/admin/storage/compact", "", adminToken)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+
+	nameMyTest6 := "disable and enable link"
+	t.Run(nameMyTest6, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest6)
+
+		configApp.SetAdminToken(adminToken)
+		shortLink := strings.TrimPrefix(pathShort, "/")
+
+		statusCode, body := doRequest(handlerAdmin, http.MethodPost, "/admin/links/"+shortLink+"/disable", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `{"short_link":"`+shortLink+`","disabled":true}`, body)
+
+		// ссылка в кеше, но все равно не открывается
+		statusCode, _ = doRequest(handler, http.MethodGet, pathShort, "", "")
+		assert.Equal(t, http.StatusGone, statusCode)
+
+		// ссылка с тем же кодом в другом рабочем пространстве не отключается
+		statusCode, _ = doRequest(handlerAdmin, http.MethodPost, "/admin/links/"+shortLink+"/disable?workspace=other", "", adminToken)
+		assert.Equal(t, http.StatusNotFound, statusCode)
+
+		statusCode, _ = doRequest(handlerAdmin, http.MethodPost, "/admin/links/"+shortLink+"/enable", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		statusCode, _ = doRequest(handler, http.MethodGet, pathShort, "", "")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+
+		statusCode, _ = doRequest(handlerAdmin, http.MethodPost, "/admin/links/unknown-link/disable", "", adminToken)
+		assert.Equal(t, http.StatusNotFound, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest6)
+	})
+
+	nameMyTest7 := "disabled link is kept in storage"
+	t.Run(nameMyTest7, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest7)
+
+		configApp.SetAdminToken(adminToken)
+		pathFile := filepath.Join(t.TempDir(), "storage.json")
+
+		// новое хранилище на том же файле - как после перезапуска сервиса
+		newHandlers := func() (handler, handlerAdmin http.Handler) {
+			storageFile, err := storagerestorer.NewStorageShortsFromFileStorage(pathFile, configApp)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			serviceFile := service.NewServiceShortLink(storageFile, deps.Dependencies)
+			return NewRouterHandler(serviceFile, deps), NewAdminRouterHandler(serviceFile, deps)
+		}
+
+		handlerFile, handlerAdminFile := newHandlers()
+		statusCode, shortURL := doRequest(handlerFile, http.MethodPost, "/", "https://disabled-test.com", "")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		pathShortFile := strings.TrimPrefix(shortURL, configApp.GetHostShortLink())
+		shortLink := strings.TrimPrefix(pathShortFile, "/")
+
+		statusCode, _ = doRequest(handlerAdminFile, http.MethodPost, "/admin/links/"+shortLink+"/disable", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		handlerFile, handlerAdminFile = newHandlers()
+		statusCode, _ = doRequest(handlerFile, http.MethodGet, pathShortFile, "", "")
+		assert.Equal(t, http.StatusGone, statusCode)
+
+		// после сжатия файла признак отключения сохраняется
+		statusCode, body := doRequest(handlerAdminFile, http.MethodPost, "/admin/storage/compact", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `{"backend":"file","rows":1}`, body)
+		handlerFile, handlerAdminFile = newHandlers()
+		statusCode, _ = doRequest(handlerFile, http.MethodGet, pathShortFile, "", "")
+		assert.Equal(t, http.StatusGone, statusCode)
+
+		statusCode, _ = doRequest(handlerAdminFile, http.MethodPost, "/admin/links/"+shortLink+"/enable", "", adminToken)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		handlerFile, _ = newHandlers()
+		statusCode, _ = doRequest(handlerFile, http.MethodGet, pathShortFile, "", "")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest7)
+	})
+}
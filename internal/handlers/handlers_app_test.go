@@ -0,0 +1,162 @@
+package handlers_test
+
+import (
+	"context"
+	"go-url-shortener/internal/app"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты сборки приложения из явно переданных зависимостей
+func TestApp(t *testing.T) {
+
+	// создаем конфигурацию отдельного приложения, глобальная конфигурация не используется
+	newConfig := func(hostShortLink string) *config.ConfigType {
+		configTest := &config.ConfigType{}
+		configTest.SetHostShortLink(hostShortLink)
+		configTest.SetStorageBackend(modelsStorage.StorageBackendMemory)
+		configTest.SetLevelLogs(6)
+		return configTest
+	}
+
+	createShortURL := func(handler http.Handler, fullURL string) (statusCode int, shortURL string) {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fullURL))
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(bytesBody)
+	}
+
+	nameMyTest := "two apps in one process"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		appFirst, err := app.New(app.Options{Config: newConfig("http://first.test")})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer appFirst.Close(context.Background())
+
+		appSecond, err := app.New(app.Options{Config: newConfig("http://second.test")})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer appSecond.Close(context.Background())
+
+		statusCode, shortURL := createShortURL(appFirst.GetHandler(), "https://app-first.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.HasPrefix(shortURL, "http://first.test/"))
+
+		statusCode, shortURL = createShortURL(appSecond.GetHandler(), "https://app-second.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.HasPrefix(shortURL, "http://second.test/"))
+
+		// у приложений свои хранилища, ссылки одного не видны в другом
+		shortPath := strings.TrimPrefix(shortURL, "http://second.test")
+		request := httptest.NewRequest(http.MethodGet, shortPath, nil)
+		respWriter := httptest.NewRecorder()
+		appFirst.GetHandler().ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		assert.Equal(t, "", res.Header.Get("Location"))
+
+		respWriter = httptest.NewRecorder()
+		appSecond.GetHandler().ServeHTTP(respWriter, request)
+		resSecond := respWriter.Result()
+		defer resSecond.Body.Close()
+		assert.Equal(t, "https://app-second.com", resSecond.Header.Get("Location"))
+
+		// метрики и признак остановки у каждого приложения свои
+		assert.Equal(t, float64(1), appFirst.GetMetrics().GetLinksCreated())
+		assert.Equal(t, float64(1), appSecond.GetMetrics().GetRedirects())
+		assert.Equal(t, float64(0), appFirst.GetMetrics().GetRedirects())
+
+		appFirst.GetHealth().SetDraining(true)
+		respWriter = httptest.NewRecorder()
+		appFirst.GetHandler().ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, respWriter.Code)
+		respWriter = httptest.NewRecorder()
+		appSecond.GetHandler().ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, respWriter.Code)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "app without config"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		_, err := app.New(app.Options{})
+		assert.Error(t, err)
+
+		// домены и рабочие пространства читаются из конфигурации приложения, с ошибкой в них приложение не создается
+		configDomains := newConfig("http://domains.test")
+		configDomains.SetShortDomains("brand.io")
+		_, err = app.New(app.Options{Config: configDomains})
+		assert.Error(t, err)
+
+		configWorkspaces := newConfig("http://workspaces.test")
+		configWorkspaces.SetWorkspacesFile(filepath.Join(t.TempDir(), "none.json"))
+		_, err = app.New(app.Options{Config: configWorkspaces})
+		assert.Error(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "reload config of app"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		pathConfig := filepath.Join(t.TempDir(), "config.yaml")
+		t.Setenv("CONFIG", pathConfig)
+		cacheSizeGlobal := config.GetAppConfig().GetCacheSize()
+
+		configTest := newConfig("http://reload.test")
+		configTest.SetCacheSize(10)
+		configTest.SetAdminToken("app-token")
+		appTest, err := app.New(app.Options{Config: configTest})
+		if !assert.NoError(t, err) {
+			return
+		}
+		storageCache, ok := appTest.GetStorage().(modelsStorage.StorageCacheStatsInterface)
+		if !assert.Equal(t, true, ok) {
+			return
+		}
+
+		// запрос перезагрузки к приложению перечитывает его конфигурацию, а не общую
+		assert.NoError(t, os.WriteFile(pathConfig, []byte("admin_token: app-token\ncache_size: 20\n"), 0600))
+		request := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+		request.Header.Set("Authorization", "Bearer app-token")
+		respWriter := httptest.NewRecorder()
+		appTest.GetAdminHandler().ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 20, configTest.GetCacheSize())
+		assert.Equal(t, 20, storageCache.GetCacheStats().Capacity)
+		assert.Equal(t, cacheSizeGlobal, config.GetAppConfig().GetCacheSize())
+
+		// остановленное приложение больше не подписано на перезагрузку своей конфигурации
+		assert.NoError(t, appTest.Close(context.Background()))
+		assert.NoError(t, os.WriteFile(pathConfig, []byte("admin_token: app-token\ncache_size: 30\n"), 0600))
+		_, _, err = configTest.Reload()
+		assert.NoError(t, err)
+		assert.Equal(t, 30, configTest.GetCacheSize())
+		assert.Equal(t, 20, storageCache.GetCacheStats().Capacity)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"io"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты разбора адресов сервера и базового адреса с префиксом пути
+func TestBasePathHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	hostService := configApp.GetHostShortLink()
+	//--- End устанавливаем данные конфигурации для теста
+
+	nameMyTest := "parse base url"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		tests := []struct {
+			value   string
+			want    string
+			wantErr bool
+		}{
+			{value: "http://localhost:8080", want: "http://localhost:8080"},
+			{value: "https://sho.rt", want: "https://sho.rt"},
+			{value: "https://Example.com:443/s/", want: "https://example.com/s"},
+			{value: "http://[::1]:8080", want: "http://[::1]:8080"},
+			{value: "http://[::1]:80", want: "http://[::1]"},
+			{value: "localhost:8080", wantErr: true},
+			{value: "ftp://sho.rt", wantErr: true},
+			{value: "https://sho.rt:99999", wantErr: true},
+			{value: "https://sho.rt/?a=1", wantErr: true},
+			{value: "", wantErr: true},
+		}
+		for _, test := range tests {
+			baseURL, err := config.ParseBaseURL(test.value)
+			if test.wantErr {
+				assert.Error(t, err, test.value)
+				continue
+			}
+			assert.NoError(t, err, test.value)
+			assert.Equal(t, test.want, baseURL)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "parse server address"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		tests := []struct {
+			value       string
+			wantNetwork string
+			wantAddress string
+			wantErr     bool
+		}{
+			{value: "localhost:8090", wantNetwork: "tcp", wantAddress: "localhost:8090"},
+			{value: ":8090", wantNetwork: "tcp", wantAddress: ":8090"},
+			{value: "[::1]:8090", wantNetwork: "tcp", wantAddress: "[::1]:8090"},
+			{value: "localhost", wantNetwork: "tcp", wantAddress: "localhost:8080"},
+			{value: "::1", wantNetwork: "tcp", wantAddress: "[::1]:8080"},
+			{value: "unix:/run/shortener.sock", wantNetwork: "unix", wantAddress: "/run/shortener.sock"},
+			{value: "unix:///run/shortener.sock", wantNetwork: "unix", wantAddress: "/run/shortener.sock"},
+			{value: "localhost:port", wantErr: true},
+			{value: "unix:", wantErr: true},
+			{value: "", wantErr: true},
+		}
+		for _, test := range tests {
+			network, address, err := config.ParseAddressServer(test.value)
+			if test.wantErr {
+				assert.Error(t, err, test.value)
+				continue
+			}
+			assert.NoError(t, err, test.value)
+			assert.Equal(t, test.wantNetwork, network)
+			assert.Equal(t, test.wantAddress, address)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "router under base path"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		hostServicePrefix := hostService + "/s"
+		configApp.SetHostShortLink(hostServicePrefix)
+		defer configApp.SetHostShortLink(hostService)
+
+		// домены читаются из конфигурации с новым BASE_URL
+		deps := newTestDependencies(t, configApp)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		doRequest := func(method, target, body string) (statusCode int, bodyResponse string, location string) {
+			request := httptest.NewRequest(method, target, strings.NewReader(body))
+			respWriter := httptest.NewRecorder()
+			handler.ServeHTTP(respWriter, request)
+			res := respWriter.Result()
+			defer res.Body.Close()
+			bytesBody, _ := io.ReadAll(res.Body)
+			return res.StatusCode, string(bytesBody), res.Header.Get("Location")
+		}
+
+		// вне префикса маршрутов нет
+		statusCode, _, _ := doRequest(http.MethodPost, "/", "https://prefix.com")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, _, _ = doRequest(http.MethodPost, "/sx/", "https://prefix.com")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		statusCode, shortURL, _ := doRequest(http.MethodPost, "/s/", "https://prefix.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.HasPrefix(shortURL, hostServicePrefix+"/"))
+
+		shortPath := strings.TrimPrefix(shortURL, hostService)
+		_, _, location := doRequest(http.MethodGet, shortPath, "")
+		assert.Equal(t, "https://prefix.com", location)
+
+		statusCode, _, _ = doRequest(http.MethodPost, "/s/api/shorten", `{"url":"https://prefix-json.com"}`)
+		assert.Equal(t, http.StatusCreated, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+}
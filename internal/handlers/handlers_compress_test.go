@@ -54,6 +54,9 @@ func TestNewRouterCompressHandler(t *testing.T) {
 	configApp.SetLevelLogs(6)
 	//--- End устанавливаем данные конфигурации для теста
 
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
 	// печатаем расположение лога после инициализации конфига
 	logger.GetLogger().Debugf("Путь до файла хранилища ссылок: %+v", pathTempFile)
 
@@ -61,7 +64,7 @@ func TestNewRouterCompressHandler(t *testing.T) {
 	ctx := context.TODO()
 
 	defer func() {
-		var storageShortLink = storageShort.NewStorageShorts()
+		var storageShortLink = storageShort.NewStorageShorts(storageShort.GetDefaultDependencies())
 		err := storageShortLink.ClearStorage(ctx)
 		if err != nil {
 			logger.GetLogger().Debug("не смогли очистить данные хранилища: " + err.Error())
@@ -72,7 +75,7 @@ func TestNewRouterCompressHandler(t *testing.T) {
 	}()
 
 	// заполняем данными хранилище
-	var storageShortLink = storageShort.NewStorageShorts()
+	var storageShortLink = storageShort.NewStorageShorts(storageShort.GetDefaultDependencies())
 	testFullURL1 := "https://testSite.com"
 	testShortLink1 := "RRRTTTTT"
 	testFullURL2 := "https://dsdsdsdds.com"
@@ -102,7 +105,7 @@ func TestNewRouterCompressHandler(t *testing.T) {
 	//logger.GetLogger().Debugf("Установили данные хранилища ссылок: %+v", dataStore)
 
 	// инициализируем сервис на базе конфига
-	serviceShortLink := service.NewServiceShortLink(storageShortLink, configApp)
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
 
 	// хост сервиса
 	hostService := configApp.GetHostShortLink()
@@ -239,7 +242,7 @@ func TestNewRouterCompressHandler(t *testing.T) {
 			}
 
 			respWriter := httptest.NewRecorder()
-			handler := NewRouterHandler(tt.serviceShortLink)
+			handler := NewRouterHandler(tt.serviceShortLink, deps)
 			handler.ServeHTTP(respWriter, request)
 
 			res := respWriter.Result()
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты перезагрузки конфигурации без перезапуска
+func TestConfigReloadHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	oldAddrServer := configApp.GetAddrServer()
+	oldMaxURLLength := configApp.GetMaxURLLength()
+	oldCacheTTL := configApp.GetCacheTTL()
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	pathConfig := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CONFIG", pathConfig)
+	defer func() {
+		configApp.SetLevelLogs(6)
+		configApp.SetMaxURLLength(oldMaxURLLength)
+		configApp.SetCacheTTL(oldCacheTTL)
+		configApp.SetAdminToken("")
+		logger.SetLevelLog(6)
+	}()
+
+	nameMyTest := "reload by admin request"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		serviceShortLink := service.NewServiceShortLink(storage, deps.Dependencies)
+		handler := NewRouterHandler(serviceShortLink, deps)
+		handlerAdmin := NewAdminRouterHandler(serviceShortLink, deps)
+
+		doReload := func(token string) (statusCode int, body string) {
+			request := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+			if token != "" {
+				request.Header.Set("Authorization", "Bearer "+token)
+			}
+			respWriter := httptest.NewRecorder()
+			handlerAdmin.ServeHTTP(respWriter, request)
+			res := respWriter.Result()
+			defer res.Body.Close()
+			bytesBody, _ := io.ReadAll(res.Body)
+			return res.StatusCode, string(bytesBody)
+		}
+
+		// без токена служебные запросы выключены
+		statusCode, _ := doReload("")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		configApp.SetAdminToken("reload-token")
+		statusCode, _ = doReload("wrong-token")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+
+		// основной сервер перезагрузку не принимает, она есть только на служебном
+		request := httptest.NewRequest(http.MethodPost, "/api/admin/config/reload", nil)
+		request.Header.Set("Authorization", "Bearer reload-token")
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		isNotified := false
+		unsubscribe := config.Subscribe(func(configApp config.ConfigTypeInterface) {
+			isNotified = true
+		})
+		defer unsubscribe()
+
+		dataConfig := "admin_token: reload-token\nlevel_logs: 5\nmax_url_length: 100\ncache_ttl: 1m\nserver_address: localhost:9999\n"
+		assert.NoError(t, os.WriteFile(pathConfig, []byte(dataConfig), 0600))
+		statusCode, body := doReload("reload-token")
+		if !assert.Equal(t, http.StatusOK, statusCode, body) {
+			return
+		}
+		dataResponse := modelsResponses.ResponseConfigReload{}
+		if assert.NoError(t, json.Unmarshal([]byte(body), &dataResponse)) {
+			assert.Contains(t, dataResponse.Changed, "level_logs")
+			assert.Contains(t, dataResponse.Changed, "max_url_length")
+			assert.Contains(t, strings.Join(dataResponse.Warnings, "\n"), "server_address")
+		}
+		assert.Equal(t, true, isNotified)
+		assert.Equal(t, 5, configApp.GetLevelLogs())
+		assert.Equal(t, 100, configApp.GetMaxURLLength())
+		assert.Equal(t, time.Minute, configApp.GetCacheTTL())
+		// адрес сервера меняется только при перезапуске
+		assert.Equal(t, oldAddrServer, configApp.GetAddrServer())
+
+		// новая длина ссылки действует сразу
+		request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://reload.com/"+strings.Repeat("a", 200)))
+		respWriter = httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res = respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "invalid config is not applied"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		configApp.SetMaxURLLength(100)
+		assert.NoError(t, os.WriteFile(pathConfig, []byte("max_url_length: 200\nlevel_logs: 42\n"), 0600))
+		_, _, err := config.Reload()
+		assert.Error(t, err)
+		assert.Equal(t, 100, configApp.GetMaxURLLength())
+
+		assert.NoError(t, os.WriteFile(pathConfig, []byte("max_url_lenght: 200\n"), 0600))
+		_, _, err = config.Reload()
+		assert.Error(t, err)
+		assert.Equal(t, 100, configApp.GetMaxURLLength())
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/logger"
+
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты пула соединений с БД
+func TestDBPool(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	nameMyTest := "statement cache mode"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		mode, err := dbconn.ParseQueryExecMode("cache_describe")
+		assert.NoError(t, err)
+		assert.Equal(t, pgx.QueryExecModeCacheDescribe, mode)
+
+		mode, err = dbconn.ParseQueryExecMode("simple_protocol")
+		assert.NoError(t, err)
+		assert.Equal(t, pgx.QueryExecModeSimpleProtocol, mode)
+
+		_, err = dbconn.ParseQueryExecMode("prepare_all")
+		assert.Error(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "pool stats"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		dbHandler := dbconn.GetDBHandler()
+		if dbHandler.GetErrSetup() != nil {
+			logger.GetLogger().Debugln("Тест не выполнялся: нет подключения к БД")
+			return
+		}
+
+		_, err := dbHandler.GetPool().Exec(context.TODO(), "SELECT 1")
+		assert.NoError(t, err)
+
+		poolStats := dbHandler.GetPoolStats()
+		assert.Equal(t, true, poolStats.TotalConns > 0)
+		assert.Equal(t, true, poolStats.AcquireCount > 0)
+		assert.Equal(t, int32(0), poolStats.AcquiredConns)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"go-url-shortener/internal/config"
+	errDriver "go-url-shortener/internal/database/errors/pgxerrors"
+	"go-url-shortener/internal/database/retry"
+	"go-url-shortener/internal/logger"
+	"io"
+	"time"
+
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты повторов запросов к БД и автоматического выключателя
+func TestDBRetry(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// контекст
+	ctx := context.TODO()
+
+	errSerialization := &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	errAdminShutdown := &pgconn.PgError{Code: pgerrcode.AdminShutdown}
+	errUnique := &pgconn.PgError{Code: pgerrcode.UniqueViolation}
+
+	nameMyTest := "classify errors"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		tests := []struct {
+			err         error
+			isRetryable bool
+			isConnErr   bool
+		}{
+			{err: errSerialization, isRetryable: true, isConnErr: false},
+			{err: &pgconn.PgError{Code: pgerrcode.DeadlockDetected}, isRetryable: true, isConnErr: false},
+			{err: errAdminShutdown, isRetryable: true, isConnErr: true},
+			{err: &pgconn.PgError{Code: pgerrcode.ConnectionFailure}, isRetryable: true, isConnErr: true},
+			{err: io.ErrUnexpectedEOF, isRetryable: true, isConnErr: true},
+			{err: errUnique, isRetryable: false, isConnErr: false},
+			{err: errors.New("ошибка синтаксиса"), isRetryable: false, isConnErr: false},
+			{err: nil, isRetryable: false, isConnErr: false},
+		}
+		for _, tt := range tests {
+			isRetryable, _ := errDriver.IsRetryable(tt.err)
+			assert.Equal(t, tt.isRetryable, isRetryable, "%v", tt.err)
+			isConnErr, _ := errDriver.IsConnectionError(tt.err)
+			assert.Equal(t, tt.isConnErr, isConnErr, "%v", tt.err)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "retry transient errors"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		retrier := retry.NewRetrier(retry.Options{
+			Attempts: 3,
+			Delay:    time.Millisecond,
+			MaxDelay: 5 * time.Millisecond,
+		})
+
+		// после двух конфликтов сериализации запрос проходит
+		countCalls := 0
+		err := retrier.Do(ctx, func(ctx context.Context) error {
+			countCalls++
+			if countCalls < 3 {
+				return errSerialization
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, countCalls)
+
+		// постоянные ошибки не повторяются
+		countCalls = 0
+		err = retrier.Do(ctx, func(ctx context.Context) error {
+			countCalls++
+			return errUnique
+		})
+		assert.ErrorIs(t, err, errUnique)
+		assert.Equal(t, 1, countCalls)
+
+		// попытки заканчиваются
+		countCalls = 0
+		err = retrier.Do(ctx, func(ctx context.Context) error {
+			countCalls++
+			return errSerialization
+		})
+		assert.ErrorIs(t, err, errSerialization)
+		assert.Equal(t, 4, countCalls)
+
+		// операции без повторов
+		countCalls = 0
+		err = retrier.DoOnce(ctx, func(ctx context.Context) error {
+			countCalls++
+			return errSerialization
+		})
+		assert.ErrorIs(t, err, errSerialization)
+		assert.Equal(t, 1, countCalls)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "circuit breaker"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		retrier := retry.NewRetrier(retry.Options{
+			BreakerThreshold: 2,
+			BreakerTimeout:   50 * time.Millisecond,
+		})
+		breaker := retrier.GetBreaker()
+
+		countCalls := 0
+		operationDown := func(ctx context.Context) error {
+			countCalls++
+			return errAdminShutdown
+		}
+
+		retrier.Do(ctx, operationDown)
+		assert.Equal(t, retry.StateClosed, breaker.GetState())
+		retrier.Do(ctx, operationDown)
+		assert.Equal(t, retry.StateOpen, breaker.GetState())
+
+		// БД не трогаем, пока выключатель открыт
+		err := retrier.Do(ctx, operationDown)
+		assert.ErrorIs(t, err, retry.ErrCircuitOpen)
+		assert.Equal(t, 2, countCalls)
+
+		// после паузы пробный запрос закрывает выключатель
+		time.Sleep(60 * time.Millisecond)
+		err = retrier.Do(ctx, func(ctx context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, retry.StateClosed, breaker.GetState())
+		assert.Equal(t, 1, breaker.GetCountOpened())
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "stale success does not close breaker"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		breaker := retry.NewBreaker(1, 50*time.Millisecond)
+
+		// медленный запрос пропущен до срабатывания выключателя
+		assert.NoError(t, breaker.Allow())
+		startedSlow := time.Now()
+
+		assert.NoError(t, breaker.Allow())
+		breaker.Done(true, time.Now())
+		assert.Equal(t, retry.StateOpen, breaker.GetState())
+
+		// его успех не закрывает открытый выключатель
+		breaker.Done(false, startedSlow)
+		assert.Equal(t, retry.StateOpen, breaker.GetState())
+		assert.ErrorIs(t, breaker.Allow(), retry.ErrCircuitOpen)
+
+		// и не закрывает его во время пробного запроса
+		time.Sleep(60 * time.Millisecond)
+		assert.NoError(t, breaker.Allow())
+		startedTrial := time.Now()
+		breaker.Done(false, startedSlow)
+		assert.Equal(t, retry.StateHalfOpen, breaker.GetState())
+
+		// закрывает только успешный пробный запрос
+		breaker.Done(false, startedTrial)
+		assert.Equal(t, retry.StateClosed, breaker.GetState())
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "delay grows without max delay"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		retrier := retry.NewRetrier(retry.Options{
+			Attempts: 4,
+			Delay:    4 * time.Millisecond,
+		})
+
+		countCalls := 0
+		timeStart := time.Now()
+		err := retrier.Do(ctx, func(ctx context.Context) error {
+			countCalls++
+			return errSerialization
+		})
+		assert.ErrorIs(t, err, errSerialization)
+		assert.Equal(t, 5, countCalls)
+
+		// границы задержек 4, 8, 16, 32 мс, каждая задержка не меньше половины границы;
+		// без роста задержек прошло бы не больше 16 мс
+		assert.GreaterOrEqual(t, time.Since(timeStart), 30*time.Millisecond)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+}
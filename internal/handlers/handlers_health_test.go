@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты проверок живости и готовности сервиса
+func TestHealthHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	doRequest := func(handler http.Handler, path string) (statusCode int, body string) {
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, path, nil))
+		res := respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(bytesBody)
+	}
+	getDetails := func(handler http.Handler) (statusCode int, dataResponse modelsResponses.ResponseHealth) {
+		statusCode, body := doRequest(handler, "/health/details")
+		assert.NoError(t, json.Unmarshal([]byte(body), &dataResponse))
+		return
+	}
+	getComponent := func(dataResponse modelsResponses.ResponseHealth, name string) (component modelsResponses.ResponseHealthComponent) {
+		for _, component = range dataResponse.Components {
+			if component.Name == name {
+				return
+			}
+		}
+		return modelsResponses.ResponseHealthComponent{}
+	}
+
+	storageMemory, _ := storagememory.NewStorageShorts("", 0, configApp)
+	handlerMemory := NewRouterHandler(service.NewServiceShortLink(storageMemory, deps.Dependencies), deps)
+
+	nameMyTest := "memory storage is ready"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		statusCode, body := doRequest(handlerMemory, "/healthz")
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, "ok", body)
+
+		statusCode, body = doRequest(handlerMemory, "/readyz")
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, "ok", body)
+
+		statusCode, dataResponse := getDetails(handlerMemory)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, "ok", dataResponse.Status)
+		componentStorage := getComponent(dataResponse, "storage")
+		assert.Equal(t, "ok", componentStorage.Status)
+		assert.Equal(t, true, componentStorage.Required)
+		assert.Equal(t, modelsStorage.StorageBackendMemory, componentStorage.Backend)
+		assert.Equal(t, "ok", getComponent(dataResponse, "shutdown").Status)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "draining service is not ready"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		deps.Health.SetDraining(true)
+		defer deps.Health.SetDraining(false)
+
+		// процесс при этом жив
+		statusCode, _ := doRequest(handlerMemory, "/healthz")
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		statusCode, body := doRequest(handlerMemory, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		assert.Equal(t, true, strings.Contains(body, "draining"), body)
+
+		statusCode, dataResponse := getDetails(handlerMemory)
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		assert.Equal(t, "error", dataResponse.Status)
+		assert.Equal(t, "draining", getComponent(dataResponse, "shutdown").Status)
+		assert.Equal(t, "ok", getComponent(dataResponse, "storage").Status)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "file storage readiness"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		dirStorage := filepath.Join(t.TempDir(), "storage")
+		storageFile, err := storagerestorer.NewStorageShortsFromFileStorage(filepath.Join(dirStorage, "storage.json"), configApp)
+		if !assert.NoError(t, err) {
+			return
+		}
+		handlerFile := NewRouterHandler(service.NewServiceShortLink(storageFile, deps.Dependencies), deps)
+
+		statusCode, _ := doRequest(handlerFile, "/readyz")
+		assert.Equal(t, http.StatusOK, statusCode)
+		_, dataResponse := getDetails(handlerFile)
+		assert.Equal(t, modelsStorage.StorageBackendFile, getComponent(dataResponse, "storage").Backend)
+
+		// без папки файл хранилища не создать и не дописать
+		assert.NoError(t, os.RemoveAll(dirStorage))
+		statusCode, body := doRequest(handlerFile, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		assert.Equal(t, true, strings.Contains(body, "storage"), body)
+
+		statusCode, dataResponse = getDetails(handlerFile)
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		componentStorage := getComponent(dataResponse, "storage")
+		assert.Equal(t, "error", componentStorage.Status)
+		assert.NotEqual(t, "", componentStorage.Error)
+
+		// живость от хранилища не зависит
+		statusCode, _ = doRequest(handlerFile, "/healthz")
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты выводов, форматов и ротации логов
+func TestLogOutput(t *testing.T) {
+
+	// конфигурация отдельного логера, общий логер процесса не трогаем
+	newConfig := func(logsPath, output, format string) *config.ConfigType {
+		configTest := &config.ConfigType{}
+		configTest.SetLogsPath(logsPath)
+		configTest.SetLogOutput(output)
+		configTest.SetLogFormat(format)
+		configTest.SetLevelLogs(4)
+		return configTest
+	}
+
+	readLogFile := func(logsPath string) string {
+		bytesLog, _ := os.ReadFile(filepath.Join(logsPath, "goLogs", "urlShortener", "appLog.log"))
+		return string(bytesLog)
+	}
+
+	nameMyTest := "json to file"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		logsPath := t.TempDir()
+		loggerTest := logger.NewLogger(newConfig(logsPath, "file", "json"))
+		loggerTest.WithFields(logger.CustomFields{"api_key": "key-json"}).Info("запись в json")
+
+		lines := strings.Split(strings.TrimSpace(readLogFile(logsPath)), "\n")
+		record := map[string]interface{}{}
+		err := json.Unmarshal([]byte(lines[len(lines)-1]), &record)
+		assert.NoError(t, err)
+		assert.Equal(t, "запись в json", record["msg"])
+		assert.Equal(t, "info", record["level"])
+		assert.Equal(t, logger.RedactedValue, record["api_key"])
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "logfmt to file"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		logsPath := t.TempDir()
+		loggerTest := logger.NewLogger(newConfig(logsPath, "file", "logfmt"))
+		loggerTest.WithFields(logger.CustomFields{"empty": ""}).Info("запись logfmt")
+
+		textLog := readLogFile(logsPath)
+		assert.Equal(t, true, strings.Contains(textLog, `level=info msg="запись logfmt" empty=""`), textLog)
+		assert.Equal(t, true, strings.HasPrefix(textLog, `time="`), textLog)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "fallback to stderr"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		// папку логов нельзя создать внутри обычного файла
+		pathFile := filepath.Join(t.TempDir(), "file")
+		err := os.WriteFile(pathFile, []byte("не папка"), 0644)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var loggerTest logger.TypeAppLogger
+		assert.NotPanics(t, func() {
+			loggerTest = logger.NewLogger(newConfig(pathFile, "file", "text"))
+		})
+		assert.Equal(t, os.Stderr, loggerTest.Out)
+
+		// неизвестный вывод тоже заменяется на stderr
+		loggerTest = logger.NewLogger(newConfig(t.TempDir(), "kafka", "text"))
+		assert.Equal(t, os.Stderr, loggerTest.Out)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "validate log settings"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		assert.Equal(t, []string{"stdout", "file"}, logger.ParseLogOutputs(" stdout, FILE,,stdout "))
+
+		configTest := newConfig(t.TempDir(), "stdout,kafka", "xml")
+		configTest.SetLogMaxSize(-1)
+		configTest.SetLogMaxAge(-time.Second)
+		textErrors := ""
+		for _, err := range configTest.Validate() {
+			textErrors += err.Error() + "\n"
+		}
+		for _, name := range []string{"log_output", "log_format", "log_max_size", "log_max_age"} {
+			assert.Equal(t, true, strings.Contains(textErrors, name), name)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "rotate by size"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		dirLogs := t.TempDir()
+		pathLogFile := filepath.Join(dirLogs, "appLog.log")
+		logFile, err := logger.NewRotatingFile(pathLogFile, 100, 0, 2)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer logFile.Close()
+
+		// файлы с похожими названиями, но без времени ротации, ротированными не считаются и не удаляются
+		listForeign := []string{"appLog-old.log", "appLog-20261019T120000.log"}
+		for _, name := range listForeign {
+			assert.NoError(t, os.WriteFile(filepath.Join(dirLogs, name), []byte("чужой файл\n"), 0600))
+		}
+
+		record := []byte(strings.Repeat("x", 39) + "\n")
+		for i := 0; i < 10; i++ {
+			_, err = logFile.Write(record)
+			assert.NoError(t, err)
+		}
+
+		listBackups, err := logFile.GetBackups()
+		assert.NoError(t, err)
+		// старые файлы сверх двух удалены
+		assert.Len(t, listBackups, 2)
+		for _, pathBackup := range listBackups {
+			fileInfo, errStat := os.Stat(pathBackup)
+			assert.NoError(t, errStat)
+			assert.Equal(t, int64(80), fileInfo.Size())
+		}
+		fileInfo, err := os.Stat(pathLogFile)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(80), fileInfo.Size())
+		for _, name := range listForeign {
+			_, errStat := os.Stat(filepath.Join(dirLogs, name))
+			assert.NoError(t, errStat, name)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+
+	nameMyTest6 := "rotate by age"
+	t.Run(nameMyTest6, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest6)
+
+		pathLogFile := filepath.Join(t.TempDir(), "appLog.log")
+		logFile, err := logger.NewRotatingFile(pathLogFile, 0, 20*time.Millisecond, 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer logFile.Close()
+
+		_, err = logFile.Write([]byte("первая запись\n"))
+		assert.NoError(t, err)
+		_, err = logFile.Write([]byte("вторая запись\n"))
+		assert.NoError(t, err)
+		listBackups, _ := logFile.GetBackups()
+		assert.Len(t, listBackups, 0)
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = logFile.Write([]byte("третья запись\n"))
+		assert.NoError(t, err)
+
+		listBackups, _ = logFile.GetBackups()
+		if assert.Len(t, listBackups, 1) {
+			bytesBackup, _ := os.ReadFile(listBackups[0])
+			assert.Equal(t, "первая запись\nвторая запись\n", string(bytesBackup))
+		}
+		bytesLog, _ := os.ReadFile(pathLogFile)
+		assert.Equal(t, "третья запись\n", string(bytesLog))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest6)
+	})
+}
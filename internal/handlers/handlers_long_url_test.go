@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	"os"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты длинных ссылок на всех хранилищах
+func TestLongURLHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя временного файла с хранилищем
+	pathTempFile := os.TempDir() + "/storage/testStorageLongURL.json"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	oldFileStoragePath := configApp.GetFileStoragePath()
+	oldMaxURLLength := configApp.GetMaxURLLength()
+	defer func() {
+		configApp.SetFileStoragePath(oldFileStoragePath)
+		configApp.SetMaxURLLength(oldMaxURLLength)
+	}()
+	configApp.SetFileStoragePath(pathTempFile)
+	configApp.SetMaxURLLength(16384)
+
+	// контекст
+	ctx := context.TODO()
+
+	// ссылка рекламной площадки на несколько килобайт
+	longFullURL := "https://ads.example.com/click?campaign=" + strings.Repeat("utm_source%3Dpartner%26", 180)
+
+	// создание короткой ссылки, возвращает код ответа и короткую ссылку без хоста
+	addLongURL := func(handler http.Handler, fullURL string) (int, string) {
+		bodyRequest, _ := json.Marshal(map[string]string{"url": fullURL})
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(string(bodyRequest)))
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+
+		dataResponse := modelsResponses.ResponseServiceLink{}
+		json.NewDecoder(res.Body).Decode(&dataResponse)
+		partsLink := strings.Split(dataResponse.Result, "/")
+		return res.StatusCode, partsLink[len(partsLink)-1]
+	}
+
+	getRedirect := func(handler http.Handler, shortLink string) (int, string) {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortLink, nil)
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		return res.StatusCode, res.Header.Get("Location")
+	}
+
+	tests := []struct {
+		name    string
+		backend string
+	}{
+		{
+			name:    "file backend",
+			backend: modelsStorage.StorageBackendFile,
+		},
+		{
+			name:    "memory backend",
+			backend: modelsStorage.StorageBackendMemory,
+		},
+		{
+			name:    "postgres backend",
+			backend: modelsStorage.StorageBackendPostgres,
+		},
+		{
+			name:    "postgres cached backend",
+			backend: modelsStorage.StorageBackendPostgresCached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			logger.GetLogger().Debugf("### Начало теста: %s", tt.name)
+
+			isDatabase := strings.HasPrefix(tt.backend, "postgres")
+			if isDatabase && configApp.GetDatabaseDsn() == "" {
+				logger.GetLogger().Debugln("Тест не выполнялся: не задан DatabaseDsn")
+				return
+			}
+
+			os.Remove(pathTempFile)
+			defer os.Remove(pathTempFile)
+
+			storage, err := storageShort.NewStorageShortsByBackend(tt.backend, storageShort.GetDefaultDependencies())
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer storage.ClearStorage(ctx)
+			if storageCloser, ok := storage.(modelsStorage.StorageCloserInterface); ok {
+				defer storageCloser.Close(ctx)
+			}
+
+			handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+			statusCode, shortLink := addLongURL(handler, longFullURL)
+			assert.Equal(t, http.StatusCreated, statusCode)
+
+			// дубль длинной ссылки определяется
+			statusCode, shortLinkDouble := addLongURL(handler, longFullURL)
+			assert.Equal(t, http.StatusConflict, statusCode)
+			assert.Equal(t, shortLink, shortLinkDouble)
+
+			statusCode, location := getRedirect(handler, shortLink)
+			assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+			assert.Equal(t, longFullURL, location)
+
+			logger.GetLogger().Debugf("### Конец теста: %s", tt.name)
+		})
+	}
+
+	nameMyTest := "restore long url from file"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		os.Remove(pathTempFile)
+		defer os.Remove(pathTempFile)
+
+		// ссылка длиннее стандартного буфера сканера строк
+		veryLongFullURL := "https://ads.example.com/click?data=" + strings.Repeat("x", 100*1024)
+		storage, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		err = storage.AddShortLinkForURL(ctx, veryLongFullURL, "VERYLONG")
+		assert.NoError(t, err)
+
+		storageRestored, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		fullURL, err := storageRestored.GetFullLinkByShort(ctx, "VERYLONG")
+		assert.NoError(t, err)
+		assert.Equal(t, veryLongFullURL, fullURL)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "url longer than limit"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		os.Remove(pathTempFile)
+		defer os.Remove(pathTempFile)
+
+		configApp.SetMaxURLLength(1024)
+		defer configApp.SetMaxURLLength(16384)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		// текстовый запрос
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(longFullURL))
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		bodyResponse := respWriter.Body.String()
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, true, strings.Contains(bodyResponse, "превышает максимально допустимую 1024"))
+
+		// JSON запрос
+		statusCode, _ := addLongURL(handler, longFullURL)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		// групповой запрос
+		bodyBatch := `[{"correlation_id":"1","original_url":"https://short.com"},{"correlation_id":"2","original_url":"` + longFullURL + `"}]`
+		request = httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(bodyBatch))
+		respWriter = httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res = respWriter.Result()
+		bodyResponse = respWriter.Body.String()
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, true, strings.Contains(bodyResponse, "correlation_id = 2"))
+
+		countLinks, _ := storage.GetCountLink(ctx)
+		assert.Equal(t, 0, countLinks)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	"go-url-shortener/internal/metrics"
+	storagecache "go-url-shortener/internal/storage/storageshortlink/storagecache"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	"io"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты метрик служебного сервера
+func TestMetricsHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	defer configApp.SetAdminToken("")
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	storageMemory, _ := storagememory.NewStorageShorts("", 0, configApp)
+	storageShortLink, err := storagecache.NewStorageShorts(storageMemory, 100, time.Minute, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+	handler := NewRouterHandler(serviceShortLink, deps)
+	handlerAdmin := NewAdminRouterHandler(serviceShortLink, deps)
+
+	doRequest := func(handler http.Handler, request *http.Request) (statusCode int, body string) {
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(bytesBody)
+	}
+	getMetrics := func(token string) (statusCode int, body string) {
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		return doRequest(handlerAdmin, request)
+	}
+
+	nameMyTest := "metrics of requests"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		// метрики копятся с создания обработчиков, поэтому сравниваем с значениями до запросов
+		linksCreatedBefore := deps.Metrics.GetLinksCreated()
+		redirectsBefore := deps.Metrics.GetRedirects()
+
+		statusCode, shortURL := doRequest(handler, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://metrics.com")))
+		assert.Equal(t, http.StatusCreated, statusCode)
+		statusCode, _ = doRequest(handler, httptest.NewRequest(http.MethodPost, "/api/shorten/batch",
+			strings.NewReader(`[{"correlation_id":"1","original_url":"https://metrics-1.com"},{"correlation_id":"2","original_url":"https://metrics-2.com"}]`)))
+		assert.Equal(t, http.StatusCreated, statusCode)
+
+		pathShort := strings.TrimPrefix(shortURL, configApp.GetHostShortLink())
+		for i := 0; i < 2; i++ {
+			statusCode, _ = doRequest(handler, httptest.NewRequest(http.MethodGet, pathShort, nil))
+			assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		}
+		statusCode, _ = doRequest(handler, httptest.NewRequest(http.MethodGet, "/metrics-unknown/path", nil))
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		// произвольный метод не попадает в метку как есть
+		doRequest(handler, httptest.NewRequest("METRICS-CUSTOM", "/", nil))
+
+		assert.Equal(t, linksCreatedBefore+3, deps.Metrics.GetLinksCreated())
+		assert.Equal(t, redirectsBefore+2, deps.Metrics.GetRedirects())
+
+		statusCode, body := getMetrics("")
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		// в метке маршрута шаблон маршрута, а не путь с короткой ссылкой
+		for _, line := range []string{
+			"# TYPE shortener_http_requests_total counter",
+			`shortener_http_requests_total{route="/",method="POST",status="201"}`,
+			`shortener_http_requests_total{route="/{shortLink}",method="GET",status="307"}`,
+			`shortener_http_requests_total{route="unmatched",method="GET",status="400"}`,
+			"# TYPE shortener_http_request_duration_seconds histogram",
+			`shortener_http_request_duration_seconds_bucket{route="/{shortLink}",method="GET",status="307",le="+Inf"}`,
+			`shortener_http_request_duration_seconds_count{route="/{shortLink}",method="GET",status="307"}`,
+			"shortener_redirects_total ",
+			"shortener_links_created_total ",
+			`shortener_batch_size_bucket{le="5"}`,
+			`shortener_storage_operation_duration_seconds_count{backend="memory",operation="add_short_link"}`,
+			"# TYPE shortener_cache_hit_ratio gauge",
+			"shortener_cache_size ",
+			"go_goroutines ",
+			"process_start_time_seconds ",
+		} {
+			assert.Equal(t, true, strings.Contains(body, line), line)
+		}
+		assert.Equal(t, false, strings.Contains(body, pathShort+`"`))
+		assert.Equal(t, true, strings.Contains(body, `method="OTHER"`))
+		assert.Equal(t, false, strings.Contains(body, "METRICS-CUSTOM"))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "metrics with admin token"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		configApp.SetAdminToken("metrics-token")
+
+		statusCode, _ := getMetrics("")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+		statusCode, _ = getMetrics("wrong-token")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+		statusCode, body := getMetrics("metrics-token")
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, true, strings.Contains(body, "shortener_http_requests_total"))
+
+		// на основном сервере метрик нет
+		statusCode, _ = doRequest(handler, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.NotEqual(t, http.StatusOK, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "format of metrics"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		registry := metrics.NewRegistry()
+		counter := registry.NewCounter("test_total", "Тестовый счетчик", "name")
+		counter.Inc(`a"b\c`)
+		counter.Add(2, `a"b\c`)
+		counter.Add(-1, `a"b\c`)
+		histogram := registry.NewHistogram("test_seconds", "Тестовая гистограмма", []float64{1, 0.1})
+		histogram.Observe(0.05)
+		histogram.Observe(0.5)
+		histogram.Observe(5)
+
+		var builder strings.Builder
+		err := registry.Write(&builder)
+		assert.NoError(t, err)
+		expected := strings.Join([]string{
+			"# HELP test_total Тестовый счетчик",
+			"# TYPE test_total counter",
+			`test_total{name="a\"b\\c"} 3`,
+			"# HELP test_seconds Тестовая гистограмма",
+			"# TYPE test_seconds histogram",
+			`test_seconds_bucket{le="0.1"} 1`,
+			`test_seconds_bucket{le="1"} 2`,
+			`test_seconds_bucket{le="+Inf"} 3`,
+			"test_seconds_sum 5.55",
+			"test_seconds_count 3",
+		}, "\n") + "\n"
+		assert.Equal(t, expected, builder.String())
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+}
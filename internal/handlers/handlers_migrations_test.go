@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/database/migrations"
+	"go-url-shortener/internal/logger"
+	"strings"
+
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты миграций таблиц коротких ссылок
+func TestMigrations(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя тестовой таблицы
+	nameTestTable := "test_table_migrations"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// контекст
+	ctx := context.TODO()
+
+	nameMyTest := "embedded migrations"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		// пул открывает соединения лениво, для чтения встроенных миграций БД не нужна
+		db, err := pgxpool.New(ctx, "postgres://localhost/none")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer db.Close()
+
+		migrator, err := migrations.NewMigrator(db, nameTestTable)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		listMigrations := migrator.GetMigrations()
+		if assert.Equal(t, true, len(listMigrations) >= 2) {
+			assert.Equal(t, 1, listMigrations[0].Version)
+			assert.Equal(t, true, strings.Contains(listMigrations[0].UpSQL, `CREATE TABLE IF NOT EXISTS "`+nameTestTable+`"`))
+			assert.Equal(t, true, strings.Contains(listMigrations[0].DownSQL, `DROP TABLE IF EXISTS "`+nameTestTable+`"`))
+			// канал уведомлений подставляется в триггер
+			assert.Equal(t, true, strings.Contains(listMigrations[1].UpSQL, dbconn.GetChannelChanges(nameTestTable)))
+		}
+		// функция уведомлений берется из общего шаблона, каждая миграция передает свои колонки
+		for _, migration := range listMigrations {
+			switch migration.Version {
+			case 2:
+				assert.Equal(t, true, strings.Contains(migration.UpSQL, "'full_url', NEW.FULL_URL)::text;"), migration.UpSQL)
+			case 5:
+				assert.Equal(t, true, strings.Contains(migration.UpSQL, "'short_domain', NEW.SHORT_DOMAIN)::text;"), migration.UpSQL)
+				assert.Equal(t, true, strings.Contains(migration.DownSQL, "'workspace_id', NEW.WORKSPACE_ID)::text;"), migration.DownSQL)
+				assert.Equal(t, false, strings.Contains(migration.DownSQL, "SHORT_DOMAIN)::text;"), migration.DownSQL)
+			}
+		}
+		for i := 1; i < len(listMigrations); i++ {
+			assert.Less(t, listMigrations[i-1].Version, listMigrations[i].Version)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "up, status and down"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		dbHandler := dbconn.GetDBHandler()
+		if dbHandler.GetErrSetup() != nil {
+			logger.GetLogger().Debugln("Тест не выполнялся: нет подключения к БД")
+			return
+		}
+
+		migrator, err := migrations.NewMigrator(dbHandler.GetPool(), nameTestTable)
+		if !assert.NoError(t, err) {
+			return
+		}
+		countMigrations := len(migrator.GetMigrations())
+
+		_, err = migrator.Up(ctx)
+		assert.NoError(t, err)
+		// повторный запуск ничего не применяет
+		countApplied, err := migrator.Up(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, countApplied)
+
+		listStatus, err := migrator.Status(ctx)
+		assert.NoError(t, err)
+		for _, status := range listStatus {
+			assert.Equal(t, true, status.IsApplied)
+		}
+
+		countRolledBack, err := migrator.Down(ctx, countMigrations)
+		assert.NoError(t, err)
+		assert.Equal(t, countMigrations, countRolledBack)
+
+		listStatus, err = migrator.Status(ctx)
+		assert.NoError(t, err)
+		for _, status := range listStatus {
+			assert.Equal(t, false, status.IsApplied)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "added migrations are immutable"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		// контрольные суммы текста миграций после подстановок, текст уже добавленной миграции не меняется,
+		// изменение схемы делается новой миграцией, а ее контрольная сумма дописывается сюда
+		checksumsMigrations := map[int]string{
+			1: "b5f173d15369fb55155edca864ad84f2ad13fd6eb9111f5f8bf99ddb6ff5eae4",
+			2: "dc48c5d632c80d8921d80681da5e226f7c54e044d111bb86171e5b2427a01c44",
+			3: "b95bdc1b8fd8922f8ed8603b7f50fe97e881718cce93cecfda812e8b2f501b1b",
+			4: "d4e053f8f52e4e8a9f4aedee5ebd3134b1d4a650bdfe99203cc1aca14ffc93d8",
+			5: "599f307533ff306d221f97a4fa2a3ee3ff5d3dee60c371afdaa1e62d781b09b6",
+			6: "53920178fa07d469374ffc8b055c42a58636c1a036d212527d8667cd06aa1ef0",
+		}
+
+		db, err := pgxpool.New(ctx, "postgres://localhost/none")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer db.Close()
+
+		migrator, err := migrations.NewMigrator(db, nameTestTable)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, migration := range migrator.GetMigrations() {
+			// перевод строк зависит от настроек git, в сумму не входит
+			textMigration := strings.ReplaceAll(migration.UpSQL+migration.DownSQL, "\r\n", "\n")
+			checksum := sha256.Sum256([]byte(textMigration))
+			assert.Equal(t, checksumsMigrations[migration.Version], hex.EncodeToString(checksum[:]),
+				"миграция %d_%s изменена или не добавлена в список контрольных сумм", migration.Version, migration.Name)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "duplicate short links stop migration"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		dbHandler := dbconn.GetDBHandler()
+		if dbHandler.GetErrSetup() != nil {
+			logger.GetLogger().Debugln("Тест не выполнялся: нет подключения к БД")
+			return
+		}
+
+		migrator, err := migrations.NewMigrator(dbHandler.GetPool(), nameTestTable)
+		if !assert.NoError(t, err) {
+			return
+		}
+		countMigrations := len(migrator.GetMigrations())
+		defer migrator.Down(ctx, countMigrations)
+
+		// таблица в состоянии до 0004, когда короткая ссылка еще не была уникальной
+		_, err = migrator.Up(ctx)
+		assert.NoError(t, err)
+		_, err = migrator.Down(ctx, countMigrations-3)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = dbHandler.GetPool().Exec(ctx, "INSERT INTO "+nameTestTable+" (FULL_URL, SHORT_LINK) VALUES "+
+			"('https://a.example', 'dup'), ('https://b.example', 'dup'), ('https://c.example', 'single')")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// дубли не переименовываются, миграция останавливается со списком дублей
+		_, err = migrator.Up(ctx)
+		if assert.Error(t, err) {
+			assert.Equal(t, true, strings.Contains(err.Error(), "dup (ID: "), err.Error())
+			assert.Equal(t, false, strings.Contains(err.Error(), "single"), err.Error())
+		}
+		var countRows int
+		err = dbHandler.GetPool().QueryRow(ctx, "SELECT count(*) FROM "+nameTestTable+" WHERE SHORT_LINK = 'dup'").Scan(&countRows)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, countRows)
+
+		// после разбора дублей миграция выполняется
+		_, err = dbHandler.GetPool().Exec(ctx, "DELETE FROM "+nameTestTable+" WHERE FULL_URL = 'https://b.example'")
+		assert.NoError(t, err)
+		_, err = migrator.Up(ctx)
+		assert.NoError(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+}
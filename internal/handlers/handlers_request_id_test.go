@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	cookiesUserData "go-url-shortener/internal/userdata/usercookies"
+	"io"
+	"regexp"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты идентификатора запроса и логов в контексте запроса
+func TestRequestID(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+	if !assert.NoError(t, err) {
+		return
+	}
+	handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+	// перехватываем вывод логера, строки одного запроса ищем по request_id
+	doRequest := func(request *http.Request) (res *http.Response, body string, linesLog []string) {
+		var bufferLog bytes.Buffer
+		appLogger := logger.GetLogger()
+		outputLog := appLogger.Out
+		appLogger.SetOutput(&bufferLog)
+		defer appLogger.SetOutput(outputLog)
+
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res = respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res, string(bytesBody), strings.Split(strings.TrimSpace(bufferLog.String()), "\n")
+	}
+
+	nameMyTest := "request id from header"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://request-id.com"))
+		request.Header.Set("X-Request-ID", "req-123")
+		request.AddCookie(&http.Cookie{Name: cookiesUserData.NameCookiesUserID, Value: cookiesUserData.SignUserID("user-123", configApp.GetUserIDSecret())})
+		res, shortURL, linesLog := doRequest(request)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "req-123", res.Header.Get("X-Request-ID"))
+
+		// и строки сервиса, и итоговая строка запроса с полями запроса
+		isServiceLine, isRequestLine := false, false
+		for _, line := range linesLog {
+			isServiceLine = isServiceLine || strings.Contains(line, "Создали новый случайный короткий код")
+			isRequestLine = isRequestLine || strings.Contains(line, "Зарегистрирован запрос")
+			if strings.Contains(line, "Создали новый случайный короткий код") || strings.Contains(line, "Зарегистрирован запрос") {
+				assert.Equal(t, true, strings.Contains(line, "request_id=req-123"), line)
+				assert.Equal(t, true, strings.Contains(line, "user_id=user-123"), line)
+				assert.Equal(t, true, strings.Contains(line, "route=/ "), line)
+			}
+		}
+		assert.Equal(t, true, isServiceLine)
+		assert.Equal(t, true, isRequestLine)
+
+		// у маршрута с параметром в логах шаблон маршрута, а не путь
+		request = httptest.NewRequest(http.MethodGet, strings.TrimPrefix(shortURL, configApp.GetHostShortLink()), nil)
+		request.Header.Set("X-Request-ID", "req-456")
+		res, _, linesLog = doRequest(request)
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		textLog := strings.Join(linesLog, "\n")
+		assert.Equal(t, true, strings.Contains(textLog, `request_id=req-456 route="/{shortLink}"`), textLog)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "generated request id"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		regexpRequestID := regexp.MustCompile(`^[0-9a-f]{32}$`)
+		for _, headerRequestID := range []string{"", "id with spaces", strings.Repeat("x", 200), "id\nline"} {
+			request := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+			if headerRequestID != "" {
+				request.Header[http.CanonicalHeaderKey("X-Request-ID")] = []string{headerRequestID}
+			}
+			res, _, linesLog := doRequest(request)
+			requestID := res.Header.Get("X-Request-ID")
+			assert.Equal(t, true, regexpRequestID.MatchString(requestID), requestID)
+			assert.Equal(t, true, strings.Contains(strings.Join(linesLog, "\n"), "request_id="+requestID))
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+}
@@ -37,11 +37,14 @@ func TestNewRouterHandlerServer(t *testing.T) {
 	configApp.SetLevelLogs(6)
 	//--- End устанавливаем данные конфигурации для теста
 
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
 	// контекст
 	ctx := context.TODO()
 
 	defer func() {
-		var storageShortLink = storageShort.NewStorageShorts()
+		var storageShortLink = storageShort.NewStorageShorts(storageShort.GetDefaultDependencies())
 		err := storageShortLink.ClearStorage(ctx)
 		if err != nil {
 			logger.GetLogger().Debug("не смогли очистить данные хранилища: " + err.Error())
@@ -52,7 +55,7 @@ func TestNewRouterHandlerServer(t *testing.T) {
 	}()
 
 	// заполняем данными хранилище
-	var storageShortLink = storageShort.NewStorageShorts()
+	var storageShortLink = storageShort.NewStorageShorts(storageShort.GetDefaultDependencies())
 	testFullURL1 := "https://testSite.com"
 	testShortLink1 := "RRRTTTTT"
 	testFullURL2 := "https://dsdsdsdds.com"
@@ -82,10 +85,10 @@ func TestNewRouterHandlerServer(t *testing.T) {
 	//logger.GetLogger().Debugf("Установили данные хранилища ссылок: %+v", dataStore)
 
 	// инициализируем сервис на конфиге
-	serviceShortLink := service.NewServiceShortLink(storageShortLink, configApp)
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
 
 	// обработчик запросов
-	handlerRouter := NewRouterHandler(serviceShortLink)
+	handlerRouter := NewRouterHandler(serviceShortLink, deps)
 
 	// запускаем тестовый сервер
 	serverTest := httptest.NewServer(handlerRouter)
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"go-url-shortener/internal/shortdomains"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"go-url-shortener/internal/storage/storageshortlink/storagecache"
+	"io"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты нескольких доменов коротких ссылок с выбором домена при создании ссылки
+func TestShortDomainsHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	hostService := configApp.GetHostShortLink()
+	//--- End устанавливаем данные конфигурации для теста
+
+	registry, err := shortdomains.NewRegistry(hostService, []string{"https://brand.io", "http://go.brand.io:8080"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	deps := newTestDependencies(t, configApp)
+	deps.ShortDomains = registry
+
+	// контекст
+	ctx := context.TODO()
+	ctxBrand := modelsStorage.WithShortDomain(ctx, "brand.io")
+
+	// запрос к сервису на хост host, пусто - хост по умолчанию
+	doRequest := func(handler http.Handler, method, target, host, body string) (statusCode int, bodyResponse string, location string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if host != "" {
+			request.Host = host
+		}
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(bytesBody), res.Header.Get("Location")
+	}
+
+	nameMyTest := "same short link on different domains"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		storageMemory, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		storage, err := storagecache.NewStorageShorts(storageMemory, 100, time.Minute, time.Minute)
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		// незарегистрированная ссылка попадает в кеш только своего домена
+		statusCode, _, _ := doRequest(handler, http.MethodGet, "/SAME0001", "brand.io", "")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		assert.NoError(t, storage.AddShortLinkForURL(ctx, "https://default.com", "SAME0001"))
+		assert.NoError(t, storage.AddShortLinkForURL(ctxBrand, "https://brand.com", "SAME0001"))
+
+		_, _, location := doRequest(handler, http.MethodGet, "/SAME0001", "", "")
+		assert.Equal(t, "https://default.com", location)
+		_, _, location = doRequest(handler, http.MethodGet, "/SAME0001", "BRAND.io", "")
+		assert.Equal(t, "https://brand.com", location)
+		// на третьем домене такой ссылки нет
+		statusCode, _, _ = doRequest(handler, http.MethodGet, "/SAME0001", "go.brand.io:8080", "")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "select domain when shortening"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		statusCode, shortURL, _ := doRequest(handler, http.MethodPost, "/", "", "https://same.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.HasPrefix(shortURL, hostService+"/"))
+
+		// на другом домене та же полная ссылка новая, а не дубль
+		statusCode, body, _ := doRequest(handler, http.MethodPost, "/api/shorten", "", `{"url":"https://same.com","domain":"brand.io"}`)
+		assert.Equal(t, http.StatusCreated, statusCode)
+		dataResponse := modelsResponses.ResponseServiceLink{}
+		if assert.NoError(t, json.Unmarshal([]byte(body), &dataResponse)) {
+			assert.Equal(t, true, strings.HasPrefix(dataResponse.Result, "https://brand.io/"))
+		}
+
+		statusCode, shortURLPort, _ := doRequest(handler, http.MethodPost, "/?domain=http://go.brand.io:8080", "", "https://same.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.HasPrefix(shortURLPort, "http://go.brand.io:8080/"))
+
+		// без выбора домена ссылка создается на домене запроса
+		statusCode, shortURLRepeat, _ := doRequest(handler, http.MethodPost, "/", "brand.io", "https://same.com")
+		assert.Equal(t, http.StatusConflict, statusCode)
+		assert.Equal(t, dataResponse.Result, shortURLRepeat)
+
+		// ссылка открывается только на своем домене
+		shortLink := strings.TrimPrefix(dataResponse.Result, "https://brand.io")
+		_, _, location := doRequest(handler, http.MethodGet, shortLink, "brand.io", "")
+		assert.Equal(t, "https://same.com", location)
+		statusCode, _, _ = doRequest(handler, http.MethodGet, shortLink, "", "")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		// группа ссылок создается на выбранном домене
+		statusCode, body, _ = doRequest(handler, http.MethodPost, "/api/shorten/batch?domain=brand.io", "",
+			`[{"correlation_id":"1","original_url":"https://batch.com"}]`)
+		assert.Equal(t, http.StatusCreated, statusCode)
+		dataBatch := modelsResponses.ResponseBatchServiceLinks{}
+		if assert.NoError(t, json.Unmarshal([]byte(body), &dataBatch)) && assert.Len(t, dataBatch, 1) {
+			assert.Equal(t, true, strings.HasPrefix(dataBatch[0].ShortURL, "https://brand.io/"))
+		}
+
+		count, err := storage.GetCountLink(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		count, err = storage.GetCountLink(ctxBrand)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "unknown domain"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		statusCode, _, _ := doRequest(handler, http.MethodPost, "/api/shorten", "", `{"url":"https://unknown.com","domain":"evil.io"}`)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, _, _ = doRequest(handler, http.MethodPost, "/?domain=evil.io", "", "https://unknown.com")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		count, err := storage.GetCountLink(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		_, err = shortdomains.NewRegistry(hostService, []string{"brand.io"})
+		assert.Error(t, err)
+		_, err = shortdomains.NewRegistry(hostService, []string{"https://brand.io", "http://BRAND.io/"})
+		assert.Error(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+}
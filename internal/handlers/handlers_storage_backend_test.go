@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"os"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты явного выбора хранилища
+func TestStorageBackendSelection(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя тестовой таблицы
+	nameTestTable := "test_table_restore"
+	// имя временного файла с хранилищем
+	pathTempFile := os.TempDir() + "/storage/testStorage.json"
+	configApp := config.GetAppConfig()
+	configApp.SetFileStoragePath(pathTempFile)
+	configApp.SetNameTableRestorer(nameTestTable)
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	oldBackend := configApp.GetStorageBackend()
+	defer configApp.SetStorageBackend(oldBackend)
+	configApp.SetAdminToken("diagnostics-token")
+	defer configApp.SetAdminToken("")
+
+	nameMyTest := "unknown backend"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		_, err := storageShort.NewStorageShortsByBackend("postgress", storageShort.GetDefaultDependencies())
+		if assert.Error(t, err) {
+			assert.Equal(t, true, strings.Contains(err.Error(), "postgress"))
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "postgres without database does not fall back"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		if configApp.GetDatabaseDsn() != "" {
+			logger.GetLogger().Debugln("Тест не выполнялся: задан DatabaseDsn")
+			return
+		}
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendPostgres, storageShort.GetDefaultDependencies())
+		assert.Error(t, err)
+		assert.Nil(t, storage)
+
+		storage, err = storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendPostgresCached, storageShort.GetDefaultDependencies())
+		assert.Error(t, err)
+		assert.Nil(t, storage)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	tests := []struct {
+		name          string
+		configBackend string
+		activeBackend string
+	}{
+		{
+			name:          "file backend",
+			configBackend: modelsStorage.StorageBackendFile,
+			activeBackend: modelsStorage.StorageBackendFile,
+		},
+		{
+			name:          "memory backend",
+			configBackend: modelsStorage.StorageBackendMemory,
+			activeBackend: modelsStorage.StorageBackendMemory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			logger.GetLogger().Debugf("### Начало теста: %s", tt.name)
+
+			configApp.SetStorageBackend(tt.configBackend)
+			storage, err := storageShort.NewStorageShortsByBackend(tt.configBackend, storageShort.GetDefaultDependencies())
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.activeBackend, storageShort.GetStorageBackendName(storage))
+
+			// выбранное хранилище видно на диагностическом адресе служебного сервера
+			serviceShortLink := service.NewServiceShortLink(storage, deps.Dependencies)
+			request := httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil)
+			request.Header.Set("Authorization", "Bearer diagnostics-token")
+			respWriter := httptest.NewRecorder()
+			NewAdminRouterHandler(serviceShortLink, deps).ServeHTTP(respWriter, request)
+
+			res := respWriter.Result()
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+
+			dataResponse := modelsResponses.ResponseDiagnostics{}
+			err = json.NewDecoder(res.Body).Decode(&dataResponse)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.configBackend, dataResponse.Storage.ConfigBackend)
+			assert.Equal(t, tt.activeBackend, dataResponse.Storage.ActiveBackend)
+
+			logger.GetLogger().Debugf("### Конец теста: %s", tt.name)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"go-url-shortener/internal/storage/storageshortlink/storagecache"
+	storagedb "go-url-shortener/internal/storage/storageshortlink/storagedb"
+	"strconv"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты группового добавления ссылок с результатом по каждой ссылке
+func TestBatchReturningHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	// контекст
+	ctx := context.TODO()
+
+	nameMyTest := "batch with existing links"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		storageMemory, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		// кеш эмулирует групповое добавление для хранилища в памяти
+		storage, err := storagecache.NewStorageShorts(storageMemory, 100, time.Minute, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+		err = storage.AddShortLinkForURL(ctx, "https://exist.com", "EXIST001")
+		assert.NoError(t, err)
+
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		bodyBatch := `[` +
+			`{"correlation_id":"1","original_url":"https://exist.com"},` +
+			`{"correlation_id":"2","original_url":"https://new.com"},` +
+			`{"correlation_id":"3","original_url":"https://new.com"}` +
+			`]`
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(bodyBatch))
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		dataResponse := modelsResponses.ResponseBatchServiceLinks{}
+		err = json.NewDecoder(res.Body).Decode(&dataResponse)
+		if !assert.NoError(t, err) {
+			return
+		}
+		mapShortURL := map[string]string{}
+		for _, row := range dataResponse {
+			mapShortURL[row.CorrelationID] = row.ShortURL
+		}
+		assert.Equal(t, true, strings.HasSuffix(mapShortURL["1"], "/EXIST001"))
+		// одинаковые полные ссылки в группе получают одну короткую ссылку
+		assert.Equal(t, mapShortURL["2"], mapShortURL["3"])
+
+		countLinks, _ := storage.GetCountLink(ctx)
+		assert.Equal(t, 2, countLinks)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "occupied short links"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		storageMemory, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		storage, err := storagecache.NewStorageShorts(storageMemory, 100, time.Minute, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// заняты все короткие ссылки из одного символа
+		chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+		for _, char := range chars {
+			err = storage.AddShortLinkForURL(ctx, "https://occupied.com/"+string(char), string(char))
+			assert.NoError(t, err)
+		}
+
+		// занятая короткая ссылка не перезаписывается и не теряется молча
+		err = storage.AddShortLinkForURL(ctx, "https://other.com", "A")
+		assert.ErrorIs(t, err, modelsStorage.ErrExistShortLink)
+		fullURL, err := storage.GetFullLinkByShort(ctx, "A")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://occupied.com/A", fullURL)
+
+		// в групповом результате нет полной ссылки, короткая ссылка которой занята
+		result, err := storage.AddBatchShortLinksReturning(ctx, modelsStorage.DataStorageShortLink{
+			"B":        {ShortLink: "B", FullURL: "https://other.com"},
+			"FREE0001": {ShortLink: "FREE0001", FullURL: "https://free.com"},
+		})
+		assert.NoError(t, err)
+		_, ok := result["https://other.com"]
+		assert.Equal(t, false, ok)
+		assert.Equal(t, "FREE0001", result["https://free.com"].ShortLink)
+		assert.Equal(t, false, result["https://free.com"].IsExisted)
+
+		// сервис пробует другие короткие ссылки, но все они заняты
+		serviceShortLink := service.NewServiceShortLink(storage, deps.Dependencies)
+		serviceShortLink.SetLength(1)
+		_, err = serviceShortLink.AddNewFullURL(ctx, "https://other.com")
+		assert.ErrorIs(t, err, modelsStorage.ErrExistShortLink)
+		_, err = serviceShortLink.GetBatchShortLink(ctx, []string{"https://other.com"})
+		assert.ErrorIs(t, err, modelsStorage.ErrExistShortLink)
+
+		countLinks, _ := storage.GetCountLink(ctx)
+		assert.Equal(t, len(chars)+1, countLinks)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+}
+
+// получаем группу ссылок с уникальными полными ссылками
+func getBenchmarkBatch(prefix string, size int) modelsStorage.DataStorageShortLink {
+	dataBatch := make(modelsStorage.DataStorageShortLink, size)
+	for i := 0; i < size; i++ {
+		shortLink := prefix + strconv.Itoa(i)
+		dataBatch[shortLink] = modelsStorage.RowStorageShortLink{
+			ShortLink: shortLink,
+			FullURL:   "https://bench.example.com/" + shortLink,
+		}
+	}
+	return dataBatch
+}
+
+// Замер группового добавления ссылок в хранилище
+func benchmarkAddBatch(b *testing.B, storage modelsStorage.StorageShortInterface, sizeBatch int) {
+
+	ctx := context.TODO()
+	defer storage.ClearStorage(ctx)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		dataBatch := getBenchmarkBatch(fmt.Sprintf("B%dN%d_", sizeBatch, i), sizeBatch)
+		b.StartTimer()
+
+		err := storage.AddBatchShortLinks(ctx, dataBatch)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Групповое добавление в хранилище в памяти, как базовое значение
+func BenchmarkAddBatchMemory(b *testing.B) {
+	config.GetAppConfig().SetLevelLogs(2)
+
+	for _, sizeBatch := range []int{100, 1000, 10000} {
+		b.Run(strconv.Itoa(sizeBatch), func(b *testing.B) {
+			storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+			if err != nil {
+				b.Fatal(err)
+			}
+			benchmarkAddBatch(b, storage, sizeBatch)
+		})
+	}
+}
+
+// Групповое добавление в БД, нужна локальная Postgres в DatabaseDsn
+func BenchmarkAddBatchDB(b *testing.B) {
+	configApp := config.GetAppConfig()
+	configApp.SetLevelLogs(2)
+	if configApp.GetDatabaseDsn() == "" {
+		b.Skip("не задан DatabaseDsn")
+	}
+
+	storage, err := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
+	if err != nil {
+		b.Skip("нет подключения к БД: " + err.Error())
+	}
+
+	for _, sizeBatch := range []int{100, 1000, 10000} {
+		b.Run(strconv.Itoa(sizeBatch), func(b *testing.B) {
+			benchmarkAddBatch(b, storage, sizeBatch)
+		})
+
+		// половина группы уже есть в БД, результат по каждой ссылке за один запрос
+		b.Run("returning/"+strconv.Itoa(sizeBatch), func(b *testing.B) {
+			ctx := context.TODO()
+			defer storage.ClearStorage(ctx)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				prefix := fmt.Sprintf("R%dN%d_", sizeBatch, i)
+				err := storage.AddBatchShortLinks(ctx, getBenchmarkBatch(prefix, sizeBatch/2))
+				if err != nil {
+					b.Fatal(err)
+				}
+				dataBatch := getBenchmarkBatch(prefix, sizeBatch)
+				b.StartTimer()
+
+				result, err := storage.AddBatchShortLinksReturning(ctx, dataBatch)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(result) != sizeBatch {
+					b.Fatalf("ожидали %d ссылок в результате, получили %d", sizeBatch, len(result))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	storagecache "go-url-shortener/internal/storage/storageshortlink/storagecache"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	"sync/atomic"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// хранилище, которое считает запросы полной ссылки
+type countingTestStorage struct {
+	storagememory.StorageShortInterface
+	countGet *atomic.Int64
+}
+
+func (store countingTestStorage) GetFullLinkByShort(ctx context.Context, shortLink string) (string, error) {
+	store.countGet.Add(1)
+	return store.StorageShortInterface.GetFullLinkByShort(ctx, shortLink)
+}
+
+// Это тесты кеша перед хранилищем
+func TestCacheStorageHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	// контекст
+	ctx := context.TODO()
+
+	countGet := &atomic.Int64{}
+	storageMemory, _ := storagememory.NewStorageShorts("", 0, config.GetAppConfig())
+	storageCounting := countingTestStorage{
+		StorageShortInterface: storageMemory,
+		countGet:              countGet,
+	}
+
+	storageShortLink, err := storagecache.NewStorageShorts(storageCounting, 2, time.Minute, 50*time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+	handler := NewRouterHandler(serviceShortLink, deps)
+
+	// запрос редиректа, возвращает код ответа
+	getRedirect := func(shortLink string) int {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortLink, nil)
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	storageShortLink.AddShortLinkForURL(ctx, "https://cache1.com", "CACHE111")
+	storageShortLink.AddShortLinkForURL(ctx, "https://cache2.com", "CACHE222")
+	storageShortLink.AddShortLinkForURL(ctx, "https://cache3.com", "CACHE333")
+
+	nameMyTest := "read through cache"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		countGet.Store(0)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusTemporaryRedirect, getRedirect("CACHE111"))
+		}
+		assert.Equal(t, int64(1), countGet.Load())
+
+		cacheStats := storageShortLink.GetCacheStats()
+		assert.Equal(t, uint64(2), cacheStats.Hits)
+		assert.Equal(t, uint64(1), cacheStats.Misses)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "negative cache and invalidation"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		countGet.Store(0)
+		assert.Equal(t, http.StatusBadRequest, getRedirect("UNKNOWN1"))
+		assert.Equal(t, http.StatusBadRequest, getRedirect("UNKNOWN1"))
+		assert.Equal(t, int64(1), countGet.Load())
+		assert.Equal(t, uint64(1), storageShortLink.GetCacheStats().NegativeHits)
+
+		// добавление ссылки сбрасывает запись о незарегистрированной ссылке
+		storageShortLink.AddShortLinkForURL(ctx, "https://unknown1.com", "UNKNOWN1")
+		assert.Equal(t, http.StatusTemporaryRedirect, getRedirect("UNKNOWN1"))
+		assert.Equal(t, int64(2), countGet.Load())
+
+		// запись о незарегистрированной ссылке живет недолго
+		assert.Equal(t, http.StatusBadRequest, getRedirect("UNKNOWN2"))
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, http.StatusBadRequest, getRedirect("UNKNOWN2"))
+		assert.Equal(t, int64(4), countGet.Load())
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "evict least recently used"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		storageShortLink.Purge()
+		getRedirect("CACHE111")
+		getRedirect("CACHE222")
+		// CACHE111 использовалась недавно, поэтому вытесняется CACHE222
+		getRedirect("CACHE111")
+		getRedirect("CACHE333")
+
+		countGet.Store(0)
+		getRedirect("CACHE111")
+		assert.Equal(t, int64(0), countGet.Load())
+		getRedirect("CACHE222")
+		assert.Equal(t, int64(1), countGet.Load())
+
+		cacheStats := storageShortLink.GetCacheStats()
+		assert.Equal(t, 2, cacheStats.Size)
+		assert.Equal(t, true, cacheStats.Evictions >= 2)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "clear storage purges cache"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, getRedirect("CACHE111"))
+		storageShortLink.ClearStorage(ctx)
+		assert.Equal(t, http.StatusBadRequest, getRedirect("CACHE111"))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "counters in diagnostics"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		configApp.SetAdminToken("diagnostics-token")
+		defer configApp.SetAdminToken("")
+		handlerAdmin := NewAdminRouterHandler(serviceShortLink, deps)
+
+		// на основном сервере диагностики нет
+		request := httptest.NewRequest(http.MethodGet, "/api/diagnostics", nil)
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		assert.Equal(t, http.StatusBadRequest, respWriter.Result().StatusCode)
+
+		request = httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil)
+		request.Header.Set("Authorization", "Bearer diagnostics-token")
+		respWriter = httptest.NewRecorder()
+		handlerAdmin.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		dataResponse := modelsResponses.ResponseDiagnostics{}
+		err := json.NewDecoder(res.Body).Decode(&dataResponse)
+		assert.NoError(t, err)
+		if assert.NotNil(t, dataResponse.Cache) {
+			assert.Equal(t, 2, dataResponse.Cache.Capacity)
+			assert.Equal(t, storageShortLink.GetCacheStats().Hits, dataResponse.Cache.Hits)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storagecache "go-url-shortener/internal/storage/storageshortlink/storagecache"
+	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	"os"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты применения изменений, сделанных другими экземплярами сервиса
+func TestStorageChangesHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя временного файла с хранилищем
+	pathTempFile := os.TempDir() + "/storage/testStorageChanges.json"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	// контекст
+	ctx := context.TODO()
+
+	os.Remove(pathTempFile)
+	defer os.Remove(pathTempFile)
+
+	storageRestorer, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+	if !assert.NoError(t, err) {
+		return
+	}
+	storageShortLink, err := storagecache.NewStorageShorts(storageRestorer, 100, time.Minute, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	storageShortLink.AddShortLinkForURL(ctx, "https://changes1.com", "CHANGES1")
+
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+	handler := NewRouterHandler(serviceShortLink, deps)
+
+	// запрос редиректа, возвращает код ответа и адрес редиректа
+	getRedirect := func(shortLink string) (int, string) {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortLink, nil)
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		return res.StatusCode, res.Header.Get("Location")
+	}
+
+	// применение уведомления в том виде, как его отправляет триггер
+	applyPayload := func(t *testing.T, payload string) {
+		change, err := dbconn.ParseNotificationChange(payload)
+		if assert.NoError(t, err) {
+			assert.NoError(t, storageShortLink.ApplyChange(ctx, change))
+		}
+	}
+
+	nameMyTest := "insert from other instance"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		// ссылка закеширована как незарегистрированная
+		statusCode, _ := getRedirect("CHANGES2")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		applyPayload(t, `{"op":"INSERT","id":"2","short_link":"CHANGES2","full_url":"https://changes2.com"}`)
+
+		statusCode, location := getRedirect("CHANGES2")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		assert.Equal(t, "https://changes2.com", location)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "update from other instance"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		// ссылка закеширована со старым адресом
+		_, location := getRedirect("CHANGES1")
+		assert.Equal(t, "https://changes1.com", location)
+
+		applyPayload(t, `{"op":"UPDATE","id":"1","short_link":"CHANGED1","full_url":"https://changed1.com","old_short_link":"CHANGES1"}`)
+
+		statusCode, _ := getRedirect("CHANGES1")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		statusCode, location = getRedirect("CHANGED1")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		assert.Equal(t, "https://changed1.com", location)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "delete from other instance"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		applyPayload(t, `{"op":"DELETE","old_short_link":"CHANGES2"}`)
+
+		statusCode, _ := getRedirect("CHANGES2")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "resync after missed notifications"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		// в источнике данных лежит только то, что записал этот экземпляр
+		applyPayload(t, `{"op":"RESYNC"}`)
+
+		statusCode, location := getRedirect("CHANGES1")
+		assert.Equal(t, http.StatusTemporaryRedirect, statusCode)
+		assert.Equal(t, "https://changes1.com", location)
+		statusCode, _ = getRedirect("CHANGED1")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "invalid notification"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		_, err := dbconn.ParseNotificationChange(`{"op":"MERGE"}`)
+		assert.Error(t, err)
+		_, err = dbconn.ParseNotificationChange(`not json`)
+		assert.Error(t, err)
+
+		change, err := dbconn.ParseNotificationChange(`{"op":"DELETE","old_short_link":"CHANGES1"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, modelsStorage.ChangeOperationDelete, change.Operation)
+		assert.Equal(t, "CHANGES1", change.OldShortLink)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+}
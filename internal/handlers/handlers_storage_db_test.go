@@ -36,11 +36,14 @@ func TestDBStorageHandlerServer(t *testing.T) {
 	configApp.SetLevelLogs(6)
 	//--- End устанавливаем данные конфигурации для теста
 
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
 	// контекст
 	ctx := context.TODO()
 
 	defer func() {
-		var storageShortLink, errCreate = storagedb.NewStorageShorts()
+		var storageShortLink, errCreate = storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 		if errCreate == nil {
 			err := storageShortLink.ClearStorage(ctx)
 			if err != nil {
@@ -65,7 +68,7 @@ func TestDBStorageHandlerServer(t *testing.T) {
 	nameMyTest := "Check create DB storage"
 	t.Run(nameMyTest, func(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
-		_, err := storagedb.NewStorageShorts()
+		_, err := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 		assert.NoError(t, err)
 		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
 
@@ -78,7 +81,7 @@ func TestDBStorageHandlerServer(t *testing.T) {
 	}
 
 	// создаем пустое хранилище
-	storageShortLink, _ := storagedb.NewStorageShorts()
+	storageShortLink, _ := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 
 	testFullURL1 := "https://dsdsdsdds.com"
 	testShortLink1 := "UUUUUUUU"
@@ -100,7 +103,7 @@ func TestDBStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
 
 		// новое хранилище
-		storageShortLink, _ := storagedb.NewStorageShorts()
+		storageShortLink, _ := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 		// вверху добавили две ссылки, проверяем, что в хранилище ТРИ ссылки
 		countLinks, _ := storageShortLink.GetCountLink(ctx)
 		assert.Equal(t, countLinks, 3)
@@ -114,7 +117,7 @@ func TestDBStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
 
 		// новое хранилище
-		storageShortLink, _ := storagedb.NewStorageShorts()
+		storageShortLink, _ := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 
 		// запрашиваем 3 ссылки
 		// должны 2 получить, одна не существующая
@@ -164,7 +167,7 @@ func TestDBStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
 
 		// новое хранилище
-		storageShortLink, _ := storagedb.NewStorageShorts()
+		storageShortLink, _ := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 
 		listBatch := modelsStorage.DataStorageShortLink{
 			testShortLink1: modelsStorage.RowStorageShortLink{
@@ -224,7 +227,7 @@ func TestDBStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
 
 		// новое хранилище
-		storageShortLink, _ := storagedb.NewStorageShorts()
+		storageShortLink, _ := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 
 		// копия существующего url
 		testDoubleFullURL := testFullURL1
@@ -285,14 +288,12 @@ func TestDBStorageHandlerServer(t *testing.T) {
 
 			logger.GetLogger().Debugf("### Начало теста: %s", tt.name)
 
-			configApp := config.GetAppConfig()
-
 			// новое хранилище, при инициализации должно заполниться
-			storageShortLink, _ := storagedb.NewStorageShorts()
+			storageShortLink, _ := storagedb.NewStorageShorts(dbconn.GetDBHandler(), config.GetAppConfig().GetNameTableRestorer())
 			logger.GetLogger().Debugf("Восстановленные данные хранилища ссылок: %+v", storageShortLink)
 
-			serviceShortLink := service.NewServiceShortLink(storageShortLink, configApp)
-			handlerRouter := NewRouterHandler(serviceShortLink)
+			serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+			handlerRouter := NewRouterHandler(serviceShortLink, deps)
 			serverTest := httptest.NewServer(handlerRouter)
 
 			logger.GetLogger().Debugln("Сервер подняли на адресе: " + serverTest.URL)
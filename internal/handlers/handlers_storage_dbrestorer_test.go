@@ -35,11 +35,14 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 	configApp.SetLevelLogs(6)
 	//--- End устанавливаем данные конфигурации для теста
 
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
 	// контекст
 	ctx := context.TODO()
 
 	defer func() {
-		var storageShortLink, errCreate = storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		var storageShortLink, errCreate = storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 		if errCreate == nil {
 			err := storageShortLink.ClearStorage(ctx)
 			if err != nil {
@@ -64,7 +67,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 	nameMyTest := "Check create DB storage"
 	t.Run(nameMyTest, func(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
-		_, err := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		_, err := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 		assert.NoError(t, err)
 		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
 
@@ -77,7 +80,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 	}
 
 	// создаем пустое хранилище
-	storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+	storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 
 	testFullURL1 := "https://dsdsdsdds.com"
 	testShortLink1 := "UUUUUUUU"
@@ -94,7 +97,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 	logger.GetLogger().Debugf("Установили данные хранилища ссылок: %+v", storageShortLink)
 
 	defer func() {
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 		dbRestorer, _ := storageShortLink.GetRestorer()
 		err := dbRestorer.ClearRows()
 		if err != nil {
@@ -111,7 +114,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 		// вверху добавили две ссылки, проверяем, что в хранилище три ссылки
 		countLinks, _ := storageShortLink.GetCountLink(ctx)
 		assert.Equal(t, countLinks, 3)
@@ -125,7 +128,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 
 		// запрашиваем 3 ссылки
 		// должны 2 получить, одна не существующая
@@ -173,7 +176,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 
 		listBatch := modelsStorage.DataStorageShortLink{
 			testShortLink1: modelsStorage.RowStorageShortLink{
@@ -233,7 +236,7 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 
 		// копия существующего url
 		testDoubleFullURL := testFullURL1
@@ -294,14 +297,12 @@ func TestDBRestorerStorageHandlerServer(t *testing.T) {
 
 			logger.GetLogger().Debugf("### Начало теста: %s", tt.name)
 
-			configApp := config.GetAppConfig()
-
 			// новое хранилище, при инициализации должно заполниться
-			storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(nameTestTable)
+			storageShortLink, _ := storagerestorer.NewStorageShortsFromDB(dbconn.GetDBHandler(), nameTestTable)
 			logger.GetLogger().Debugf("Восстановленные данные хранилища ссылок: %+v", storageShortLink)
 
-			serviceShortLink := service.NewServiceShortLink(storageShortLink, configApp)
-			handlerRouter := NewRouterHandler(serviceShortLink)
+			serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+			handlerRouter := NewRouterHandler(serviceShortLink, deps)
 			serverTest := httptest.NewServer(handlerRouter)
 
 			logger.GetLogger().Debugln("Сервер подняли на адресе: " + serverTest.URL)
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storagefailover "go-url-shortener/internal/storage/storageshortlink/storagefailover"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestPrimaryDown = errors.New("тестовая ошибка: база данных недоступна")
+
+// основное хранилище, которое можно "выключить" в тесте
+type flakyTestStorage struct {
+	storagememory.StorageShortInterface
+	isDown *atomic.Bool
+}
+
+func (store flakyTestStorage) GetFullLinkByShort(ctx context.Context, shortLink string) (string, error) {
+	if store.isDown.Load() {
+		return "", errTestPrimaryDown
+	}
+	return store.StorageShortInterface.GetFullLinkByShort(ctx, shortLink)
+}
+
+func (store flakyTestStorage) GetShortLinkByURL(ctx context.Context, fullURL string) (string, error) {
+	if store.isDown.Load() {
+		return "", errTestPrimaryDown
+	}
+	return store.StorageShortInterface.GetShortLinkByURL(ctx, fullURL)
+}
+
+func (store flakyTestStorage) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) error {
+	if store.isDown.Load() {
+		return errTestPrimaryDown
+	}
+	return store.StorageShortInterface.AddShortLinkForURL(ctx, fullURL, shortLink)
+}
+
+func (store flakyTestStorage) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (modelsStorage.DataStorageShortLink, error) {
+	if store.isDown.Load() {
+		return nil, errTestPrimaryDown
+	}
+	return store.StorageShortInterface.GetShortLinks(ctx, options)
+}
+
+// проверка доступности "выключаемого" хранилища
+type flakyTestPing struct {
+	isDown *atomic.Bool
+}
+
+func (ping flakyTestPing) Ping() error {
+	if ping.isDown.Load() {
+		return errTestPrimaryDown
+	}
+	return nil
+}
+
+// Это тесты переключения хранилища при недоступности БД
+func TestFailoverStorageHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя временного файла с журналом
+	pathJournalFile := os.TempDir() + "/storage/testFailoverJournal.json"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	// контекст
+	ctx := context.TODO()
+
+	os.Remove(pathJournalFile)
+	defer os.Remove(pathJournalFile)
+
+	isDown := &atomic.Bool{}
+	storageMemory, _ := storagememory.NewStorageShorts("", 0, config.GetAppConfig())
+	primary := flakyTestStorage{
+		StorageShortInterface: storageMemory,
+		isDown:                isDown,
+	}
+	journal, err := fileRestorer.NewFileRestorer(pathJournalFile, config.GetAppConfig())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	storageShortLink, err := storagefailover.NewStorageShortsWithBackoff(primary, flakyTestPing{isDown: isDown}, journal,
+		time.Hour, 10*time.Millisecond, 50*time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer storageShortLink.Close(ctx)
+
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+	handler := NewRouterHandler(serviceShortLink, deps)
+
+	testFullURL1 := "https://failover1.com"
+	testShortLink1 := "FAILOVR1"
+	storageShortLink.AddShortLinkForURL(ctx, testFullURL1, testShortLink1)
+
+	nameMyTest := "read from cache while database is down"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		isDown.Store(true)
+
+		request := httptest.NewRequest(http.MethodGet, "/"+testShortLink1, nil)
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		assert.Equal(t, testFullURL1, res.Header.Get("Location"))
+		assert.Equal(t, false, storageShortLink.IsPrimaryHealthy())
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "write to journal while database is down"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://failover2.com"))
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		// дубль определяется по локальным данным
+		request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testFullURL1))
+		respWriter = httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res = respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		// в БД ссылки нет, она в журнале
+		shortLink, _ := storageMemory.GetShortLinkByURL(ctx, "https://failover2.com")
+		assert.Equal(t, "", shortLink)
+		dataJournal, _ := os.ReadFile(pathJournalFile)
+		assert.Equal(t, true, strings.Contains(string(dataJournal), "https://failover2.com"))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "replay journal with conflicts after recovery"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		// пока БД была недоступна для нас, другие экземпляры сервиса добавили конфликтующие ссылки
+		storageShortLink.AddShortLinkForURL(ctx, "https://failover3.com", "JOURNAL3")
+		storageShortLink.AddShortLinkForURL(ctx, "https://failover4.com", "JOURNAL4")
+		storageMemory.AddShortLinkForURL(ctx, "https://failover3.com", "OTHER333")
+		storageMemory.AddShortLinkForURL(ctx, "https://other4.com", "JOURNAL4")
+
+		isDown.Store(false)
+		assert.Eventually(t, storageShortLink.IsPrimaryHealthy, time.Second, 10*time.Millisecond)
+
+		// ссылка из журнала попала в БД
+		shortLink, _ := storageMemory.GetShortLinkByURL(ctx, "https://failover2.com")
+		assert.NotEqual(t, "", shortLink)
+
+		// конфликт FULL_URL: побеждает БД
+		shortLink, _ = storageShortLink.GetShortLinkByURL(ctx, "https://failover3.com")
+		assert.Equal(t, "OTHER333", shortLink)
+
+		// конфликт SHORT_LINK: выданная короткая ссылка не переназначается, запись остается в журнале
+		fullURL, _ := storageMemory.GetFullLinkByShort(ctx, "JOURNAL4")
+		assert.Equal(t, "https://other4.com", fullURL)
+		shortLink, _ = storageMemory.GetShortLinkByURL(ctx, "https://failover4.com")
+		assert.Equal(t, "", shortLink)
+
+		// в журнале только запись с конфликтом SHORT_LINK
+		dataJournal, _ := os.ReadFile(pathJournalFile)
+		assert.Equal(t, true, strings.Contains(string(dataJournal), "https://failover4.com"))
+		assert.Equal(t, false, strings.Contains(string(dataJournal), "https://failover2.com"))
+		assert.Equal(t, false, strings.Contains(string(dataJournal), "https://failover3.com"))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "changes from database update local data"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		// другой экземпляр сервиса добавил ссылку, уведомление пришло до отключения БД
+		storageMemory.AddShortLinkForURL(ctx, "https://failover5.com", "NOTIFY55")
+		err := storageShortLink.ApplyChange(ctx, modelsStorage.ChangeShortLink{
+			Operation: modelsStorage.ChangeOperationInsert,
+			Row:       modelsStorage.RowStorageShortLink{ShortLink: "NOTIFY55", FullURL: "https://failover5.com", UUID: "55"},
+		})
+		assert.NoError(t, err)
+
+		isDown.Store(true)
+		defer isDown.Store(false)
+
+		fullURL, err := storageShortLink.GetFullLinkByShort(ctx, "NOTIFY55")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://failover5.com", fullURL)
+
+		// удаление ссылки тоже доходит до локальных данных
+		err = storageShortLink.ApplyChange(ctx, modelsStorage.ChangeShortLink{
+			Operation:    modelsStorage.ChangeOperationDelete,
+			OldShortLink: "NOTIFY55",
+		})
+		assert.NoError(t, err)
+		_, err = storageShortLink.GetFullLinkByShort(ctx, "NOTIFY55")
+		assert.ErrorIs(t, err, modelsStorage.ErrNotFoundShortLink)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+}
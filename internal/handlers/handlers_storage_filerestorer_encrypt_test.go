@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"go-url-shortener/internal/app/commands"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты шифрования файла хранилища
+func TestFileRestorerEncryptStorage(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя временного файла с хранилищем
+	pathTempFile := os.TempDir() + "/storage/testStorageEncrypt.json"
+	// файл с ключами шифрования
+	pathKeyFile := os.TempDir() + "/storage/testStorageEncrypt.keys"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	key1 := "k1:" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	key2 := "k2:" + base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+
+	// контекст
+	ctx := context.TODO()
+
+	defer func() {
+		// ключи в конфиге общие для всех тестов, сбрасываем их
+		configApp.SetFileStorageKey("")
+		configApp.SetFileStorageKeyFile("")
+		os.Remove(pathTempFile)
+		os.Remove(pathKeyFile)
+	}()
+
+	// в файле уже есть старая незашифрованная запись
+	os.MkdirAll(os.TempDir()+"/storage", 0777)
+	testLegacyFullURL := "https://legacy.com/?token=plain"
+	testLegacyShortLink := "LEGACY11"
+	legacyRow := "{\"ShortLink\":\"" + testLegacyShortLink + "\",\"FullURL\":\"" + testLegacyFullURL + "\",\"UUID\":\"1\"}\n"
+	errWrite := os.WriteFile(pathTempFile, []byte(legacyRow), 0644)
+	if !assert.NoError(t, errWrite) {
+		return
+	}
+
+	configApp.SetFileStorageKey(key1)
+
+	testFullURL := "https://secret.com/?token=very_secret_token"
+	testShortLink := "SECRET11"
+
+	nameMyTest := "write encrypted row"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		storageShortLink, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = storageShortLink.AddShortLinkForURL(ctx, testFullURL, testShortLink)
+		assert.NoError(t, err)
+
+		// в файле не должно быть ссылки открытым текстом
+		dataFile, _ := os.ReadFile(pathTempFile)
+		assert.Equal(t, false, strings.Contains(string(dataFile), "very_secret_token"))
+		assert.Equal(t, true, strings.Contains(string(dataFile), "\"KeyID\":\"k1\""))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "restore encrypted and legacy rows"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		storageShortLink, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		countLinks, _ := storageShortLink.GetCountLink(ctx)
+		assert.Equal(t, 2, countLinks)
+
+		fullURL, err := storageShortLink.GetFullLinkByShort(ctx, testShortLink)
+		assert.NoError(t, err)
+		assert.Equal(t, testFullURL, fullURL)
+
+		fullURL, err = storageShortLink.GetFullLinkByShort(ctx, testLegacyShortLink)
+		assert.NoError(t, err)
+		assert.Equal(t, testLegacyFullURL, fullURL)
+
+		// редирект тоже работает по зашифрованному хранилищу
+		serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+		request := httptest.NewRequest(http.MethodGet, "/"+testShortLink, nil)
+		respWriter := httptest.NewRecorder()
+		NewRouterHandler(serviceShortLink, deps).ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		assert.Equal(t, testFullURL, res.Header.Get("Location"))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "reencrypt with new key"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		// новый ключ первый - активный, старый нужен для чтения
+		configApp.SetFileStorageKey("")
+		errWrite := os.WriteFile(pathKeyFile, []byte("# ключи хранилища\n"+key2+"\n"+key1+"\n"), 0600)
+		if !assert.NoError(t, errWrite) {
+			return
+		}
+		configApp.SetFileStorageKeyFile(pathKeyFile)
+
+		restorer, err := fileRestorer.NewFileRestorer(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		countRows, err := restorer.ReEncrypt()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, countRows)
+
+		dataFile, _ := os.ReadFile(pathTempFile)
+		assert.Equal(t, false, strings.Contains(string(dataFile), "\"KeyID\":\"k1\""))
+		assert.Equal(t, false, strings.Contains(string(dataFile), testLegacyFullURL))
+
+		// после ротации старый ключ больше не нужен
+		configApp.SetFileStorageKeyFile("")
+		configApp.SetFileStorageKey(key2)
+		storageShortLink, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		countLinks, _ := storageShortLink.GetCountLink(ctx)
+		assert.Equal(t, 2, countLinks)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "encrypted rows are not read without key"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		configApp.SetFileStorageKey("")
+		storageShortLink, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		countLinks, _ := storageShortLink.GetCountLink(ctx)
+		assert.Equal(t, 0, countLinks)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "reencrypt is refused while storage is open"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		if runtime.GOOS == "windows" {
+			t.Skip("блокировка файла хранилища между процессами работает только на unix")
+		}
+
+		pathFileStorage := configApp.GetFileStoragePath()
+		defer configApp.SetFileStoragePath(pathFileStorage)
+		pathLockedFile := filepath.Join(t.TempDir(), "storage.json")
+		configApp.SetFileStoragePath(pathLockedFile)
+		configApp.SetFileStorageKey(key2)
+
+		storageShortLink, err := storagerestorer.NewStorageShortsFromFileStorage(pathLockedFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, storageShortLink.AddShortLinkForURL(ctx, testFullURL, testShortLink))
+
+		// сервер держит файл, перешифровать его нельзя
+		_, err = commands.Run(configApp, []string{"reencrypt"})
+		assert.ErrorIs(t, err, fileRestorer.ErrFileLocked)
+
+		storageCloser, ok := storageShortLink.(modelsStorage.StorageCloserInterface)
+		if !assert.Equal(t, true, ok) {
+			return
+		}
+		assert.NoError(t, storageCloser.Close(ctx))
+
+		_, err = commands.Run(configApp, []string{"reencrypt"})
+		assert.NoError(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"go-url-shortener/internal/logger"
 	modelsStorage "go-url-shortener/internal/models/storageshortlink"
 	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
 	"os"
 	"strings"
 
@@ -35,6 +36,9 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 	configApp.SetLevelLogs(6)
 	//--- End устанавливаем данные конфигурации для теста
 
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
 	// печатаем расположение лога после инициализации конфига
 	logger.GetLogger().Debugf("Путь до файла хранилища ссылок: %+v", pathTempFile)
 
@@ -42,7 +46,7 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 	ctx := context.TODO()
 
 	defer func() {
-		var storageShortLink, _ = storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+		var storageShortLink, _ = storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 		err := storageShortLink.ClearStorage(ctx)
 		if err != nil {
 			logger.GetLogger().Debug("не смогли очистить данные хранилища: " + err.Error())
@@ -57,7 +61,7 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 	nameMyTest := "check create File storage"
 	t.Run(nameMyTest, func(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
-		_, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+		_, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 		assert.NoError(t, err)
 		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
 
@@ -70,7 +74,7 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 	}
 
 	// создаем пустое хранилище
-	storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+	storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 
 	testFullURL1 := "https://dsdsdsdds_W.com"
 	testShortLink1 := "UUUUUUUU"
@@ -92,7 +96,7 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 		// вверху добавили две ссылки, проверяем, что в хранилище три ссылки
 		countLinks, _ := storageShortLink.GetCountLink(ctx)
 		data, _ := storageShortLink.GetShortLinks(ctx, nil)
@@ -103,13 +107,43 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 
 	})
 
+	nameMyTest21 := "stream rows from restorer"
+	t.Run(nameMyTest21, func(t *testing.T) {
+
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest21)
+
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		storageRestorer, _ := storageShortLink.GetRestorer()
+
+		// читаем все строки по одной в порядке записи
+		listShortLinks := []string{}
+		err := storageRestorer.ReadEach(context.Background(), func(dataRow restorer.RowDataRestorer) error {
+			listShortLinks = append(listShortLinks, dataRow.ShortLink)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{testShortLink1, testShortLink2, testShortLink3}, listShortLinks)
+
+		// ошибка обработчика прекращает чтение
+		errStop := errors.New("остановка чтения")
+		countRead := 0
+		err = storageRestorer.ReadEach(context.Background(), func(dataRow restorer.RowDataRestorer) error {
+			countRead++
+			return errStop
+		})
+		assert.Equal(t, true, errors.Is(err, errStop))
+		assert.Equal(t, 1, countRead)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest21)
+	})
+
 	nameMyTest3 := "get short link with filter"
 	t.Run(nameMyTest3, func(t *testing.T) {
 
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 
 		// запрашиваем 3 ссылки
 		// должны 2 получить, одна не существующая
@@ -157,7 +191,7 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 
 		listBatch := modelsStorage.DataStorageShortLink{
 			testShortLink1: modelsStorage.RowStorageShortLink{
@@ -217,7 +251,7 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
 
 		// новое хранилище, при инициализации должно заполниться
-		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+		storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 
 		// копия существующего url
 		testDoubleFullURL := testFullURL1
@@ -297,14 +331,12 @@ func TestFileRestorerStorageHandlerServer(t *testing.T) {
 
 			logger.GetLogger().Debugf("### Начало теста: %s", tt.name)
 
-			configApp := config.GetAppConfig()
-
 			// новое хранилище, при инициализации должно заполниться
-			storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile)
+			storageShortLink, _ := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
 			logger.GetLogger().Debugf("Восстановленные данные хранилища ссылок: %+v", storageShortLink)
 
-			serviceShortLink := service.NewServiceShortLink(storageShortLink, configApp)
-			handlerRouter := NewRouterHandler(serviceShortLink)
+			serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+			handlerRouter := NewRouterHandler(serviceShortLink, deps)
 			serverTest := httptest.NewServer(handlerRouter)
 
 			logger.GetLogger().Debugln("Сервер подняли на адресе: " + serverTest.URL)
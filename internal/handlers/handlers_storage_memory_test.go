@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	"os"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты хранилища только в памяти
+func TestMemoryStorageHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя временного файла со снимком хранилища
+	pathSnapshotFile := os.TempDir() + "/storage/testMemorySnapshot.json"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
+	// контекст
+	ctx := context.TODO()
+
+	os.Remove(pathSnapshotFile)
+	defer os.Remove(pathSnapshotFile)
+
+	testFullURL1 := "https://memory1.com"
+	testShortLink1 := "MEMORY11"
+
+	nameMyTest := "work without snapshot"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		storageShortLink, err := storagememory.NewStorageShorts("", 0, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer storageShortLink.Close(ctx)
+
+		err = storageShortLink.AddShortLinkForURL(ctx, testFullURL1, testShortLink1)
+		assert.NoError(t, err)
+
+		serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
+		handler := NewRouterHandler(serviceShortLink, deps)
+
+		// дубль полной ссылки
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testFullURL1))
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		// редирект
+		request = httptest.NewRequest(http.MethodGet, "/"+testShortLink1, nil)
+		respWriter = httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res = respWriter.Result()
+		res.Body.Close()
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		assert.Equal(t, testFullURL1, res.Header.Get("Location"))
+
+		// файл снимка не создается
+		_, errStat := os.Stat(pathSnapshotFile)
+		assert.Equal(t, true, os.IsNotExist(errStat))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "snapshot on close and restore"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		storageShortLink, err := storagememory.NewStorageShorts(pathSnapshotFile, 0, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		storageShortLink.AddShortLinkForURL(ctx, testFullURL1, testShortLink1)
+		storageShortLink.AddShortLinkForURL(ctx, "https://memory2.com", "MEMORY22")
+		dataBefore, _ := storageShortLink.GetShortLinks(ctx, nil)
+
+		err = storageShortLink.Close(ctx)
+		assert.NoError(t, err)
+
+		storageRestored, err := storagememory.NewStorageShorts(pathSnapshotFile, 0, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer storageRestored.Close(ctx)
+
+		countLinks, _ := storageRestored.GetCountLink(ctx)
+		assert.Equal(t, 2, countLinks)
+		fullURL, err := storageRestored.GetFullLinkByShort(ctx, testShortLink1)
+		assert.NoError(t, err)
+		assert.Equal(t, testFullURL1, fullURL)
+
+		// uuid восстановленных ссылок не меняются, а новые uuid с ними не совпадают
+		dataRestored, _ := storageRestored.GetShortLinks(ctx, nil)
+		for shortLink, dataRow := range dataBefore {
+			assert.Equal(t, dataRow.UUID, dataRestored[shortLink].UUID)
+		}
+		storageRestored.AddShortLinkForURL(ctx, "https://memory4.com", "MEMORY44")
+		dataRestored, _ = storageRestored.GetShortLinks(ctx, nil)
+		for shortLink, dataRow := range dataBefore {
+			assert.NotEqual(t, dataRow.UUID, dataRestored["MEMORY44"].UUID, shortLink)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "periodic snapshot"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		storageShortLink, err := storagememory.NewStorageShorts(pathSnapshotFile, 10*time.Millisecond, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer storageShortLink.Close(ctx)
+
+		storageShortLink.AddShortLinkForURL(ctx, "https://memory3.com", "MEMORY33")
+
+		// ждем, пока снимок запишется по таймеру
+		assert.Eventually(t, func() bool {
+			dataFile, _ := os.ReadFile(pathSnapshotFile)
+			return strings.Contains(string(dataFile), "MEMORY33")
+		}, time.Second, 10*time.Millisecond)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/database/identifier"
+	"go-url-shortener/internal/database/migrations"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"strings"
+
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты проверки имени таблицы коротких ссылок
+func TestTableName(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	nameMyTest := "valid names"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		tests := []struct {
+			value    string
+			name     string
+			sanitize string
+			channel  string
+		}{
+			{value: "shortlinks", name: "shortlinks", sanitize: `"shortlinks"`, channel: "changes_shortlinks"},
+			// без кавычек Postgres приводит имя к нижнему регистру, поэтому старые таблицы находятся
+			{value: "ShortLinks", name: "shortlinks", sanitize: `"shortlinks"`, channel: "changes_shortlinks"},
+			{value: "_links_2", name: "_links_2", sanitize: `"_links_2"`, channel: "changes__links_2"},
+			{value: "public.links", name: "public.links", sanitize: `"public"."links"`, channel: "changes_public_links"},
+		}
+		for _, tt := range tests {
+			tableName, err := identifier.ParseTableName(tt.value)
+			if !assert.NoError(t, err, tt.value) {
+				continue
+			}
+			assert.Equal(t, tt.name, tableName.String())
+			assert.Equal(t, tt.sanitize, tableName.Sanitize())
+			assert.Equal(t, tt.channel, dbconn.GetChannelChanges(tableName.String()))
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "hostile names"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		listNames := []string{
+			"",
+			"links; DROP TABLE users",
+			"links--",
+			`"links"`,
+			`links"; DROP TABLE users; --`,
+			"links (ID)",
+			"links name",
+			"1links",
+			"public.",
+			".links",
+			"a.b.c",
+			"public.links;",
+			"ссылки",
+			"links\x00",
+			strings.Repeat("a", 64),
+			// имя канала уведомлений не помещается в 63 символа
+			strings.Repeat("a", 30) + "." + strings.Repeat("b", 30),
+		}
+		for _, value := range listNames {
+			_, err := identifier.ParseTableName(value)
+			assert.Error(t, err, value)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "migrations with schema"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		// пул открывает соединения лениво, для чтения встроенных миграций БД не нужна
+		db, err := pgxpool.New(context.TODO(), "postgres://localhost/none")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer db.Close()
+
+		migrator, err := migrations.NewMigrator(db, "Public.Links")
+		if !assert.NoError(t, err) {
+			return
+		}
+		listMigrations := migrator.GetMigrations()
+		assert.Equal(t, true, strings.Contains(listMigrations[0].UpSQL, `CREATE TABLE IF NOT EXISTS "public"."links"`))
+		assert.Equal(t, true, strings.Contains(listMigrations[0].UpSQL, `CREATE INDEX IF NOT EXISTS "short_link_index_public_links" ON "public"."links"`))
+		assert.Equal(t, true, strings.Contains(listMigrations[1].UpSQL, `FUNCTION "public"."notify_changes_public_links"()`))
+		assert.Equal(t, true, strings.Contains(listMigrations[2].UpSQL, `DROP INDEX IF EXISTS "public"."full_url_index_public_links"`))
+
+		_, err = migrations.NewMigrator(db, "links; DROP TABLE users")
+		assert.Error(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest4 := "storage refuses invalid name"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		oldNameTable := configApp.GetNameTableRestorer()
+		defer configApp.SetNameTableRestorer(oldNameTable)
+		configApp.SetNameTableRestorer("links; DROP TABLE users")
+
+		for _, backend := range []string{modelsStorage.StorageBackendPostgres, modelsStorage.StorageBackendPostgresCached, modelsStorage.StorageBackendPostgresFailover} {
+			_, err := storageShort.NewStorageShortsByBackend(backend, storageShort.GetDefaultDependencies())
+			if assert.Error(t, err, backend) {
+				assert.Equal(t, true, strings.Contains(err.Error(), "некорректное имя таблицы"), err.Error())
+			}
+		}
+
+		// хранилищам без БД имя таблицы не нужно
+		_, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		assert.NoError(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+
+	nameMyTest5 := "names of table objects fit identifier limit"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		// самое длинное имя по таблице - индекс WORKSPACE_FULL_URL_HASH_index_<таблица>
+		tests := []struct {
+			value   string
+			isValid bool
+		}{
+			{value: strings.Repeat("a", 33), isValid: true},
+			{value: strings.Repeat("a", 34), isValid: false},
+			{value: "public." + strings.Repeat("a", 26), isValid: true},
+			{value: "public." + strings.Repeat("a", 27), isValid: false},
+		}
+		for _, tt := range tests {
+			// имя канала помещается, поэтому само имя таблицы корректно
+			_, err := identifier.ParseTableName(tt.value)
+			assert.NoError(t, err, tt.value)
+
+			tableName, err := migrations.ParseTableName(tt.value)
+			if !tt.isValid {
+				assert.Error(t, err, tt.value)
+				continue
+			}
+			if !assert.NoError(t, err, tt.value) {
+				continue
+			}
+			channel := dbconn.GetChannelChanges(tableName.String())
+			listNames := []string{
+				channel,
+				"notify_" + channel + "_truncate",
+				tableName.JoinName(migrations.PrefixIndexFullURL),
+				tableName.JoinName(migrations.PrefixIndexShortLink),
+				tableName.JoinName("WORKSPACE_FULL_URL_HASH_index"),
+			}
+			for _, name := range listNames {
+				assert.LessOrEqual(t, len(name), identifier.MaxLengthIdentifier, name)
+			}
+		}
+
+		// миграции и хранилища не создаются для слишком длинного имени
+		db, err := pgxpool.New(context.TODO(), "postgres://localhost/none")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer db.Close()
+
+		_, err = migrations.NewMigrator(db, strings.Repeat("a", 33))
+		assert.NoError(t, err)
+		_, err = migrations.NewMigrator(db, strings.Repeat("a", 34))
+		assert.Error(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+}
@@ -6,10 +6,14 @@ import (
 	"go-url-shortener/internal/app/service"
 	"go-url-shortener/internal/config"
 	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/health"
 	"go-url-shortener/internal/logger"
+	"go-url-shortener/internal/metrics"
 	modelsService "go-url-shortener/internal/models/service"
 	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"go-url-shortener/internal/shortdomains"
 	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"go-url-shortener/internal/workspaces"
 	"io"
 	"os"
 
@@ -36,11 +40,14 @@ func TestNewRouterHandlerNoServer(t *testing.T) {
 	configApp.SetLevelLogs(6)
 	//--- End устанавливаем данные конфигурации для теста
 
+	// домены, рабочие пространства и метрики обработчиков теста
+	deps := newTestDependencies(t, configApp)
+
 	// контекст
 	ctx := context.TODO()
 
 	defer func() {
-		var storageShortLink = storageShort.NewStorageShorts()
+		var storageShortLink = storageShort.NewStorageShorts(storageShort.GetDefaultDependencies())
 		err := storageShortLink.ClearStorage(ctx)
 		if err != nil {
 			logger.GetLogger().Debug("не смогли очистить данные хранилища: " + err.Error())
@@ -51,7 +58,7 @@ func TestNewRouterHandlerNoServer(t *testing.T) {
 	}()
 
 	// заполняем данными хранилище
-	var storageShortLink = storageShort.NewStorageShorts()
+	var storageShortLink = storageShort.NewStorageShorts(storageShort.GetDefaultDependencies())
 	testFullURL1 := "https://testSite.com"
 	testShortLink1 := "RRRTTTTT"
 	testFullURL2 := "https://dsdsdsdds.com"
@@ -81,7 +88,7 @@ func TestNewRouterHandlerNoServer(t *testing.T) {
 	//logger.GetLogger().Debugf("Установили данные хранилища ссылок: %+v", dataStore)
 
 	// сервис на конфиге
-	serviceShortLink := service.NewServiceShortLink(storageShortLink, configApp)
+	serviceShortLink := service.NewServiceShortLink(storageShortLink, deps.Dependencies)
 
 	// хост сервиса
 	hostService := configApp.GetHostShortLink()
@@ -312,7 +319,7 @@ func TestNewRouterHandlerNoServer(t *testing.T) {
 
 			respWriter := httptest.NewRecorder()
 
-			handler := NewRouterHandler(tt.serviceShortLink)
+			handler := NewRouterHandler(tt.serviceShortLink, deps)
 			handler.ServeHTTP(respWriter, request)
 
 			res := respWriter.Result()
@@ -346,3 +353,24 @@ func TestNewRouterHandlerNoServer(t *testing.T) {
 		})
 	}
 }
+
+// Зависимости обработчиков теста: домены и рабочие пространства из настроек конфигурации,
+// свои метрики и признак остановки, соединение с БД общей конфигурации
+func newTestDependencies(t testing.TB, configApp config.ConfigTypeInterface) Dependencies {
+
+	registryDomains, err := shortdomains.NewRegistryFromConfig(configApp)
+	assert.NoError(t, err)
+	registryWorkspaces, err := workspaces.LoadRegistryFromConfig(configApp)
+	assert.NoError(t, err)
+
+	return Dependencies{
+		Dependencies: service.Dependencies{
+			Config:       configApp,
+			ShortDomains: registryDomains,
+			Workspaces:   registryWorkspaces,
+			Metrics:      metrics.NewAppMetrics(),
+		},
+		DBHandler: dbconn.GetDBHandler(),
+		Health:    health.NewStatus(),
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"go-url-shortener/internal/app/service"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	modelsWorkspace "go-url-shortener/internal/models/workspace"
+	storageShort "go-url-shortener/internal/storage/storageshortlink"
+	"go-url-shortener/internal/storage/storageshortlink/storagecache"
+	"go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	cookiesUserData "go-url-shortener/internal/userdata/usercookies"
+	"go-url-shortener/internal/workspaces"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Это тесты рабочих пространств с отдельными наборами коротких ссылок
+func TestWorkspaceHandler(t *testing.T) {
+
+	//--- Start устанавливаем данные конфигурации для теста
+	// имя временного файла с хранилищем
+	pathTempFile := os.TempDir() + "/storage/testStorageWorkspace.json"
+	configApp := config.GetAppConfig()
+	// дебаг режим
+	configApp.SetLevelLogs(6)
+	//--- End устанавливаем данные конфигурации для теста
+
+	registry, err := workspaces.NewRegistry([]modelsWorkspace.Workspace{
+		{
+			ID:         "team-a",
+			Name:       "Команда A",
+			PathPrefix: "/team-a",
+			Members: []modelsWorkspace.Member{
+				{UserID: "admin-1", Role: modelsWorkspace.RoleAdmin},
+				{UserID: "user-1", Role: modelsWorkspace.RoleMember},
+			},
+		},
+		{
+			ID:     "team-b",
+			Domain: "b.short.ru",
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	deps := newTestDependencies(t, configApp)
+	deps.Workspaces = registry
+
+	// контекст
+	ctx := context.TODO()
+	ctxTeamA := modelsWorkspace.WithWorkspaceID(ctx, "team-a")
+	ctxTeamB := modelsWorkspace.WithWorkspaceID(ctx, "team-b")
+
+	// запрос к сервису от пользователя userID с подписанной кукой, пусто - без кук
+	doRequest := func(handler http.Handler, method, target, host, userID, body string) (statusCode int, bodyResponse string, location string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if host != "" {
+			request.Host = host
+		}
+		if userID != "" {
+			request.AddCookie(&http.Cookie{Name: cookiesUserData.NameCookiesUserID, Value: cookiesUserData.SignUserID(userID, configApp.GetUserIDSecret())})
+		}
+		respWriter := httptest.NewRecorder()
+		handler.ServeHTTP(respWriter, request)
+		res := respWriter.Result()
+		defer res.Body.Close()
+		bytesBody, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(bytesBody), res.Header.Get("Location")
+	}
+
+	nameMyTest := "same full url in different workspaces"
+	t.Run(nameMyTest, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		statusCode, shortURL, _ := doRequest(handler, http.MethodPost, "/", "", "", "https://same.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, false, strings.Contains(shortURL, "/team-a/"))
+
+		// в другом рабочем пространстве та же ссылка новая, а не дубль
+		statusCode, shortURLTeamA, _ := doRequest(handler, http.MethodPost, "/team-a/", "", "user-1", "https://same.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.Contains(shortURLTeamA, "/team-a/"))
+
+		statusCode, shortURLTeamB, _ := doRequest(handler, http.MethodPost, "/", "b.short.ru", "", "https://same.com")
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.Equal(t, true, strings.HasPrefix(shortURLTeamB, "http://b.short.ru/"))
+
+		// повтор в рабочем пространстве - дубль
+		statusCode, shortURLRepeat, _ := doRequest(handler, http.MethodPost, "/team-a/", "", "user-1", "https://same.com")
+		assert.Equal(t, http.StatusConflict, statusCode)
+		assert.Equal(t, shortURLTeamA, shortURLRepeat)
+
+		count, err := storage.GetCountLink(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		count, err = storage.GetCountLink(ctxTeamA)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest)
+	})
+
+	nameMyTest2 := "same short link in different workspaces"
+	t.Run(nameMyTest2, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest2)
+
+		storageMemory, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		storage, err := storagecache.NewStorageShorts(storageMemory, 100, time.Minute, time.Minute)
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		// незарегистрированная ссылка попадает в кеш только своего рабочего пространства
+		statusCode, _, _ := doRequest(handler, http.MethodGet, "/team-a/SAME0001", "", "", "")
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		assert.NoError(t, storage.AddShortLinkForURL(ctx, "https://default.com", "SAME0001"))
+		assert.NoError(t, storage.AddShortLinkForURL(ctxTeamA, "https://team-a.com", "SAME0001"))
+		assert.NoError(t, storage.AddShortLinkForURL(ctxTeamB, "https://team-b.com", "SAME0001"))
+
+		_, _, location := doRequest(handler, http.MethodGet, "/SAME0001", "", "", "")
+		assert.Equal(t, "https://default.com", location)
+		// переход по ссылке не требует участия в рабочем пространстве
+		_, _, location = doRequest(handler, http.MethodGet, "/team-a/SAME0001", "", "", "")
+		assert.Equal(t, "https://team-a.com", location)
+		_, _, location = doRequest(handler, http.MethodGet, "/SAME0001", "b.short.ru:8080", "", "")
+		assert.Equal(t, "https://team-b.com", location)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest2)
+	})
+
+	nameMyTest3 := "workspace membership"
+	t.Run(nameMyTest3, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest3)
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		statusCode, _, _ := doRequest(handler, http.MethodPost, "/team-a/", "", "", "https://member.com")
+		assert.Equal(t, http.StatusForbidden, statusCode)
+		statusCode, _, _ = doRequest(handler, http.MethodPost, "/team-a/api/shorten", "", "stranger", `{"url":"https://member.com"}`)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+		statusCode, _, _ = doRequest(handler, http.MethodPost, "/team-a/api/shorten", "", "user-1", `{"url":"https://member.com"}`)
+		assert.Equal(t, http.StatusCreated, statusCode)
+		statusCode, _, _ = doRequest(handler, http.MethodPost, "/team-a/api/shorten", "", "admin-1", `{"url":"https://admin.com"}`)
+		assert.Equal(t, http.StatusCreated, statusCode)
+		assert.NoError(t, storage.AddShortLinkForURL(ctx, "https://default.com", "DEFAULT1"))
+
+		// все ссылки рабочего пространства видит только администратор
+		statusCode, _, _ = doRequest(handler, http.MethodGet, "/team-a/api/workspace/urls", "", "user-1", "")
+		assert.Equal(t, http.StatusForbidden, statusCode)
+		statusCode, _, _ = doRequest(handler, http.MethodGet, "/api/workspace/urls", "", "admin-1", "")
+		assert.Equal(t, http.StatusForbidden, statusCode)
+
+		statusCode, body, _ := doRequest(handler, http.MethodGet, "/team-a/api/workspace/urls", "", "admin-1", "")
+		assert.Equal(t, http.StatusOK, statusCode)
+		listShortLinks := []modelsResponses.ResponseListShortLinks{}
+		if assert.NoError(t, json.Unmarshal([]byte(body), &listShortLinks)) {
+			listFullURL := []string{}
+			for _, row := range listShortLinks {
+				listFullURL = append(listFullURL, row.OriginalURL)
+				assert.Equal(t, true, strings.Contains(row.ShortURL, "/team-a/"))
+			}
+			assert.ElementsMatch(t, []string{"https://member.com", "https://admin.com"}, listFullURL)
+		}
+
+		statusCode, body, _ = doRequest(handler, http.MethodGet, "/team-a/api/workspace", "", "admin-1", "")
+		assert.Equal(t, http.StatusOK, statusCode)
+		dataWorkspace := modelsResponses.ResponseWorkspace{}
+		if assert.NoError(t, json.Unmarshal([]byte(body), &dataWorkspace)) {
+			assert.Equal(t, "team-a", dataWorkspace.ID)
+			assert.Equal(t, "admin-1", dataWorkspace.UserID)
+			assert.Equal(t, modelsWorkspace.RoleAdmin, dataWorkspace.Role)
+		}
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest3)
+	})
+
+	nameMyTest5 := "signed user id cookie"
+	t.Run(nameMyTest5, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest5)
+
+		configApp.SetUserIDSecret("test-user-id-secret")
+		defer configApp.SetUserIDSecret("")
+
+		storage, err := storageShort.NewStorageShortsByBackend(modelsStorage.StorageBackendMemory, storageShort.GetDefaultDependencies())
+		if !assert.NoError(t, err) {
+			return
+		}
+		handler := NewRouterHandler(service.NewServiceShortLink(storage, deps.Dependencies), deps)
+
+		doRequestCookie := func(valueCookie string) (res *http.Response) {
+			request := httptest.NewRequest(http.MethodGet, "/team-a/api/workspace/urls", nil)
+			request.AddCookie(&http.Cookie{Name: cookiesUserData.NameCookiesUserID, Value: valueCookie})
+			respWriter := httptest.NewRecorder()
+			handler.ServeHTTP(respWriter, request)
+			return respWriter.Result()
+		}
+
+		// идентификатор без подписи или с подписью другим ключом не дает роль
+		for _, valueCookie := range []string{"admin-1", cookiesUserData.SignUserID("admin-1", "other-secret"), "admin-1.", ""} {
+			res := doRequestCookie(valueCookie)
+			res.Body.Close()
+			assert.Equal(t, http.StatusForbidden, res.StatusCode, valueCookie)
+
+			// вместо такой куки пользователь получает новый идентификатор надолго
+			cookiesUserID := res.Cookies()
+			if assert.Equal(t, 1, len(cookiesUserID)) {
+				assert.Equal(t, cookiesUserData.NameCookiesUserID, cookiesUserID[0].Name)
+				assert.Equal(t, false, strings.HasPrefix(cookiesUserID[0].Value, "admin-1."))
+				assert.Greater(t, cookiesUserID[0].MaxAge, 30*24*60*60)
+			}
+		}
+
+		// в рабочем пространстве еще нет ссылок, но доступ к ним у администратора есть
+		res := doRequestCookie(cookiesUserData.SignUserID("admin-1", "test-user-id-secret"))
+		res.Body.Close()
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, 0, len(res.Cookies()))
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest5)
+	})
+
+	nameMyTest4 := "restore workspaces from file"
+	t.Run(nameMyTest4, func(t *testing.T) {
+		logger.GetLogger().Debugf("### Начало теста: %s", nameMyTest4)
+
+		os.Remove(pathTempFile)
+		defer os.Remove(pathTempFile)
+
+		storage, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, storage.AddShortLinkForURL(ctx, "https://same.com", "FILE0001"))
+		assert.NoError(t, storage.AddShortLinkForURL(ctxTeamA, "https://same.com", "FILE0001"))
+
+		storageRestored, err := storagerestorer.NewStorageShortsFromFileStorage(pathTempFile, config.GetAppConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+		count, err := storageRestored.GetCountLink(ctxTeamA)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		fullURL, err := storageRestored.GetFullLinkByShort(ctxTeamA, "FILE0001")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://same.com", fullURL)
+		_, err = storageRestored.GetFullLinkByShort(ctxTeamB, "FILE0001")
+		assert.Error(t, err)
+
+		logger.GetLogger().Debugf("### Конец теста: %s", nameMyTest4)
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-url-shortener/internal/logger"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"net/http"
+	"time"
+)
+
+// состояния частей сервиса
+const (
+	healthStatusOK       = "ok"
+	healthStatusError    = "error"
+	healthStatusDraining = "draining"
+)
+
+// Процесс жив: отвечает всегда, пока обрабатывает запросы
+func (dh dataHandler) getHealthz(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte(healthStatusOK))
+}
+
+// Сервис готов принимать запросы: работающее хранилище ссылок доступно и сервис не останавливается
+func (dh dataHandler) getReadyz(res http.ResponseWriter, req *http.Request) {
+
+	isReady, listComponents := dh.checkHealth(req, false)
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if isReady {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(healthStatusOK))
+		return
+	}
+
+	// в ответе первая причина неготовности
+	strError := "ошибка: сервис не готов принимать запросы"
+	for _, component := range listComponents {
+		if component.Required && component.Status != healthStatusOK {
+			strError += ": " + component.Name + " " + component.Status
+			if component.Error != "" {
+				strError += ": " + component.Error
+			}
+			break
+		}
+	}
+	logger.FromContext(req.Context()).Warn(strError)
+
+	res.WriteHeader(http.StatusServiceUnavailable)
+	res.Write([]byte(strError))
+}
+
+// Подробное состояние частей сервиса со временем проверки каждой
+func (dh dataHandler) getHealthDetails(res http.ResponseWriter, req *http.Request) {
+
+	isReady, listComponents := dh.checkHealth(req, true)
+
+	dataResponse := modelsResponses.ResponseHealth{
+		Status:     healthStatusOK,
+		Components: listComponents,
+	}
+	statusCode := http.StatusOK
+	if !isReady {
+		dataResponse.Status = healthStatusError
+		statusCode = http.StatusServiceUnavailable
+	}
+	bytesResult, _ := json.Marshal(&dataResponse)
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(statusCode)
+	res.Write(bytesResult)
+}
+
+// Проверка частей сервиса, сервис готов, если в порядке все обязательные части
+// isWithDB - проверить и соединение с БД, оно не обязательно: хранилище в БД само проверяет подключение,
+// а хранилище в файле или в памяти работает и без БД
+func (dh dataHandler) checkHealth(req *http.Request, isWithDB bool) (isReady bool, listComponents []modelsResponses.ResponseHealthComponent) {
+
+	// проверка одной части, ошибка записывается в состояние части
+	checkComponent := func(name string, isRequired bool, check func() error) modelsResponses.ResponseHealthComponent {
+		start := time.Now()
+		err := check()
+		component := modelsResponses.ResponseHealthComponent{
+			Name:      name,
+			Status:    healthStatusOK,
+			Required:  isRequired,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			component.Status = healthStatusError
+			component.Error = err.Error()
+		}
+		return component
+	}
+
+	storage := dh.service.GetStorage()
+	componentStorage := checkComponent("storage", true, func() error {
+		if readyChecker, ok := storage.(modelsStorage.StorageReadyCheckerInterface); ok {
+			return readyChecker.CheckReady(req.Context())
+		}
+		return nil
+	})
+	if storageDescriber, ok := storage.(modelsStorage.StorageDescriberInterface); ok {
+		componentStorage.Backend = storageDescriber.GetBackendName()
+	}
+	listComponents = append(listComponents, componentStorage)
+
+	// соединение с БД проверяем, только если БД настроена
+	if isWithDB && dh.configApp.GetDatabaseDsn() != "" {
+		listComponents = append(listComponents, checkComponent("database", false, func() error {
+			err := dh.dbHandler.GetErrSetup()
+			if err != nil {
+				return err
+			}
+			return dh.dbHandler.Ping()
+		}))
+	}
+
+	componentShutdown := modelsResponses.ResponseHealthComponent{
+		Name:     "shutdown",
+		Status:   healthStatusOK,
+		Required: true,
+	}
+	if dh.health.IsDraining() {
+		componentShutdown.Status = healthStatusDraining
+	}
+	listComponents = append(listComponents, componentShutdown)
+
+	isReady = true
+	for _, component := range listComponents {
+		if component.Required && component.Status != healthStatusOK {
+			isReady = false
+		}
+	}
+	return
+}
@@ -0,0 +1,24 @@
+package health
+
+import "sync/atomic"
+
+// Признак остановки сервиса: новые запросы на него направлять не надо, но начатые еще обрабатываются
+// У каждого приложения свой признак, его выставляет тот, кто останавливает сервер приложения
+type Status struct {
+	isDraining atomic.Bool
+}
+
+// Создание признака, сервис работает
+func NewStatus() *Status {
+	return &Status{}
+}
+
+// Отметка, что сервис останавливается, после нее проверка готовности не проходит
+func (status *Status) SetDraining(value bool) {
+	status.isDraining.Store(value)
+}
+
+// Без признака сервис считается работающим
+func (status *Status) IsDraining() bool {
+	return status != nil && status.isDraining.Load()
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Запись лога запроса: поля запроса добавляются по мере его обработки,
+// например пользователь становится известен только после разбора кук
+type requestLog struct {
+	mutex sync.Mutex
+	entry *log.Entry
+}
+
+type ctxKeyRequestLog struct{}
+
+// Контекст с записью лога запроса, все строки через FromContext будут с переданными полями
+func NewContext(ctx context.Context, fields CustomFields) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestLog{}, &requestLog{
+		entry: GetLogger().WithFields(fields),
+	})
+}
+
+// Добавление полей в запись лога запроса
+// Поля видны во всех контекстах запроса, и в уже созданных внешними middleware, поэтому итоговая строка лога запроса их тоже получит
+// Без записи лога запроса в контексте ничего не делает
+func AddContextFields(ctx context.Context, fields CustomFields) {
+	logRequest, ok := ctx.Value(ctxKeyRequestLog{}).(*requestLog)
+	if !ok {
+		return
+	}
+	logRequest.mutex.Lock()
+	defer logRequest.mutex.Unlock()
+	logRequest.entry = logRequest.entry.WithFields(log.Fields(fields))
+}
+
+// Логер для операций в контексте: запись лога запроса или общий логер, если контекст не от запроса
+func FromContext(ctx context.Context) *log.Entry {
+	if ctx != nil {
+		if logRequest, ok := ctx.Value(ctxKeyRequestLog{}).(*requestLog); ok {
+			logRequest.mutex.Lock()
+			defer logRequest.mutex.Unlock()
+			return logRequest.entry
+		}
+	}
+	return log.NewEntry(GetLogger().Logger)
+}
+
+// Логер для одной строки с дополнительными полями, поля в запись лога запроса не сохраняются
+func WithContextFields(ctx context.Context, fields CustomFields) *log.Entry {
+	return FromContext(ctx).WithFields(log.Fields(fields))
+}
+
+// Перенос записи лога запроса в другой контекст, например в контекст операций, который не прерывается вместе с запросом
+func CopyContext(target context.Context, source context.Context) context.Context {
+	logRequest, ok := source.Value(ctxKeyRequestLog{}).(*requestLog)
+	if !ok {
+		return target
+	}
+	return context.WithValue(target, ctxKeyRequestLog{}, logRequest)
+}
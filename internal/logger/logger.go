@@ -1,138 +1,257 @@
-package logger
-
-import (
-	"go-url-shortener/internal/config"
-
-	"os"
-	"path/filepath"
-
-	log "github.com/sirupsen/logrus"
-)
-
-// тип нашего логера приложения
-type TypeAppLogger struct {
-	*log.Logger
-}
-
-// своя реализация полей и методов внешнего пакета logrus,
-// чтобы не разбрасывать по коду его вызовы
-type CustomFields log.Fields
-
-func (logger TypeAppLogger) WithFields(additinalFields CustomFields) *log.Entry {
-	logrusFields := log.Fields(additinalFields)
-	return logger.Logger.WithFields(logrusFields)
-}
-
-// переменная логера
-var appLogger TypeAppLogger
-
-// Маркер синглтона, что сущность, уже инициировали
-var setupLogger = false
-
-func GetLogger() TypeAppLogger {
-	if !setupLogger {
-		initLogger()
-		setupLogger = true
-	}
-	return appLogger
-}
-
-// инициализация сущности
-func initLogger() {
-
-	// создаем файл логов
-	pathLogFile, errorCreateLogFile := createLogFile()
-	// fmt.Println("ЛОГИ ТУТ: " + pathLogFile)
-
-	loggerBase := createBaseLogger(pathLogFile)
-	appLogger = TypeAppLogger{
-		loggerBase,
-	}
-
-	levelLogConfig := config.GetAppConfig().GetLevelLogs()
-	SetLevelLog(levelLogConfig)
-	appLogger.Debugf("Уровень логирования: %d", levelLogConfig)
-
-	if errorCreateLogFile != nil {
-		appLogger.Errorln("Произошла ошибка создания файла лога: " + errorCreateLogFile.Error())
-	}
-
-}
-
-// Создание файла лога
-func createLogFile() (pathLogFile string, errorCreateLogFile error) {
-
-	// получаем папку для логов
-	mainFolderLog := config.GetAppConfig().GetLogsPath()
-	logAppDir, err := getFolderLogs(mainFolderLog)
-	if err != nil {
-		errorCreateLogFile = err
-	} else {
-		separatorOS := string(filepath.Separator)
-		pathLogFile = logAppDir + separatorOS + "appLog.log"
-	}
-
-	// если не смогли создать файл с логом,
-	// то пишем в домашнюю директорию в аварийный файл
-	if errorCreateLogFile != nil {
-		separatorOS := string(filepath.Separator)
-		userHomePath := config.GetAppConfig().GetFileStoragePath()
-		pathLogFile = userHomePath + separatorOS + "go_app_crash_log.log"
-	}
-
-	return
-}
-
-// Создание объекта Логера из стандартной библиотеки
-func createBaseLogger(pathToFileLog string) *log.Logger {
-
-	file, err := os.OpenFile(pathToFileLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
-	}
-	//defer file.Close()
-
-	logger := log.New()
-
-	// устанавливаем вывод логов в файл
-	logger.SetOutput(file)
-
-	// устанавливаем вывод логов в формате JSON
-	logger.SetFormatter(&log.TextFormatter{})
-
-	return logger
-}
-
-// Устанавливаем уровень логирования
-func SetLevelLog(level int) {
-	levelLog := log.Level(level)
-	appLogger.SetLevel(levelLog)
-}
-
-// Получение пути до папки с логами
-func getFolderLogs(mainFolderLog string) (logAppDir string, err error) {
-	logGoDir := mainFolderLog + "/goLogs"
-	err = createFolder(logGoDir)
-	if err != nil {
-		return
-	}
-
-	logAppDir = logGoDir + "/urlShortener"
-	err = createFolder(logAppDir)
-	return
-}
-
-// Создание папки по указанному пути
-func createFolder(folderPath string) error {
-	// создаем папку logs в корне проекта
-	_, err := os.Stat(folderPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			err = os.Mkdir(folderPath, 0755)
-			if err != nil {
-				panic(err)
-			}
-		}
-	}
-	return err
-}
+package logger
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// тип нашего логера приложения
+type TypeAppLogger struct {
+	*log.Logger
+}
+
+// своя реализация полей и методов внешнего пакета logrus,
+// чтобы не разбрасывать по коду его вызовы
+type CustomFields log.Fields
+
+func (logger TypeAppLogger) WithFields(additinalFields CustomFields) *log.Entry {
+	logrusFields := log.Fields(additinalFields)
+	return logger.Logger.WithFields(logrusFields)
+}
+
+// переменная логера
+var appLogger TypeAppLogger
+
+// Маркер синглтона, что сущность, уже инициировали
+var setupLogger = false
+
+func GetLogger() TypeAppLogger {
+	if !setupLogger {
+		initLogger()
+		setupLogger = true
+	}
+	return appLogger
+}
+
+// Настройки логера, их отдает конфигурация приложения
+// Логер не зависит от пакета конфигурации, потому что конфигурация хранит свои секреты как Secret
+type Settings interface {
+	GetLogsPath() string
+	GetLevelLogs() int
+	GetLogOutput() string
+	GetLogFormat() string
+	GetLogMaxSize() int
+	GetLogMaxAge() time.Duration
+	GetLogMaxBackups() int
+}
+
+// источник настроек общего логера, его задает конфигурация приложения
+var getDefaultSettings func() Settings
+
+// Источник настроек общего логера процесса
+// Функция вызывается один раз, при первом обращении к общему логеру
+func SetDefaultSettings(getSettings func() Settings) {
+	getDefaultSettings = getSettings
+}
+
+// инициализация сущности
+// Без источника настроек логи пишутся в stderr в текстовом формате
+func initLogger() {
+
+	if getDefaultSettings == nil {
+		appLogger = TypeAppLogger{createBaseLogger(os.Stderr, "")}
+		return
+	}
+	appLogger = NewLogger(getDefaultSettings())
+}
+
+// Создание логера по переданным настройкам: выводы, формат, ротация и уровень логирования
+// Если какой-то вывод настроить не удалось, то вместо него логи пишутся в stderr, сервис из-за логов не падает
+func NewLogger(configApp Settings) (logger TypeAppLogger) {
+
+	output, logFile, listErrors := createOutput(configApp)
+	logger = TypeAppLogger{
+		createBaseLogger(output, configApp.GetLogFormat()),
+	}
+
+	// ошибку ротации пишем в этот же логер, запись, на которой ротация не удалась, не теряется
+	if logFile != nil {
+		logFile.SetHandlerError(func(err error) {
+			logger.Errorln("Не удалось ротировать файл лога: " + err.Error())
+		})
+	}
+
+	levelLogConfig := configApp.GetLevelLogs()
+	logger.SetLevel(log.Level(levelLogConfig))
+	logger.Debugf("Уровень логирования: %d", levelLogConfig)
+
+	for _, err := range listErrors {
+		logger.Errorln("Произошла ошибка настройки вывода логов, логи пишутся в stderr: " + err.Error())
+	}
+	return
+}
+
+// Вывод логов по настройке LOG_OUTPUT, при нескольких выводах записи дублируются в каждый
+// logFile - файл лога, если он есть среди выводов
+func createOutput(configApp Settings) (output io.Writer, logFile *RotatingFile, listErrors []error) {
+
+	listWriters := []io.Writer{}
+	isStderr := false
+	useStderr := func() {
+		if !isStderr {
+			listWriters = append(listWriters, os.Stderr)
+			isStderr = true
+		}
+	}
+
+	for _, nameOutput := range ParseLogOutputs(configApp.GetLogOutput()) {
+		switch nameOutput {
+		case "stdout":
+			listWriters = append(listWriters, os.Stdout)
+		case "stderr":
+			useStderr()
+		case "file":
+			var err error
+			logFile, err = createLogFile(configApp)
+			if err != nil {
+				listErrors = append(listErrors, err)
+				useStderr()
+				continue
+			}
+			listWriters = append(listWriters, logFile)
+		default:
+			listErrors = append(listErrors, fmt.Errorf("ошибка: неизвестный вывод логов %q", nameOutput))
+			useStderr()
+		}
+	}
+	if len(listWriters) == 0 {
+		useStderr()
+	}
+
+	if len(listWriters) == 1 {
+		return listWriters[0], logFile, listErrors
+	}
+	return io.MultiWriter(listWriters...), logFile, listErrors
+}
+
+// Создание файла лога с ротацией в папке логов
+func createLogFile(configApp Settings) (logFile *RotatingFile, err error) {
+
+	// получаем папку для логов
+	mainFolderLog := configApp.GetLogsPath()
+	logAppDir, err := getFolderLogs(mainFolderLog)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка: не удалось создать папку логов: %w", err)
+	}
+
+	separatorOS := string(filepath.Separator)
+	pathLogFile := logAppDir + separatorOS + "appLog.log"
+	// размер в настройке задается в мегабайтах
+	maxSize := int64(configApp.GetLogMaxSize()) * 1024 * 1024
+	return NewRotatingFile(pathLogFile, maxSize, configApp.GetLogMaxAge(), configApp.GetLogMaxBackups())
+}
+
+// Разбор списка выводов логов через запятую, повторы отбрасываются
+func ParseLogOutputs(value string) (listOutputs []string) {
+
+	isAdded := make(map[string]bool)
+	for _, output := range strings.Split(value, ",") {
+		output = strings.ToLower(strings.TrimSpace(output))
+		if output != "" && !isAdded[output] {
+			listOutputs = append(listOutputs, output)
+			isAdded[output] = true
+		}
+	}
+	return
+}
+
+// Создание объекта Логера из стандартной библиотеки
+func createBaseLogger(output io.Writer, formatLog string) *log.Logger {
+
+	logger := log.New()
+
+	// устанавливаем вывод логов
+	logger.SetOutput(output)
+
+	// устанавливаем формат записей
+	logger.SetFormatter(newFormatter(formatLog))
+
+	// секреты в полях логов скрываем по названию поля
+	logger.AddHook(redactHook{})
+
+	return logger
+}
+
+// Формат записей лога: text - как раньше, json - для сборщиков логов в контейнерах,
+// logfmt - строгие пары key=value без цветов и с полным временем
+func newFormatter(formatLog string) log.Formatter {
+	switch formatLog {
+	case "json":
+		return &log.JSONFormatter{}
+	case "logfmt":
+		return &log.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			QuoteEmptyFields: true,
+		}
+	}
+	return &log.TextFormatter{}
+}
+
+// Устанавливаем уровень логирования
+func SetLevelLog(level int) {
+	levelLog := log.Level(level)
+	appLogger.SetLevel(levelLog)
+}
+
+// Текущий уровень логирования
+func GetLevelLog() int {
+	return int(GetLogger().GetLevel())
+}
+
+// Название уровня логирования: panic, fatal, error, warning, info, debug или trace
+func GetLevelLogName(level int) string {
+	return log.Level(level).String()
+}
+
+// Уровень логирования по названию
+func ParseLevelLog(name string) (level int, err error) {
+	levelLog, err := log.ParseLevel(name)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка: неизвестный уровень логирования %q: %w", name, err)
+	}
+	return int(levelLog), nil
+}
+
+// Получение пути до папки с логами
+func getFolderLogs(mainFolderLog string) (logAppDir string, err error) {
+	logGoDir := mainFolderLog + "/goLogs"
+	err = createFolder(logGoDir)
+	if err != nil {
+		return
+	}
+
+	logAppDir = logGoDir + "/urlShortener"
+	err = createFolder(logAppDir)
+	return
+}
+
+// Создание папки по указанному пути
+// Ошибку возвращаем, а не паникуем: без папки логи пойдут в stderr
+func createFolder(folderPath string) error {
+	// создаем папку logs в корне проекта
+	_, err := os.Stat(folderPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = os.Mkdir(folderPath, 0755)
+		}
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// формат времени в названии ротированного файла, по нему же файлы сортируются от старых к новым
+const formatTimeRotate = "20060102T150405.000000000"
+
+// Файл лога с ротацией по размеру и возрасту
+// Ротированный файл переименовывается в appLog-<время>.log рядом с текущим,
+// старые ротированные файлы сверх maxBackups удаляются
+type RotatingFile struct {
+	mutex sync.Mutex
+
+	path string
+	// размер в байтах, после которого файл ротируется, 0 - без ротации по размеру
+	maxSize int64
+	// возраст файла, после которого он ротируется, 0 - без ротации по времени
+	// возраст считается с открытия файла процессом или с прошлой ротации
+	maxAge time.Duration
+	// сколько ротированных файлов хранить, 0 - хранить все
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// обработчик ошибок ротации, без него ошибка возвращается из Write
+	handlerError func(err error)
+	// обработчик пишет в этот же файл, ошибки его записей повторно не обрабатываем
+	isHandlingError bool
+}
+
+// Открытие файла лога с ротацией, записи дописываются в конец существующего файла
+func NewRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (rotatingFile *RotatingFile, err error) {
+
+	rotatingFile = &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	err = rotatingFile.open()
+	if err != nil {
+		return nil, err
+	}
+	return rotatingFile, nil
+}
+
+func (rotatingFile *RotatingFile) open() error {
+
+	file, err := os.OpenFile(rotatingFile.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка: не удалось открыть файл лога: %w", err)
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("ошибка: не удалось получить размер файла лога: %w", err)
+	}
+
+	rotatingFile.file = file
+	rotatingFile.size = fileInfo.Size()
+	rotatingFile.openedAt = time.Now()
+	return nil
+}
+
+// Обработчик ошибок ротации, например запись ошибки в лог
+// Обработчик вызывается после записи, на которой ротация не удалась, и может сам писать в этот файл
+func (rotatingFile *RotatingFile) SetHandlerError(handlerError func(err error)) {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+	rotatingFile.handlerError = handlerError
+}
+
+// Запись в файл, перед записью файл при необходимости ротируется
+// Если ротировать не получилось, то запись продолжается в текущий файл,
+// а ошибка ротации уходит в обработчик ошибок или возвращается вместе с записанными байтами
+func (rotatingFile *RotatingFile) Write(bytesLog []byte) (n int, err error) {
+
+	n, err, errRotate := rotatingFile.write(bytesLog)
+	if err != nil || errRotate == nil {
+		return
+	}
+
+	rotatingFile.mutex.Lock()
+	handlerError := rotatingFile.handlerError
+	isHandlingError := rotatingFile.isHandlingError
+	rotatingFile.isHandlingError = true
+	rotatingFile.mutex.Unlock()
+	if isHandlingError {
+		return
+	}
+	if handlerError == nil {
+		err = errRotate
+	} else {
+		handlerError(errRotate)
+	}
+
+	rotatingFile.mutex.Lock()
+	rotatingFile.isHandlingError = false
+	rotatingFile.mutex.Unlock()
+	return
+}
+
+func (rotatingFile *RotatingFile) write(bytesLog []byte) (n int, err error, errRotate error) {
+
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+
+	// после неудачной ротации файл мог остаться закрытым, пробуем открыть заново
+	if rotatingFile.file == nil {
+		err = rotatingFile.open()
+		if err != nil {
+			return 0, err, nil
+		}
+	}
+
+	if rotatingFile.isNeedRotate(len(bytesLog)) {
+		errRotate = rotatingFile.rotate()
+		if errRotate != nil && rotatingFile.file == nil {
+			return 0, errRotate, nil
+		}
+	}
+
+	n, err = rotatingFile.file.Write(bytesLog)
+	rotatingFile.size += int64(n)
+	return
+}
+
+func (rotatingFile *RotatingFile) isNeedRotate(sizeWrite int) bool {
+	// пустой файл не ротируем, даже если одна запись больше допустимого размера
+	if rotatingFile.size == 0 {
+		return false
+	}
+	if rotatingFile.maxSize > 0 && rotatingFile.size+int64(sizeWrite) > rotatingFile.maxSize {
+		return true
+	}
+	return rotatingFile.maxAge > 0 && time.Since(rotatingFile.openedAt) >= rotatingFile.maxAge
+}
+
+// Принудительная ротация файла
+func (rotatingFile *RotatingFile) Rotate() error {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+	return rotatingFile.rotate()
+}
+
+func (rotatingFile *RotatingFile) rotate() (err error) {
+
+	if rotatingFile.file != nil {
+		err = rotatingFile.file.Close()
+		rotatingFile.file = nil
+		if err != nil {
+			err = fmt.Errorf("ошибка: не удалось закрыть файл лога перед ротацией: %w", err)
+		}
+	}
+
+	var errRename error
+	if err == nil {
+		errRename = os.Rename(rotatingFile.path, rotatingFile.getBackupPath())
+	}
+
+	errOpen := rotatingFile.open()
+	if errOpen != nil {
+		return errOpen
+	}
+	if err != nil {
+		return err
+	}
+	if errRename != nil {
+		// следующую попытку делаем только после очередной порции записей
+		rotatingFile.size = 0
+		return fmt.Errorf("ошибка: не удалось ротировать файл лога: %w", errRename)
+	}
+
+	return rotatingFile.removeOldBackups()
+}
+
+// путь до ротированного файла: appLog.log -> appLog-20261019T120000.000000000.log
+func (rotatingFile *RotatingFile) getBackupPath() string {
+	prefix, ext := rotatingFile.getBackupPrefix()
+	return prefix + time.Now().Format(formatTimeRotate) + ext
+}
+
+func (rotatingFile *RotatingFile) getBackupPrefix() (prefix string, ext string) {
+	ext = filepath.Ext(rotatingFile.path)
+	return strings.TrimSuffix(rotatingFile.path, ext) + "-", ext
+}
+
+// Ротированные файлы лога от старых к новым
+// Ротированным считается только файл с временем ротации в названии: appLog-<formatTimeRotate>.log
+func (rotatingFile *RotatingFile) GetBackups() (listBackups []string, err error) {
+
+	prefix, ext := rotatingFile.getBackupPrefix()
+	dirLogs := filepath.Dir(rotatingFile.path)
+	listEntries, err := os.ReadDir(dirLogs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка: не удалось получить список ротированных файлов лога: %w", err)
+	}
+
+	prefixName := filepath.Base(prefix)
+	for _, entry := range listEntries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefixName) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		timeRotate := strings.TrimSuffix(strings.TrimPrefix(name, prefixName), ext)
+		if len(timeRotate) != len(formatTimeRotate) {
+			continue
+		}
+		if _, errParse := time.Parse(formatTimeRotate, timeRotate); errParse != nil {
+			continue
+		}
+		listBackups = append(listBackups, filepath.Join(dirLogs, name))
+	}
+	sort.Strings(listBackups)
+	return
+}
+
+// Удаление ротированных файлов сверх допустимого количества, удаляются самые старые
+func (rotatingFile *RotatingFile) removeOldBackups() error {
+
+	if rotatingFile.maxBackups <= 0 {
+		return nil
+	}
+	listBackups, err := rotatingFile.GetBackups()
+	if err != nil {
+		return err
+	}
+	for len(listBackups) > rotatingFile.maxBackups {
+		err = os.Remove(listBackups[0])
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("ошибка: не удалось удалить старый файл лога: %w", err)
+		}
+		listBackups = listBackups[1:]
+	}
+	return nil
+}
+
+// Закрытие файла лога
+func (rotatingFile *RotatingFile) Close() error {
+
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+
+	if rotatingFile.file == nil {
+		return nil
+	}
+	err := rotatingFile.file.Close()
+	rotatingFile.file = nil
+	return err
+}
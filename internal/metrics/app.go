@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Метрики сервиса коротких ссылок одного приложения, копятся с его создания
+// Приложение создает свои метрики и передает их сервису и обработчикам запросов
+type AppMetrics struct {
+	registry *Registry
+
+	httpRequests             *Counter
+	httpRequestDuration      *Histogram
+	redirects                *Counter
+	linksCreated             *Counter
+	batchSize                *Histogram
+	storageOperationDuration *Histogram
+}
+
+// Создание метрик приложения
+func NewAppMetrics() *AppMetrics {
+	registry := NewRegistry()
+	return &AppMetrics{
+		registry: registry,
+		httpRequests: registry.NewCounter("shortener_http_requests_total",
+			"Количество обработанных запросов по маршруту, методу и коду ответа", "route", "method", "status"),
+		httpRequestDuration: registry.NewHistogram("shortener_http_request_duration_seconds",
+			"Время обработки запросов по маршруту, методу и коду ответа", DefaultDurationBuckets, "route", "method", "status"),
+		redirects: registry.NewCounter("shortener_redirects_total",
+			"Количество переходов по коротким ссылкам"),
+		linksCreated: registry.NewCounter("shortener_links_created_total",
+			"Количество созданных коротких ссылок"),
+		batchSize: registry.NewHistogram("shortener_batch_size",
+			"Количество ссылок в групповом запросе", []float64{1, 5, 10, 50, 100, 500, 1000, 5000}),
+		storageOperationDuration: registry.NewHistogram("shortener_storage_operation_duration_seconds",
+			"Время операций хранилища ссылок по типу хранилища и операции", DefaultDurationBuckets, "backend", "operation"),
+	}
+}
+
+// маршрут запросов, не попавших ни в один маршрут: пути таких запросов не пишем, чтобы не плодить значения метки
+const RouteUnmatched = "unmatched"
+
+// метод запроса не из стандартных: произвольные методы не пишем, чтобы не плодить значения метки
+const MethodOther = "OTHER"
+
+// стандартные методы HTTP, только они попадают в метку метода как есть
+var listMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Учет обработанного запроса
+func (appMetrics *AppMetrics) ObserveRequest(route, method string, statusCode int, duration time.Duration) {
+	if route == "" {
+		route = RouteUnmatched
+	}
+	if !listMethods[method] {
+		method = MethodOther
+	}
+	status := strconv.Itoa(statusCode)
+	appMetrics.httpRequests.Inc(route, method, status)
+	appMetrics.httpRequestDuration.Observe(duration.Seconds(), route, method, status)
+}
+
+// Учет перехода по короткой ссылке
+func (appMetrics *AppMetrics) IncRedirects() {
+	appMetrics.redirects.Inc()
+}
+
+// Учет созданных коротких ссылок
+func (appMetrics *AppMetrics) AddLinksCreated(count int) {
+	appMetrics.linksCreated.Add(float64(count))
+}
+
+// Учет размера группового запроса
+func (appMetrics *AppMetrics) ObserveBatchSize(size int) {
+	appMetrics.batchSize.Observe(float64(size))
+}
+
+// Учет времени операции хранилища
+func (appMetrics *AppMetrics) ObserveStorageOperation(backend, operation string, duration time.Duration) {
+	appMetrics.storageOperationDuration.Observe(duration.Seconds(), backend, operation)
+}
+
+// Количество созданных коротких ссылок с создания метрик
+func (appMetrics *AppMetrics) GetLinksCreated() float64 {
+	return appMetrics.linksCreated.Get()
+}
+
+// Количество переходов по коротким ссылкам с создания метрик
+func (appMetrics *AppMetrics) GetRedirects() float64 {
+	return appMetrics.redirects.Get()
+}
+
+// Вывод метрик сервиса, рантайма Go и переданных сборщиков
+func (appMetrics *AppMetrics) Write(output io.Writer, listCollectors ...CollectFunc) error {
+	return appMetrics.registry.Write(output, append([]CollectFunc{collectRuntime}, listCollectors...)...)
+}
+
+// Обработчик запроса метрик в текстовом формате Prometheus
+func (appMetrics *AppMetrics) NewHandler(listCollectors ...CollectFunc) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		res.WriteHeader(http.StatusOK)
+		appMetrics.Write(res, listCollectors...)
+	})
+}
+
+// время запуска процесса
+var timeStart = time.Now()
+
+// Время запуска процесса
+func GetTimeStart() time.Time {
+	return timeStart
+}
+
+// Метрики рантайма Go
+func collectRuntime(writer *Writer) {
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	writer.WriteGauge("go_goroutines", "Количество горутин", float64(runtime.NumGoroutine()))
+	writer.WriteGauge("go_maxprocs", "Количество процессоров, на которых одновременно выполняется Go", float64(runtime.GOMAXPROCS(0)))
+	writer.WriteGauge("go_info", "Версия Go", 1, Label{Name: "version", Value: runtime.Version()})
+	writer.WriteGauge("go_memstats_alloc_bytes", "Занято памяти в куче", float64(memStats.Alloc))
+	writer.WriteGauge("go_memstats_sys_bytes", "Получено памяти от ОС", float64(memStats.Sys))
+	writer.WriteGauge("go_memstats_heap_objects", "Количество объектов в куче", float64(memStats.HeapObjects))
+	writer.WriteCounter("go_memstats_mallocs_total", "Количество выделений памяти", float64(memStats.Mallocs))
+	writer.WriteCounter("go_memstats_frees_total", "Количество освобождений памяти", float64(memStats.Frees))
+	writer.WriteCounter("go_gc_cycles_total", "Количество завершенных сборок мусора", float64(memStats.NumGC))
+	writer.WriteCounter("go_gc_pause_seconds_total", "Суммарное время пауз сборщика мусора", float64(memStats.PauseTotalNs)/float64(time.Second))
+	writer.WriteGauge("process_start_time_seconds", "Время запуска процесса в секундах с начала эпохи", float64(timeStart.UnixNano())/float64(time.Second))
+}
@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Метрики в текстовом формате Prometheus без сторонних библиотек
+// Формат: https://prometheus.io/docs/instrumenting/exposition_formats/
+
+// Сборщик метрик, которые не копятся, а снимаются в момент запроса: пул соединений, кеш, рантайм
+type CollectFunc func(writer *Writer)
+
+// метрика, которая копит значения между запросами
+type metric interface {
+	write(writer *Writer)
+}
+
+// Набор метрик
+type Registry struct {
+	mutex       sync.Mutex
+	listMetrics []metric
+	listNames   map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		listNames: map[string]bool{},
+	}
+}
+
+// регистрация метрики, одинаковые названия - ошибка в коде, поэтому паникуем сразу при запуске
+func (registry *Registry) register(name string, value metric) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if registry.listNames[name] {
+		panic(fmt.Sprintf("ошибка: метрика %s уже зарегистрирована", name))
+	}
+	registry.listNames[name] = true
+	registry.listMetrics = append(registry.listMetrics, value)
+}
+
+// Вывод всех метрик набора и метрик сборщиков в текстовом формате
+func (registry *Registry) Write(output io.Writer, listCollectors ...CollectFunc) error {
+
+	registry.mutex.Lock()
+	listMetrics := append([]metric{}, registry.listMetrics...)
+	registry.mutex.Unlock()
+
+	writer := newWriter(output)
+	for _, value := range listMetrics {
+		value.write(writer)
+	}
+	for _, collector := range listCollectors {
+		collector(writer)
+	}
+	return writer.flush()
+}
+
+// Метка метрики: название и значение
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Вывод метрик в текстовом формате
+// Заголовок HELP и TYPE выводится один раз перед первым значением метрики,
+// поэтому значения одной метрики надо выводить подряд
+type Writer struct {
+	output     *bufio.Writer
+	err        error
+	listHeader map[string]bool
+}
+
+func newWriter(output io.Writer) *Writer {
+	return &Writer{
+		output:     bufio.NewWriter(output),
+		listHeader: map[string]bool{},
+	}
+}
+
+func (writer *Writer) flush() error {
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.output.Flush()
+}
+
+func (writer *Writer) writeString(value string) {
+	if writer.err != nil {
+		return
+	}
+	_, writer.err = writer.output.WriteString(value)
+}
+
+func (writer *Writer) writeHeader(name, help, typeMetric string) {
+	if writer.listHeader[name] {
+		return
+	}
+	writer.listHeader[name] = true
+	writer.writeString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	writer.writeString("# TYPE " + name + " " + typeMetric + "\n")
+}
+
+func (writer *Writer) writeSample(name string, listLabels []Label, value float64) {
+	writer.writeString(name + formatLabels(listLabels) + " " + formatValue(value) + "\n")
+}
+
+// Значение метрики, которое может как расти, так и уменьшаться
+func (writer *Writer) WriteGauge(name, help string, value float64, listLabels ...Label) {
+	writer.writeHeader(name, help, "gauge")
+	writer.writeSample(name, listLabels, value)
+}
+
+// Значение метрики, которое только растет
+func (writer *Writer) WriteCounter(name, help string, value float64, listLabels ...Label) {
+	writer.writeHeader(name, help, "counter")
+	writer.writeSample(name, listLabels, value)
+}
+
+func formatLabels(listLabels []Label) string {
+	if len(listLabels) == 0 {
+		return ""
+	}
+	listPairs := make([]string, 0, len(listLabels))
+	for _, label := range listLabels {
+		listPairs = append(listPairs, label.Name+`="`+escapeLabelValue(label.Value)+`"`)
+	}
+	return "{" + strings.Join(listPairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var replacerLabelValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return replacerLabelValue.Replace(value)
+}
+
+var replacerHelp = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(value string) string {
+	return replacerHelp.Replace(value)
+}
+
+// значения меток в ключ map и обратно
+const separatorLabelValues = "\xff"
+
+func joinLabelValues(listNames []string, listValues []string) string {
+	if len(listValues) != len(listNames) {
+		panic(fmt.Sprintf("ошибка: у метрики метки %v, а передано значений %d", listNames, len(listValues)))
+	}
+	return strings.Join(listValues, separatorLabelValues)
+}
+
+func getLabels(listNames []string, key string) (listLabels []Label) {
+	if len(listNames) == 0 {
+		return nil
+	}
+	listValues := strings.Split(key, separatorLabelValues)
+	for i, name := range listNames {
+		listLabels = append(listLabels, Label{Name: name, Value: listValues[i]})
+	}
+	return
+}
+
+// ключи значений по порядку, чтобы вывод не зависел от обхода map
+func getSortedKeys[T any](values map[string]T) []string {
+	listKeys := make([]string, 0, len(values))
+	for key := range values {
+		listKeys = append(listKeys, key)
+	}
+	sort.Strings(listKeys)
+	return listKeys
+}
+
+// Счетчик с метками
+type Counter struct {
+	name       string
+	help       string
+	listLabels []string
+
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// Регистрация счетчика, значения меток при увеличении передаются в порядке listLabels
+func (registry *Registry) NewCounter(name, help string, listLabels ...string) *Counter {
+	counter := &Counter{
+		name:       name,
+		help:       help,
+		listLabels: listLabels,
+		values:     map[string]float64{},
+	}
+	registry.register(name, counter)
+	return counter
+}
+
+func (counter *Counter) Inc(listValues ...string) {
+	counter.Add(1, listValues...)
+}
+
+// Увеличение счетчика, отрицательные значения игнорируются, счетчик только растет
+func (counter *Counter) Add(value float64, listValues ...string) {
+	if value < 0 {
+		return
+	}
+	key := joinLabelValues(counter.listLabels, listValues)
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.values[key] += value
+}
+
+// Текущее значение счетчика
+func (counter *Counter) Get(listValues ...string) float64 {
+	key := joinLabelValues(counter.listLabels, listValues)
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	return counter.values[key]
+}
+
+func (counter *Counter) write(writer *Writer) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	writer.writeHeader(counter.name, counter.help, "counter")
+	if len(counter.listLabels) == 0 && len(counter.values) == 0 {
+		// счетчик без меток выводим и нулевым
+		writer.writeSample(counter.name, nil, 0)
+		return
+	}
+	for _, key := range getSortedKeys(counter.values) {
+		writer.writeSample(counter.name, getLabels(counter.listLabels, key), counter.values[key])
+	}
+}
+
+// Границы корзин гистограммы длительности в секундах
+var DefaultDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// значения гистограммы для одного набора меток
+type histogramValue struct {
+	listCounts []uint64
+	count      uint64
+	sum        float64
+}
+
+// Гистограмма с метками
+type Histogram struct {
+	name        string
+	help        string
+	listLabels  []string
+	listBuckets []float64
+
+	mutex  sync.Mutex
+	values map[string]*histogramValue
+}
+
+// Регистрация гистограммы, границы корзин по возрастанию
+func (registry *Registry) NewHistogram(name, help string, listBuckets []float64, listLabels ...string) *Histogram {
+	listBuckets = append([]float64{}, listBuckets...)
+	sort.Float64s(listBuckets)
+	histogram := &Histogram{
+		name:        name,
+		help:        help,
+		listLabels:  listLabels,
+		listBuckets: listBuckets,
+		values:      map[string]*histogramValue{},
+	}
+	registry.register(name, histogram)
+	return histogram
+}
+
+func (histogram *Histogram) Observe(value float64, listValues ...string) {
+	key := joinLabelValues(histogram.listLabels, listValues)
+
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	valueHistogram, ok := histogram.values[key]
+	if !ok {
+		valueHistogram = &histogramValue{
+			listCounts: make([]uint64, len(histogram.listBuckets)),
+		}
+		histogram.values[key] = valueHistogram
+	}
+	for i, bucket := range histogram.listBuckets {
+		if value <= bucket {
+			valueHistogram.listCounts[i]++
+		}
+	}
+	valueHistogram.count++
+	valueHistogram.sum += value
+}
+
+// Количество наблюдений гистограммы
+func (histogram *Histogram) GetCount(listValues ...string) uint64 {
+	key := joinLabelValues(histogram.listLabels, listValues)
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	if valueHistogram, ok := histogram.values[key]; ok {
+		return valueHistogram.count
+	}
+	return 0
+}
+
+func (histogram *Histogram) write(writer *Writer) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	writer.writeHeader(histogram.name, histogram.help, "histogram")
+	for _, key := range getSortedKeys(histogram.values) {
+		valueHistogram := histogram.values[key]
+		listLabels := getLabels(histogram.listLabels, key)
+
+		// корзины накопительные: в каждой все значения не больше границы
+		for i, bucket := range histogram.listBuckets {
+			labelsBucket := append(append([]Label{}, listLabels...), Label{Name: "le", Value: formatValue(bucket)})
+			writer.writeSample(histogram.name+"_bucket", labelsBucket, float64(valueHistogram.listCounts[i]))
+		}
+		labelsInf := append(append([]Label{}, listLabels...), Label{Name: "le", Value: "+Inf"})
+		writer.writeSample(histogram.name+"_bucket", labelsInf, float64(valueHistogram.count))
+		writer.writeSample(histogram.name+"_sum", listLabels, valueHistogram.sum)
+		writer.writeSample(histogram.name+"_count", listLabels, float64(valueHistogram.count))
+	}
+}
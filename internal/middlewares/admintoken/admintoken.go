@@ -0,0 +1,62 @@
+package admintoken
+
+import (
+	"crypto/subtle"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	"net/http"
+	"strings"
+)
+
+// Служебные запросы доступны только с токеном из настройки ADMIN_TOKEN в заголовке Authorization: Bearer <токен>
+// Токен не связан с куками пользователей. Без настроенного токена служебные запросы выключены.
+// Токен читается при каждом запросе, чтобы работал новый токен после перезагрузки конфигурации
+func RequireAdminToken(configApp config.ConfigTypeInterface) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return requireAdminToken(configApp, handler)
+	}
+}
+
+func requireAdminToken(configApp config.ConfigTypeInterface, handler http.Handler) http.Handler {
+	adminFunc := func(res http.ResponseWriter, req *http.Request) {
+
+		adminToken := configApp.GetAdminToken()
+		if adminToken == "" {
+			// как и для несуществующих маршрутов, отвечаем 400
+			res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("Вызываемый адрес не существует"))
+			return
+		}
+
+		token, isBearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !isBearer || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			strError := "ошибка: нет доступа к служебным запросам, нужен токен администратора"
+			logger.GetLogger().Debugf("%s, адрес: %s", strError, req.URL.Path)
+
+			res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			res.WriteHeader(http.StatusUnauthorized)
+			res.Write([]byte(strError))
+			return
+		}
+
+		handler.ServeHTTP(res, req)
+	}
+	return http.HandlerFunc(adminFunc)
+}
+
+// Запросы служебного сервера: без настроенного токена открыты, служебный сервер слушает отдельный адрес,
+// закрытый от внешней сети. Если токен настроен, то он нужен и здесь
+func OptionalAdminToken(configApp config.ConfigTypeInterface) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		protectedHandler := requireAdminToken(configApp, handler)
+		optionalFunc := func(res http.ResponseWriter, req *http.Request) {
+			if configApp.GetAdminToken() == "" {
+				handler.ServeHTTP(res, req)
+				return
+			}
+			protectedHandler.ServeHTTP(res, req)
+		}
+		return http.HandlerFunc(optionalFunc)
+	}
+}
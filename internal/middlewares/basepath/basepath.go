@@ -0,0 +1,54 @@
+package basepath
+
+import (
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"go-url-shortener/internal/shortdomains"
+	"net/http"
+	"strings"
+)
+
+// Сервер отвечает только по префиксу пути из базового адреса домена коротких ссылок
+// Префикс отрезается, чтобы дальше работали обычные маршруты
+// Домен запроса должен быть уже определен, у каждого домена может быть свой префикс
+func WrapBasePath(registry *shortdomains.Registry) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return wrapBasePath(registry, handler)
+	}
+}
+
+func wrapBasePath(registry *shortdomains.Registry, handler http.Handler) http.Handler {
+	basePathFunc := func(res http.ResponseWriter, req *http.Request) {
+
+		basePath := registry.GetBasePath(modelsStorage.GetShortDomain(req.Context()))
+		if basePath == "" {
+			handler.ServeHTTP(res, req)
+			return
+		}
+
+		restPath := ""
+		if req.URL.Path == basePath {
+			restPath = "/"
+		} else if strings.HasPrefix(req.URL.Path, basePath+"/") {
+			restPath = strings.TrimPrefix(req.URL.Path, basePath)
+		} else {
+			logger.GetLogger().Debugf("Адрес %s вне префикса пути %s", req.URL.Path, basePath)
+
+			// как и для несуществующих маршрутов, отвечаем 400
+			res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte("Вызываемый адрес не существует"))
+			return
+		}
+
+		// меняем путь у копии запроса и адреса, исходный запрос не трогаем
+		urlRest := *req.URL
+		urlRest.Path = restPath
+		urlRest.RawPath = ""
+		req = req.Clone(req.Context())
+		req.URL = &urlRest
+
+		handler.ServeHTTP(res, req)
+	}
+	return http.HandlerFunc(basePathFunc)
+}
@@ -1,86 +1,128 @@
-package logging
-
-import (
-	"go-url-shortener/internal/logger"
-	"net/http"
-	"strings"
-	"time"
-)
-
-// Сделаем структуру, где будем храненить сведения об ответе
-type responseData struct {
-	status int
-	size   int
-}
-
-// Это будет наша замена стандартного http.ResponseWriter
-type loggingResponseWriter struct {
-	// встраиваем оригинальный http.ResponseWriter
-	http.ResponseWriter
-	// Внедряем хранилище с данными запроса
-	responseData *responseData
-}
-
-func (r *loggingResponseWriter) Write(b []byte) (int, error) {
-	size, err := r.ResponseWriter.Write(b)
-	// сохраняем в хранилище размер
-	r.responseData.size += size
-	return size, err
-}
-
-func (r *loggingResponseWriter) WriteHeader(statusCode int) {
-	r.ResponseWriter.WriteHeader(statusCode)
-	// сохраняем в хранилище код статуса
-	r.responseData.status = statusCode
-}
-
-func WrapLogging(handler http.Handler) http.Handler {
-	logFunc := func(respWriter http.ResponseWriter, request *http.Request) {
-
-		start := time.Now()
-
-		responseData := &responseData{
-			status: 0,
-			size:   0,
-		}
-		// создаем свой собственный ResponseWriter
-		// используя оригинальный ResponseWriter
-		logWriter := loggingResponseWriter{
-			ResponseWriter: respWriter,
-			responseData:   responseData,
-		}
-
-		// внедряем оригинальную реализацию http.ResponseWriter
-		handler.ServeHTTP(&logWriter, request)
-
-		duration := time.Since(start)
-
-		// сжатие запроса
-		contentEncodingRequest := request.Header.Get("Content-Encoding")
-		// тип контента из запроса
-		acceptEncodingRequest := request.Header.Values("Accept-Encoding")
-		strAcceptEncodingRequest := strings.Join(acceptEncodingRequest, "; ")
-
-		// тип контента из запроса
-		contentTypeRequest := request.Header.Get("Content-Type")
-
-		// определяем стандартные поля JSON
-		additinalFields := logger.CustomFields{
-			"uri":      request.RequestURI,
-			"method":   request.Method,
-			"duration": duration,
-			// получаем перехваченный размер ответа
-			"sizeResponse": responseData.size,
-			// получаем перехваченный код статуса ответа
-			"statusCodeResponse": responseData.status,
-
-			"acceptEncodingRequest":  strAcceptEncodingRequest,
-			"contentEncodingRequest": contentEncodingRequest,
-			"contentTypeRequest":     contentTypeRequest,
-		}
-		textLog := "*** Зарегистрирован запрос: "
-		obLogger := logger.GetLogger()
-		obLogger.WithFields(additinalFields).Info(textLog)
-	}
-	return http.HandlerFunc(logFunc)
-}
+package logging
+
+import (
+	"context"
+	"go-url-shortener/internal/logger"
+	"go-url-shortener/internal/metrics"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Сделаем структуру, где будем храненить сведения об ответе
+type responseData struct {
+	status int
+	size   int
+}
+
+// Это будет наша замена стандартного http.ResponseWriter
+type loggingResponseWriter struct {
+	// встраиваем оригинальный http.ResponseWriter
+	http.ResponseWriter
+	// Внедряем хранилище с данными запроса
+	responseData *responseData
+}
+
+func (r *loggingResponseWriter) Write(b []byte) (int, error) {
+	size, err := r.ResponseWriter.Write(b)
+	// сохраняем в хранилище размер
+	r.responseData.size += size
+	return size, err
+}
+
+func (r *loggingResponseWriter) WriteHeader(statusCode int) {
+	r.ResponseWriter.WriteHeader(statusCode)
+	// сохраняем в хранилище код статуса
+	r.responseData.status = statusCode
+}
+
+// Шаблон маршрута запроса, пусто - запрос не попал ни в один маршрут
+// chi обрезает завершающий слеш шаблона, поэтому у корневого маршрута шаблон пустой, его возвращаем как "/"
+func getRoutePattern(routeContext *chi.Context) string {
+	route := routeContext.RoutePattern()
+	if route == "" && len(routeContext.RoutePatterns) > 0 {
+		return "/"
+	}
+	return route
+}
+
+// Запись запроса в лог и в метрики приложения
+func WrapLogging(appMetrics *metrics.AppMetrics) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return wrapLogging(appMetrics, handler)
+	}
+}
+
+func wrapLogging(appMetrics *metrics.AppMetrics, handler http.Handler) http.Handler {
+	logFunc := func(respWriter http.ResponseWriter, request *http.Request) {
+
+		start := time.Now()
+
+		responseData := &responseData{
+			status: 0,
+			size:   0,
+		}
+		// создаем свой собственный ResponseWriter
+		// используя оригинальный ResponseWriter
+		logWriter := loggingResponseWriter{
+			ResponseWriter: respWriter,
+			responseData:   responseData,
+		}
+
+		// контекст маршрута создаем заранее: роутер chi заполнит уже существующий,
+		// и после обработки запроса будет известен шаблон маршрута для метрик и логов
+		routeContext := chi.NewRouteContext()
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+
+		// внедряем оригинальную реализацию http.ResponseWriter
+		handler.ServeHTTP(&logWriter, request)
+
+		duration := time.Since(start)
+
+		// если обработчик не вызывал WriteHeader, то ответ ушел с кодом 200
+		statusCode := responseData.status
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		route := getRoutePattern(routeContext)
+		appMetrics.ObserveRequest(route, request.Method, statusCode, duration)
+		if route != "" {
+			logger.AddContextFields(request.Context(), logger.CustomFields{"route": route})
+		}
+
+		// сжатие запроса
+		contentEncodingRequest := request.Header.Get("Content-Encoding")
+		// тип контента из запроса
+		acceptEncodingRequest := request.Header.Values("Accept-Encoding")
+		strAcceptEncodingRequest := strings.Join(acceptEncodingRequest, "; ")
+
+		// тип контента из запроса
+		contentTypeRequest := request.Header.Get("Content-Type")
+
+		// определяем стандартные поля JSON
+		// секреты в адресе запроса скрываем, заголовки с токеном и куками в лог попадают скрытыми,
+		// по ним видно только, были ли они в запросе
+		additinalFields := logger.CustomFields{
+			"uri":      logger.RedactURL(request.RequestURI),
+			"method":   request.Method,
+			"duration": duration,
+			// получаем перехваченный размер ответа
+			"sizeResponse": responseData.size,
+			// получаем перехваченный код статуса ответа
+			"statusCodeResponse": responseData.status,
+
+			"acceptEncodingRequest":  strAcceptEncodingRequest,
+			"contentEncodingRequest": contentEncodingRequest,
+			"contentTypeRequest":     contentTypeRequest,
+
+			"authorizationRequest": logger.Secret(request.Header.Get("Authorization")),
+			"cookieRequest":        logger.Secret(strings.Join(request.Header.Values("Cookie"), "; ")),
+		}
+		textLog := "*** Зарегистрирован запрос: "
+		// поля запроса: request_id, user_id и маршрут, берем из записи лога запроса
+		logger.WithContextFields(request.Context(), additinalFields).Info(textLog)
+	}
+	return http.HandlerFunc(logFunc)
+}
@@ -0,0 +1,76 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go-url-shortener/internal/logger"
+	"net/http"
+)
+
+// Заголовок с идентификатором запроса
+const HeaderRequestID = "X-Request-ID"
+
+// максимальная длина идентификатора запроса от клиента, более длинный заменяем своим
+const maxLengthRequestID = 128
+
+type ctxKeyRequestID struct{}
+
+// Идентификатор запроса из контекста
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(ctxKeyRequestID{}).(string)
+	return requestID
+}
+
+// Идентификатор запроса берем из заголовка X-Request-ID, если его нет или он некорректный, то создаем новый
+// Идентификатор возвращается в ответе, а в контекст кладется запись лога запроса с request_id,
+// через нее пишут логи сервис и хранилище, поэтому все строки одного запроса можно найти по request_id
+func WrapRequestID(handler http.Handler) http.Handler {
+	requestIDFunc := func(res http.ResponseWriter, req *http.Request) {
+
+		requestID := req.Header.Get(HeaderRequestID)
+		if !isValidRequestID(requestID) {
+			newRequestID, err := newRequestID()
+			if err != nil {
+				logger.GetLogger().Error(err.Error())
+			}
+			requestID = newRequestID
+		}
+		res.Header().Set(HeaderRequestID, requestID)
+
+		ctx := context.WithValue(req.Context(), ctxKeyRequestID{}, requestID)
+		// до маршрутизации точный маршрут неизвестен, пока пишем путь запроса
+		ctx = logger.NewContext(ctx, logger.CustomFields{
+			"request_id": requestID,
+			"method":     req.Method,
+			"route":      req.URL.Path,
+		})
+
+		handler.ServeHTTP(res, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(requestIDFunc)
+}
+
+// Идентификатор от клиента попадает в логи и в ответ, поэтому допускаем только видимые ASCII символы без пробелов
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxLengthRequestID {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Новый случайный идентификатор запроса
+func newRequestID() (requestID string, err error) {
+	bytesRequestID := make([]byte, 16)
+	_, err = rand.Read(bytesRequestID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка: не удалось создать идентификатор запроса: %w", err)
+	}
+	return hex.EncodeToString(bytesRequestID), nil
+}
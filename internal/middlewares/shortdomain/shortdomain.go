@@ -0,0 +1,24 @@
+package shortdomain
+
+import (
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"go-url-shortener/internal/shortdomains"
+	"net/http"
+)
+
+// Определяем домен коротких ссылок запроса по заголовку Host
+// Переход по короткой ссылке ищет ее на этом домене, новые ссылки по умолчанию создаются на нем же
+func WrapShortDomain(registry *shortdomains.Registry) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		shortDomainFunc := func(res http.ResponseWriter, req *http.Request) {
+
+			domain, ok := registry.Resolve(req.Host)
+			if ok {
+				req = req.WithContext(modelsStorage.WithShortDomain(req.Context(), domain))
+			}
+
+			handler.ServeHTTP(res, req)
+		}
+		return http.HandlerFunc(shortDomainFunc)
+	}
+}
@@ -0,0 +1,88 @@
+package workspace
+
+import (
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsWorkspace "go-url-shortener/internal/models/workspace"
+	cookiesUserData "go-url-shortener/internal/userdata/usercookies"
+	"go-url-shortener/internal/workspaces"
+	"net/http"
+)
+
+// Определяем рабочее пространство запроса по домену или префиксу пути и пользователя по подписанной куке
+// Префикс пути рабочего пространства отрезается, чтобы дальше работали обычные маршруты
+// Ключ подписи читается при каждом запросе из настройки USER_ID_SECRET
+func WrapWorkspace(configApp config.ConfigTypeInterface, registry *workspaces.Registry) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return wrapWorkspace(configApp, registry, handler)
+	}
+}
+
+func wrapWorkspace(configApp config.ConfigTypeInterface, registry *workspaces.Registry, handler http.Handler) http.Handler {
+	workspaceFunc := func(res http.ResponseWriter, req *http.Request) {
+
+		// кука без подписи или с чужой подписью не принимается, пользователь получает новый идентификатор
+		secret := configApp.GetUserIDSecret()
+		userID := cookiesUserData.GetCookiesUserID(req, secret)
+		if userID == "" {
+			newUserID, err := cookiesUserData.NewUserID()
+			if err != nil {
+				logger.GetLogger().Error(err.Error())
+			} else {
+				userID = newUserID
+				cookiesUserData.SetCookiesUserID(userID, secret, res)
+			}
+		}
+		ctx := modelsWorkspace.WithUserID(req.Context(), userID)
+		logger.AddContextFields(ctx, logger.CustomFields{"user_id": userID})
+
+		ws, restPath, ok := registry.Resolve(req.Host, req.URL.Path)
+		if ok {
+			ctx = modelsWorkspace.WithWorkspaceID(ctx, ws.ID)
+		}
+		req = req.WithContext(ctx)
+
+		if restPath != req.URL.Path {
+			// запрос уже скопирован WithContext, меняем путь у копии адреса
+			urlRest := *req.URL
+			urlRest.Path = restPath
+			urlRest.RawPath = ""
+			req.URL = &urlRest
+		}
+
+		handler.ServeHTTP(res, req)
+	}
+	return http.HandlerFunc(workspaceFunc)
+}
+
+// Проверка роли пользователя в рабочем пространстве запроса
+// RoleMember - создавать ссылки в пространстве с участниками могут только участники, пространство без участников открыто всем
+// RoleAdmin - только администраторы рабочего пространства, в общем пространстве администраторов нет
+func RequireRole(registry *workspaces.Registry, role string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		roleFunc := func(res http.ResponseWriter, req *http.Request) {
+
+			ctx := req.Context()
+			ws, _ := registry.Get(modelsWorkspace.GetWorkspaceID(ctx))
+			userRole := ws.GetRole(modelsWorkspace.GetUserID(ctx))
+
+			isAllowed := userRole == modelsWorkspace.RoleAdmin
+			if role == modelsWorkspace.RoleMember {
+				isAllowed = isAllowed || userRole == modelsWorkspace.RoleMember || !ws.IsRestricted()
+			}
+
+			if !isAllowed {
+				strError := "ошибка: нет доступа к рабочему пространству, нужна роль " + role
+				logger.GetLogger().Debugf("%s, рабочее пространство: %q", strError, ws.ID)
+
+				res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				res.WriteHeader(http.StatusForbidden)
+				res.Write([]byte(strError))
+				return
+			}
+
+			handler.ServeHTTP(res, req)
+		}
+		return http.HandlerFunc(roleFunc)
+	}
+}
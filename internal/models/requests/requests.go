@@ -1,11 +1,18 @@
-package requests
-
-type RequestServiceLink struct {
-	URL string `json:"url,omitempty"`
-}
-
-type RowBatchServiceLink struct {
-	CorrelationID string `json:"correlation_id,omitempty"`
-	OriginalURL   string `json:"original_url,omitempty"`
-}
-type RequestBatchServiceLinks []RowBatchServiceLink
+package requests
+
+type RequestServiceLink struct {
+	URL string `json:"url,omitempty"`
+	// домен короткой ссылки, пусто - домен запроса
+	Domain string `json:"domain,omitempty"`
+}
+
+type RowBatchServiceLink struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	OriginalURL   string `json:"original_url,omitempty"`
+}
+type RequestBatchServiceLinks []RowBatchServiceLink
+
+// новый уровень логирования: panic, fatal, error, warning, info, debug или trace
+type RequestLogLevel struct {
+	Level string `json:"level"`
+}
@@ -1,16 +1,138 @@
-package responses
-
-type ResponseServiceLink struct {
-	Result string `json:"result,omitempty"`
-}
-
-type ResponseListShortLinks struct {
-	ShortURL    string `json:"short_url,omitempty"`
-	OriginalURL string `json:"original_url,omitempty"`
-}
-
-type RowBatchServiceLink struct {
-	CorrelationID string `json:"correlation_id,omitempty"`
-	ShortURL      string `json:"short_url,omitempty"`
-}
-type ResponseBatchServiceLinks []RowBatchServiceLink
+package responses
+
+type ResponseServiceLink struct {
+	Result string `json:"result,omitempty"`
+}
+
+type ResponseListShortLinks struct {
+	ShortURL    string `json:"short_url,omitempty"`
+	OriginalURL string `json:"original_url,omitempty"`
+}
+
+type RowBatchServiceLink struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+}
+type ResponseBatchServiceLinks []RowBatchServiceLink
+
+// диагностика хранилища ссылок
+type ResponseDiagnosticsStorage struct {
+	// тип хранилища из настройки STORAGE_BACKEND
+	ConfigBackend string `json:"config_backend"`
+	// тип работающего хранилища
+	ActiveBackend string `json:"active_backend"`
+}
+
+// счетчики кеша хранилища ссылок
+type ResponseDiagnosticsCache struct {
+	Hits         uint64 `json:"hits"`
+	Misses       uint64 `json:"misses"`
+	NegativeHits uint64 `json:"negative_hits"`
+	Evictions    uint64 `json:"evictions"`
+	Size         int    `json:"size"`
+	Capacity     int    `json:"capacity"`
+}
+
+// состояние пула соединений с БД
+type ResponseDiagnosticsDBPool struct {
+	AcquiredConns        int32 `json:"acquired_conns"`
+	IdleConns            int32 `json:"idle_conns"`
+	TotalConns           int32 `json:"total_conns"`
+	MaxConns             int32 `json:"max_conns"`
+	AcquireCount         int64 `json:"acquire_count"`
+	EmptyAcquireCount    int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount int64 `json:"canceled_acquire_count"`
+	// общее время ожидания соединений из пула в миллисекундах
+	AcquireWaitMs int64 `json:"acquire_wait_ms"`
+}
+
+type ResponseDiagnostics struct {
+	Storage ResponseDiagnosticsStorage `json:"storage"`
+	// пусто, если кеш выключен
+	Cache *ResponseDiagnosticsCache `json:"cache,omitempty"`
+	// пусто, если хранилище работает без БД
+	DBPool *ResponseDiagnosticsDBPool `json:"db_pool,omitempty"`
+}
+
+// состояние одной части сервиса
+type ResponseHealthComponent struct {
+	Name string `json:"name"`
+	// ok, error или draining
+	Status string `json:"status"`
+	// без этой части сервис не готов принимать запросы
+	Required bool `json:"required"`
+	// время проверки
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// тип хранилища ссылок, только у хранилища
+	Backend string `json:"backend,omitempty"`
+}
+
+// подробное состояние сервиса
+type ResponseHealth struct {
+	// ok - сервис готов принимать запросы
+	Status     string                    `json:"status"`
+	Components []ResponseHealthComponent `json:"components"`
+}
+
+// уровень логирования
+type ResponseLogLevel struct {
+	Level       string `json:"level"`
+	LevelNumber int    `json:"level_number"`
+}
+
+// состояние рантайма Go
+type ResponseRuntime struct {
+	GoVersion     string  `json:"go_version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Goroutines    int     `json:"goroutines"`
+	NumCPU        int     `json:"num_cpu"`
+	MaxProcs      int     `json:"max_procs"`
+	// память в куче и полученная от ОС
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	// сборщик мусора
+	NumGC       uint32  `json:"num_gc"`
+	GCPauseMs   float64 `json:"gc_pause_total_ms"`
+	LastGCUnixS int64   `json:"last_gc_unix,omitempty"`
+}
+
+// результат очистки кеша
+type ResponseCacheFlush struct {
+	// сколько записей было в кеше
+	Flushed int `json:"flushed"`
+}
+
+// результат сжатия хранилища
+type ResponseStorageCompact struct {
+	Backend string `json:"backend"`
+	// сколько ссылок осталось в файле
+	Rows int `json:"rows"`
+}
+
+// состояние короткой ссылки после отключения или включения
+type ResponseLinkSwitch struct {
+	ShortLink   string `json:"short_link"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	Domain      string `json:"domain,omitempty"`
+	Disabled    bool   `json:"disabled"`
+}
+
+// рабочее пространство запроса и роль пользователя в нем
+type ResponseWorkspace struct {
+	// пусто - общее рабочее пространство
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	UserID string `json:"user_id"`
+	// пусто - пользователь не участник рабочего пространства
+	Role string `json:"role,omitempty"`
+}
+
+// результат перезагрузки конфигурации
+type ResponseConfigReload struct {
+	// примененные без перезапуска настройки
+	Changed []string `json:"changed"`
+	// измененные настройки, которые начнут действовать только после перезапуска
+	Warnings []string `json:"warnings"`
+}
@@ -1,21 +1,30 @@
-package service
-
-import (
-	"context"
-	modelsResponses "go-url-shortener/internal/models/responses"
-)
-
-type RowShortLink modelsResponses.ResponseListShortLinks
-type ListShortLinks []RowShortLink
-
-// ключ - полная ссылка, значение - короткая ссылка c хостом
-type BatchShortLinks map[string]string
-
-type ServiceShortInterface interface {
-	GetBatchShortLink(ctx context.Context, listFullURL []string) (dataBatch BatchShortLinks, err error)
-	AddNewFullURL(ctx context.Context, fullURL string) (serviceLink string, err error)
-	GetServiceLinkByURL(ctx context.Context, fullURL string) (serviceLink string, err error)
-	GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error)
-	GetDataShortLinks(ctx context.Context, listFullURL any) (shortLinks ListShortLinks, err error)
-	SetLength(length int)
-}
+package service
+
+import (
+	"context"
+	modelsResponses "go-url-shortener/internal/models/responses"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+)
+
+type RowShortLink modelsResponses.ResponseListShortLinks
+type ListShortLinks []RowShortLink
+
+// ключ - полная ссылка, значение - короткая ссылка c хостом
+type BatchShortLinks map[string]string
+
+// базовый тип ошибки, если короткая ссылка отключена администратором
+// Признак отключения хранится в хранилище, ошибка общая с ним, чтобы errors.Is срабатывал для обеих
+var ErrDisabledShortLink = modelsStorage.ErrDisabledShortLink
+
+type ServiceShortInterface interface {
+	GetBatchShortLink(ctx context.Context, listFullURL []string) (dataBatch BatchShortLinks, err error)
+	AddNewFullURL(ctx context.Context, fullURL string) (serviceLink string, err error)
+	GetServiceLinkByURL(ctx context.Context, fullURL string) (serviceLink string, err error)
+	GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error)
+	GetDataShortLinks(ctx context.Context, listFullURL any) (shortLinks ListShortLinks, err error)
+	SetLength(length int)
+	// хранилище, с которым работает сервис
+	GetStorage() modelsStorage.StorageShortInterface
+	// отключение и включение короткой ссылки в пространстве имен из контекста
+	SetLinkDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error)
+}
@@ -1,73 +1,333 @@
-package storageshortlink
-
-import (
-	"context"
-	"errors"
-	"fmt"
-)
-
-// тип для одной запись с данными о короткой ссылке
-type RowStorageShortLink struct {
-	ShortLink string
-	FullURL   string
-	UUID      string
-}
-
-// вид хранения ссылок в памяти
-// ключом является короткая ссылка
-type DataStorageShortLink map[string]RowStorageShortLink
-
-// базовый тип ошибки, если мы добавлем в хранилище адрес, который там уже присутствует
-var ErrExistFullURL = errors.New("ошибка: в хранилище уже существует указанный оригинальный URL")
-
-// расширенный тип ошибки, если мы добавлем в хранилище адрес, который там уже присутствует
-type ErrExistFullURLExt struct {
-	fullURL     string
-	OriginalErr error
-}
-
-func (errExist ErrExistFullURLExt) Error() string {
-	fullURL := errExist.fullURL
-	return fmt.Sprintf("ошибка: в хранилище уже существует указанный оригинальный URL: %s", fullURL)
-}
-
-func (errExist ErrExistFullURLExt) GetFullURL() string {
-	return errExist.fullURL
-}
-
-// возвращаем оригинальную ошибку
-func (errExist *ErrExistFullURLExt) Unwrap() error {
-	return errExist.OriginalErr
-}
-
-// Создаем ошибку типа ErrExistFullURLExt
-func NewErrExistFullURLExt(fullURL string) *ErrExistFullURLExt {
-	return &ErrExistFullURLExt{
-		fullURL:     fullURL,
-		OriginalErr: ErrExistFullURL,
-	}
-}
-
-// фильтр для получения коротких ссылок
-type FilterOptionsQuery struct {
-	ListFullURL []string
-}
-
-type OptionsQuery struct {
-	Filter FilterOptionsQuery
-}
-
-// тип для хранилища данных ссылок
-type StorageShortInterface interface {
-	GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error)
-	GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error)
-	AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error)
-	// добавление коротких ссылок группой
-	AddBatchShortLinks(ctx context.Context, dataBatch DataStorageShortLink) (err error)
-	// установка всех данных хранилища
-	SetData(ctx context.Context, data DataStorageShortLink) (err error)
-	GetCountLink(ctx context.Context) (count int, err error)
-	GetShortLinks(ctx context.Context, options *OptionsQuery) (shortLinks DataStorageShortLink, err error)
-	Init(ctx context.Context) (err error)
-	ClearStorage(ctx context.Context) (err error)
-}
+package storageshortlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	modelsWorkspace "go-url-shortener/internal/models/workspace"
+)
+
+// тип для одной запись с данными о короткой ссылке
+type RowStorageShortLink struct {
+	ShortLink string
+	FullURL   string
+	UUID      string
+	// рабочее пространство ссылки, пусто - общее пространство
+	WorkspaceID string
+	// домен короткой ссылки, пусто - домен из BASE_URL
+	Domain string
+	// ссылка отключена администратором: остается в хранилище, но не открывается
+	Disabled bool
+}
+
+// Пространство имен ссылки
+func (row RowStorageShortLink) GetNamespace() Namespace {
+	return Namespace{
+		WorkspaceID: row.WorkspaceID,
+		Domain:      row.Domain,
+	}
+}
+
+// вид хранения ссылок в памяти
+// ключом является короткая ссылка, для данных всех пространств имен - ключ GetStorageKey
+type DataStorageShortLink map[string]RowStorageShortLink
+
+// Пространство имен ссылок: рабочее пространство и домен коротких ссылок
+// Короткие и полные ссылки уникальны только в пределах пространства имен
+type Namespace struct {
+	WorkspaceID string
+	Domain      string
+}
+
+// Ключ короткой или полной ссылки среди ссылок всех пространств имен
+// Для пространства имен по умолчанию ключ совпадает со ссылкой, поэтому старые данные читаются без изменений
+func GetStorageKey(namespace Namespace, value string) string {
+	if namespace == (Namespace{}) {
+		return value
+	}
+	// в идентификаторе рабочего пространства и в домене не бывает символа |
+	return namespace.WorkspaceID + "|" + namespace.Domain + "|" + value
+}
+
+type ctxKeyShortDomain struct{}
+
+// Контекст с доменом коротких ссылок
+func WithShortDomain(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, ctxKeyShortDomain{}, domain)
+}
+
+// Домен коротких ссылок из контекста, по умолчанию домен из BASE_URL
+func GetShortDomain(ctx context.Context) string {
+	domain, _ := ctx.Value(ctxKeyShortDomain{}).(string)
+	return domain
+}
+
+// Пространство имен ссылок из контекста
+func GetNamespace(ctx context.Context) Namespace {
+	return Namespace{
+		WorkspaceID: modelsWorkspace.GetWorkspaceID(ctx),
+		Domain:      GetShortDomain(ctx),
+	}
+}
+
+// Контекст с пространством имен ссылок
+func WithNamespace(ctx context.Context, namespace Namespace) context.Context {
+	ctx = modelsWorkspace.WithWorkspaceID(ctx, namespace.WorkspaceID)
+	return WithShortDomain(ctx, namespace.Domain)
+}
+
+// базовый тип ошибки, если мы добавлем в хранилище адрес, который там уже присутствует
+var ErrExistFullURL = errors.New("ошибка: в хранилище уже существует указанный оригинальный URL")
+
+// расширенный тип ошибки, если мы добавлем в хранилище адрес, который там уже присутствует
+type ErrExistFullURLExt struct {
+	fullURL     string
+	OriginalErr error
+}
+
+func (errExist ErrExistFullURLExt) Error() string {
+	fullURL := errExist.fullURL
+	return fmt.Sprintf("ошибка: в хранилище уже существует указанный оригинальный URL: %s", fullURL)
+}
+
+func (errExist ErrExistFullURLExt) GetFullURL() string {
+	return errExist.fullURL
+}
+
+// возвращаем оригинальную ошибку
+func (errExist *ErrExistFullURLExt) Unwrap() error {
+	return errExist.OriginalErr
+}
+
+// Создаем ошибку типа ErrExistFullURLExt
+func NewErrExistFullURLExt(fullURL string) *ErrExistFullURLExt {
+	return &ErrExistFullURLExt{
+		fullURL:     fullURL,
+		OriginalErr: ErrExistFullURL,
+	}
+}
+
+// базовый тип ошибки, если короткая ссылка уже занята другим оригинальным URL
+var ErrExistShortLink = errors.New("ошибка: в хранилище уже существует указанная короткая ссылка")
+
+// расширенный тип ошибки, если короткая ссылка уже занята другим оригинальным URL
+// Сервис в этом случае повторяет добавление с новой короткой ссылкой
+type ErrExistShortLinkExt struct {
+	shortLink   string
+	OriginalErr error
+}
+
+func (errExist ErrExistShortLinkExt) Error() string {
+	return "ошибка: в хранилище уже существует указанная короткая ссылка: " + errExist.shortLink
+}
+
+func (errExist ErrExistShortLinkExt) GetShortLink() string {
+	return errExist.shortLink
+}
+
+// возвращаем оригинальную ошибку
+func (errExist *ErrExistShortLinkExt) Unwrap() error {
+	return errExist.OriginalErr
+}
+
+// Создаем ошибку типа ErrExistShortLinkExt
+func NewErrExistShortLinkExt(shortLink string) *ErrExistShortLinkExt {
+	return &ErrExistShortLinkExt{
+		shortLink:   shortLink,
+		OriginalErr: ErrExistShortLink,
+	}
+}
+
+// базовый тип ошибки, если короткая ссылка не найдена в хранилище
+var ErrNotFoundShortLink = errors.New("ошибка: короткая ссылка не зарегистрирована")
+
+// расширенный тип ошибки, если короткая ссылка не найдена в хранилище
+type ErrNotFoundShortLinkExt struct {
+	shortLink   string
+	OriginalErr error
+}
+
+func (errNotFound ErrNotFoundShortLinkExt) Error() string {
+	return "Короткая ссылка " + errNotFound.shortLink + " не зарегистрирована"
+}
+
+func (errNotFound ErrNotFoundShortLinkExt) GetShortLink() string {
+	return errNotFound.shortLink
+}
+
+// возвращаем оригинальную ошибку
+func (errNotFound *ErrNotFoundShortLinkExt) Unwrap() error {
+	return errNotFound.OriginalErr
+}
+
+// Создаем ошибку типа ErrNotFoundShortLinkExt
+func NewErrNotFoundShortLinkExt(shortLink string) *ErrNotFoundShortLinkExt {
+	return &ErrNotFoundShortLinkExt{
+		shortLink:   shortLink,
+		OriginalErr: ErrNotFoundShortLink,
+	}
+}
+
+// базовый тип ошибки, если короткая ссылка отключена администратором
+var ErrDisabledShortLink = errors.New("ошибка: короткая ссылка отключена")
+
+// расширенный тип ошибки, если короткая ссылка отключена администратором
+type ErrDisabledShortLinkExt struct {
+	shortLink   string
+	OriginalErr error
+}
+
+func (errDisabled ErrDisabledShortLinkExt) Error() string {
+	return "Короткая ссылка " + errDisabled.shortLink + " отключена"
+}
+
+func (errDisabled ErrDisabledShortLinkExt) GetShortLink() string {
+	return errDisabled.shortLink
+}
+
+// возвращаем оригинальную ошибку
+func (errDisabled *ErrDisabledShortLinkExt) Unwrap() error {
+	return errDisabled.OriginalErr
+}
+
+// Создаем ошибку типа ErrDisabledShortLinkExt
+func NewErrDisabledShortLinkExt(shortLink string) *ErrDisabledShortLinkExt {
+	return &ErrDisabledShortLinkExt{
+		shortLink:   shortLink,
+		OriginalErr: ErrDisabledShortLink,
+	}
+}
+
+// фильтр для получения коротких ссылок
+type FilterOptionsQuery struct {
+	ListFullURL []string
+}
+
+type OptionsQuery struct {
+	Filter FilterOptionsQuery
+	// ссылки всех пространств имен с ключами GetStorageKey, фильтр не применяется
+	AllWorkspaces bool
+}
+
+// тип для хранилища данных ссылок
+// Операции выполняются в пространстве имен из контекста (GetNamespace),
+// кроме SetData и ClearStorage, которые работают с данными всех пространств имен
+type StorageShortInterface interface {
+	GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error)
+	GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error)
+	AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error)
+	// добавление коротких ссылок группой
+	AddBatchShortLinks(ctx context.Context, dataBatch DataStorageShortLink) (err error)
+	// установка всех данных хранилища
+	SetData(ctx context.Context, data DataStorageShortLink) (err error)
+	GetCountLink(ctx context.Context) (count int, err error)
+	GetShortLinks(ctx context.Context, options *OptionsQuery) (shortLinks DataStorageShortLink, err error)
+	Init(ctx context.Context) (err error)
+	ClearStorage(ctx context.Context) (err error)
+}
+
+// названия типов хранилищ
+const (
+	StorageBackendPostgres       = "postgres"
+	StorageBackendPostgresCached = "postgres-cached"
+	// БД с переключением на локальные данные при ее недоступности
+	StorageBackendPostgresFailover = "postgres-failover"
+	StorageBackendFile             = "file"
+	StorageBackendMemory           = "memory"
+)
+
+// хранилище, которое сообщает свой тип, например для диагностики
+type StorageDescriberInterface interface {
+	GetBackendName() string
+}
+
+// хранилище, которому нужно корректно завершить работу при остановке приложения
+type StorageCloserInterface interface {
+	Close(ctx context.Context) (err error)
+}
+
+// хранилище, которое проверяет, что может работать: БД отвечает, файл доступен на запись
+type StorageReadyCheckerInterface interface {
+	CheckReady(ctx context.Context) (err error)
+}
+
+// базовый тип ошибки, если хранилище не умеет выполнять служебную операцию
+var ErrNotSupported = errors.New("ошибка: операция не поддерживается хранилищем")
+
+// хранилище, которое умеет сжать свои данные: переписать файл только с действующими ссылками
+type StorageCompacterInterface interface {
+	Compact(ctx context.Context) (countRows int, err error)
+}
+
+// хранилище, в котором администратор может отключить короткую ссылку в пространстве имен из контекста
+// Отключенная ссылка остается в хранилище, GetFullLinkByShort возвращает для нее ErrDisabledShortLinkExt
+// Для незарегистрированной ссылки возвращается ErrNotFoundShortLinkExt
+type StorageDisablerInterface interface {
+	SetDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error)
+}
+
+// счетчики кеша перед хранилищем
+type CacheStats struct {
+	// найдено в кеше
+	Hits uint64
+	// не найдено в кеше, запрос ушел в хранилище
+	Misses uint64
+	// найдено в кеше как несуществующая короткая ссылка
+	NegativeHits uint64
+	// вытеснено из кеша при переполнении
+	Evictions uint64
+	// текущее и максимальное количество записей
+	Size     int
+	Capacity int
+}
+
+// хранилище с кешем, которое отдает счетчики кеша
+type StorageCacheStatsInterface interface {
+	GetCacheStats() CacheStats
+}
+
+// хранилище с кешем, который можно очистить
+type StorageCachePurgerInterface interface {
+	Purge()
+}
+
+// виды изменений данных коротких ссылок, о которых сообщает БД
+const (
+	ChangeOperationInsert = "INSERT"
+	ChangeOperationUpdate = "UPDATE"
+	ChangeOperationDelete = "DELETE"
+	// таблица очищена или изменение не удалось передать целиком, нужна полная синхронизация
+	ChangeOperationResync = "RESYNC"
+)
+
+// изменение одной короткой ссылки, сделанное другим экземпляром сервиса
+type ChangeShortLink struct {
+	Operation string
+	Row       RowStorageShortLink
+	// короткая ссылка до изменения, для UPDATE и DELETE
+	OldShortLink string
+}
+
+// хранилище с локальными данными, которые надо обновлять при изменениях в БД
+type StorageChangesApplierInterface interface {
+	// применить одно изменение
+	ApplyChange(ctx context.Context, change ChangeShortLink) (err error)
+	// полностью перечитать данные, например после пропущенных уведомлений
+	Resync(ctx context.Context) (err error)
+}
+
+// результат группового добавления для одной полной ссылки
+type RowBatchResultShortLink struct {
+	RowStorageShortLink
+	// ссылка уже была в хранилище, ShortLink - ее существующая короткая ссылка
+	IsExisted bool
+}
+
+// результат группового добавления, ключом является полная ссылка
+type BatchResultShortLinks map[string]RowBatchResultShortLink
+
+// хранилище, которое за один проход добавляет группу ссылок и сообщает, какие из них уже существовали
+// Полных ссылок, чья короткая ссылка оказалась занята, в результате нет
+type StorageBatchReturningInterface interface {
+	AddBatchShortLinksReturning(ctx context.Context, dataBatch DataStorageShortLink) (result BatchResultShortLinks, err error)
+}
@@ -0,0 +1,77 @@
+package workspace
+
+import "context"
+
+// идентификатор общего рабочего пространства, в нем лежат ссылки, созданные без рабочего пространства
+const DefaultWorkspaceID = ""
+
+// роли участников рабочего пространства
+const (
+	// видит все ссылки рабочего пространства
+	RoleAdmin = "admin"
+	// создает ссылки и видит свои ссылки
+	RoleMember = "member"
+)
+
+// участник рабочего пространства
+type Member struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// Рабочее пространство со своим набором коротких ссылок
+// Открывается по своему домену или по префиксу пути на общем домене
+type Workspace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// домен коротких ссылок рабочего пространства, например go.team.ru
+	Domain string `json:"domain"`
+	// префикс пути на общем домене, например /team
+	PathPrefix string   `json:"path_prefix"`
+	Members    []Member `json:"members"`
+}
+
+// Роль пользователя в рабочем пространстве, пусто - пользователь не участник
+func (ws Workspace) GetRole(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	for _, member := range ws.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// Создавать ссылки в рабочем пространстве могут только участники
+// Пространство без участников открыто для всех
+func (ws Workspace) IsRestricted() bool {
+	return len(ws.Members) > 0
+}
+
+type ctxKeyWorkspaceID struct{}
+
+// Контекст запроса с рабочим пространством, все операции хранилища выполняются в нем
+func WithWorkspaceID(ctx context.Context, workspaceID string) context.Context {
+	return context.WithValue(ctx, ctxKeyWorkspaceID{}, workspaceID)
+}
+
+// Рабочее пространство из контекста, по умолчанию общее
+func GetWorkspaceID(ctx context.Context) string {
+	workspaceID, _ := ctx.Value(ctxKeyWorkspaceID{}).(string)
+	return workspaceID
+}
+
+type ctxKeyUserID struct{}
+
+// Контекст запроса с идентификатором пользователя, по нему проверяется роль в рабочем пространстве
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, ctxKeyUserID{}, userID)
+}
+
+// Идентификатор пользователя из контекста, пусто - пользователь неизвестен
+func GetUserID(ctx context.Context) string {
+	userID, _ := ctx.Value(ctxKeyUserID{}).(string)
+	return userID
+}
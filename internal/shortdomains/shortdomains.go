@@ -0,0 +1,165 @@
+package shortdomains
+
+import (
+	"fmt"
+	"go-url-shortener/internal/config"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// домен коротких ссылок по умолчанию, ссылки на нем открываются по адресу из BASE_URL
+const DefaultDomain = ""
+
+// Набор доменов коротких ссылок
+// Домен ссылки хранится вместе с ней, поэтому одна и та же короткая ссылка может быть на разных доменах
+type Registry struct {
+	// хост домена по умолчанию из BASE_URL
+	defaultHost string
+	// префикс пути домена по умолчанию из BASE_URL
+	defaultBasePath string
+	// дополнительные домены в порядке описания в конфигурации
+	listDomains []string
+	// адрес открытия коротких ссылок для каждого дополнительного домена
+	byDomain map[string]string
+}
+
+// Хост адреса в нижнем регистре, порт по умолчанию для схемы отбрасывается
+// Адрес можно указать как полностью, https://brand.io, так и только хостом, brand.io
+func parseHost(value string) (host string, err error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	baseURL, err := config.ParseBaseURL(value)
+	if err != nil {
+		return "", fmt.Errorf("ошибка: не удалось разобрать домен коротких ссылок: %w", err)
+	}
+	urlDomain, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("ошибка: не удалось разобрать домен коротких ссылок %q: %w", value, err)
+	}
+	return urlDomain.Host, nil
+}
+
+// Создание набора доменов
+// baseURL - адрес из BASE_URL, listBaseURL - адреса дополнительных доменов вида https://brand.io
+func NewRegistry(baseURL string, listBaseURL []string) (registry *Registry, err error) {
+
+	registry = &Registry{
+		byDomain: make(map[string]string, len(listBaseURL)),
+	}
+	if baseURL != "" {
+		registry.defaultHost, err = parseHost(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		registry.defaultBasePath = config.GetBasePath(baseURL)
+	}
+
+	for _, baseURLDomain := range listBaseURL {
+		if strings.TrimSpace(baseURLDomain) == "" {
+			continue
+		}
+
+		baseURLParsed, errParse := config.ParseBaseURL(baseURLDomain)
+		if errParse != nil || !strings.Contains(baseURLDomain, "://") {
+			return nil, fmt.Errorf("ошибка: домен коротких ссылок %q надо указать адресом вида https://brand.io", baseURLDomain)
+		}
+		urlDomain, errParse := url.Parse(baseURLParsed)
+		if errParse != nil {
+			return nil, fmt.Errorf("ошибка: не удалось разобрать домен коротких ссылок %q: %w", baseURLDomain, errParse)
+		}
+		baseURLDomain = baseURLParsed
+
+		domain := urlDomain.Host
+		if domain == registry.defaultHost {
+			return nil, fmt.Errorf("ошибка: домен коротких ссылок %s совпадает с доменом из BASE_URL", domain)
+		}
+		if _, ok := registry.byDomain[domain]; ok {
+			return nil, fmt.Errorf("ошибка: домен коротких ссылок %s указан несколько раз", domain)
+		}
+
+		registry.listDomains = append(registry.listDomains, domain)
+		registry.byDomain[domain] = baseURLDomain
+	}
+
+	return registry, nil
+}
+
+// Разбор списка адресов доменов через запятую из конфигурации
+func ParseList(value string) (listBaseURL []string) {
+	for _, baseURL := range strings.Split(value, ",") {
+		baseURL = strings.TrimSpace(baseURL)
+		if baseURL != "" {
+			listBaseURL = append(listBaseURL, baseURL)
+		}
+	}
+	return
+}
+
+// Домен запроса по заголовку Host
+// Домен ищется с портом и без него, незнакомый хост относится к домену по умолчанию
+func (registry *Registry) Resolve(host string) (domain string, ok bool) {
+
+	if len(registry.listDomains) == 0 {
+		return DefaultDomain, false
+	}
+
+	host = strings.ToLower(host)
+	if _, ok = registry.byDomain[host]; ok {
+		return host, true
+	}
+	if hostname, _, errSplit := net.SplitHostPort(host); errSplit == nil {
+		if _, ok = registry.byDomain[hostname]; ok {
+			return hostname, true
+		}
+	}
+	return DefaultDomain, false
+}
+
+// Домен, выбранный клиентом при создании ссылки
+// Домен можно указать хостом или адресом, домен из BASE_URL означает домен по умолчанию
+func (registry *Registry) Lookup(value string) (domain string, err error) {
+
+	if strings.TrimSpace(value) == "" {
+		return DefaultDomain, nil
+	}
+
+	host, err := parseHost(value)
+	if err != nil {
+		return
+	}
+	if host == registry.defaultHost {
+		return DefaultDomain, nil
+	}
+	if _, ok := registry.byDomain[host]; !ok {
+		return "", fmt.Errorf("ошибка: неизвестный домен коротких ссылок %s", host)
+	}
+	return host, nil
+}
+
+// Адрес открытия коротких ссылок дополнительного домена
+// Для домена по умолчанию адрес не возвращается, он берется из BASE_URL
+func (registry *Registry) GetBaseURL(domain string) (baseURL string, ok bool) {
+	baseURL, ok = registry.byDomain[domain]
+	return
+}
+
+// Префикс пути, под которым открываются короткие ссылки домена
+func (registry *Registry) GetBasePath(domain string) string {
+	if domain == DefaultDomain {
+		return registry.defaultBasePath
+	}
+	return config.GetBasePath(registry.byDomain[domain])
+}
+
+// Все домены коротких ссылок, первым идет домен по умолчанию
+func (registry *Registry) GetList() []string {
+	return append([]string{DefaultDomain}, registry.listDomains...)
+}
+
+// Домены коротких ссылок из настроек BASE_URL и SHORT_DOMAINS
+func NewRegistryFromConfig(configApp config.ConfigTypeInterface) (registry *Registry, err error) {
+	return NewRegistry(configApp.GetHostShortLink(), ParseList(configApp.GetShortDomains()))
+}
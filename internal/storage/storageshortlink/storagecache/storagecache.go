@@ -0,0 +1,440 @@
+package storagecache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"sync"
+	"time"
+)
+
+// запись кеша
+type entryCache struct {
+	// ключ modelsStorage.GetStorageKey рабочего пространства и короткой ссылки
+	key     string
+	fullURL string
+	// короткая ссылка не зарегистрирована в хранилище
+	isNotFound bool
+	// короткая ссылка отключена администратором
+	isDisabled bool
+	expiresAt  time.Time
+}
+
+// Кеш коротких ссылок перед любым хранилищем
+// Кешируется только получение полной ссылки по короткой - это путь редиректа.
+// Размер кеша ограничен, при переполнении вытесняются давно не использованные записи.
+// Незарегистрированные короткие ссылки тоже кешируются, но на короткое время,
+// чтобы перебор коротких ссылок не доходил до хранилища.
+type StorageShortLink struct {
+	storage modelsStorage.StorageShortInterface
+
+	mutex sync.Mutex
+	// список записей, в начале недавно использованные
+	listLRU *list.List
+	// индекс ключ короткой ссылки -> элемент списка
+	items map[string]*list.Element
+
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	// увеличивается при каждой инвалидации, чтобы не закешировать устаревший ответ хранилища
+	generation uint64
+
+	hits         uint64
+	misses       uint64
+	negativeHits uint64
+	evictions    uint64
+}
+
+type StorageShortInterface interface {
+	modelsStorage.StorageShortInterface
+	modelsStorage.StorageCacheStatsInterface
+	modelsStorage.StorageChangesApplierInterface
+	modelsStorage.StorageBatchReturningInterface
+	// удалить короткую ссылку рабочего пространства из кеша
+	Invalidate(namespace modelsStorage.Namespace, shortLink string)
+	// очистить кеш
+	Purge()
+	// изменить размер кеша и время жизни записей
+	SetLimits(capacity int, ttl, negativeTTL time.Duration) error
+}
+
+// Создание кеша перед хранилищем
+// capacity - максимальное количество записей, ttl - время жизни найденной ссылки,
+// negativeTTL - время жизни записи о незарегистрированной ссылке, 0 - такие записи не кешируются
+func NewStorageShorts(storage modelsStorage.StorageShortInterface, capacity int, ttl, negativeTTL time.Duration) (StorageShortInterface, error) {
+
+	if storage == nil {
+		return nil, errors.New("ошибка: не передано хранилище для кеширования")
+	}
+	if capacity <= 0 {
+		return nil, errors.New("ошибка: размер кеша хранилища должен быть больше нуля")
+	}
+
+	return &StorageShortLink{
+		storage:     storage,
+		listLRU:     list.New(),
+		items:       make(map[string]*list.Element, capacity),
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}, nil
+}
+
+// Тип хранилища берем у кешируемого хранилища
+func (store *StorageShortLink) GetBackendName() string {
+	if storageDescriber, ok := store.storage.(modelsStorage.StorageDescriberInterface); ok {
+		return storageDescriber.GetBackendName()
+	}
+	return "unknown"
+}
+
+// Готовность определяется кешируемым хранилищем
+func (store *StorageShortLink) CheckReady(ctx context.Context) (err error) {
+	if readyChecker, ok := store.storage.(modelsStorage.StorageReadyCheckerInterface); ok {
+		return readyChecker.CheckReady(ctx)
+	}
+	return nil
+}
+
+// Сжатие кешируемого хранилища, кеш при этом не меняется
+func (store *StorageShortLink) Compact(ctx context.Context) (countRows int, err error) {
+	if storageCompacter, ok := store.storage.(modelsStorage.StorageCompacterInterface); ok {
+		return storageCompacter.Compact(ctx)
+	}
+	return 0, modelsStorage.ErrNotSupported
+}
+
+// Остановка кешируемого хранилища
+func (store *StorageShortLink) Close(ctx context.Context) (err error) {
+	if storageCloser, ok := store.storage.(modelsStorage.StorageCloserInterface); ok {
+		return storageCloser.Close(ctx)
+	}
+	return nil
+}
+
+// Получаем счетчики кеша
+func (store *StorageShortLink) GetCacheStats() modelsStorage.CacheStats {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return modelsStorage.CacheStats{
+		Hits:         store.hits,
+		Misses:       store.misses,
+		NegativeHits: store.negativeHits,
+		Evictions:    store.evictions,
+		Size:         store.listLRU.Len(),
+		Capacity:     store.capacity,
+	}
+}
+
+// удалить короткую ссылку пространства имен из кеша
+func (store *StorageShortLink) Invalidate(namespace modelsStorage.Namespace, shortLink string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.generation++
+	store.removeItem(modelsStorage.GetStorageKey(namespace, shortLink))
+}
+
+// очистить кеш
+func (store *StorageShortLink) Purge() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.generation++
+	store.listLRU.Init()
+	store.items = make(map[string]*list.Element, store.capacity)
+}
+
+func (store *StorageShortLink) removeItem(key string) {
+	if element, ok := store.items[key]; ok {
+		store.listLRU.Remove(element)
+		delete(store.items, key)
+	}
+}
+
+// поиск в кеше, устаревшие записи удаляются
+func (store *StorageShortLink) getItem(key string) (entry entryCache, generation uint64, ok bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	generation = store.generation
+	element, ok := store.items[key]
+	if !ok {
+		store.misses++
+		return
+	}
+
+	entry = element.Value.(entryCache)
+	if time.Now().After(entry.expiresAt) {
+		store.listLRU.Remove(element)
+		delete(store.items, key)
+		store.misses++
+		return entryCache{}, generation, false
+	}
+
+	store.listLRU.MoveToFront(element)
+	if entry.isNotFound {
+		store.negativeHits++
+	} else {
+		store.hits++
+	}
+	return entry, generation, true
+}
+
+// запись в кеш с вытеснением давно не использованных записей
+// generation - значение счетчика инвалидаций на момент запроса в хранилище
+// Время жизни записи берется под блокировкой, его можно поменять при перезагрузке конфигурации
+func (store *StorageShortLink) setItem(entry entryCache, generation uint64) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// пока ходили в хранилище, данные изменились
+	if generation != store.generation {
+		return
+	}
+
+	if entry.isNotFound {
+		if store.negativeTTL <= 0 {
+			return
+		}
+		entry.expiresAt = time.Now().Add(store.negativeTTL)
+	} else {
+		entry.expiresAt = time.Now().Add(store.ttl)
+	}
+
+	if element, ok := store.items[entry.key]; ok {
+		element.Value = entry
+		store.listLRU.MoveToFront(element)
+		return
+	}
+
+	store.items[entry.key] = store.listLRU.PushFront(entry)
+	store.evictOverflow()
+}
+
+// вытеснение давно не использованных записей сверх размера кеша
+func (store *StorageShortLink) evictOverflow() {
+	for store.listLRU.Len() > store.capacity {
+		oldest := store.listLRU.Back()
+		store.listLRU.Remove(oldest)
+		delete(store.items, oldest.Value.(entryCache).key)
+		store.evictions++
+	}
+}
+
+// Изменение размера и времени жизни записей без сброса кеша
+// При уменьшении размера лишние давно не использованные записи вытесняются сразу,
+// новое время жизни действует для записей, добавленных после изменения
+func (store *StorageShortLink) SetLimits(capacity int, ttl, negativeTTL time.Duration) (err error) {
+
+	if capacity <= 0 {
+		return errors.New("ошибка: размер кеша хранилища должен быть больше нуля")
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.capacity = capacity
+	store.ttl = ttl
+	store.negativeTTL = negativeTTL
+	store.evictOverflow()
+	return
+}
+
+// Применение изменения, сделанного в БД другим экземпляром сервиса
+// Кешируемое хранилище обновляет свои данные, если умеет, после чего кеш сбрасывает затронутые записи
+func (store *StorageShortLink) ApplyChange(ctx context.Context, change modelsStorage.ChangeShortLink) (err error) {
+
+	if storageApplier, ok := store.storage.(modelsStorage.StorageChangesApplierInterface); ok {
+		err = storageApplier.ApplyChange(ctx, change)
+	}
+
+	switch change.Operation {
+	case modelsStorage.ChangeOperationInsert, modelsStorage.ChangeOperationUpdate, modelsStorage.ChangeOperationDelete:
+		namespace := change.Row.GetNamespace()
+		if change.Row.ShortLink != "" {
+			store.Invalidate(namespace, change.Row.ShortLink)
+		}
+		if change.OldShortLink != "" {
+			store.Invalidate(namespace, change.OldShortLink)
+		}
+	default:
+		store.Purge()
+	}
+	return
+}
+
+// Полная синхронизация: сбрасываем кеш и перечитываем данные кешируемого хранилища
+func (store *StorageShortLink) Resync(ctx context.Context) (err error) {
+	if storageApplier, ok := store.storage.(modelsStorage.StorageChangesApplierInterface); ok {
+		err = storageApplier.Resync(ctx)
+	}
+	store.Purge()
+	return
+}
+
+// Метод вызывающийся при создании объекта
+func (store *StorageShortLink) Init(ctx context.Context) (err error) {
+	store.Purge()
+	return store.storage.Init(ctx)
+}
+
+func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
+
+	key := modelsStorage.GetStorageKey(modelsStorage.GetNamespace(ctx), shortLink)
+	entry, generation, ok := store.getItem(key)
+	if ok {
+		if entry.isNotFound {
+			return "", modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+		}
+		if entry.isDisabled {
+			return "", modelsStorage.NewErrDisabledShortLinkExt(shortLink)
+		}
+		return entry.fullURL, nil
+	}
+
+	fullURL, err = store.storage.GetFullLinkByShort(ctx, shortLink)
+	if err == nil {
+		store.setItem(entryCache{
+			key:     key,
+			fullURL: fullURL,
+		}, generation)
+	} else if errors.Is(err, modelsStorage.ErrNotFoundShortLink) {
+		store.setItem(entryCache{
+			key:        key,
+			isNotFound: true,
+		}, generation)
+	} else if errors.Is(err, modelsStorage.ErrDisabledShortLink) {
+		store.setItem(entryCache{
+			key:        key,
+			isDisabled: true,
+		}, generation)
+	}
+	return
+}
+
+// Отключение или включение короткой ссылки в кешируемом хранилище, запись кеша сбрасывается
+func (store *StorageShortLink) SetDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error) {
+	storageDisabler, ok := store.storage.(modelsStorage.StorageDisablerInterface)
+	if !ok {
+		return modelsStorage.ErrNotSupported
+	}
+	defer store.Invalidate(modelsStorage.GetNamespace(ctx), shortLink)
+	return storageDisabler.SetDisabled(ctx, shortLink, isDisabled)
+}
+
+func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+	return store.storage.GetShortLinkByURL(ctx, fullURL)
+}
+
+func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
+	// короткая ссылка могла быть закеширована как незарегистрированная
+	defer store.Invalidate(modelsStorage.GetNamespace(ctx), shortLink)
+	return store.storage.AddShortLinkForURL(ctx, fullURL, shortLink)
+}
+
+// добавление коротких ссылок группой
+func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, dataBatch modelsStorage.DataStorageShortLink) (err error) {
+	defer func() {
+		for _, row := range dataBatch {
+			store.Invalidate(modelsStorage.GetNamespace(ctx), row.ShortLink)
+		}
+	}()
+	return store.storage.AddBatchShortLinks(ctx, dataBatch)
+}
+
+// добавление коротких ссылок группой с результатом по каждой полной ссылке
+// Если кешируемое хранилище не умеет это за один запрос, то сначала читаем существующие ссылки
+func (store *StorageShortLink) AddBatchShortLinksReturning(ctx context.Context, dataBatch modelsStorage.DataStorageShortLink) (result modelsStorage.BatchResultShortLinks, err error) {
+	defer func() {
+		for _, row := range dataBatch {
+			store.Invalidate(modelsStorage.GetNamespace(ctx), row.ShortLink)
+		}
+	}()
+
+	if storageReturning, ok := store.storage.(modelsStorage.StorageBatchReturningInterface); ok {
+		return storageReturning.AddBatchShortLinksReturning(ctx, dataBatch)
+	}
+
+	listFullURL := make([]string, 0, len(dataBatch))
+	for _, row := range dataBatch {
+		listFullURL = append(listFullURL, row.FullURL)
+	}
+	options := &modelsStorage.OptionsQuery{
+		Filter: modelsStorage.FilterOptionsQuery{
+			ListFullURL: listFullURL,
+		},
+	}
+	rowsExists, err := store.storage.GetShortLinks(ctx, options)
+	if err != nil {
+		return
+	}
+
+	result = make(modelsStorage.BatchResultShortLinks, len(dataBatch))
+	for _, row := range rowsExists {
+		result[row.FullURL] = modelsStorage.RowBatchResultShortLink{
+			RowStorageShortLink: row,
+			IsExisted:           true,
+		}
+	}
+
+	dataNew := modelsStorage.DataStorageShortLink{}
+	for shortLink, row := range dataBatch {
+		if _, ok := result[row.FullURL]; ok {
+			continue
+		}
+		dataNew[shortLink] = row
+	}
+
+	err = store.storage.AddBatchShortLinks(ctx, dataNew)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataNew) == 0 {
+		return
+	}
+
+	// строки с занятой короткой ссылкой хранилище пропускает, в результат берем только добавленные
+	listNewFullURL := make([]string, 0, len(dataNew))
+	for _, row := range dataNew {
+		listNewFullURL = append(listNewFullURL, row.FullURL)
+	}
+	options.Filter.ListFullURL = listNewFullURL
+	rowsAdded, err := store.storage.GetShortLinks(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for shortLink, row := range rowsAdded {
+		rowNew, ok := dataNew[shortLink]
+		result[row.FullURL] = modelsStorage.RowBatchResultShortLink{
+			RowStorageShortLink: row,
+			IsExisted:           !ok || rowNew.FullURL != row.FullURL,
+		}
+	}
+	return
+}
+
+// установка всех данных хранилища
+func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+	defer store.Purge()
+	return store.storage.SetData(ctx, data)
+}
+
+func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
+	return store.storage.GetCountLink(ctx)
+}
+
+// получаем список данных коротких ссылок по фильтру
+func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
+	return store.storage.GetShortLinks(ctx, options)
+}
+
+// Удаляем данные хранилища
+func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
+	defer store.Purge()
+	return store.storage.ClearStorage(ctx)
+}
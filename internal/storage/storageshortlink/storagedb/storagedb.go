@@ -1,405 +1,549 @@
-package storagedb
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"go-url-shortener/internal/config"
-	dbconn "go-url-shortener/internal/database/connect"
-	errDriver "go-url-shortener/internal/database/errors/pgxerrors"
-	"go-url-shortener/internal/logger"
-	"strings"
-
-	modelsStorage "go-url-shortener/internal/models/storageshortlink"
-)
-
-func getPackageError(textError string) error {
-	textModuleError := "storageshortlink: " + textError
-	return errors.New(textModuleError)
-}
-
-// Хранилище коротких ссылок в БД
-type StorageShortLink struct {
-	nameTableData string
-	dbHandler     *dbconn.DBHandler
-}
-
-type StorageShortInterface interface {
-	modelsStorage.StorageShortInterface
-}
-
-func NewStorageShorts() (storage StorageShortInterface, err error) {
-
-	dbHandler := dbconn.GetDBHandler()
-	err = dbHandler.GetErrSetup()
-	if err == nil {
-		err = dbHandler.Ping()
-	}
-
-	if err != nil {
-		logger.GetLogger().Error("Не создать хранилище из БД, не возможно к БД подключиться: " + err.Error())
-		return nil, err
-	}
-
-	// название таблицы в базе данных с короткими ссылками
-	nameTableData := config.GetAppConfig().GetNameTableRestorer()
-	// проверка существования таблицы
-	err = createShortLinkTable(nameTableData)
-	if err != nil {
-		logger.GetLogger().Error("ошибка создания таблицы для хранения ссылок: " + err.Error())
-		return nil, err
-	}
-
-	storage = &StorageShortLink{
-		nameTableData: nameTableData,
-		dbHandler:     dbHandler,
-	}
-	return storage, nil
-}
-
-func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
-
-	oldData, err := store.GetShortLinks(ctx, nil)
-	if err != nil {
-		return
-	}
-
-	err = store.ClearStorage(ctx)
-	if err != nil {
-		return
-	}
-
-	err = store.AddBatchShortLinks(ctx, data)
-	if err != nil {
-		store.AddBatchShortLinks(ctx, oldData)
-		return
-	}
-
-	return
-}
-
-// добавление коротких ссылок группой
-func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
-
-	// открываем транзакцию
-	poolConn := store.dbHandler.GetPool()
-	tx, err := poolConn.BeginTx(ctx, nil)
-	if err != nil {
-		logger.GetLogger().Error("ошибка: не смогли открыть транзакцию: " + err.Error())
-		return
-	}
-
-	nameTable := store.nameTableData
-	for _, row := range data {
-		// все изменения записываются в транзакцию
-		// игнорируем ошибку дублирующего FULL_URL, чтобы транзакция выполнилась при ее наличии
-		sqlAdd := "INSERT INTO " + nameTable + " (SHORT_LINK, FULL_URL) VALUES($1,$2) ON CONFLICT (FULL_URL) DO NOTHING"
-		_, err := tx.ExecContext(
-			ctx,
-			sqlAdd,
-			row.ShortLink,
-			row.FullURL,
-		)
-		if err != nil {
-			logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlAdd + ": " + err.Error())
-			logger.GetLogger().Debugln("отменяем транзакцию")
-			errRoll := tx.Rollback()
-			if errRoll != nil {
-				logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-			}
-			return err
-		}
-	}
-
-	// завершаем транзакцию
-	err = tx.Commit()
-	if err != nil {
-		logger.GetLogger().Error("ошибка: не смогли сделать commit транзакции: " + err.Error())
-	}
-
-	return
-}
-
-// получаем список данных коротких ссылок по фильтру
-func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
-
-	var shortLinksRows []modelsStorage.RowStorageShortLink
-
-	// если передан фильтр по полным ссылкам
-	if options != nil {
-		shortLinksRows, err = store.readFilter(ctx, options)
-	} else {
-		shortLinksRows, err = store.readAll(ctx)
-	}
-
-	// колличество результатов
-	lenRows := len(shortLinksRows)
-	shortLinks = make(modelsStorage.DataStorageShortLink, lenRows)
-	for _, row := range shortLinksRows {
-
-		shortLink := row.ShortLink
-		shortLinks[shortLink] = modelsStorage.RowStorageShortLink{
-			ShortLink: shortLink,
-			FullURL:   row.FullURL,
-			UUID:      row.UUID,
-		}
-	}
-
-	return
-}
-
-func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
-
-	nameTable := store.nameTableData
-	sqlSelectQuery := "SELECT COUNT(*) as COUNT_ROWS FROM " + nameTable
-
-	poolConn := store.dbHandler.GetPool()
-	rows, err := poolConn.QueryContext(ctx, sqlSelectQuery)
-	// обязательно закрываем чтение строк
-	defer func() {
-		if err == nil {
-			err = rows.Close()
-		}
-	}()
-
-	if err != nil {
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlSelectQuery + ": " + err.Error())
-		return
-	}
-
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			logger.GetLogger().Error("ошибка чтения списка всех ссылок из БД: " + err.Error())
-			return count, err
-		}
-	}
-
-	// Проверим ошибки, чтобы понять, что считывание полностью было завершено
-	if err := rows.Err(); err != nil {
-		logger.GetLogger().Error("чтение строк из таблицы не было завершено корректно, возникла ошибка: " + err.Error())
-	}
-
-	return
-}
-
-func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
-
-	nameTable := store.nameTableData
-	sqlAddRow := "INSERT INTO " + nameTable + " (FULL_URL, SHORT_LINK) VALUES ($1, $2)"
-	poolConn := store.dbHandler.GetPool()
-	_, err = poolConn.ExecContext(ctx, sqlAddRow, fullURL, shortLink)
-	if err != nil {
-		isUniqErr, _ := errDriver.IsUniqueViolation(err)
-		if isUniqErr {
-			err = modelsStorage.NewErrExistFullURLExt(fullURL)
-		}
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlAddRow + ": " + err.Error())
-	}
-
-	return
-}
-
-func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
-
-	nameTable := store.nameTableData
-	sqlSelectRow := "SELECT ID, FULL_URL, SHORT_LINK FROM " + nameTable + " WHERE FULL_URL=$1 LIMIT 1"
-	allRows, err := store.readRows(ctx, sqlSelectRow, fullURL)
-	if err != nil {
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlSelectRow + ": " + err.Error())
-		return
-	}
-
-	if len(allRows) > 0 {
-		row := allRows[0]
-		return row.ShortLink, nil
-	}
-
-	return
-}
-
-func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
-
-	nameTable := store.nameTableData
-	sqlSelectRow := "SELECT ID, FULL_URL, SHORT_LINK FROM " + nameTable + " WHERE SHORT_LINK=$1 LIMIT 1"
-	allRows, err := store.readRows(ctx, sqlSelectRow, shortLink)
-	if err != nil {
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlSelectRow + ": " + err.Error())
-		return
-	}
-
-	if len(allRows) > 0 {
-		row := allRows[0]
-		return row.FullURL, nil
-	} else {
-		// должны показать ошибку
-		err = getPackageError("Короткая ссылка " + shortLink + " не зарегистрирована")
-	}
-
-	return
-}
-
-// Метод вызывающийся при создании объекта
-func (store *StorageShortLink) Init(ctx context.Context) (err error) {
-	return nil
-}
-
-// Прочитать строчки в базе по запросу
-func (store *StorageShortLink) readRows(ctx context.Context, sqlSelectQuery string, args ...any) (allRows []modelsStorage.RowStorageShortLink, err error) {
-
-	poolConn := store.dbHandler.GetPool()
-	rows, err := poolConn.QueryContext(ctx, sqlSelectQuery, args...)
-	// обязательно закрываем чтение строк
-	defer func() {
-		if err == nil {
-			err = rows.Close()
-		}
-	}()
-
-	if err != nil {
-		logger.GetLogger().Errorf("ошибка: при выполении запроса " + sqlSelectQuery + ": " + err.Error())
-		return
-	}
-
-	for rows.Next() {
-		var uuid string
-		var fullURL string
-		var shortLink string
-		if err := rows.Scan(&uuid, &fullURL, &shortLink); err != nil {
-			logger.GetLogger().Error("ошибка чтения строки из БД хранилища: " + err.Error())
-		} else {
-
-			if fullURL != "" && shortLink != "" {
-				allRows = append(allRows, modelsStorage.RowStorageShortLink{
-					ShortLink: shortLink,
-					FullURL:   fullURL,
-					UUID:      uuid,
-				})
-			}
-		}
-	}
-
-	// Проверим ошибки, чтобы понять, что считывание полностью было завершено
-	if err := rows.Err(); err != nil {
-		logger.GetLogger().Error("ошибка: чтение строк из таблицы было завершено некорректно, возникла ошибка: " + err.Error())
-	}
-
-	return
-}
-
-// Прочитать все строки в таблице и вернуть результат в виде слайса
-func (store *StorageShortLink) readAll(ctx context.Context) (allRows []modelsStorage.RowStorageShortLink, err error) {
-	nameTable := store.nameTableData
-	sqlSelectRows := "SELECT ID, FULL_URL, SHORT_LINK FROM " + nameTable + " ORDER BY ID ASC"
-	allRows, err = store.readRows(ctx, sqlSelectRows)
-	if err != nil {
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlSelectRows + ": " + err.Error())
-	}
-	return
-}
-
-// Прочитать все строки в таблице и вернуть результат в виде слайса
-func (store *StorageShortLink) readFilter(ctx context.Context, options *modelsStorage.OptionsQuery) (allRows []modelsStorage.RowStorageShortLink, err error) {
-
-	nameTable := store.nameTableData
-
-	if options != nil {
-		listFullURL := options.Filter.ListFullURL
-		countURLs := len(listFullURL)
-		if countURLs > 0 {
-
-			sliceValueAny := make([]string, countURLs)
-			for key, url := range listFullURL {
-				sliceValueAny[key] = "\"" + url + "\""
-			}
-			strValueAny := "{" + strings.Join(sliceValueAny, ",") + "}"
-
-			sqlSelectRows := "SELECT ID, FULL_URL, SHORT_LINK FROM " + nameTable + " "
-			sqlSelectRows += "WHERE FULL_URL = ANY ($1) ORDER BY ID ASC"
-
-			allRows, err = store.readRows(ctx, sqlSelectRows, strValueAny)
-			//logger.GetLogger().Debugf("результат запроса: %+v", allRows)
-			if err != nil {
-				logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlSelectRows + ": " + err.Error())
-			}
-		}
-	}
-	return
-}
-
-// Очистить данные хранилища
-func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
-	tableName := store.nameTableData
-	sqlTruncate := "TRUNCATE TABLE " + tableName
-	poolConn := store.dbHandler.GetPool()
-	_, err = poolConn.ExecContext(ctx, sqlTruncate)
-	if err != nil {
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlTruncate + ": " + err.Error())
-	}
-	return
-}
-
-// создаем таблицу для хранения коротких ссылок
-func createShortLinkTable(tableName string) (err error) {
-
-	dbHandler := dbconn.GetDBHandler()
-	poolConn := dbHandler.GetPool()
-
-	ctx := context.TODO()
-	tx, err := poolConn.BeginTx(ctx, nil)
-	if err != nil {
-		logger.GetLogger().Error("ошибка: не смогли открыть транзакцию: " + err.Error())
-		return
-	}
-
-	sqlCreateTable := "" +
-		"create table IF NOT EXISTS " + tableName + " (" +
-		"	ID SERIAL PRIMARY KEY," +
-		"	FULL_URL varchar(255)," +
-		"	SHORT_LINK varchar(255)," +
-		"   CREATED TIMESTAMP DEFAULT CURRENT_TIMESTAMP" +
-		") "
-	_, err = tx.ExecContext(ctx, sqlCreateTable)
-	if err != nil {
-		errRoll := tx.Rollback()
-		if errRoll != nil {
-			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-		}
-
-		err = fmt.Errorf("ошибка: не смогли создать таблицу для хранения коротких ссылок: %w", err)
-		return
-	}
-
-	// делаем индекс для быстрого поиска полной ссылки по короткой ссылке
-	sqlCreateIndexShort := "CREATE INDEX IF NOT EXISTS SHORT_LINK_index_" + tableName + " ON " + tableName + " (SHORT_LINK)"
-	_, err = tx.ExecContext(ctx, sqlCreateIndexShort)
-	if err != nil {
-		errRoll := tx.Rollback()
-		if errRoll != nil {
-			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-		}
-
-		err = fmt.Errorf("ошибка: не смогли создать индекс у поля SHORT_LINK: %w", err)
-		return
-	}
-
-	// делаем уникальный индекс поля FULL_URL как ограничение для целостности данных
-	sqlCreateIndexFull := "CREATE UNIQUE INDEX IF NOT EXISTS FULL_URL_index_" + tableName + " ON " + tableName + " (FULL_URL)"
-	_, err = tx.ExecContext(ctx, sqlCreateIndexFull)
-	if err != nil {
-		errRoll := tx.Rollback()
-		if errRoll != nil {
-			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-		}
-
-		err = fmt.Errorf("ошибка: не смогли создать индекс у поля FULL_URL: %w", err)
-		return
-	}
-
-	// завершаем транзакцию
-	err = tx.Commit()
-	if err != nil {
-		logger.GetLogger().Error("ошибка: не смогли сделать commit транзакции: " + err.Error())
-	}
-
-	return
-}
+package storagedb
+
+import (
+	"context"
+	dbconn "go-url-shortener/internal/database/connect"
+	errDriver "go-url-shortener/internal/database/errors/pgxerrors"
+	"go-url-shortener/internal/database/migrations"
+	"go-url-shortener/internal/logger"
+	"strconv"
+
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// сколько строк группового добавления отправляется в БД одним запросом
+const sizeChunkBatch = 1000
+
+// Хранилище коротких ссылок в БД
+type StorageShortLink struct {
+	// имя таблицы в кавычках, готовое для подстановки в SQL
+	nameTableData string
+	// имена уникальных индексов полной и короткой ссылки, по ним разбираем ошибки вставки
+	nameIndexFullURL   string
+	nameIndexShortLink string
+	dbHandler          *dbconn.DBHandler
+}
+
+type StorageShortInterface interface {
+	modelsStorage.StorageShortInterface
+	modelsStorage.StorageBatchReturningInterface
+}
+
+// Создание хранилища в таблице nameTableData через переданное соединение с БД
+func NewStorageShorts(dbHandler *dbconn.DBHandler, nameTableData string) (storage StorageShortInterface, err error) {
+
+	err = dbHandler.GetErrSetup()
+	if err == nil {
+		err = dbHandler.Ping()
+	}
+
+	if err != nil {
+		logger.GetLogger().Error("Не создать хранилище из БД, не возможно к БД подключиться: " + err.Error())
+		return nil, err
+	}
+
+	tableName, err := migrations.ParseTableName(nameTableData)
+	if err != nil {
+		logger.GetLogger().Error(err.Error())
+		return nil, err
+	}
+
+	// создание и обновление таблицы миграциями
+	err = migrations.MigrateTable(dbHandler.GetPool(), nameTableData)
+	if err != nil {
+		logger.GetLogger().Error("ошибка создания таблицы для хранения ссылок: " + err.Error())
+		return nil, err
+	}
+
+	storage = &StorageShortLink{
+		nameTableData:      tableName.Sanitize(),
+		nameIndexFullURL:   tableName.JoinName(migrations.PrefixIndexFullURL),
+		nameIndexShortLink: tableName.JoinName(migrations.PrefixIndexShortLink),
+		dbHandler:          dbHandler,
+	}
+	return storage, nil
+}
+
+func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+
+	oldData, err := store.GetShortLinks(ctx, &modelsStorage.OptionsQuery{AllWorkspaces: true})
+	if err != nil {
+		return
+	}
+
+	err = store.ClearStorage(ctx)
+	if err != nil {
+		return
+	}
+
+	// данные всех рабочих пространств, пространство берем из строки
+	_, err = store.insertBatch(ctx, data, false, true)
+	if err != nil {
+		store.insertBatch(ctx, oldData, false, true)
+		return
+	}
+
+	return
+}
+
+// добавление коротких ссылок группой
+func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+	_, err = store.insertBatch(ctx, data, false, false)
+	return
+}
+
+// добавление коротких ссылок группой с результатом по каждой полной ссылке
+// Для уже существующих полных ссылок возвращается их короткая ссылка из БД
+// Строки с занятой короткой ссылкой не добавляются и в результат не попадают
+func (store *StorageShortLink) AddBatchShortLinksReturning(ctx context.Context, data modelsStorage.DataStorageShortLink) (result modelsStorage.BatchResultShortLinks, err error) {
+
+	result, err = store.insertBatch(ctx, data, true, false)
+	if err != nil {
+		return
+	}
+
+	// ссылки, которые параллельно добавил другой запрос, не видны в снимке нашего запроса
+	// их немного, дочитываем отдельно
+	listMissingURL := []string{}
+	for _, row := range data {
+		if _, ok := result[row.FullURL]; !ok {
+			listMissingURL = append(listMissingURL, row.FullURL)
+		}
+	}
+	if len(listMissingURL) == 0 {
+		return
+	}
+
+	options := &modelsStorage.OptionsQuery{
+		Filter: modelsStorage.FilterOptionsQuery{
+			ListFullURL: listMissingURL,
+		},
+	}
+	rowsExists, err := store.GetShortLinks(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rowsExists {
+		result[row.FullURL] = modelsStorage.RowBatchResultShortLink{
+			RowStorageShortLink: row,
+			IsExisted:           true,
+		}
+	}
+	return
+}
+
+// Групповая вставка частями по sizeChunkBatch строк в одной транзакции
+// Строки передаются массивами, поэтому размер запроса не зависит от количества строк
+// isReturning - вернуть для каждой полной ссылки ее строку в БД
+// isRowNamespace - пространство имен берется из строки, а не из контекста
+func (store *StorageShortLink) insertBatch(ctx context.Context, data modelsStorage.DataStorageShortLink, isReturning bool, isRowNamespace bool) (result modelsStorage.BatchResultShortLinks, err error) {
+
+	result = make(modelsStorage.BatchResultShortLinks, len(data))
+	if len(data) == 0 {
+		return
+	}
+
+	namespace := modelsStorage.GetNamespace(ctx)
+	listShortLink := make([]string, 0, len(data))
+	listFullURL := make([]string, 0, len(data))
+	listWorkspaceID := make([]string, 0, len(data))
+	listDomain := make([]string, 0, len(data))
+	// признак отключения передается текстом, как и остальные колонки, и приводится к boolean в запросе
+	listDisabled := make([]string, 0, len(data))
+	for _, row := range data {
+		if isRowNamespace {
+			namespace = row.GetNamespace()
+		}
+		listShortLink = append(listShortLink, row.ShortLink)
+		listFullURL = append(listFullURL, row.FullURL)
+		listWorkspaceID = append(listWorkspaceID, namespace.WorkspaceID)
+		listDomain = append(listDomain, namespace.Domain)
+		listDisabled = append(listDisabled, strconv.FormatBool(row.Disabled))
+	}
+
+	nameTable := store.nameTableData
+	// игнорируем дублирующие FULL_URL пространства имен, уникальность FULL_URL проверяется по его хешу
+	// строки с занятой SHORT_LINK тоже пропускаются, их полные ссылки не попадут в результат
+	sqlInsert := "INSERT INTO " + nameTable + " (SHORT_LINK, FULL_URL, WORKSPACE_ID, SHORT_DOMAIN, DISABLED) " +
+		"SELECT SHORT_LINK, FULL_URL, WORKSPACE_ID, SHORT_DOMAIN, DISABLED::boolean FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) AS INPUT_ROWS(SHORT_LINK, FULL_URL, WORKSPACE_ID, SHORT_DOMAIN, DISABLED) " +
+		"ON CONFLICT DO NOTHING"
+	if isReturning {
+		// вторая часть запроса видит таблицу до вставки, поэтому возвращает только уже существовавшие ссылки
+		sqlInsert = "WITH INPUT_ROWS AS (" +
+			"	SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) AS INPUT_ROWS(SHORT_LINK, FULL_URL, WORKSPACE_ID, SHORT_DOMAIN, DISABLED)" +
+			"), INSERTED AS (" +
+			"	INSERT INTO " + nameTable + " (SHORT_LINK, FULL_URL, WORKSPACE_ID, SHORT_DOMAIN, DISABLED) SELECT SHORT_LINK, FULL_URL, WORKSPACE_ID, SHORT_DOMAIN, DISABLED::boolean FROM INPUT_ROWS" +
+			"	ON CONFLICT DO NOTHING RETURNING ID, SHORT_LINK, FULL_URL" +
+			") " +
+			"SELECT ID, SHORT_LINK, FULL_URL, false FROM INSERTED " +
+			"UNION ALL " +
+			"SELECT T.ID, T.SHORT_LINK, T.FULL_URL, true FROM INPUT_ROWS " +
+			"JOIN " + nameTable + " T ON T.WORKSPACE_ID = INPUT_ROWS.WORKSPACE_ID AND T.SHORT_DOMAIN = INPUT_ROWS.SHORT_DOMAIN " +
+			"AND T.FULL_URL_HASH = sha256(convert_to(INPUT_ROWS.FULL_URL, 'UTF8')) AND T.FULL_URL = INPUT_ROWS.FULL_URL"
+	}
+
+	// вставка не создает дублей, поэтому транзакцию можно повторить целиком
+	err = store.dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) error {
+		// результат неудачной попытки не должен попасть в итог
+		result = make(modelsStorage.BatchResultShortLinks, len(data))
+		return store.execBatchTx(ctx, sqlInsert, result, [][]string{listShortLink, listFullURL, listWorkspaceID, listDomain, listDisabled}, isReturning)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Групповая вставка частями в одной транзакции
+// listColumns - значения колонок вставляемых строк: короткие ссылки, полные ссылки, рабочие пространства, домены и признаки отключения
+func (store *StorageShortLink) execBatchTx(ctx context.Context, sqlInsert string, result modelsStorage.BatchResultShortLinks, listColumns [][]string, isReturning bool) (err error) {
+
+	// открываем транзакцию
+	poolConn := store.dbHandler.GetPool()
+	tx, err := poolConn.Begin(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("ошибка: не смогли открыть транзакцию: " + err.Error())
+		return
+	}
+
+	countRows := len(listColumns[0])
+	for start := 0; start < countRows; start += sizeChunkBatch {
+		end := start + sizeChunkBatch
+		if end > countRows {
+			end = countRows
+		}
+
+		argsChunk := make([]any, 0, len(listColumns))
+		for _, listColumn := range listColumns {
+			argsChunk = append(argsChunk, listColumn[start:end])
+		}
+
+		if isReturning {
+			err = readBatchResult(ctx, tx, sqlInsert, result, argsChunk...)
+		} else {
+			_, err = tx.Exec(ctx, sqlInsert, argsChunk...)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlInsert + ": " + err.Error())
+			logger.FromContext(ctx).Debugln("отменяем транзакцию")
+			errRoll := tx.Rollback(ctx)
+			if errRoll != nil {
+				logger.FromContext(ctx).Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
+			}
+			return
+		}
+	}
+
+	// завершаем транзакцию
+	err = tx.Commit(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("ошибка: не смогли сделать commit транзакции: " + err.Error())
+	}
+	return
+}
+
+// Выполнение вставки части группы и чтение результата по каждой полной ссылке
+func readBatchResult(ctx context.Context, tx pgx.Tx, sqlInsert string, result modelsStorage.BatchResultShortLinks, args ...any) (err error) {
+
+	rows, err := tx.Query(ctx, sqlInsert, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := modelsStorage.RowBatchResultShortLink{}
+		err = rows.Scan(&row.UUID, &row.ShortLink, &row.FullURL, &row.IsExisted)
+		if err != nil {
+			return
+		}
+		result[row.FullURL] = row
+	}
+	return rows.Err()
+}
+
+// получаем список данных коротких ссылок по фильтру
+func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
+
+	var shortLinksRows []modelsStorage.RowStorageShortLink
+
+	isAllWorkspaces := options != nil && options.AllWorkspaces
+	// если передан фильтр по полным ссылкам
+	if options != nil && !isAllWorkspaces {
+		shortLinksRows, err = store.readFilter(ctx, options)
+	} else {
+		shortLinksRows, err = store.readAll(ctx, isAllWorkspaces)
+	}
+
+	// колличество результатов
+	lenRows := len(shortLinksRows)
+	shortLinks = make(modelsStorage.DataStorageShortLink, lenRows)
+	for _, row := range shortLinksRows {
+
+		key := row.ShortLink
+		if isAllWorkspaces {
+			key = modelsStorage.GetStorageKey(row.GetNamespace(), row.ShortLink)
+		}
+		shortLinks[key] = row
+	}
+
+	return
+}
+
+func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
+
+	nameTable := store.nameTableData
+	sqlSelectQuery := "SELECT COUNT(*) as COUNT_ROWS FROM " + nameTable + " WHERE WORKSPACE_ID=$1 AND SHORT_DOMAIN=$2"
+
+	namespace := modelsStorage.GetNamespace(ctx)
+	poolConn := store.dbHandler.GetPool()
+	err = store.dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) error {
+		return poolConn.QueryRow(ctx, sqlSelectQuery, namespace.WorkspaceID, namespace.Domain).Scan(&count)
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlSelectQuery + ": " + err.Error())
+	}
+
+	return
+}
+
+func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
+
+	nameTable := store.nameTableData
+	sqlAddRow := "INSERT INTO " + nameTable + " (FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN) VALUES ($1, $2, $3, $4)"
+	namespace := modelsStorage.GetNamespace(ctx)
+	poolConn := store.dbHandler.GetPool()
+	// повтор вставки после обрыва соединения мог бы вернуть ошибку дубля уже добавленной ссылки
+	err = store.dbHandler.GetRetrier().DoOnce(ctx, func(ctx context.Context) (err error) {
+		_, err = poolConn.Exec(ctx, sqlAddRow, fullURL, shortLink, namespace.WorkspaceID, namespace.Domain)
+		return
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlAddRow + ": " + err.Error())
+		err = store.getErrUnique(err, fullURL, shortLink)
+	}
+
+	return
+}
+
+// Ошибка уникального индекса превращается в ошибку хранилища по имени индекса
+// Остальные ошибки возвращаются как есть
+func (store *StorageShortLink) getErrUnique(err error, fullURL, shortLink string) error {
+
+	isUniqErr, _ := errDriver.IsUniqueViolation(err)
+	if !isUniqErr {
+		return err
+	}
+
+	switch errDriver.GetConstraintName(err) {
+	case store.nameIndexFullURL:
+		return modelsStorage.NewErrExistFullURLExt(fullURL)
+	case store.nameIndexShortLink:
+		return modelsStorage.NewErrExistShortLinkExt(shortLink)
+	}
+	return err
+}
+
+func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+
+	nameTable := store.nameTableData
+	// поиск идет по индексу хеша, сравнение самой ссылки защищает от коллизий
+	sqlSelectRow := "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + nameTable + " WHERE WORKSPACE_ID=$2 AND SHORT_DOMAIN=$3 AND FULL_URL_HASH=sha256(convert_to($1::text, 'UTF8')) AND FULL_URL=$1 LIMIT 1"
+	namespace := modelsStorage.GetNamespace(ctx)
+	allRows, err := store.readRows(ctx, sqlSelectRow, fullURL, namespace.WorkspaceID, namespace.Domain)
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlSelectRow + ": " + err.Error())
+		return
+	}
+
+	if len(allRows) > 0 {
+		row := allRows[0]
+		return row.ShortLink, nil
+	}
+
+	return
+}
+
+func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
+
+	nameTable := store.nameTableData
+	sqlSelectRow := "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + nameTable + " WHERE WORKSPACE_ID=$2 AND SHORT_DOMAIN=$3 AND SHORT_LINK=$1 LIMIT 1"
+	namespace := modelsStorage.GetNamespace(ctx)
+	allRows, err := store.readRows(ctx, sqlSelectRow, shortLink, namespace.WorkspaceID, namespace.Domain)
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlSelectRow + ": " + err.Error())
+		return
+	}
+
+	if len(allRows) > 0 {
+		row := allRows[0]
+		if row.Disabled {
+			return "", modelsStorage.NewErrDisabledShortLinkExt(shortLink)
+		}
+		return row.FullURL, nil
+	} else {
+		// должны показать ошибку
+		err = modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+	}
+
+	return
+}
+
+// Отключение или включение короткой ссылки
+// Изменение строки попадает в уведомления БД, поэтому его применяют и остальные экземпляры сервиса
+func (store *StorageShortLink) SetDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error) {
+
+	nameTable := store.nameTableData
+	sqlUpdateRow := "UPDATE " + nameTable + " SET DISABLED=$4 WHERE WORKSPACE_ID=$2 AND SHORT_DOMAIN=$3 AND SHORT_LINK=$1"
+	namespace := modelsStorage.GetNamespace(ctx)
+
+	poolConn := store.dbHandler.GetPool()
+	var countRows int64
+	// повтор изменения безопасен, признак просто установится еще раз
+	err = store.dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) (err error) {
+		result, err := poolConn.Exec(ctx, sqlUpdateRow, shortLink, namespace.WorkspaceID, namespace.Domain, isDisabled)
+		countRows = result.RowsAffected()
+		return
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlUpdateRow + ": " + err.Error())
+		return
+	}
+	if countRows == 0 {
+		err = modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+	}
+	return
+}
+
+// Тип хранилища
+func (store *StorageShortLink) GetBackendName() string {
+	return modelsStorage.StorageBackendPostgres
+}
+
+// Хранилище готово, если БД отвечает
+func (store *StorageShortLink) CheckReady(ctx context.Context) (err error) {
+	return store.dbHandler.Ping()
+}
+
+// Метод вызывающийся при создании объекта
+func (store *StorageShortLink) Init(ctx context.Context) (err error) {
+	return nil
+}
+
+// Прочитать строчки в базе по запросу
+func (store *StorageShortLink) readRows(ctx context.Context, sqlSelectQuery string, args ...any) (allRows []modelsStorage.RowStorageShortLink, err error) {
+
+	err = store.dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) (err error) {
+		allRows, err = store.queryRows(ctx, sqlSelectQuery, args...)
+		return
+	})
+	return
+}
+
+// Выполнение запроса чтения строк
+func (store *StorageShortLink) queryRows(ctx context.Context, sqlSelectQuery string, args ...any) (allRows []modelsStorage.RowStorageShortLink, err error) {
+
+	poolConn := store.dbHandler.GetPool()
+	rows, err := poolConn.Query(ctx, sqlSelectQuery, args...)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("ошибка: при выполении запроса " + sqlSelectQuery + ": " + err.Error())
+		return
+	}
+	// обязательно закрываем чтение строк
+	defer rows.Close()
+
+	for rows.Next() {
+		var uuid string
+		var fullURL string
+		var shortLink string
+		var workspaceID string
+		var domain string
+		var isDisabled bool
+		if err := rows.Scan(&uuid, &fullURL, &shortLink, &workspaceID, &domain, &isDisabled); err != nil {
+			logger.FromContext(ctx).Error("ошибка чтения строки из БД хранилища: " + err.Error())
+		} else {
+
+			if fullURL != "" && shortLink != "" {
+				allRows = append(allRows, modelsStorage.RowStorageShortLink{
+					ShortLink:   shortLink,
+					FullURL:     fullURL,
+					UUID:        uuid,
+					WorkspaceID: workspaceID,
+					Domain:      domain,
+					Disabled:    isDisabled,
+				})
+			}
+		}
+	}
+
+	// Проверим ошибки, чтобы понять, что считывание полностью было завершено
+	// обрыв соединения во время чтения возвращаем, чтобы запрос повторился
+	if err = rows.Err(); err != nil {
+		logger.FromContext(ctx).Error("ошибка: чтение строк из таблицы было завершено некорректно, возникла ошибка: " + err.Error())
+		return nil, err
+	}
+
+	return
+}
+
+// Прочитать все строки пространства имен и вернуть результат в виде слайса
+// isAllWorkspaces - прочитать строки всех пространств имен
+func (store *StorageShortLink) readAll(ctx context.Context, isAllWorkspaces bool) (allRows []modelsStorage.RowStorageShortLink, err error) {
+	nameTable := store.nameTableData
+	sqlSelectRows := "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + nameTable + " WHERE WORKSPACE_ID=$1 AND SHORT_DOMAIN=$2 ORDER BY ID ASC"
+	namespace := modelsStorage.GetNamespace(ctx)
+	args := []any{namespace.WorkspaceID, namespace.Domain}
+	if isAllWorkspaces {
+		sqlSelectRows = "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + nameTable + " ORDER BY ID ASC"
+		args = nil
+	}
+	allRows, err = store.readRows(ctx, sqlSelectRows, args...)
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlSelectRows + ": " + err.Error())
+	}
+	return
+}
+
+// Прочитать все строки в таблице и вернуть результат в виде слайса
+func (store *StorageShortLink) readFilter(ctx context.Context, options *modelsStorage.OptionsQuery) (allRows []modelsStorage.RowStorageShortLink, err error) {
+
+	nameTable := store.nameTableData
+
+	if options != nil {
+		listFullURL := options.Filter.ListFullURL
+		countURLs := len(listFullURL)
+		if countURLs > 0 {
+
+			sqlSelectRows := "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + nameTable + " "
+			sqlSelectRows += "WHERE WORKSPACE_ID = $2 AND SHORT_DOMAIN = $3 "
+			sqlSelectRows += "AND FULL_URL_HASH IN (SELECT sha256(convert_to(URL, 'UTF8')) FROM unnest($1::text[]) AS URL) "
+			sqlSelectRows += "AND FULL_URL = ANY ($1) ORDER BY ID ASC"
+
+			namespace := modelsStorage.GetNamespace(ctx)
+			allRows, err = store.readRows(ctx, sqlSelectRows, listFullURL, namespace.WorkspaceID, namespace.Domain)
+			//logger.GetLogger().Debugf("результат запроса: %+v", allRows)
+			if err != nil {
+				logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlSelectRows + ": " + err.Error())
+			}
+		}
+	}
+	return
+}
+
+// Очистить данные хранилища
+func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
+	tableName := store.nameTableData
+	sqlTruncate := "TRUNCATE TABLE " + tableName
+	poolConn := store.dbHandler.GetPool()
+	err = store.dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) (err error) {
+		_, err = poolConn.Exec(ctx, sqlTruncate)
+		return
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlTruncate + ": " + err.Error())
+	}
+	return
+}
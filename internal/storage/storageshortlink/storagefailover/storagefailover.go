@@ -0,0 +1,631 @@
+package storagefailover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// как часто проверяем БД, пока она доступна
+	defaultIntervalHealthy = 5 * time.Second
+	// начальная задержка проверки недоступной БД, удваивается после каждой неудачи
+	defaultMinBackoff = 500 * time.Millisecond
+	// максимальная задержка проверки недоступной БД
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// ошибка операций, которые нельзя выполнить без основного хранилища
+var ErrPrimaryUnavailable = errors.New("ошибка: операция невозможна, пока база данных недоступна")
+
+// Проверка доступности основного хранилища, например dbconn.DBHandler
+type HealthChecker interface {
+	Ping() (err error)
+}
+
+// Хранилище с переключением на локальные данные при недоступности БД
+// Пока БД доступна, все операции идут в нее, а локальный кеш поддерживается в актуальном состоянии.
+// Когда БД недоступна, чтение идет из кеша, а новые ссылки пишутся в журнал.
+// После восстановления БД журнал воспроизводится в нее и очищается.
+type StorageShortLink struct {
+	primary       modelsStorage.StorageShortInterface
+	healthChecker HealthChecker
+	// локальная копия данных для чтения при недоступности БД
+	cache storagememory.StorageShortInterface
+	// журнал записей, сделанных при недоступности БД
+	journal restorer.Restorer
+
+	// флаг доступности основного хранилища
+	isHealthy atomic.Bool
+	// блокировка журнала: запись при недоступности БД и воспроизведение журнала не пересекаются
+	mutexJournal sync.Mutex
+
+	intervalHealthy time.Duration
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+
+	// сигнал проверяющей горутине, что состояние изменилось
+	checkNow  chan struct{}
+	stop      chan struct{}
+	wait      sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type StorageShortInterface interface {
+	modelsStorage.StorageShortInterface
+	modelsStorage.StorageCloserInterface
+	modelsStorage.StorageChangesApplierInterface
+	// основное хранилище сейчас доступно
+	IsPrimaryHealthy() bool
+}
+
+// Создание хранилища с переключением
+// primary - основное хранилище, healthChecker - проверка его доступности, journal - журнал записей
+func NewStorageShorts(primary modelsStorage.StorageShortInterface, healthChecker HealthChecker, journal restorer.Restorer) (storage StorageShortInterface, err error) {
+	return NewStorageShortsWithBackoff(primary, healthChecker, journal, defaultIntervalHealthy, defaultMinBackoff, defaultMaxBackoff)
+}
+
+// Создание хранилища с переключением и своими интервалами проверки БД
+func NewStorageShortsWithBackoff(primary modelsStorage.StorageShortInterface, healthChecker HealthChecker, journal restorer.Restorer,
+	intervalHealthy, minBackoff, maxBackoff time.Duration) (storage StorageShortInterface, err error) {
+
+	// снимки локальной копии не нужны, поэтому и конфигурация с ключами шифрования не нужна
+	cache, err := storagememory.NewStorageShorts("", 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &StorageShortLink{
+		primary:         primary,
+		healthChecker:   healthChecker,
+		cache:           cache,
+		journal:         journal,
+		intervalHealthy: intervalHealthy,
+		minBackoff:      minBackoff,
+		maxBackoff:      maxBackoff,
+		checkNow:        make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+	}
+
+	// журнал мог остаться после прошлой остановки во время недоступности БД
+	// локальные данные еще пустые, поэтому загружаем их даже при пустом журнале
+	ctx := context.TODO()
+	err = store.resync(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка: не удалось синхронизировать журнал с базой данных: %w", err)
+	}
+
+	store.wait.Add(1)
+	go store.runMonitor()
+
+	return store, nil
+}
+
+// Тип хранилища
+func (store *StorageShortLink) GetBackendName() string {
+	return modelsStorage.StorageBackendPostgresFailover
+}
+
+// основное хранилище сейчас доступно
+func (store *StorageShortLink) IsPrimaryHealthy() bool {
+	return store.isHealthy.Load()
+}
+
+// Метод вызывающийся при создании объекта
+func (store *StorageShortLink) Init(ctx context.Context) (err error) {
+	return nil
+}
+
+// Хранилище готово, пока доступна БД, а без нее - пока можно писать в журнал
+// БД здесь не проверяется, ее доступность отслеживает проверяющая горутина
+func (store *StorageShortLink) CheckReady(ctx context.Context) (err error) {
+	if store.isHealthy.Load() {
+		return nil
+	}
+	if readyChecker, ok := store.journal.(restorer.ReadyChecker); ok {
+		err = readyChecker.CheckReady()
+		if err != nil {
+			return fmt.Errorf("ошибка: база данных недоступна, а журнал записей недоступен на запись: %w", err)
+		}
+	}
+	return nil
+}
+
+// Остановка проверки доступности БД
+func (store *StorageShortLink) Close(ctx context.Context) (err error) {
+	store.closeOnce.Do(func() {
+		close(store.stop)
+		store.wait.Wait()
+	})
+	return nil
+}
+
+// Проверка доступности БД в отдельной горутине
+// Пока БД недоступна, проверяем ее с нарастающей задержкой
+func (store *StorageShortLink) runMonitor() {
+	defer store.wait.Done()
+
+	backoff := store.minBackoff
+	for {
+		delay := store.intervalHealthy
+		if !store.isHealthy.Load() {
+			delay = backoff
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-store.stop:
+			timer.Stop()
+			return
+		case <-store.checkNow:
+			// состояние поменялось, пересчитываем задержку
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		err := store.checkHealth(context.TODO())
+		if err != nil {
+			backoff *= 2
+			if backoff > store.maxBackoff {
+				backoff = store.maxBackoff
+			}
+		} else {
+			backoff = store.minBackoff
+		}
+	}
+}
+
+// Проверка БД, при восстановлении воспроизводим журнал
+func (store *StorageShortLink) checkHealth(ctx context.Context) (err error) {
+
+	err = store.healthChecker.Ping()
+	if err != nil {
+		store.markUnhealthy(ctx, err)
+		return
+	}
+
+	if !store.isHealthy.Load() {
+		err = store.resync(ctx, false)
+		if err != nil {
+			logger.FromContext(ctx).Error("ошибка синхронизации журнала с базой данных: " + err.Error())
+			return
+		}
+		logger.FromContext(ctx).Infoln("База данных снова доступна, журнал синхронизирован")
+	}
+	return
+}
+
+// Переключаемся на локальные данные
+func (store *StorageShortLink) markUnhealthy(ctx context.Context, errReason error) {
+	if store.isHealthy.CompareAndSwap(true, false) {
+		logger.FromContext(ctx).Warnf("База данных недоступна, переключились на локальные данные: %s", errReason.Error())
+		select {
+		case store.checkNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Операция с основным хранилищем завершилась ошибкой
+// Если БД не отвечает, то переключаемся на локальные данные и возвращаем true
+func (store *StorageShortLink) isPrimaryFailure(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, modelsStorage.ErrExistFullURL) || errors.Is(err, modelsStorage.ErrExistShortLink) ||
+		errors.Is(err, modelsStorage.ErrNotFoundShortLink) || errors.Is(err, modelsStorage.ErrDisabledShortLink) {
+		return false
+	}
+
+	errPing := store.healthChecker.Ping()
+	if errPing == nil {
+		return false
+	}
+	store.markUnhealthy(ctx, errPing)
+	return true
+}
+
+// Воспроизведение журнала в БД и обновление локальных данных
+// Локальные данные перечитываются из БД, только если журнал был воспроизведен или isReloadCache = true.
+// Изменения других экземпляров сервиса за время недоступности БД приходят через Resync
+// при переподключении слушателя уведомлений
+func (store *StorageShortLink) resync(ctx context.Context, isReloadCache bool) (err error) {
+
+	store.mutexJournal.Lock()
+	defer store.mutexJournal.Unlock()
+
+	countRows := 0
+	countConflicts := 0
+	// строки, короткие ссылки которых в БД уже заняты другими адресами
+	listKept := []restorer.RowDataRestorer{}
+	err = store.journal.ReadEach(ctx, func(dataRow restorer.RowDataRestorer) (err error) {
+		isConflict, isKept, err := store.replayRow(ctx, dataRow)
+		if err != nil {
+			return
+		}
+		countRows++
+		if isConflict {
+			countConflicts++
+		}
+		if isKept {
+			listKept = append(listKept, dataRow)
+		}
+		return
+	})
+	if err != nil {
+		// часть строк могла записаться, при следующей попытке они будут пропущены как уже существующие
+		return
+	}
+
+	if countRows > 0 {
+		err = store.rewriteJournal(ctx, listKept)
+		if err != nil {
+			return
+		}
+	}
+
+	// в БД могли появиться ссылки от других экземпляров сервиса, полностью обновляем кеш
+	if countRows > 0 || isReloadCache {
+		err = store.reloadCache(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if countRows > 0 {
+		logger.FromContext(ctx).Infof("Воспроизвели журнал в базе данных: записей %d, конфликтов %d", countRows, countConflicts)
+	}
+	if len(listKept) > 0 {
+		logger.FromContext(ctx).Errorf("В журнале остались записи, короткие ссылки которых в базе данных заняты другими адресами: %d, их нужно разобрать вручную", len(listKept))
+	}
+
+	store.isHealthy.Store(true)
+	return nil
+}
+
+// Замена журнала строками, которые остались после воспроизведения
+// Журнал переписывается через временный файл, поэтому при ошибке записи строки с конфликтами не теряются
+func (store *StorageShortLink) rewriteJournal(ctx context.Context, listKept []restorer.RowDataRestorer) (err error) {
+
+	journalRewriter, ok := store.journal.(restorer.Rewriter)
+	if ok {
+		_, err = journalRewriter.RewriteRows(func(handler restorer.HandlerRowRestorer) (err error) {
+			for _, dataRow := range listKept {
+				err = handler(dataRow)
+				if err != nil {
+					return
+				}
+			}
+			return
+		})
+		return
+	}
+
+	// журнал, который не умеет переписываться целиком, очищаем и записываем заново
+	err = store.journal.ClearRows()
+	if err != nil {
+		return
+	}
+	for _, dataRow := range listKept {
+		err = store.journal.WriteRow(ctx, dataRow)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Полное обновление локальных данных из БД
+func (store *StorageShortLink) reloadCache(ctx context.Context) (err error) {
+	allRows, err := store.primary.GetShortLinks(ctx, &modelsStorage.OptionsQuery{AllWorkspaces: true})
+	if err != nil {
+		return
+	}
+	return store.cache.SetData(ctx, allRows)
+}
+
+// Запись одной строки журнала в БД
+// Конфликты решаются в пользу данных БД:
+// - если полная ссылка уже есть в БД, то запись журнала отбрасывается;
+// - если короткая ссылка занята другим адресом, то запись остается в журнале, isKept = true.
+// Короткая ссылка из журнала уже выдана клиенту, поэтому другую короткую ссылку адресу не выдаем.
+func (store *StorageShortLink) replayRow(ctx context.Context, dataRow restorer.RowDataRestorer) (isConflict, isKept bool, err error) {
+
+	fullURL := dataRow.FullURL
+	shortLink := dataRow.ShortLink
+	// строка журнала записывается в свое пространство имен
+	ctx = modelsStorage.WithNamespace(ctx, modelsStorage.Namespace{WorkspaceID: dataRow.WorkspaceID, Domain: dataRow.Domain})
+
+	existShortLink, err := store.primary.GetShortLinkByURL(ctx, fullURL)
+	if err != nil {
+		return
+	}
+	if existShortLink != "" {
+		if existShortLink != shortLink {
+			logger.FromContext(ctx).Warnf("Конфликт FULL_URL при синхронизации журнала: %s уже имеет короткую ссылку %s, ссылка %s отброшена", fullURL, existShortLink, shortLink)
+			isConflict = true
+		}
+		return
+	}
+
+	// отключенная администратором короткая ссылка тоже занята
+	existFullURL, err := store.primary.GetFullLinkByShort(ctx, shortLink)
+	if err == nil || errors.Is(err, modelsStorage.ErrDisabledShortLink) {
+		logger.FromContext(ctx).Errorf("Конфликт SHORT_LINK при синхронизации журнала: %s уже ведет на %s, запись для %s оставлена в журнале", shortLink, existFullURL, fullURL)
+		return true, true, nil
+	} else if !errors.Is(err, modelsStorage.ErrNotFoundShortLink) {
+		return
+	}
+
+	err = store.primary.AddShortLinkForURL(ctx, fullURL, shortLink)
+	if errors.Is(err, modelsStorage.ErrExistFullURL) {
+		// ссылку успел добавить другой экземпляр сервиса
+		return true, false, nil
+	}
+	if errors.Is(err, modelsStorage.ErrExistShortLink) {
+		// короткую ссылку успел занять другой экземпляр сервиса
+		logger.FromContext(ctx).Errorf("Конфликт SHORT_LINK при синхронизации журнала: %s уже занята, запись для %s оставлена в журнале", shortLink, fullURL)
+		return true, true, nil
+	}
+	return
+}
+
+// Применение изменения, сделанного в БД другим экземпляром сервиса, к локальным данным
+func (store *StorageShortLink) ApplyChange(ctx context.Context, change modelsStorage.ChangeShortLink) (err error) {
+
+	switch change.Operation {
+	case modelsStorage.ChangeOperationInsert, modelsStorage.ChangeOperationUpdate:
+		if change.Row.ShortLink == "" || change.Row.FullURL == "" {
+			return store.Resync(ctx)
+		}
+		return store.cache.ApplyChange(ctx, change)
+	case modelsStorage.ChangeOperationDelete:
+		return store.cache.ApplyChange(ctx, change)
+	}
+	return store.Resync(ctx)
+}
+
+// Полная синхронизация локальных данных с БД, например после пропущенных уведомлений
+// Пока БД недоступна, синхронизация не нужна: она будет сделана вместе с воспроизведением журнала
+func (store *StorageShortLink) Resync(ctx context.Context) (err error) {
+
+	store.mutexJournal.Lock()
+	defer store.mutexJournal.Unlock()
+
+	if !store.isHealthy.Load() {
+		return nil
+	}
+	err = store.reloadCache(ctx)
+	store.isPrimaryFailure(ctx, err)
+	return
+}
+
+// Запись в журнал и кеш, пока БД недоступна
+func (store *StorageShortLink) addToJournal(ctx context.Context, fullURL, shortLink string) (err error) {
+
+	existShortLink, err := store.cache.GetShortLinkByURL(ctx, fullURL)
+	if err != nil {
+		return
+	}
+	if existShortLink != "" {
+		return modelsStorage.NewErrExistFullURLExt(fullURL)
+	}
+	// короткую ссылку проверяем до записи в журнал, иначе журнал и кеш разойдутся
+	_, err = store.cache.GetFullLinkByShort(ctx, shortLink)
+	if err == nil || errors.Is(err, modelsStorage.ErrDisabledShortLink) {
+		return modelsStorage.NewErrExistShortLinkExt(shortLink)
+	} else if !errors.Is(err, modelsStorage.ErrNotFoundShortLink) {
+		return
+	}
+
+	namespace := modelsStorage.GetNamespace(ctx)
+	err = store.journal.WriteRow(ctx, restorer.RowDataRestorer{
+		ShortLink:   shortLink,
+		FullURL:     fullURL,
+		WorkspaceID: namespace.WorkspaceID,
+		Domain:      namespace.Domain,
+	})
+	if err != nil {
+		return
+	}
+	return store.cache.AddShortLinkForURL(ctx, fullURL, shortLink)
+}
+
+// Добавление ссылки при недоступной БД
+// Возвращает isDone = false, если БД успела восстановиться и запись надо делать в нее
+func (store *StorageShortLink) addWhileUnhealthy(ctx context.Context, fullURL, shortLink string) (isDone bool, err error) {
+
+	store.mutexJournal.Lock()
+	defer store.mutexJournal.Unlock()
+
+	// пока ждали блокировку, журнал мог быть воспроизведен
+	if store.isHealthy.Load() {
+		return false, nil
+	}
+	return true, store.addToJournal(ctx, fullURL, shortLink)
+}
+
+func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
+
+	if !store.isHealthy.Load() {
+		isDone, err := store.addWhileUnhealthy(ctx, fullURL, shortLink)
+		if isDone {
+			return err
+		}
+	}
+
+	err = store.primary.AddShortLinkForURL(ctx, fullURL, shortLink)
+	if store.isPrimaryFailure(ctx, err) {
+		_, err = store.addWhileUnhealthy(ctx, fullURL, shortLink)
+		return
+	}
+	if err == nil {
+		store.cache.AddShortLinkForURL(ctx, fullURL, shortLink)
+	}
+	return
+}
+
+// добавление коротких ссылок группой
+func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+
+	if store.isHealthy.Load() {
+		err = store.primary.AddBatchShortLinks(ctx, data)
+		if !store.isPrimaryFailure(ctx, err) {
+			if err == nil {
+				store.addBatchToCache(ctx, data)
+			}
+			return
+		}
+	}
+
+	store.mutexJournal.Lock()
+	defer store.mutexJournal.Unlock()
+
+	for _, row := range data {
+		err = store.addToJournal(ctx, row.FullURL, row.ShortLink)
+		// дубли не прерывают групповое добавление
+		if errors.Is(err, modelsStorage.ErrExistFullURL) || errors.Is(err, modelsStorage.ErrExistShortLink) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Копирование в кеш строк группы, которые БД действительно добавила
+// Строки с занятой короткой ссылкой БД пропускает, поэтому берем строки из БД, а не из группы
+func (store *StorageShortLink) addBatchToCache(ctx context.Context, data modelsStorage.DataStorageShortLink) {
+
+	listFullURL := make([]string, 0, len(data))
+	for _, row := range data {
+		listFullURL = append(listFullURL, row.FullURL)
+	}
+	options := &modelsStorage.OptionsQuery{
+		Filter: modelsStorage.FilterOptionsQuery{
+			ListFullURL: listFullURL,
+		},
+	}
+	rowsAdded, err := store.primary.GetShortLinks(ctx, options)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("ошибка: не удалось прочитать добавленные ссылки для локальных данных: %s", err.Error())
+		return
+	}
+	for _, row := range rowsAdded {
+		store.cache.ApplyChange(ctx, modelsStorage.ChangeShortLink{
+			Operation: modelsStorage.ChangeOperationInsert,
+			Row:       row,
+		})
+	}
+}
+
+func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
+
+	if store.isHealthy.Load() {
+		fullURL, err = store.primary.GetFullLinkByShort(ctx, shortLink)
+		// ссылки других экземпляров сервиса попадают в локальные данные через ApplyChange
+		if !store.isPrimaryFailure(ctx, err) {
+			return
+		}
+	}
+	return store.cache.GetFullLinkByShort(ctx, shortLink)
+}
+
+func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+
+	if store.isHealthy.Load() {
+		shortLink, err = store.primary.GetShortLinkByURL(ctx, fullURL)
+		if !store.isPrimaryFailure(ctx, err) {
+			return
+		}
+	}
+	return store.cache.GetShortLinkByURL(ctx, fullURL)
+}
+
+func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
+
+	if store.isHealthy.Load() {
+		count, err = store.primary.GetCountLink(ctx)
+		if !store.isPrimaryFailure(ctx, err) {
+			return
+		}
+	}
+	return store.cache.GetCountLink(ctx)
+}
+
+// получаем список данных коротких ссылок по фильтру
+func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
+
+	if store.isHealthy.Load() {
+		shortLinks, err = store.primary.GetShortLinks(ctx, options)
+		if !store.isPrimaryFailure(ctx, err) {
+			return
+		}
+	}
+	return store.cache.GetShortLinks(ctx, options)
+}
+
+// Отключение или включение короткой ссылки, возможно только при доступной БД
+// В журнал признак не пишется: журнал воспроизводится только добавлением ссылок
+func (store *StorageShortLink) SetDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error) {
+
+	storageDisabler, ok := store.primary.(modelsStorage.StorageDisablerInterface)
+	if !ok {
+		return modelsStorage.ErrNotSupported
+	}
+	if !store.isHealthy.Load() {
+		return ErrPrimaryUnavailable
+	}
+
+	err = storageDisabler.SetDisabled(ctx, shortLink, isDisabled)
+	if err != nil {
+		store.isPrimaryFailure(ctx, err)
+		return
+	}
+	err = store.cache.SetDisabled(ctx, shortLink, isDisabled)
+	if errors.Is(err, modelsStorage.ErrNotFoundShortLink) {
+		// ссылки еще нет в локальных данных, она придет вместе с уведомлением БД
+		err = nil
+	}
+	return
+}
+
+// установка всех данных хранилища, возможна только при доступной БД
+func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+
+	if !store.isHealthy.Load() {
+		return ErrPrimaryUnavailable
+	}
+
+	err = store.primary.SetData(ctx, data)
+	if err != nil {
+		store.isPrimaryFailure(ctx, err)
+		return
+	}
+	return store.cache.SetData(ctx, data)
+}
+
+// Удаляем данные хранилища, возможно только при доступной БД
+func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
+
+	if !store.isHealthy.Load() {
+		return ErrPrimaryUnavailable
+	}
+
+	err = store.primary.ClearStorage(ctx)
+	if err != nil {
+		store.isPrimaryFailure(ctx, err)
+		return
+	}
+	return store.cache.ClearStorage(ctx)
+}
@@ -0,0 +1,476 @@
+package storagememory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
+	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Хранилище коротких ссылок только в памяти
+// Данные можно периодически сохранять в файл снимка и восстанавливать из него при запуске
+type StorageShortLink struct {
+	mutex sync.RWMutex
+	// ключом является modelsStorage.GetStorageKey пространства имен и короткой ссылки
+	data modelsStorage.DataStorageShortLink
+	// индекс полная ссылка -> короткая ссылка для быстрой проверки дублей
+	// ключом является modelsStorage.GetStorageKey пространства имен и полной ссылки
+	indexFullURL map[string]string
+	// количество ссылок в каждом пространстве имен
+	countByNamespace map[modelsStorage.Namespace]int
+	// счетчик для uuid, чтобы uuid не повторялись после удаления данных
+	lastUUID int
+
+	// файл снимка, nil - снимки не делаются
+	snapshot *fileRestorer.FileRestorer
+	// интервал снимков, 0 - только при остановке
+	snapshotInterval time.Duration
+	// версия данных, увеличивается при каждом изменении
+	version uint64
+	// версия данных, сохраненная в последнем снимке
+	snapshotVersion uint64
+	// снимки пишутся по одному, чтобы старая копия данных не перезаписала более новую
+	mutexSnapshot sync.Mutex
+
+	stopSnapshots chan struct{}
+	waitSnapshots sync.WaitGroup
+	closeOnce     sync.Once
+}
+
+type StorageShortInterface interface {
+	modelsStorage.StorageShortInterface
+	modelsStorage.StorageCloserInterface
+	modelsStorage.StorageDisablerInterface
+	// применение изменения из БД, когда хранилище - локальная копия данных БД
+	ApplyChange(ctx context.Context, change modelsStorage.ChangeShortLink) (err error)
+}
+
+// создание хранилища в памяти
+// pathSnapshot - путь до файла снимка, пусто - без снимков
+// snapshotInterval - как часто сохранять снимок, 0 - только при остановке
+// configApp - конфигурация с ключами шифрования файла снимка, без снимков может быть nil
+func NewStorageShorts(pathSnapshot string, snapshotInterval time.Duration, configApp config.ConfigTypeInterface) (storage StorageShortInterface, err error) {
+
+	store := &StorageShortLink{
+		data:             make(modelsStorage.DataStorageShortLink),
+		indexFullURL:     make(map[string]string),
+		countByNamespace: make(map[modelsStorage.Namespace]int),
+		snapshotInterval: snapshotInterval,
+		stopSnapshots:    make(chan struct{}),
+	}
+
+	if pathSnapshot != "" {
+		store.snapshot, err = fileRestorer.NewFileRestorer(pathSnapshot, configApp)
+		if err != nil {
+			logger.GetLogger().Error("ошибка создания файла снимка хранилища в памяти: " + err.Error())
+			return nil, err
+		}
+		logger.GetLogger().Debug("Используется файл снимка хранилища в памяти: " + pathSnapshot)
+	}
+
+	err = store.Init(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	if store.snapshot != nil && snapshotInterval > 0 {
+		store.waitSnapshots.Add(1)
+		go store.runSnapshots()
+	}
+
+	return store, nil
+}
+
+// Метод вызывающийся при создании объекта, восстанавливает данные из снимка
+func (store *StorageShortLink) Init(ctx context.Context) (err error) {
+
+	if store.snapshot == nil {
+		return nil
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.clearData()
+	err = store.snapshot.ReadEach(ctx, func(dataRow restorer.RowDataRestorer) error {
+		// uuid из снимка сохраняем, чтобы они не менялись после перезапуска
+		store.addRow(modelsStorage.RowStorageShortLink{
+			ShortLink:   dataRow.ShortLink,
+			FullURL:     dataRow.FullURL,
+			UUID:        dataRow.UUID,
+			WorkspaceID: dataRow.WorkspaceID,
+			Domain:      dataRow.Domain,
+			Disabled:    dataRow.Disabled,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка: не удалось восстановить хранилище в памяти из снимка: %w", err)
+	}
+
+	// данные совпадают со снимком
+	store.snapshotVersion = store.version
+	logger.FromContext(ctx).Debugf("Прочитано коротких ссылок из снимка: %d", len(store.data))
+	return
+}
+
+// Тип хранилища
+func (store *StorageShortLink) GetBackendName() string {
+	return modelsStorage.StorageBackendMemory
+}
+
+// Хранилище в памяти готово всегда, а со снимками - пока файл снимка доступен на запись
+func (store *StorageShortLink) CheckReady(ctx context.Context) (err error) {
+	if store.snapshot == nil {
+		return nil
+	}
+	return store.snapshot.CheckReady()
+}
+
+// Периодическое сохранение снимка
+func (store *StorageShortLink) runSnapshots() {
+	defer store.waitSnapshots.Done()
+
+	ticker := time.NewTicker(store.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-store.stopSnapshots:
+			return
+		case <-ticker.C:
+			err := store.Snapshot()
+			if err != nil {
+				logger.GetLogger().Error("ошибка сохранения снимка хранилища в памяти: " + err.Error())
+			}
+		}
+	}
+}
+
+// Сохранение снимка хранилища в файл, если были изменения
+func (store *StorageShortLink) Snapshot() (err error) {
+
+	if store.snapshot == nil {
+		return nil
+	}
+	_, err = store.saveSnapshot(false)
+	return
+}
+
+// Сжатие хранилища: снимок переписывается, даже если данные не менялись
+func (store *StorageShortLink) Compact(ctx context.Context) (countRows int, err error) {
+
+	if store.snapshot == nil {
+		return 0, modelsStorage.ErrNotSupported
+	}
+	return store.saveSnapshot(true)
+}
+
+// запись снимка, isForce - записать и без изменений данных
+func (store *StorageShortLink) saveSnapshot(isForce bool) (countRows int, err error) {
+
+	store.mutexSnapshot.Lock()
+	defer store.mutexSnapshot.Unlock()
+
+	// под блокировкой чтения только копируем строки, в файл пишем без блокировки,
+	// чтобы запись снимка на диск не останавливала добавление ссылок
+	store.mutex.RLock()
+	versionData := store.version
+	if versionData == store.snapshotVersion && !isForce {
+		store.mutex.RUnlock()
+		return 0, nil
+	}
+	listRows := make([]restorer.RowDataRestorer, 0, len(store.data))
+	for _, dataRow := range store.data {
+		listRows = append(listRows, restorer.RowDataRestorer{
+			ShortLink:   dataRow.ShortLink,
+			FullURL:     dataRow.FullURL,
+			UUID:        dataRow.UUID,
+			WorkspaceID: dataRow.WorkspaceID,
+			Domain:      dataRow.Domain,
+			Disabled:    dataRow.Disabled,
+		})
+	}
+	store.mutex.RUnlock()
+
+	countRows, err = store.snapshot.RewriteRows(func(handler restorer.HandlerRowRestorer) (err error) {
+		for _, dataRow := range listRows {
+			err = handler(dataRow)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	// изменения после копирования строк попадут в следующий снимок
+	store.mutex.Lock()
+	store.snapshotVersion = versionData
+	store.mutex.Unlock()
+
+	logger.GetLogger().Debugf("Сохранили снимок хранилища в памяти, записей: %d", countRows)
+	return
+}
+
+// Остановка хранилища: прекращаем периодические снимки и сохраняем последний снимок
+func (store *StorageShortLink) Close(ctx context.Context) (err error) {
+	store.closeOnce.Do(func() {
+		close(store.stopSnapshots)
+		store.waitSnapshots.Wait()
+		err = store.Snapshot()
+	})
+	return
+}
+
+// добавление строки без блокировок, вызывающий код должен держать блокировку записи
+// пространство имен берется из строки, пустой UUID - выдать новый, иначе это идентификатор восстановленной строки
+func (store *StorageShortLink) addRow(row modelsStorage.RowStorageShortLink) (err error) {
+
+	namespace := row.GetNamespace()
+	fullURL := row.FullURL
+	shortLink := row.ShortLink
+	uuid := row.UUID
+
+	keyFullURL := modelsStorage.GetStorageKey(namespace, fullURL)
+	if _, ok := store.indexFullURL[keyFullURL]; ok {
+		return modelsStorage.NewErrExistFullURLExt(fullURL)
+	}
+
+	// как и в остальных хранилищах, существующую короткую ссылку не перезаписываем
+	keyShortLink := modelsStorage.GetStorageKey(namespace, shortLink)
+	if _, ok := store.data[keyShortLink]; ok {
+		return modelsStorage.NewErrExistShortLinkExt(shortLink)
+	}
+
+	if uuid == "" {
+		store.lastUUID++
+		uuid = strconv.Itoa(store.lastUUID)
+	} else if numberUUID, errParse := strconv.Atoi(uuid); errParse == nil && numberUUID > store.lastUUID {
+		// новые uuid выдаем после восстановленных, чтобы они не повторялись
+		store.lastUUID = numberUUID
+	}
+
+	row.UUID = uuid
+	store.data[keyShortLink] = row
+	store.indexFullURL[keyFullURL] = shortLink
+	store.countByNamespace[namespace]++
+	store.version++
+	return nil
+}
+
+// удаление строки без блокировок, вызывающий код должен держать блокировку записи
+func (store *StorageShortLink) removeRow(namespace modelsStorage.Namespace, shortLink string) {
+
+	keyShortLink := modelsStorage.GetStorageKey(namespace, shortLink)
+	dataRow, ok := store.data[keyShortLink]
+	if !ok {
+		return
+	}
+
+	delete(store.data, keyShortLink)
+	keyFullURL := modelsStorage.GetStorageKey(namespace, dataRow.FullURL)
+	if store.indexFullURL[keyFullURL] == shortLink {
+		delete(store.indexFullURL, keyFullURL)
+	}
+	store.countByNamespace[namespace]--
+	if store.countByNamespace[namespace] <= 0 {
+		delete(store.countByNamespace, namespace)
+	}
+	store.version++
+}
+
+// удаление всех данных без блокировок
+func (store *StorageShortLink) clearData() {
+	store.data = make(modelsStorage.DataStorageShortLink)
+	store.indexFullURL = make(map[string]string)
+	store.countByNamespace = make(map[modelsStorage.Namespace]int)
+	store.version++
+}
+
+// установка всех данных хранилища
+func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.clearData()
+	for _, row := range data {
+		err = store.addRow(row)
+		if err != nil && !errors.Is(err, modelsStorage.ErrExistFullURL) && !errors.Is(err, modelsStorage.ErrExistShortLink) {
+			return
+		}
+	}
+	return nil
+}
+
+// Применение изменения, сделанного в БД, к данным в памяти
+// Нужно, когда хранилище в памяти - локальная копия данных БД. Полную синхронизацию делает владелец копии через SetData
+func (store *StorageShortLink) ApplyChange(ctx context.Context, change modelsStorage.ChangeShortLink) (err error) {
+
+	namespace := change.Row.GetNamespace()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	switch change.Operation {
+	case modelsStorage.ChangeOperationInsert, modelsStorage.ChangeOperationUpdate:
+		if change.OldShortLink != "" {
+			store.removeRow(namespace, change.OldShortLink)
+		}
+		store.removeRow(namespace, change.Row.ShortLink)
+		// у полной ссылки в пространстве имен одна короткая ссылка
+		if shortLink, ok := store.indexFullURL[modelsStorage.GetStorageKey(namespace, change.Row.FullURL)]; ok {
+			store.removeRow(namespace, shortLink)
+		}
+		return store.addRow(change.Row)
+
+	case modelsStorage.ChangeOperationDelete:
+		store.removeRow(namespace, change.OldShortLink)
+		return nil
+	}
+	return fmt.Errorf("ошибка: изменение коротких ссылок %s нельзя применить к хранилищу в памяти", change.Operation)
+}
+
+// добавление коротких ссылок группой
+func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+
+	namespace := modelsStorage.GetNamespace(ctx)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, row := range data {
+		err = store.addRow(modelsStorage.RowStorageShortLink{
+			ShortLink:   row.ShortLink,
+			FullURL:     row.FullURL,
+			WorkspaceID: namespace.WorkspaceID,
+			Domain:      namespace.Domain,
+		})
+		// дубли не прерывают групповое добавление
+		if errors.Is(err, modelsStorage.ErrExistFullURL) || errors.Is(err, modelsStorage.ErrExistShortLink) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// получаем список данных коротких ссылок по фильтру
+// возвращается копия данных, чтобы вызывающий код не зависел от блокировок хранилища
+func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
+
+	namespace := modelsStorage.GetNamespace(ctx)
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if options != nil && options.AllWorkspaces {
+		shortLinks = make(modelsStorage.DataStorageShortLink, len(store.data))
+		for key, dataRow := range store.data {
+			shortLinks[key] = dataRow
+		}
+		return
+	}
+
+	if options == nil {
+		shortLinks = make(modelsStorage.DataStorageShortLink, store.countByNamespace[namespace])
+		for _, dataRow := range store.data {
+			if dataRow.GetNamespace() == namespace {
+				shortLinks[dataRow.ShortLink] = dataRow
+			}
+		}
+		return
+	}
+
+	shortLinks = modelsStorage.DataStorageShortLink{}
+	for _, fullURL := range options.Filter.ListFullURL {
+		shortLink, ok := store.indexFullURL[modelsStorage.GetStorageKey(namespace, fullURL)]
+		if ok {
+			shortLinks[shortLink] = store.data[modelsStorage.GetStorageKey(namespace, shortLink)]
+		}
+	}
+	return
+}
+
+func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.countByNamespace[modelsStorage.GetNamespace(ctx)], nil
+}
+
+func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	namespace := modelsStorage.GetNamespace(ctx)
+	return store.addRow(modelsStorage.RowStorageShortLink{
+		ShortLink:   shortLink,
+		FullURL:     fullURL,
+		WorkspaceID: namespace.WorkspaceID,
+		Domain:      namespace.Domain,
+	})
+}
+
+func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.indexFullURL[modelsStorage.GetStorageKey(modelsStorage.GetNamespace(ctx), fullURL)], nil
+}
+
+func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	rowData, ok := store.data[modelsStorage.GetStorageKey(modelsStorage.GetNamespace(ctx), shortLink)]
+	if !ok {
+		// должны показать ошибку
+		err = modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+		return
+	}
+	if rowData.Disabled {
+		err = modelsStorage.NewErrDisabledShortLinkExt(shortLink)
+		return
+	}
+	return rowData.FullURL, nil
+}
+
+// Отключение или включение короткой ссылки, признак попадет в следующий снимок
+func (store *StorageShortLink) SetDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	keyShortLink := modelsStorage.GetStorageKey(modelsStorage.GetNamespace(ctx), shortLink)
+	rowData, ok := store.data[keyShortLink]
+	if !ok {
+		return modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+	}
+	if rowData.Disabled != isDisabled {
+		rowData.Disabled = isDisabled
+		store.data[keyShortLink] = rowData
+		store.version++
+	}
+	return nil
+}
+
+// Удаляем данные хранилища
+// Файл снимка перезапишется при следующем снимке
+func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.clearData()
+	return nil
+}
@@ -1,206 +1,223 @@
-package dbrestorer
-
-import (
-	"context"
-	"fmt"
-	dbconn "go-url-shortener/internal/database/connect"
-	errDriver "go-url-shortener/internal/database/errors/pgxerrors"
-	"go-url-shortener/internal/logger"
-	modelsStorage "go-url-shortener/internal/models/storageshortlink"
-	"go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
-)
-
-// Тип для восстановителя коротких ссылок из базы данных
-type DBRestorer struct {
-	nameTable string
-}
-
-func NewDBRestorer(nameTable string) (restorer *DBRestorer, err error) {
-
-	dbHandler := dbconn.GetDBHandler()
-	err = dbHandler.GetErrSetup()
-	if err == nil {
-		err = dbHandler.Ping()
-	}
-
-	if err != nil {
-		logger.GetLogger().Error("Не создать восстановитель хранилища из БД, не возможно к БД подключиться: " + err.Error())
-		return nil, err
-	}
-
-	// проверка существования таблицы
-	err = createRestoreTable(nameTable)
-	if err != nil {
-		logger.GetLogger().Error("ошибка создания таблицы для хранения ссылок: " + err.Error())
-		return
-	}
-
-	restorer = &DBRestorer{
-		nameTable: nameTable,
-	}
-
-	return
-}
-
-// Записать одну строчку в файл с данными востановления
-func (dbRestorer *DBRestorer) WriteRow(dataRow restorer.RowDataRestorer) (err error) {
-
-	tableName := dbRestorer.nameTable
-	fullURL := dataRow.FullURL
-	shortLink := dataRow.ShortLink
-
-	sqlAddRow := "INSERT INTO " + tableName + " (FULL_URL, SHORT_LINK) VALUES ($1, $2)"
-
-	dbHandler := dbconn.GetDBHandler()
-	poolConn := dbHandler.GetPool()
-	_, err = poolConn.Exec(sqlAddRow, fullURL, shortLink)
-	if err != nil {
-		logger.GetLogger().Errorln("ошибка: при выполении запроса " + sqlAddRow + ": " + err.Error())
-
-		isUniqErr, _ := errDriver.IsUniqueViolation(err)
-		if isUniqErr {
-			err = modelsStorage.NewErrExistFullURLExt(fullURL)
-		}
-	}
-
-	return
-}
-
-// Прочитать строчки в базе по запросу
-func (dbRestorer *DBRestorer) readRows(sqlSelectQuery string) (allRows []restorer.RowDataRestorer, err error) {
-
-	dbHandler := dbconn.GetDBHandler()
-	poolConn := dbHandler.GetPool()
-	rows, err := poolConn.Query(sqlSelectQuery)
-	// обязательно закрываем чтение строк
-	defer func() {
-		if err == nil {
-			err = rows.Close()
-		}
-	}()
-
-	if err != nil {
-		return
-	}
-
-	for rows.Next() {
-		var uuid string
-		var fullURL string
-		var shortLink string
-		if err := rows.Scan(&uuid, &fullURL, &shortLink); err != nil {
-			logger.GetLogger().Error("ошибка чтения строки из БД хранилища: " + err.Error())
-		} else {
-
-			if fullURL != "" && shortLink != "" {
-				allRows = append(allRows, restorer.RowDataRestorer{
-					ShortLink: shortLink,
-					FullURL:   fullURL,
-					UUID:      uuid,
-				})
-			}
-		}
-	}
-
-	// Проверим ошибки, чтобы понять, что считывание полностью было завершено
-	if err := rows.Err(); err != nil {
-		logger.GetLogger().Error("чтение строк из таблицы не было завершено корректно, возникла ошщибка: " + err.Error())
-	}
-
-	return
-}
-
-// Прочитать одну строчку в таблице с данными востановления
-func (dbRestorer *DBRestorer) ReadRow() (dataRow restorer.RowDataRestorer, err error) {
-	tableName := dbRestorer.nameTable
-	sqlSelectRow := "SELECT ID, FULL_URL, SHORT_LINK FROM " + tableName + " ORDER BY ID ASC LIMIT 1"
-	allRows, err := dbRestorer.readRows(sqlSelectRow)
-	if len(allRows) > 0 {
-		dataRow = allRows[0]
-	}
-	return
-}
-
-// Прочитать все строки в таблице с данными востановления и вернуть результат в виде слайса
-func (dbRestorer *DBRestorer) ReadAll() (allRows []restorer.RowDataRestorer, err error) {
-	tableName := dbRestorer.nameTable
-	sqlSelectRows := "SELECT ID, FULL_URL, SHORT_LINK FROM " + tableName + " ORDER BY ID ASC"
-	allRows, err = dbRestorer.readRows(sqlSelectRows)
-	return
-}
-
-// Очистить данные хранилища
-func (dbRestorer *DBRestorer) ClearRows() (err error) {
-	tableName := dbRestorer.nameTable
-	sqlTruncate := "TRUNCATE TABLE " + tableName
-
-	dbHandler := dbconn.GetDBHandler()
-	poolConn := dbHandler.GetPool()
-	_, err = poolConn.Exec(sqlTruncate)
-	return
-}
-
-// создаем таблицу для хранения коротких ссылок
-func createRestoreTable(tableName string) (err error) {
-
-	dbHandler := dbconn.GetDBHandler()
-	poolConn := dbHandler.GetPool()
-
-	ctx := context.TODO()
-	tx, err := poolConn.BeginTx(ctx, nil)
-	if err != nil {
-		logger.GetLogger().Error("ошибка: не смогли открыть транзакцию: " + err.Error())
-		return
-	}
-
-	sqlCreateTable := "" +
-		"create table IF NOT EXISTS " + tableName + " (" +
-		"	ID SERIAL PRIMARY KEY," +
-		"	FULL_URL varchar(255)," +
-		"	SHORT_LINK varchar(255)," +
-		"   CREATED TIMESTAMP DEFAULT CURRENT_TIMESTAMP" +
-		") "
-	_, err = tx.ExecContext(ctx, sqlCreateTable)
-	if err != nil {
-		errRoll := tx.Rollback()
-		if errRoll != nil {
-			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-		}
-
-		err = fmt.Errorf("ошибка: не смогли создать таблицу для восстановления коротких ссылок: %w", err)
-		return
-	}
-
-	// делаем индекс для быстрого поиска полной ссылки по короткой ссылке
-	sqlCreateIndexShort := "CREATE INDEX IF NOT EXISTS SHORT_LINK_index_" + tableName + " ON " + tableName + " (SHORT_LINK)"
-	_, err = tx.ExecContext(ctx, sqlCreateIndexShort)
-	if err != nil {
-		errRoll := tx.Rollback()
-		if errRoll != nil {
-			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-		}
-
-		err = fmt.Errorf("ошибка: не смогли создать индекс у поля SHORT_LINK: %w", err)
-		return
-	}
-
-	// делаем уникальный индекс поля FULL_URL как ограничение для целостности данных
-	sqlCreateIndexFull := "CREATE UNIQUE INDEX IF NOT EXISTS FULL_URL_index_" + tableName + " ON " + tableName + " (FULL_URL)"
-	_, err = tx.ExecContext(ctx, sqlCreateIndexFull)
-	if err != nil {
-		errRoll := tx.Rollback()
-		if errRoll != nil {
-			logger.GetLogger().Error("ошибка: не смогли сделать Rollback транзакции: " + errRoll.Error())
-		}
-
-		err = fmt.Errorf("ошибка: не смогли создать индекс у поля FULL_URL: %w", err)
-		return
-	}
-
-	// завершаем транзакцию
-	err = tx.Commit()
-	if err != nil {
-		logger.GetLogger().Error("ошибка: не смогли сделать commit транзакции: " + err.Error())
-	}
-
-	return
-}
+package dbrestorer
+
+import (
+	"context"
+	"errors"
+	dbconn "go-url-shortener/internal/database/connect"
+	errDriver "go-url-shortener/internal/database/errors/pgxerrors"
+	"go-url-shortener/internal/database/migrations"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	"go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Тип для восстановителя коротких ссылок из базы данных
+type DBRestorer struct {
+	// имя таблицы в кавычках, готовое для подстановки в SQL
+	nameTable string
+	// имена уникальных индексов полной и короткой ссылки, по ним разбираем ошибки вставки
+	nameIndexFullURL   string
+	nameIndexShortLink string
+	dbHandler          *dbconn.DBHandler
+}
+
+func NewDBRestorer(dbHandler *dbconn.DBHandler, nameTable string) (restorer *DBRestorer, err error) {
+
+	err = dbHandler.GetErrSetup()
+	if err == nil {
+		err = dbHandler.Ping()
+	}
+
+	if err != nil {
+		logger.GetLogger().Error("Не создать восстановитель хранилища из БД, не возможно к БД подключиться: " + err.Error())
+		return nil, err
+	}
+
+	tableName, err := migrations.ParseTableName(nameTable)
+	if err != nil {
+		logger.GetLogger().Error(err.Error())
+		return nil, err
+	}
+
+	// создание и обновление таблицы миграциями
+	err = migrations.MigrateTable(dbHandler.GetPool(), nameTable)
+	if err != nil {
+		logger.GetLogger().Error("ошибка создания таблицы для хранения ссылок: " + err.Error())
+		return
+	}
+
+	restorer = &DBRestorer{
+		nameTable:          tableName.Sanitize(),
+		nameIndexFullURL:   tableName.JoinName(migrations.PrefixIndexFullURL),
+		nameIndexShortLink: tableName.JoinName(migrations.PrefixIndexShortLink),
+		dbHandler:          dbHandler,
+	}
+
+	return
+}
+
+// Проверка подключения к БД
+func (dbRestorer *DBRestorer) CheckReady() (err error) {
+	return dbRestorer.dbHandler.Ping()
+}
+
+// Записать одну строчку в файл с данными востановления
+func (dbRestorer *DBRestorer) WriteRow(ctx context.Context, dataRow restorer.RowDataRestorer) (err error) {
+
+	tableName := dbRestorer.nameTable
+	fullURL := dataRow.FullURL
+	shortLink := dataRow.ShortLink
+
+	sqlAddRow := "INSERT INTO " + tableName + " (FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED) VALUES ($1, $2, $3, $4, $5)"
+
+	dbHandler := dbRestorer.dbHandler
+	poolConn := dbHandler.GetPool()
+	// вставку не повторяем, повтор после обрыва соединения мог бы вернуть ошибку дубля
+	err = dbHandler.GetRetrier().DoOnce(ctx, func(ctx context.Context) (err error) {
+		_, err = poolConn.Exec(ctx, sqlAddRow, fullURL, shortLink, dataRow.WorkspaceID, dataRow.Domain, dataRow.Disabled)
+		return
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlAddRow + ": " + err.Error())
+
+		isUniqErr, _ := errDriver.IsUniqueViolation(err)
+		if isUniqErr {
+			switch errDriver.GetConstraintName(err) {
+			case dbRestorer.nameIndexFullURL:
+				err = modelsStorage.NewErrExistFullURLExt(fullURL)
+			case dbRestorer.nameIndexShortLink:
+				err = modelsStorage.NewErrExistShortLinkExt(shortLink)
+			}
+		}
+	}
+
+	return
+}
+
+// Изменение строки таблицы: меняется признак отключения ссылки
+// Если строки нет, возвращается ErrNotFoundShortLinkExt
+func (dbRestorer *DBRestorer) UpdateRow(ctx context.Context, dataRow restorer.RowDataRestorer) (err error) {
+
+	tableName := dbRestorer.nameTable
+	sqlUpdateRow := "UPDATE " + tableName + " SET DISABLED = $1 WHERE SHORT_LINK = $2 AND WORKSPACE_ID = $3 AND SHORT_DOMAIN = $4"
+
+	dbHandler := dbRestorer.dbHandler
+	poolConn := dbHandler.GetPool()
+	var countRows int64
+	// повтор изменения безопасен, признак просто установится еще раз
+	err = dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) (err error) {
+		result, err := poolConn.Exec(ctx, sqlUpdateRow, dataRow.Disabled, dataRow.ShortLink, dataRow.WorkspaceID, dataRow.Domain)
+		countRows = result.RowsAffected()
+		return
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorln("ошибка: при выполении запроса " + sqlUpdateRow + ": " + err.Error())
+		return
+	}
+	if countRows == 0 {
+		err = modelsStorage.NewErrNotFoundShortLinkExt(dataRow.ShortLink)
+	}
+	return
+}
+
+// Прочитать одну строчку в таблице с данными востановления
+// Читаем только одну строку, без выборки всей таблицы
+func (dbRestorer *DBRestorer) ReadRow() (dataRow restorer.RowDataRestorer, err error) {
+	tableName := dbRestorer.nameTable
+	sqlSelectRow := "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + tableName + " ORDER BY ID ASC LIMIT 1"
+
+	dbHandler := dbRestorer.dbHandler
+	poolConn := dbHandler.GetPool()
+	err = dbHandler.GetRetrier().Do(context.Background(), func(ctx context.Context) error {
+		return poolConn.QueryRow(ctx, sqlSelectRow).Scan(&dataRow.UUID, &dataRow.FullURL, &dataRow.ShortLink, &dataRow.WorkspaceID, &dataRow.Domain, &dataRow.Disabled)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// пустая таблица не является ошибкой
+		return restorer.RowDataRestorer{}, nil
+	}
+	return
+}
+
+// Прочитать все строки в таблице с данными востановления и вернуть результат в виде слайса
+func (dbRestorer *DBRestorer) ReadAll(ctx context.Context) (allRows []restorer.RowDataRestorer, err error) {
+
+	err = dbRestorer.ReadEach(ctx, func(dataRow restorer.RowDataRestorer) error {
+		allRows = append(allRows, dataRow)
+		return nil
+	})
+	return
+}
+
+// Потоковое чтение всех строк таблицы через курсор
+// Строки, которые не удалось прочитать, пропускаются
+func (dbRestorer *DBRestorer) ReadEach(ctx context.Context, handler restorer.HandlerRowRestorer) (err error) {
+	tableName := dbRestorer.nameTable
+	sqlSelectRows := "SELECT ID, FULL_URL, SHORT_LINK, WORKSPACE_ID, SHORT_DOMAIN, DISABLED FROM " + tableName + " ORDER BY ID ASC"
+
+	dbHandler := dbRestorer.dbHandler
+	poolConn := dbHandler.GetPool()
+	// повторяем только открытие курсора, строки уже переданные в handler повторно не читаем
+	var rows pgx.Rows
+	err = dbHandler.GetRetrier().Do(ctx, func(ctx context.Context) (err error) {
+		rows, err = poolConn.Query(ctx, sqlSelectRows)
+		return
+	})
+	if err != nil {
+		return
+	}
+	// обязательно закрываем чтение строк
+	defer rows.Close()
+
+	for rows.Next() {
+		var uuid string
+		var fullURL string
+		var shortLink string
+		var workspaceID string
+		var domain string
+		var isDisabled bool
+		if err := rows.Scan(&uuid, &fullURL, &shortLink, &workspaceID, &domain, &isDisabled); err != nil {
+			logger.FromContext(ctx).Error("ошибка чтения строки из БД хранилища: " + err.Error())
+			continue
+		}
+
+		if fullURL == "" || shortLink == "" {
+			continue
+		}
+
+		err = handler(restorer.RowDataRestorer{
+			ShortLink:   shortLink,
+			FullURL:     fullURL,
+			UUID:        uuid,
+			WorkspaceID: workspaceID,
+			Domain:      domain,
+			Disabled:    isDisabled,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	// Проверим ошибки, чтобы понять, что считывание полностью было завершено
+	err = rows.Err()
+	if err != nil {
+		logger.FromContext(ctx).Error("чтение строк из таблицы не было завершено корректно, возникла ошщибка: " + err.Error())
+	}
+
+	return
+}
+
+// Очистить данные хранилища
+func (dbRestorer *DBRestorer) ClearRows() (err error) {
+	tableName := dbRestorer.nameTable
+	sqlTruncate := "TRUNCATE TABLE " + tableName
+
+	dbHandler := dbRestorer.dbHandler
+	poolConn := dbHandler.GetPool()
+	err = dbHandler.GetRetrier().Do(context.Background(), func(ctx context.Context) (err error) {
+		_, err = poolConn.Exec(ctx, sqlTruncate)
+		return
+	})
+	return
+}
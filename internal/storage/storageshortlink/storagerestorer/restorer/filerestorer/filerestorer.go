@@ -1,191 +1,409 @@
-package filerestorer
-
-import (
-	"bufio"
-	"encoding/json"
-	"go-url-shortener/internal/logger"
-	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
-	"os"
-	"path/filepath"
-)
-
-// Тип для восстановителя коротких ссылок из файла
-type FileRestorer struct {
-	pathfile string
-}
-
-func NewFileRestorer(pathFile string) (restorer *FileRestorer, err error) {
-
-	pathFile, err = createRestoreFile(pathFile)
-	if err != nil {
-		logger.GetLogger().Error("ошибка создания файла хранилища ссылок: " + err.Error())
-		return nil, err
-	}
-
-	restorer = &FileRestorer{
-		pathfile: pathFile,
-	}
-
-	return
-}
-
-// Открыть файл
-func (fileRestorer *FileRestorer) openFile() (file *os.File, err error) {
-
-	pathFile := fileRestorer.pathfile
-
-	// открываем файл для записи в конец
-	file, err = os.OpenFile(pathFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0777)
-	if err != nil {
-		return nil, err
-	}
-	return
-}
-
-// Записать одну строчку в файл с данными востановления
-func (fileRestorer *FileRestorer) WriteRow(dataRow restorer.RowDataRestorer) (err error) {
-
-	file, err := fileRestorer.openFile()
-	defer func() {
-		err = file.Close()
-	}()
-
-	if err != nil {
-		return
-	}
-
-	writer := bufio.NewWriter(file)
-
-	dataBytes, err := json.Marshal(dataRow)
-	if err != nil {
-		return err
-	}
-
-	// записываем событие в буфер
-	if _, err := writer.Write(dataBytes); err != nil {
-		return err
-	}
-
-	// добавляем перенос строки
-	if err := writer.WriteByte('\n'); err != nil {
-		return err
-	}
-
-	// записываем буфер в файл
-	return writer.Flush()
-}
-
-// Прочитать одну строчку в файле с данными востановления
-func (fileRestorer *FileRestorer) readerReadRow(reader *bufio.Scanner) (dataRow restorer.RowDataRestorer, isLastRow bool, err error) {
-
-	// одиночное сканирование до следующей строки
-	if !reader.Scan() {
-		errScan := reader.Err()
-		isLastRow = (errScan == nil)
-		return dataRow, isLastRow, errScan
-	}
-
-	// читаем данные из scanner
-	dataBytes := reader.Bytes()
-	err = json.Unmarshal(dataBytes, &dataRow)
-	if err != nil {
-		return dataRow, isLastRow, err
-	}
-
-	return dataRow, isLastRow, nil
-}
-
-// Прочитать одну строчку в файле с данными востановления
-func (fileRestorer *FileRestorer) ReadRow() (dataRow restorer.RowDataRestorer, err error) {
-
-	file, err := fileRestorer.openFile()
-	defer func() {
-		err = file.Close()
-	}()
-	if err != nil {
-		return
-	}
-
-	reader := bufio.NewScanner(file)
-	dataRow, _, err = fileRestorer.readerReadRow(reader)
-	return
-}
-
-// Прочитать весь файл с данными востановления и вернуть результат в виде слайса
-func (fileRestorer *FileRestorer) ReadAll() (allRows []restorer.RowDataRestorer, err error) {
-
-	file, err := fileRestorer.openFile()
-	defer func() {
-		err = file.Close()
-	}()
-
-	if err != nil {
-		return
-	}
-
-	reader := bufio.NewScanner(file)
-
-	for {
-		dataRow, isLastRow, err := fileRestorer.readerReadRow(reader)
-		if err != nil {
-			logger.GetLogger().Error("ошибка чтения строки из файла хранилища: " + err.Error())
-		} else {
-			if dataRow.ShortLink != "" && dataRow.FullURL != "" {
-				allRows = append(allRows, dataRow)
-			}
-		}
-
-		if isLastRow {
-			break
-		}
-	}
-	return
-}
-
-// Очистить данные хранилища
-func (fileRestorer *FileRestorer) ClearRows() (err error) {
-
-	err = os.Truncate(fileRestorer.pathfile, 0)
-	if err != nil {
-		logger.GetLogger().Error("ошибка очистки файла хранилища: " + err.Error())
-	}
-
-	/*
-		file, err := fileRestorer.openFile()
-		if err != nil {
-			logger.GetLogger().Error("ошибка открытия файла хранилища: " + err.Error())
-		} else {
-
-			err = file.Truncate(0)
-			if err != nil {
-				logger.GetLogger().Error("ошибка очистки файла хранилища: " + err.Error())
-			}
-			file.Close()
-
-		}*/
-
-	return
-}
-
-func createRestoreFile(pathFile string) (pathRestoreFile string, err error) {
-
-	nameFile := filepath.Base(pathFile)
-	// путь с правильными разделителями операционной системы
-	pathToFile := filepath.Dir(pathFile)
-
-	// записываем путь с разделителями как в операционной системе
-	separatorOS := string(filepath.Separator)
-	pathRestoreFile = pathToFile + separatorOS + nameFile
-
-	// создаем папку logs в корне проекта
-	_, err = os.Stat(pathToFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			err = os.MkdirAll(pathToFile, 0777)
-			if err != nil && !os.IsExist(err) {
-				return
-			}
-		}
-	}
-	return
-}
+package filerestorer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/encryption/keyring"
+	"go-url-shortener/internal/logger"
+	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// максимальный размер одной строки файла
+// по умолчанию сканер читает строки до 64 Кб, а длинные ссылки в зашифрованном виде могут быть больше
+const maxSizeRow = 16 * 1024 * 1024
+
+// Тип для восстановителя коротких ссылок из файла
+type FileRestorer struct {
+	pathfile string
+	// ключи шифрования записей, если nil, то записи пишутся открытым текстом
+	keyRing *keyring.KeyRing
+	// файл переписывается через один и тот же временный файл, поэтому переписывания не пересекаются
+	mutexRewrite sync.Mutex
+}
+
+// Зашифрованная строка файла хранилища
+// Старые незашифрованные строки не содержат KeyID
+type encryptedRow struct {
+	KeyID string
+	Nonce []byte
+	Data  []byte
+}
+
+// Создание восстановителя, ключи шифрования берутся из переданной конфигурации
+func NewFileRestorer(pathFile string, configApp config.ConfigTypeInterface) (restorer *FileRestorer, err error) {
+
+	keyRing, err := keyring.NewKeyRing(configApp.GetFileStorageKey(), configApp.GetFileStorageKeyFile())
+	if err != nil {
+		logger.GetLogger().Error("ошибка загрузки ключей шифрования файла хранилища: " + err.Error())
+		return nil, err
+	}
+
+	return NewFileRestorerWithKeyRing(pathFile, keyRing)
+}
+
+// Создание восстановителя с переданными ключами шифрования
+func NewFileRestorerWithKeyRing(pathFile string, keyRing *keyring.KeyRing) (restorer *FileRestorer, err error) {
+
+	pathFile, err = createRestoreFile(pathFile)
+	if err != nil {
+		logger.GetLogger().Error("ошибка создания файла хранилища ссылок: " + err.Error())
+		return nil, err
+	}
+
+	// сразу проверяем, что в файл можно писать, а не при первой записи ссылки
+	err = checkWritable(pathFile)
+	if err != nil {
+		logger.GetLogger().Error(err.Error())
+		return nil, err
+	}
+
+	if keyRing != nil {
+		logger.GetLogger().Debug("Записи файла хранилища шифруются ключом: " + keyRing.GetActiveKeyID())
+	}
+
+	restorer = &FileRestorer{
+		pathfile: pathFile,
+		keyRing:  keyRing,
+	}
+
+	return
+}
+
+// Проверка, что в файл хранилища по-прежнему можно писать, например для проверки готовности сервиса
+func (fileRestorer *FileRestorer) CheckReady() (err error) {
+	return checkWritable(fileRestorer.pathfile)
+}
+
+// файл открывается на дозапись, поэтому его содержимое не меняется
+func checkWritable(pathFile string) (err error) {
+	file, err := os.OpenFile(pathFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return fmt.Errorf("ошибка: нет доступа на запись в файл хранилища %s: %w", pathFile, err)
+	}
+	return file.Close()
+}
+
+// Сериализация строки для записи в файл
+// Если заданы ключи, то строка шифруется активным ключом
+func (fileRestorer *FileRestorer) encodeRow(dataRow restorer.RowDataRestorer) (dataBytes []byte, err error) {
+
+	dataBytes, err = json.Marshal(dataRow)
+	if err != nil || fileRestorer.keyRing == nil {
+		return
+	}
+
+	keyID, nonce, encryptedData, err := fileRestorer.keyRing.Encrypt(dataBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(encryptedRow{
+		KeyID: keyID,
+		Nonce: nonce,
+		Data:  encryptedData,
+	})
+}
+
+// Десериализация строки из файла
+// Поддерживаются как зашифрованные, так и старые незашифрованные строки
+func (fileRestorer *FileRestorer) decodeRow(dataBytes []byte) (dataRow restorer.RowDataRestorer, err error) {
+
+	var rowEncrypted encryptedRow
+	err = json.Unmarshal(dataBytes, &rowEncrypted)
+	if err != nil {
+		return
+	}
+
+	// незашифрованная строка
+	if rowEncrypted.KeyID == "" {
+		err = json.Unmarshal(dataBytes, &dataRow)
+		return
+	}
+
+	if fileRestorer.keyRing == nil {
+		err = errors.New("ошибка: строка зашифрована ключом " + rowEncrypted.KeyID + ", но ключи шифрования не переданы")
+		return
+	}
+
+	plainData, err := fileRestorer.keyRing.Decrypt(rowEncrypted.KeyID, rowEncrypted.Nonce, rowEncrypted.Data)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(plainData, &dataRow)
+	return
+}
+
+// Открыть файл
+func (fileRestorer *FileRestorer) openFile() (file *os.File, err error) {
+
+	pathFile := fileRestorer.pathfile
+
+	// открываем файл для записи в конец
+	file, err = os.OpenFile(pathFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Записать одну строчку в файл с данными востановления
+func (fileRestorer *FileRestorer) WriteRow(ctx context.Context, dataRow restorer.RowDataRestorer) (err error) {
+
+	file, err := fileRestorer.openFile()
+	defer func() {
+		err = file.Close()
+	}()
+
+	if err != nil {
+		return
+	}
+
+	writer := bufio.NewWriter(file)
+
+	dataBytes, err := fileRestorer.encodeRow(dataRow)
+	if err != nil {
+		return err
+	}
+
+	// записываем событие в буфер
+	if _, err := writer.Write(dataBytes); err != nil {
+		return err
+	}
+
+	// добавляем перенос строки
+	if err := writer.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	// записываем буфер в файл
+	return writer.Flush()
+}
+
+// Изменение строки: в файл дописывается новая версия строки
+// При восстановлении действует последняя строка с той же короткой ссылкой, старые убирает сжатие файла
+func (fileRestorer *FileRestorer) UpdateRow(ctx context.Context, dataRow restorer.RowDataRestorer) (err error) {
+	return fileRestorer.WriteRow(ctx, dataRow)
+}
+
+// Сканер строк файла с увеличенным размером строки
+func newScannerRows(file *os.File) *bufio.Scanner {
+	reader := bufio.NewScanner(file)
+	reader.Buffer(make([]byte, 0, 64*1024), maxSizeRow)
+	return reader
+}
+
+// Прочитать одну строчку в файле с данными востановления
+func (fileRestorer *FileRestorer) readerReadRow(reader *bufio.Scanner) (dataRow restorer.RowDataRestorer, isLastRow bool, err error) {
+
+	// одиночное сканирование до следующей строки
+	if !reader.Scan() {
+		errScan := reader.Err()
+		isLastRow = (errScan == nil)
+		return dataRow, isLastRow, errScan
+	}
+
+	// читаем данные из scanner
+	dataBytes := reader.Bytes()
+	dataRow, err = fileRestorer.decodeRow(dataBytes)
+	if err != nil {
+		return dataRow, isLastRow, err
+	}
+
+	return dataRow, isLastRow, nil
+}
+
+// Прочитать одну строчку в файле с данными востановления
+func (fileRestorer *FileRestorer) ReadRow() (dataRow restorer.RowDataRestorer, err error) {
+
+	file, err := fileRestorer.openFile()
+	defer func() {
+		err = file.Close()
+	}()
+	if err != nil {
+		return
+	}
+
+	reader := newScannerRows(file)
+	dataRow, _, err = fileRestorer.readerReadRow(reader)
+	return
+}
+
+// Прочитать весь файл с данными востановления и вернуть результат в виде слайса
+func (fileRestorer *FileRestorer) ReadAll(ctx context.Context) (allRows []restorer.RowDataRestorer, err error) {
+
+	err = fileRestorer.ReadEach(ctx, func(dataRow restorer.RowDataRestorer) error {
+		allRows = append(allRows, dataRow)
+		return nil
+	})
+	return
+}
+
+// Потоковое чтение файла с данными востановления построчно через сканер
+// Строки, которые не удалось прочитать, пропускаются
+func (fileRestorer *FileRestorer) ReadEach(ctx context.Context, handler restorer.HandlerRowRestorer) (err error) {
+	return fileRestorer.readEach(ctx, handler, false)
+}
+
+// Потоковое чтение файла
+// isStrict - при ошибке чтения строки прекращаем чтение, а не пропускаем строку
+func (fileRestorer *FileRestorer) readEach(ctx context.Context, handler restorer.HandlerRowRestorer, isStrict bool) (err error) {
+
+	file, err := fileRestorer.openFile()
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader := newScannerRows(file)
+	numberRow := 0
+	for reader.Scan() {
+		numberRow++
+
+		dataRow, errDecode := fileRestorer.decodeRow(reader.Bytes())
+		if errDecode != nil {
+			if isStrict {
+				return fmt.Errorf("ошибка: не удалось прочитать строку %d файла хранилища: %w", numberRow, errDecode)
+			}
+			logger.FromContext(ctx).Error("ошибка чтения строки из файла хранилища: " + errDecode.Error())
+			continue
+		}
+
+		if dataRow.ShortLink == "" || dataRow.FullURL == "" {
+			continue
+		}
+
+		err = handler(dataRow)
+		if err != nil {
+			return
+		}
+	}
+
+	// ошибка самого сканера, например слишком длинная строка
+	err = reader.Err()
+	if err != nil {
+		err = fmt.Errorf("ошибка: чтение файла хранилища прервано на строке %d: %w", numberRow+1, err)
+	}
+	return
+}
+
+// Очистить данные хранилища
+func (fileRestorer *FileRestorer) ClearRows() (err error) {
+
+	err = os.Truncate(fileRestorer.pathfile, 0)
+	if err != nil {
+		logger.GetLogger().Error("ошибка очистки файла хранилища: " + err.Error())
+	}
+
+	/*
+		file, err := fileRestorer.openFile()
+		if err != nil {
+			logger.GetLogger().Error("ошибка открытия файла хранилища: " + err.Error())
+		} else {
+
+			err = file.Truncate(0)
+			if err != nil {
+				logger.GetLogger().Error("ошибка очистки файла хранилища: " + err.Error())
+			}
+			file.Close()
+
+		}*/
+
+	return
+}
+
+// Перешифровать весь файл хранилища активным ключом
+func (fileRestorer *FileRestorer) ReEncrypt() (countRows int, err error) {
+
+	if fileRestorer.keyRing == nil {
+		err = errors.New("ошибка: не переданы ключи шифрования, перешифровать файл хранилища невозможно")
+		return
+	}
+
+	// в отличие от чтения хранилища, тут нельзя пропускать строки, иначе они потеряются
+	return fileRestorer.RewriteRows(func(handler restorer.HandlerRowRestorer) error {
+		return fileRestorer.readEach(context.Background(), handler, true)
+	})
+}
+
+// Полностью переписать файл хранилища строками из источника
+// Файл переписывается через временный файл, чтобы при ошибке не потерять данные
+func (fileRestorer *FileRestorer) RewriteRows(source restorer.SourceRowsRestorer) (countRows int, err error) {
+
+	fileRestorer.mutexRewrite.Lock()
+	defer fileRestorer.mutexRewrite.Unlock()
+
+	pathTempFile := fileRestorer.pathfile + ".rewrite"
+	tempFile, err := os.OpenFile(pathTempFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tempFile.Close()
+			os.Remove(pathTempFile)
+		}
+	}()
+
+	writer := bufio.NewWriter(tempFile)
+	err = source(func(dataRow restorer.RowDataRestorer) (err error) {
+		dataBytes, err := fileRestorer.encodeRow(dataRow)
+		if err != nil {
+			return
+		}
+		if _, err = writer.Write(dataBytes); err != nil {
+			return
+		}
+		if err = writer.WriteByte('\n'); err != nil {
+			return
+		}
+		countRows++
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	if err = writer.Flush(); err != nil {
+		return
+	}
+	if err = tempFile.Sync(); err != nil {
+		return
+	}
+	if err = tempFile.Close(); err != nil {
+		return
+	}
+
+	err = os.Rename(pathTempFile, fileRestorer.pathfile)
+	return
+}
+
+func createRestoreFile(pathFile string) (pathRestoreFile string, err error) {
+
+	nameFile := filepath.Base(pathFile)
+	// путь с правильными разделителями операционной системы
+	pathToFile := filepath.Dir(pathFile)
+
+	// записываем путь с разделителями как в операционной системе
+	separatorOS := string(filepath.Separator)
+	pathRestoreFile = pathToFile + separatorOS + nameFile
+
+	// создаем папку logs в корне проекта
+	_, err = os.Stat(pathToFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = os.MkdirAll(pathToFile, 0777)
+			if err != nil && !os.IsExist(err) {
+				return
+			}
+		}
+	}
+	return
+}
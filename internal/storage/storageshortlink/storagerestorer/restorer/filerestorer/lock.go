@@ -0,0 +1,53 @@
+package filerestorer
+
+import (
+	"errors"
+	"os"
+)
+
+// базовый тип ошибки, если файл хранилища занят другим процессом
+var ErrFileLocked = errors.New("ошибка: файл хранилища используется другим процессом")
+
+// Блокировка файла хранилища между процессами
+// Блокируется отдельный файл рядом с хранилищем: файл хранилища при перезаписи заменяется новым,
+// и блокировка самого файла осталась бы на старом файле
+// Сервер держит общую блокировку, пока работает с файлом, команды, переписывающие файл целиком,
+// берут исключительную блокировку и не запускаются, пока файл открыт сервером
+type FileLock struct {
+	file *os.File
+}
+
+// путь до файла блокировки хранилища
+func getPathLockFile(pathFile string) string {
+	return pathFile + ".lock"
+}
+
+// Общая блокировка файла хранилища, ее одновременно могут держать несколько хранилищ
+func LockShared(pathFile string) (lock *FileLock, err error) {
+	return lockFile(pathFile, false)
+}
+
+// Исключительная блокировка файла хранилища без ожидания
+// Если файл заблокирован другим процессом, возвращается ErrFileLocked
+func TryLockExclusive(pathFile string) (lock *FileLock, err error) {
+	return lockFile(pathFile, true)
+}
+
+// Снятие блокировки
+func (lock *FileLock) Unlock() (err error) {
+	if lock == nil || lock.file == nil {
+		return nil
+	}
+	err = unlockFile(lock.file)
+	errClose := lock.file.Close()
+	lock.file = nil
+	if err == nil {
+		err = errClose
+	}
+	return
+}
+
+// открытие файла блокировки, сам файл после снятия блокировки не удаляется
+func openLockFile(pathFile string) (file *os.File, err error) {
+	return os.OpenFile(getPathLockFile(pathFile), os.O_RDWR|os.O_CREATE, 0600)
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package filerestorer
+
+import "os"
+
+// на системах без flock файл блокировки только создается, проверка между процессами не выполняется
+// Перешифрование на таких системах выполняется только при остановленном сервере
+func lockFile(pathFile string, isExclusive bool) (lock *FileLock, err error) {
+
+	file, err := openLockFile(pathFile)
+	if err != nil {
+		return
+	}
+	return &FileLock{file: file}, nil
+}
+
+func unlockFile(file *os.File) (err error) {
+	return nil
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package filerestorer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// блокировка через flock, ее снимает система при завершении процесса
+func lockFile(pathFile string, isExclusive bool) (lock *FileLock, err error) {
+
+	file, err := openLockFile(pathFile)
+	if err != nil {
+		return
+	}
+
+	how := syscall.LOCK_SH
+	if isExclusive {
+		how = syscall.LOCK_EX | syscall.LOCK_NB
+	}
+	err = syscall.Flock(int(file.Fd()), how)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			err = ErrFileLocked
+		}
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+func unlockFile(file *os.File) (err error) {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -1,14 +1,45 @@
-package restorer
-
-type RowDataRestorer struct {
-	ShortLink string
-	FullURL   string
-	UUID      string
-}
-
-type Restorer interface {
-	WriteRow(dataRow RowDataRestorer) (err error)
-	ReadRow() (dataRow RowDataRestorer, err error)
-	ReadAll() (allRows []RowDataRestorer, err error)
-	ClearRows() (err error)
-}
+package restorer
+
+import "context"
+
+type RowDataRestorer struct {
+	ShortLink string
+	FullURL   string
+	UUID      string
+	// рабочее пространство ссылки, у ссылок общего пространства поле не пишется
+	WorkspaceID string `json:",omitempty"`
+	// домен короткой ссылки, у ссылок домена BASE_URL поле не пишется
+	Domain string `json:",omitempty"`
+	// ссылка отключена администратором, у включенных ссылок поле не пишется
+	Disabled bool `json:",omitempty"`
+}
+
+// Функция обработки строки при потоковом чтении
+// Если функция вернула ошибку, то чтение прекращается и ошибка возвращается из ReadEach
+type HandlerRowRestorer func(dataRow RowDataRestorer) (err error)
+
+// Контекст операций нужен для логера запроса и для отмены запросов к БД
+type Restorer interface {
+	WriteRow(ctx context.Context, dataRow RowDataRestorer) (err error)
+	// изменение уже записанной строки с той же короткой ссылкой в том же пространстве имен
+	UpdateRow(ctx context.Context, dataRow RowDataRestorer) (err error)
+	ReadRow() (dataRow RowDataRestorer, err error)
+	ReadAll(ctx context.Context) (allRows []RowDataRestorer, err error)
+	// потоковое чтение всех строк без загрузки их в память
+	ReadEach(ctx context.Context, handler HandlerRowRestorer) (err error)
+	ClearRows() (err error)
+}
+
+// Источник строк для перезаписи восстановителя, должен передать каждую строку в handler
+type SourceRowsRestorer func(handler HandlerRowRestorer) (err error)
+
+// Восстановитель, который переписывает все строки целиком
+// Старые строки заменяются новыми только после записи всех новых строк, поэтому при ошибке данные не теряются
+type Rewriter interface {
+	RewriteRows(source SourceRowsRestorer) (countRows int, err error)
+}
+
+// Восстановитель, который проверяет, что в него можно писать
+type ReadyChecker interface {
+	CheckReady() (err error)
+}
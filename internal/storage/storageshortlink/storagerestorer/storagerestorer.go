@@ -1,310 +1,518 @@
-package storagerestorer
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"go-url-shortener/internal/config"
-	"go-url-shortener/internal/logger"
-	modelsStorage "go-url-shortener/internal/models/storageshortlink"
-	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
-	dbRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/dbrestorer"
-	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
-)
-
-func getPackageError(textError string) error {
-	textModuleError := "storageshortlink: " + textError
-	return errors.New(textModuleError)
-}
-
-// тип для хранилища данных ссылок
-type StorageShortInterface interface {
-	modelsStorage.StorageShortInterface
-
-	GetRestorer() (restorer restorer.Restorer, err error)
-}
-
-// Хранилище коротких ссылок в памяти
-type StorageShortLink struct {
-	Data     modelsStorage.DataStorageShortLink
-	Restorer restorer.Restorer
-}
-
-func NewStorageShorts() (StorageShortInterface, error) {
-
-	// название таблицы в базе данных с короткими ссылками
-	nameTableRestorer := config.GetAppConfig().GetNameTableRestorer()
-	storage, err := NewStorageShortsFromDB(nameTableRestorer)
-	if err != nil {
-		// путь из конфигурации до файла хранилища с короткими ссылками
-		pathFileStorage := config.GetAppConfig().GetFileStoragePath()
-		storage, err = NewStorageShortsFromFileStorage(pathFileStorage)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return storage, nil
-}
-
-// создание хранилища на базе файла
-func NewStorageShortsFromFileStorage(pathFileStorage string) (StorageShortInterface, error) {
-
-	data := make(modelsStorage.DataStorageShortLink)
-	storageRestorer, err := fileRestorer.NewFileRestorer(pathFileStorage)
-	if err != nil {
-		logger.GetLogger().Error("При инициализации хранилища ссылок в Файле возникла ошибка: " + err.Error())
-		return nil, err
-	}
-
-	storage := &StorageShortLink{
-		Data:     data,
-		Restorer: storageRestorer,
-	}
-	storage.Init(context.TODO())
-	return storage, nil
-}
-
-// создание хранилища на базе таблицы базы данных
-func NewStorageShortsFromDB(nameTable string) (StorageShortInterface, error) {
-
-	logger.GetLogger().Debug("Используется таблица для хранения коротких ссылок: " + nameTable)
-
-	data := make(modelsStorage.DataStorageShortLink)
-	// путь из конфигурации до файла хранилища с короткими ссылками
-	storageRestorer, err := dbRestorer.NewDBRestorer(nameTable)
-	if err != nil {
-		logger.GetLogger().Error("При инициализации хранилища ссылок в БД возникла ошибка: " + err.Error())
-		return nil, err
-	}
-
-	storage := &StorageShortLink{
-		Data:     data,
-		Restorer: storageRestorer,
-	}
-	storage.Init(context.TODO())
-	return storage, nil
-}
-
-// установка всех данных хранилища
-func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
-
-	// запомнили старые данные
-	oldData := store.Data
-
-	err = store.ClearStorage(ctx)
-	if err != nil {
-		return
-	}
-
-	err = store.AddBatchShortLinks(ctx, data)
-	if err != nil {
-		err = store.ClearStorage(ctx)
-		if err != nil {
-			err = store.AddBatchShortLinks(ctx, oldData)
-		}
-
-	}
-	return
-}
-
-// добавление коротких ссылок группой
-func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
-
-	for _, row := range data {
-		shortLink := row.ShortLink
-		fullURL := row.FullURL
-		err = store.AddShortLinkForURL(ctx, fullURL, shortLink)
-		if err != nil {
-
-			// если это ошибка, что мы не можем вставить дубль, то идем дальше
-			if errors.Is(err, modelsStorage.ErrExistFullURL) {
-				// обнуляем ошибку
-				// эта ошибка не должна прерывать дальнейшее выполнение клиентского кода,
-				// вызывающего у сервиса метод AddBatchShortLinks
-				err = nil
-				continue
-			}
-
-			err = errors.New("ошибка: групповая установка данных в хранилище была остановлена, произошла ошибка: " + err.Error())
-			break
-		}
-	}
-	return
-}
-
-// получаем список данных коротких ссылок по фильтру
-func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
-
-	// если передан фильтр по полным ссылкам
-	if options != nil {
-
-		shortLinks = modelsStorage.DataStorageShortLink{}
-		if len(options.Filter.ListFullURL) > 0 {
-
-			tempData := make(map[string]string, 0)
-			for _, fullURL := range options.Filter.ListFullURL {
-				tempData[fullURL] = fullURL
-			}
-
-			for _, dataRow := range store.Data {
-				fullURL := dataRow.FullURL
-				if _, ok := tempData[fullURL]; ok {
-					shortLink := dataRow.ShortLink
-					shortLinks[shortLink] = modelsStorage.RowStorageShortLink{
-						ShortLink: shortLink,
-						FullURL:   dataRow.FullURL,
-						UUID:      dataRow.UUID,
-					}
-				}
-			}
-		}
-
-		return shortLinks, nil
-
-	} else {
-		return store.Data, nil
-	}
-
-}
-
-func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
-	return len(store.Data), nil
-}
-
-func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
-
-	// uuid сделаем просто порядковым номером
-	orderLink := len(store.Data) + 1
-	uuid := fmt.Sprintf("%d", orderLink)
-
-	// надо проверить, что fullURL еще не существует в нашем хранилище
-	for _, dataRow := range store.Data {
-		fullURLRow := dataRow.FullURL
-		if fullURLRow == fullURL {
-			err = modelsStorage.NewErrExistFullURLExt(fullURL)
-			break
-		}
-	}
-	if err != nil {
-		return
-	}
-
-	// важно
-	// если не отследить, что в хранилище уже есть запись с указанной короткой ссылкой,
-	// то данные в памяти просто обновятся с существующим ключом, а в рестороре добавится новая запись
-	// данные перестанут соответсвовать в памяти и в рестороре
-	if _, ok := store.Data[shortLink]; !ok {
-
-		rowDataRestorer := restorer.RowDataRestorer{
-			ShortLink: shortLink,
-			FullURL:   fullURL,
-			UUID:      uuid,
-		}
-
-		// делаем запись в ресторер
-		err = store.Restorer.WriteRow(rowDataRestorer)
-		if err == nil {
-			// делаем запись в память
-			store.Data[shortLink] = modelsStorage.RowStorageShortLink{
-				ShortLink: shortLink,
-				FullURL:   fullURL,
-				UUID:      uuid,
-			}
-		}
-	}
-
-	return
-}
-
-func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
-
-	for _, rowData := range store.Data {
-		if fullURL == rowData.FullURL {
-			shortLink = rowData.ShortLink
-			break
-		}
-	}
-	return
-}
-
-func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
-
-	rowData, ok := store.Data[shortLink]
-	if !ok {
-		// должны показать ошибку
-		err = getPackageError("Короткая ссылка " + shortLink + " не зарегистрирована")
-	} else {
-		fullURL = rowData.FullURL
-	}
-	return
-}
-
-func (store *StorageShortLink) SetRestorer(restorer restorer.Restorer) (err error) {
-	store.Restorer = restorer
-	return
-}
-
-func (store *StorageShortLink) SetMemoryData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
-	store.Data = data
-	return
-}
-
-// Удаление данных из памяти
-// Данные Ресторера не трогаем
-func (store *StorageShortLink) clearMemoryData(ctx context.Context) (err error) {
-	emptyData := make(modelsStorage.DataStorageShortLink)
-	store.SetMemoryData(ctx, emptyData)
-	return
-}
-
-func (store *StorageShortLink) InitRestorer(ctx context.Context, restorer restorer.Restorer) (err error) {
-	store.SetRestorer(restorer)
-	store.Restore(ctx)
-	return
-}
-
-func (store *StorageShortLink) Restore(ctx context.Context) (err error) {
-
-	store.clearMemoryData(ctx)
-
-	listRows, err := store.Restorer.ReadAll()
-	logger.GetLogger().Debugf("Прочитано коротких ссылок из Ресторера: %d", len(listRows))
-
-	if err != nil {
-		return
-	} else {
-		dataStorage := modelsStorage.DataStorageShortLink{}
-
-		for _, dataRow := range listRows {
-			shortLink := dataRow.ShortLink
-			dataStorage[shortLink] = modelsStorage.RowStorageShortLink{
-				ShortLink: shortLink,
-				FullURL:   dataRow.FullURL,
-				UUID:      dataRow.UUID,
-			}
-		}
-
-		store.SetMemoryData(ctx, dataStorage)
-	}
-	return
-}
-
-func (store *StorageShortLink) GetRestorer() (restorer restorer.Restorer, err error) {
-	restorer = store.Restorer
-	return
-}
-
-// Метод вызывающийся при создании объекта
-func (store *StorageShortLink) Init(ctx context.Context) (err error) {
-	return store.Restore(ctx)
-}
-
-// Удаляем данные хранилища
-func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
-	err = store.Restorer.ClearRows()
-	if err == nil {
-		err = store.clearMemoryData(ctx)
-	}
-
-	return err
-}
+package storagerestorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/logger"
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+	restorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer"
+	dbRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/dbrestorer"
+	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
+	"sync"
+)
+
+// тип для хранилища данных ссылок
+type StorageShortInterface interface {
+	modelsStorage.StorageShortInterface
+
+	GetRestorer() (restorer restorer.Restorer, err error)
+}
+
+// Хранилище коротких ссылок в памяти
+type StorageShortLink struct {
+	// ключом является modelsStorage.GetStorageKey пространства имен и короткой ссылки
+	Data     modelsStorage.DataStorageShortLink
+	Restorer restorer.Restorer
+	// общая блокировка файла хранилища, пока она есть, файл нельзя перешифровать командой reencrypt
+	fileLock *fileRestorer.FileLock
+	// данные в памяти меняются и из обработчиков запросов, и из слушателя уведомлений БД
+	mutex sync.RWMutex
+}
+
+// Создание хранилища с восстановлением из БД, а если БД недоступна, то из файла
+func NewStorageShorts(dbHandler *dbconn.DBHandler, configApp config.ConfigTypeInterface) (StorageShortInterface, error) {
+
+	// название таблицы в базе данных с короткими ссылками
+	nameTableRestorer := configApp.GetNameTableRestorer()
+	storage, err := NewStorageShortsFromDB(dbHandler, nameTableRestorer)
+	if err != nil {
+		// путь из конфигурации до файла хранилища с короткими ссылками
+		pathFileStorage := configApp.GetFileStoragePath()
+		storage, err = NewStorageShortsFromFileStorage(pathFileStorage, configApp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return storage, nil
+}
+
+// создание хранилища на базе файла, ключи шифрования файла берутся из конфигурации
+func NewStorageShortsFromFileStorage(pathFileStorage string, configApp config.ConfigTypeInterface) (StorageShortInterface, error) {
+
+	data := make(modelsStorage.DataStorageShortLink)
+	storageRestorer, err := fileRestorer.NewFileRestorer(pathFileStorage, configApp)
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища ссылок в Файле возникла ошибка: " + err.Error())
+		return nil, err
+	}
+
+	fileLock, err := fileRestorer.LockShared(pathFileStorage)
+	if err != nil {
+		logger.GetLogger().Error("Не удалось заблокировать файл хранилища ссылок: " + err.Error())
+		return nil, err
+	}
+
+	storage := &StorageShortLink{
+		Data:     data,
+		Restorer: storageRestorer,
+		fileLock: fileLock,
+	}
+	storage.Init(context.TODO())
+	return storage, nil
+}
+
+// создание хранилища на базе таблицы базы данных
+func NewStorageShortsFromDB(dbHandler *dbconn.DBHandler, nameTable string) (StorageShortInterface, error) {
+
+	logger.GetLogger().Debug("Используется таблица для хранения коротких ссылок: " + nameTable)
+
+	data := make(modelsStorage.DataStorageShortLink)
+	// путь из конфигурации до файла хранилища с короткими ссылками
+	storageRestorer, err := dbRestorer.NewDBRestorer(dbHandler, nameTable)
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища ссылок в БД возникла ошибка: " + err.Error())
+		return nil, err
+	}
+
+	storage := &StorageShortLink{
+		Data:     data,
+		Restorer: storageRestorer,
+	}
+	storage.Init(context.TODO())
+	return storage, nil
+}
+
+// установка всех данных хранилища
+func (store *StorageShortLink) SetData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+
+	// запомнили старые данные
+	oldData := store.Data
+
+	err = store.ClearStorage(ctx)
+	if err != nil {
+		return
+	}
+
+	// данные всех пространств имен, пространство имен берем из строки
+	err = store.addBatch(ctx, data, true)
+	if err != nil {
+		err = store.ClearStorage(ctx)
+		if err != nil {
+			err = store.addBatch(ctx, oldData, true)
+		}
+
+	}
+	return
+}
+
+// добавление коротких ссылок группой
+func (store *StorageShortLink) AddBatchShortLinks(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+	return store.addBatch(ctx, data, false)
+}
+
+// добавление группы ссылок
+// isRowNamespace - пространство имен берется из строки, а не из контекста
+func (store *StorageShortLink) addBatch(ctx context.Context, data modelsStorage.DataStorageShortLink, isRowNamespace bool) (err error) {
+
+	namespace := modelsStorage.GetNamespace(ctx)
+	for _, row := range data {
+		if isRowNamespace {
+			namespace = row.GetNamespace()
+		}
+		err = store.addRow(ctx, modelsStorage.RowStorageShortLink{
+			ShortLink:   row.ShortLink,
+			FullURL:     row.FullURL,
+			WorkspaceID: namespace.WorkspaceID,
+			Domain:      namespace.Domain,
+			Disabled:    row.Disabled,
+		})
+		if err != nil {
+
+			// если это ошибка, что мы не можем вставить дубль, то идем дальше
+			if errors.Is(err, modelsStorage.ErrExistFullURL) || errors.Is(err, modelsStorage.ErrExistShortLink) {
+				// обнуляем ошибку
+				// эта ошибка не должна прерывать дальнейшее выполнение клиентского кода,
+				// вызывающего у сервиса метод AddBatchShortLinks
+				err = nil
+				continue
+			}
+
+			err = errors.New("ошибка: групповая установка данных в хранилище была остановлена, произошла ошибка: " + err.Error())
+			break
+		}
+	}
+	return
+}
+
+// получаем список данных коротких ссылок по фильтру
+func (store *StorageShortLink) GetShortLinks(ctx context.Context, options *modelsStorage.OptionsQuery) (shortLinks modelsStorage.DataStorageShortLink, err error) {
+
+	namespace := modelsStorage.GetNamespace(ctx)
+
+	// если передан фильтр по полным ссылкам
+	if options != nil && !options.AllWorkspaces {
+
+		store.mutex.RLock()
+		defer store.mutex.RUnlock()
+
+		shortLinks = modelsStorage.DataStorageShortLink{}
+		if len(options.Filter.ListFullURL) > 0 {
+
+			tempData := make(map[string]string, 0)
+			for _, fullURL := range options.Filter.ListFullURL {
+				tempData[fullURL] = fullURL
+			}
+
+			for _, dataRow := range store.Data {
+				if dataRow.GetNamespace() != namespace {
+					continue
+				}
+				fullURL := dataRow.FullURL
+				if _, ok := tempData[fullURL]; ok {
+					shortLink := dataRow.ShortLink
+					shortLinks[shortLink] = dataRow
+				}
+			}
+		}
+
+		return shortLinks, nil
+
+	} else {
+		// отдаем копию, чтобы данные в памяти можно было менять, пока вызывающий код читает результат
+		store.mutex.RLock()
+		defer store.mutex.RUnlock()
+
+		shortLinks = make(modelsStorage.DataStorageShortLink)
+		for key, dataRow := range store.Data {
+			if options != nil {
+				// ссылки всех пространств имен
+				shortLinks[key] = dataRow
+			} else if dataRow.GetNamespace() == namespace {
+				shortLinks[dataRow.ShortLink] = dataRow
+			}
+		}
+		return shortLinks, nil
+	}
+
+}
+
+func (store *StorageShortLink) GetCountLink(ctx context.Context) (count int, err error) {
+	namespace := modelsStorage.GetNamespace(ctx)
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, dataRow := range store.Data {
+		if dataRow.GetNamespace() == namespace {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (store *StorageShortLink) AddShortLinkForURL(ctx context.Context, fullURL, shortLink string) (err error) {
+	namespace := modelsStorage.GetNamespace(ctx)
+	return store.addRow(ctx, modelsStorage.RowStorageShortLink{
+		ShortLink:   shortLink,
+		FullURL:     fullURL,
+		WorkspaceID: namespace.WorkspaceID,
+		Domain:      namespace.Domain,
+	})
+}
+
+// добавление ссылки, пространство имен берется из строки, uuid выдается новый
+func (store *StorageShortLink) addRow(ctx context.Context, row modelsStorage.RowStorageShortLink) (err error) {
+
+	namespace := row.GetNamespace()
+	fullURL := row.FullURL
+	shortLink := row.ShortLink
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// uuid сделаем просто порядковым номером
+	orderLink := len(store.Data) + 1
+	uuid := fmt.Sprintf("%d", orderLink)
+
+	// надо проверить, что fullURL еще не существует в пространстве имен
+	for _, dataRow := range store.Data {
+		fullURLRow := dataRow.FullURL
+		if fullURLRow == fullURL && dataRow.GetNamespace() == namespace {
+			err = modelsStorage.NewErrExistFullURLExt(fullURL)
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	// важно
+	// если не отследить, что в хранилище уже есть запись с указанной короткой ссылкой,
+	// то данные в памяти просто обновятся с существующим ключом, а в рестороре добавится новая запись
+	// данные перестанут соответсвовать в памяти и в рестороре
+	keyShortLink := modelsStorage.GetStorageKey(namespace, shortLink)
+	if _, ok := store.Data[keyShortLink]; ok {
+		return modelsStorage.NewErrExistShortLinkExt(shortLink)
+	}
+
+	rowDataRestorer := restorer.RowDataRestorer{
+		ShortLink:   shortLink,
+		FullURL:     fullURL,
+		UUID:        uuid,
+		WorkspaceID: namespace.WorkspaceID,
+		Domain:      namespace.Domain,
+		Disabled:    row.Disabled,
+	}
+
+	// делаем запись в ресторер
+	err = store.Restorer.WriteRow(ctx, rowDataRestorer)
+	if err == nil {
+		// делаем запись в память
+		row.UUID = uuid
+		store.Data[keyShortLink] = row
+	}
+
+	return
+}
+
+// Отключение или включение короткой ссылки, признак сначала пишется в ресторер, потом в память
+func (store *StorageShortLink) SetDisabled(ctx context.Context, shortLink string, isDisabled bool) (err error) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	keyShortLink := modelsStorage.GetStorageKey(modelsStorage.GetNamespace(ctx), shortLink)
+	dataRow, ok := store.Data[keyShortLink]
+	if !ok {
+		return modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+	}
+	if dataRow.Disabled == isDisabled {
+		return nil
+	}
+
+	err = store.Restorer.UpdateRow(ctx, restorer.RowDataRestorer{
+		ShortLink:   dataRow.ShortLink,
+		FullURL:     dataRow.FullURL,
+		UUID:        dataRow.UUID,
+		WorkspaceID: dataRow.WorkspaceID,
+		Domain:      dataRow.Domain,
+		Disabled:    isDisabled,
+	})
+	if err == nil {
+		dataRow.Disabled = isDisabled
+		store.Data[keyShortLink] = dataRow
+	}
+	return
+}
+
+func (store *StorageShortLink) GetShortLinkByURL(ctx context.Context, fullURL string) (shortLink string, err error) {
+
+	namespace := modelsStorage.GetNamespace(ctx)
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, rowData := range store.Data {
+		if fullURL == rowData.FullURL && rowData.GetNamespace() == namespace {
+			shortLink = rowData.ShortLink
+			break
+		}
+	}
+	return
+}
+
+func (store *StorageShortLink) GetFullLinkByShort(ctx context.Context, shortLink string) (fullURL string, err error) {
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	rowData, ok := store.Data[modelsStorage.GetStorageKey(modelsStorage.GetNamespace(ctx), shortLink)]
+	if !ok {
+		// должны показать ошибку
+		err = modelsStorage.NewErrNotFoundShortLinkExt(shortLink)
+	} else if rowData.Disabled {
+		err = modelsStorage.NewErrDisabledShortLinkExt(shortLink)
+	} else {
+		fullURL = rowData.FullURL
+	}
+	return
+}
+
+func (store *StorageShortLink) SetRestorer(restorer restorer.Restorer) (err error) {
+	store.Restorer = restorer
+	return
+}
+
+func (store *StorageShortLink) SetMemoryData(ctx context.Context, data modelsStorage.DataStorageShortLink) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.Data = data
+	return
+}
+
+// Удаление данных из памяти
+// Данные Ресторера не трогаем
+func (store *StorageShortLink) clearMemoryData(ctx context.Context) (err error) {
+	emptyData := make(modelsStorage.DataStorageShortLink)
+	store.SetMemoryData(ctx, emptyData)
+	return
+}
+
+func (store *StorageShortLink) InitRestorer(ctx context.Context, restorer restorer.Restorer) (err error) {
+	store.SetRestorer(restorer)
+	store.Restore(ctx)
+	return
+}
+
+// Восстановление данных из ресторера
+// Строки читаются потоково и сразу кладутся в память, без промежуточного слайса
+// Старые данные заменяются только после полного чтения, чтобы при повторном восстановлении
+// обработчики запросов не видели пустое хранилище
+func (store *StorageShortLink) Restore(ctx context.Context) (err error) {
+
+	dataStorage := modelsStorage.DataStorageShortLink{}
+	err = store.Restorer.ReadEach(ctx, func(dataRow restorer.RowDataRestorer) error {
+		row := modelsStorage.RowStorageShortLink{
+			ShortLink:   dataRow.ShortLink,
+			FullURL:     dataRow.FullURL,
+			UUID:        dataRow.UUID,
+			WorkspaceID: dataRow.WorkspaceID,
+			Domain:      dataRow.Domain,
+			Disabled:    dataRow.Disabled,
+		}
+		dataStorage[modelsStorage.GetStorageKey(row.GetNamespace(), row.ShortLink)] = row
+		return nil
+	})
+	logger.FromContext(ctx).Debugf("Прочитано коротких ссылок из Ресторера: %d", len(dataStorage))
+
+	if err != nil {
+		return
+	}
+
+	store.SetMemoryData(ctx, dataStorage)
+	return
+}
+
+func (store *StorageShortLink) GetRestorer() (restorer restorer.Restorer, err error) {
+	restorer = store.Restorer
+	return
+}
+
+// Тип хранилища определяется источником восстановления
+func (store *StorageShortLink) GetBackendName() string {
+	switch store.Restorer.(type) {
+	case *dbRestorer.DBRestorer:
+		return modelsStorage.StorageBackendPostgresCached
+	case *fileRestorer.FileRestorer:
+		return modelsStorage.StorageBackendFile
+	}
+	return "restorer"
+}
+
+// Хранилище готово, если готов источник восстановления: БД отвечает или файл доступен на запись
+func (store *StorageShortLink) CheckReady(ctx context.Context) (err error) {
+	if readyChecker, ok := store.Restorer.(restorer.ReadyChecker); ok {
+		return readyChecker.CheckReady()
+	}
+	return nil
+}
+
+// Сжатие файла хранилища: файл переписывается действующими ссылками из памяти,
+// пропадают повторы и строки, которые не удалось прочитать. Данные в БД не сжимаются
+func (store *StorageShortLink) Compact(ctx context.Context) (countRows int, err error) {
+
+	storageFileRestorer, ok := store.Restorer.(*fileRestorer.FileRestorer)
+	if !ok {
+		return 0, modelsStorage.ErrNotSupported
+	}
+
+	// пока файл переписывается, новые ссылки не добавляются, иначе они запишутся в старый файл
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return storageFileRestorer.RewriteRows(func(handler restorer.HandlerRowRestorer) (err error) {
+		for _, dataRow := range store.Data {
+			err = handler(restorer.RowDataRestorer{
+				ShortLink:   dataRow.ShortLink,
+				FullURL:     dataRow.FullURL,
+				UUID:        dataRow.UUID,
+				WorkspaceID: dataRow.WorkspaceID,
+				Domain:      dataRow.Domain,
+				Disabled:    dataRow.Disabled,
+			})
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Остановка хранилища: снимаем блокировку файла хранилища
+func (store *StorageShortLink) Close(ctx context.Context) (err error) {
+	return store.fileLock.Unlock()
+}
+
+// Применение изменения, сделанного в БД другим экземпляром сервиса
+func (store *StorageShortLink) ApplyChange(ctx context.Context, change modelsStorage.ChangeShortLink) (err error) {
+
+	switch change.Operation {
+	case modelsStorage.ChangeOperationInsert, modelsStorage.ChangeOperationUpdate:
+		if change.Row.ShortLink == "" || change.Row.FullURL == "" {
+			return store.Resync(ctx)
+		}
+
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+
+		namespace := change.Row.GetNamespace()
+		if change.OldShortLink != "" {
+			delete(store.Data, modelsStorage.GetStorageKey(namespace, change.OldShortLink))
+		}
+		store.Data[modelsStorage.GetStorageKey(namespace, change.Row.ShortLink)] = change.Row
+
+	case modelsStorage.ChangeOperationDelete:
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+
+		delete(store.Data, modelsStorage.GetStorageKey(change.Row.GetNamespace(), change.OldShortLink))
+
+	default:
+		return store.Resync(ctx)
+	}
+	return
+}
+
+// Полное перечитывание данных из ресторера
+func (store *StorageShortLink) Resync(ctx context.Context) (err error) {
+	return store.Restore(ctx)
+}
+
+// Метод вызывающийся при создании объекта
+func (store *StorageShortLink) Init(ctx context.Context) (err error) {
+	return store.Restore(ctx)
+}
+
+// Удаляем данные хранилища
+func (store *StorageShortLink) ClearStorage(ctx context.Context) (err error) {
+	err = store.Restorer.ClearRows()
+	if err == nil {
+		err = store.clearMemoryData(ctx)
+	}
+
+	return err
+}
@@ -1,50 +1,330 @@
-package storageshortlink
-
-import (
-	"go-url-shortener/internal/logger"
-	storageredb "go-url-shortener/internal/storage/storageshortlink/storagedb"
-	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
-	"log"
-
-	modelsStorage "go-url-shortener/internal/models/storageshortlink"
-)
-
-func NewStorageShorts() modelsStorage.StorageShortInterface {
-
-	storage, err := NewStorageShortsDB()
-	if err == nil {
-		logger.GetLogger().Debugln("Успешно создали хранилиже в Базе данных")
-	} else {
-		storage, err = NewStorageShortsRestorer()
-		if err == nil {
-			logger.GetLogger().Debugln("Успешно создали хранилиже как Ресторер")
-		} else {
-			log.Fatal("Выход из программы: не удалось инициализировать хранилище ссылок")
-		}
-
-	}
-	return storage
-}
-
-// создание хранилища в памяти с восстановлением из источника
-func NewStorageShortsRestorer() (modelsStorage.StorageShortInterface, error) {
-
-	storage, err := storagerestorer.NewStorageShorts()
-	if err != nil {
-		logger.GetLogger().Error("При инициализации хранилища StorageShortsRestorer возникла ошибка: " + err.Error())
-		return nil, err
-	}
-	return storage, nil
-}
-
-// создание хранилища на базе таблицы базы данных
-func NewStorageShortsDB() (modelsStorage.StorageShortInterface, error) {
-
-	// путь из конфигурации до файла хранилища с короткими ссылками
-	storage, err := storageredb.NewStorageShorts()
-	if err != nil {
-		logger.GetLogger().Error("При инициализации хранилища ссылок в БД возникла ошибка: " + err.Error())
-		return nil, err
-	}
-	return storage, nil
-}
+package storageshortlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortener/internal/config"
+	dbconn "go-url-shortener/internal/database/connect"
+	"go-url-shortener/internal/database/migrations"
+	"go-url-shortener/internal/logger"
+	storagecache "go-url-shortener/internal/storage/storageshortlink/storagecache"
+	storageredb "go-url-shortener/internal/storage/storageshortlink/storagedb"
+	storagefailover "go-url-shortener/internal/storage/storageshortlink/storagefailover"
+	storagememory "go-url-shortener/internal/storage/storageshortlink/storagememory"
+	storagerestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer"
+	fileRestorer "go-url-shortener/internal/storage/storageshortlink/storagerestorer/restorer/filerestorer"
+	"log"
+	"strings"
+	"sync"
+
+	modelsStorage "go-url-shortener/internal/models/storageshortlink"
+)
+
+// автоматический выбор хранилища в настройке STORAGE_BACKEND: БД, затем ресторер из БД, затем ресторер из файла
+// Остальные типы хранилищ - modelsStorage.StorageBackend*
+const StorageBackendAuto = "auto"
+
+// Зависимости хранилищ: конфигурация и соединение с БД
+// Хранилища берут настройки и соединение только отсюда, а не из общих объектов приложения
+type Dependencies struct {
+	Config    config.ConfigTypeInterface
+	DBHandler *dbconn.DBHandler
+	// сюда складываются подписки хранилища на перезагрузку конфигурации и на изменения в БД,
+	// без него подписки действуют до конца процесса
+	Subscriptions *Subscriptions
+}
+
+// Подписки хранилища, которые отменяет его владелец при остановке
+type Subscriptions struct {
+	mutex     sync.Mutex
+	listClose []func()
+}
+
+func (subscriptions *Subscriptions) add(closeSubscription func()) {
+	if subscriptions == nil {
+		return
+	}
+	subscriptions.mutex.Lock()
+	defer subscriptions.mutex.Unlock()
+	subscriptions.listClose = append(subscriptions.listClose, closeSubscription)
+}
+
+// Отмена всех подписок, повторный вызов ничего не делает
+func (subscriptions *Subscriptions) Close() {
+	subscriptions.mutex.Lock()
+	listClose := subscriptions.listClose
+	subscriptions.listClose = nil
+	subscriptions.mutex.Unlock()
+
+	for _, closeSubscription := range listClose {
+		closeSubscription()
+	}
+}
+
+// Зависимости из общей конфигурации и общего соединения с БД процесса
+// Подписки таких хранилищ живут вместе с процессом, поэтому они не собираются
+func GetDefaultDependencies() Dependencies {
+	return Dependencies{
+		Config:    config.GetAppConfig(),
+		DBHandler: dbconn.GetDBHandler(),
+	}
+}
+
+// Создание хранилища, выбранного в конфигурации
+// Если хранилище создать не удалось, то выходим из программы
+func NewStorageShorts(deps Dependencies) modelsStorage.StorageShortInterface {
+
+	nameBackend := deps.Config.GetStorageBackend()
+	storage, err := NewStorageShortsByBackend(nameBackend, deps)
+	if err != nil {
+		log.Fatal("Выход из программы: " + err.Error())
+	}
+	return storage
+}
+
+// Создание хранилища указанного типа
+// Для явно указанного типа нет запасных вариантов: если хранилище недоступно, то возвращаем ошибку
+func NewStorageShortsByBackend(nameBackend string, deps Dependencies) (storage modelsStorage.StorageShortInterface, err error) {
+
+	nameBackend = strings.ToLower(strings.TrimSpace(nameBackend))
+	if nameBackend == "" {
+		nameBackend = StorageBackendAuto
+	}
+
+	// неверное имя таблицы - ошибка конфигурации, не переключаемся из-за нее на другое хранилище
+	if isBackendUseTable(nameBackend, deps.Config) {
+		nameTable := deps.Config.GetNameTableRestorer()
+		_, err = migrations.ParseTableName(nameTable)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка: хранилище ссылок %s не создано: %w", nameBackend, err)
+		}
+	}
+
+	switch nameBackend {
+	case StorageBackendAuto:
+		storage, err = newStorageShortsAuto(deps)
+	case modelsStorage.StorageBackendPostgres:
+		storage, err = NewStorageShortsDB(deps)
+	case modelsStorage.StorageBackendPostgresCached:
+		nameTableRestorer := deps.Config.GetNameTableRestorer()
+		storage, err = storagerestorer.NewStorageShortsFromDB(deps.DBHandler, nameTableRestorer)
+	case modelsStorage.StorageBackendPostgresFailover:
+		storage, err = NewStorageShortsFailover(deps)
+	case modelsStorage.StorageBackendFile:
+		pathFileStorage := deps.Config.GetFileStoragePath()
+		storage, err = storagerestorer.NewStorageShortsFromFileStorage(pathFileStorage, deps.Config)
+	case modelsStorage.StorageBackendMemory:
+		storage, err = NewStorageShortsMemory(deps)
+	default:
+		listBackends := strings.Join(GetListStorageBackends(), ", ")
+		return nil, errors.New("ошибка: неизвестный тип хранилища STORAGE_BACKEND=" + nameBackend + ", допустимые значения: " + listBackends)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка: хранилище ссылок %s недоступно: %w", nameBackend, err)
+	}
+
+	storage, unsubscribeConfig, err := wrapStorageCache(storage, deps.Config)
+	if err != nil {
+		return nil, err
+	}
+	if unsubscribeConfig != nil {
+		deps.Subscriptions.add(unsubscribeConfig)
+	}
+
+	listener, err := subscribeStorageChanges(storage, deps)
+	if err != nil {
+		if unsubscribeConfig != nil {
+			unsubscribeConfig()
+		}
+		return nil, err
+	}
+	if listener != nil {
+		deps.Subscriptions.add(listener.Close)
+	}
+
+	logger.GetLogger().Infof("Используется хранилище ссылок: %s (STORAGE_BACKEND=%s)", GetStorageBackendName(storage), nameBackend)
+	return storage, nil
+}
+
+// Список допустимых типов хранилищ
+func GetListStorageBackends() []string {
+	return []string{
+		StorageBackendAuto,
+		modelsStorage.StorageBackendPostgres,
+		modelsStorage.StorageBackendPostgresCached,
+		modelsStorage.StorageBackendPostgresFailover,
+		modelsStorage.StorageBackendFile,
+		modelsStorage.StorageBackendMemory,
+	}
+}
+
+// Название типа работающего хранилища
+func GetStorageBackendName(storage modelsStorage.StorageShortInterface) string {
+	if storageDescriber, ok := storage.(modelsStorage.StorageDescriberInterface); ok {
+		return storageDescriber.GetBackendName()
+	}
+	return "unknown"
+}
+
+// Оборачиваем хранилище в кеш, если он включен в конфигурации
+// unsubscribe отменяет подписку кеша на перезагрузку конфигурации, без кеша подписки нет и он nil
+func wrapStorageCache(storage modelsStorage.StorageShortInterface, configApp config.ConfigTypeInterface) (_ modelsStorage.StorageShortInterface, unsubscribe func(), err error) {
+
+	cacheSize := configApp.GetCacheSize()
+	if cacheSize <= 0 {
+		return storage, nil, nil
+	}
+
+	storageCache, err := storagecache.NewStorageShorts(storage, cacheSize, configApp.GetCacheTTL(), configApp.GetCacheNegativeTTL())
+	if err != nil {
+		logger.GetLogger().Error("При инициализации кеша хранилища ссылок возникла ошибка: " + err.Error())
+		return nil, nil, err
+	}
+	logger.GetLogger().Debugf("Используется кеш хранилища ссылок на %d записей", cacheSize)
+
+	// размер и время жизни записей кеша меняются при перезагрузке конфигурации
+	unsubscribe = configApp.Subscribe(func(configApp config.ConfigTypeInterface) {
+		errLimits := storageCache.SetLimits(configApp.GetCacheSize(), configApp.GetCacheTTL(), configApp.GetCacheNegativeTTL())
+		if errLimits != nil {
+			logger.GetLogger().Warnf("Настройки кеша хранилища ссылок не изменены: %s", errLimits.Error())
+		}
+	})
+	return storageCache, unsubscribe, nil
+}
+
+// Подписываем локальные данные хранилища на изменения в БД, сделанные другими экземплярами сервиса
+// Подписка нужна только хранилищам, которые работают с БД и держат данные в памяти (кеш или ресторер)
+// Без подписки listener равен nil, иначе его закрывает владелец хранилища
+func subscribeStorageChanges(storage modelsStorage.StorageShortInterface, deps Dependencies) (listener *dbconn.Listener, err error) {
+
+	storageApplier, ok := storage.(modelsStorage.StorageChangesApplierInterface)
+	if !ok {
+		return nil, nil
+	}
+	switch GetStorageBackendName(storage) {
+	case modelsStorage.StorageBackendPostgres, modelsStorage.StorageBackendPostgresCached, modelsStorage.StorageBackendPostgresFailover:
+	default:
+		return nil, nil
+	}
+
+	nameTable := deps.Config.GetNameTableRestorer()
+	handlerNotification := func(payload string) {
+		ctx := context.TODO()
+		change, err := dbconn.ParseNotificationChange(payload)
+		if err != nil {
+			logger.GetLogger().Error(err.Error())
+			return
+		}
+		err = storageApplier.ApplyChange(ctx, change)
+		if err != nil {
+			logger.GetLogger().Error("ошибка применения изменения коротких ссылок из БД: " + err.Error())
+		}
+	}
+	handlerResync := func() {
+		err := storageApplier.Resync(context.TODO())
+		if err != nil {
+			logger.GetLogger().Error("ошибка полной синхронизации коротких ссылок с БД: " + err.Error())
+			return
+		}
+		logger.GetLogger().Debugln("Локальные данные хранилища синхронизированы с БД")
+	}
+
+	listener, err = deps.DBHandler.Listen(dbconn.GetChannelChanges(nameTable), handlerNotification, handlerResync)
+	if err != nil {
+		logger.GetLogger().Error("Не удалось подписаться на изменения коротких ссылок в БД: " + err.Error())
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Хранилище работает с таблицей коротких ссылок в БД
+// При автоматическом выборе таблица используется, если задано подключение к БД
+func isBackendUseTable(nameBackend string, configApp config.ConfigTypeInterface) bool {
+	switch nameBackend {
+	case modelsStorage.StorageBackendPostgres, modelsStorage.StorageBackendPostgresCached, modelsStorage.StorageBackendPostgresFailover:
+		return true
+	case StorageBackendAuto:
+		return configApp.GetDatabaseDsn() != ""
+	}
+	return false
+}
+
+// автоматический выбор хранилища, как было до появления настройки STORAGE_BACKEND:
+// БД, а если она недоступна, то хранилище с восстановлением
+func newStorageShortsAuto(deps Dependencies) (storage modelsStorage.StorageShortInterface, err error) {
+
+	storage, err = NewStorageShortsDB(deps)
+	if err == nil {
+		logger.GetLogger().Debugln("Успешно создали хранилиже в Базе данных")
+		return
+	}
+
+	storage, err = NewStorageShortsRestorer(deps)
+	if err == nil {
+		logger.GetLogger().Debugln("Успешно создали хранилиже как Ресторер")
+		return
+	}
+
+	return nil, errors.New("не удалось инициализировать ни одно хранилище ссылок")
+}
+
+// создание хранилища в памяти с восстановлением из источника
+func NewStorageShortsRestorer(deps Dependencies) (modelsStorage.StorageShortInterface, error) {
+
+	storage, err := storagerestorer.NewStorageShorts(deps.DBHandler, deps.Config)
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища StorageShortsRestorer возникла ошибка: " + err.Error())
+		return nil, err
+	}
+	return storage, nil
+}
+
+// создание хранилища на базе таблицы базы данных
+func NewStorageShortsDB(deps Dependencies) (modelsStorage.StorageShortInterface, error) {
+
+	// название таблицы в базе данных с короткими ссылками
+	storage, err := storageredb.NewStorageShorts(deps.DBHandler, deps.Config.GetNameTableRestorer())
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища ссылок в БД возникла ошибка: " + err.Error())
+		return nil, err
+	}
+	return storage, nil
+}
+
+// создание хранилища только в памяти с необязательными снимками на диск
+func NewStorageShortsMemory(deps Dependencies) (modelsStorage.StorageShortInterface, error) {
+
+	configApp := deps.Config
+	storage, err := storagememory.NewStorageShorts(configApp.GetMemorySnapshotPath(), configApp.GetMemorySnapshotInterval(), configApp)
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища ссылок в памяти возникла ошибка: " + err.Error())
+		return nil, err
+	}
+	return storage, nil
+}
+
+// создание хранилища в БД с переключением на локальные данные при недоступности БД
+func NewStorageShortsFailover(deps Dependencies) (modelsStorage.StorageShortInterface, error) {
+
+	storageDB, err := storageredb.NewStorageShorts(deps.DBHandler, deps.Config.GetNameTableRestorer())
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища ссылок в БД возникла ошибка: " + err.Error())
+		return nil, err
+	}
+
+	pathJournal := deps.Config.GetFailoverJournalPath()
+	journal, err := fileRestorer.NewFileRestorer(pathJournal, deps.Config)
+	if err != nil {
+		logger.GetLogger().Error("При инициализации журнала хранилища возникла ошибка: " + err.Error())
+		return nil, err
+	}
+
+	storage, err := storagefailover.NewStorageShorts(storageDB, deps.DBHandler, journal)
+	if err != nil {
+		logger.GetLogger().Error("При инициализации хранилища с переключением возникла ошибка: " + err.Error())
+		return nil, err
+	}
+	return storage, nil
+}
@@ -1,82 +1,215 @@
-package usercookies
-
-import (
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"go-url-shortener/internal/logger"
-	"net/http"
-)
-
-// название cookie в которой храняться данные пользователя
-var NameCookiesUserData = "USER_DATA"
-
-// тип который описывает данные пользователя, хранящиеся в куках
-type UserDataCookies struct {
-	ListFullURL []string
-}
-
-// Получаем из кук данне пользователя
-func GetCookiesUserData(req *http.Request) (userData UserDataCookies, err error) {
-
-	if valueCookie, err := req.Cookie(NameCookiesUserData); err == nil {
-
-		cookiesLinksUsers := valueCookie.Value
-
-		bytesCookiesLinksUsers, err := base64.StdEncoding.DecodeString(cookiesLinksUsers)
-		if err != nil {
-			err = fmt.Errorf("ошибка: не получилось декодировать куки пользователя: %w", err)
-			strError := err.Error()
-			logger.GetLogger().Debug(strError)
-			return userData, err
-		}
-
-		err = json.Unmarshal(bytesCookiesLinksUsers, &userData)
-		if err != nil {
-			err = fmt.Errorf("ошибка десериализации куков "+NameCookiesUserData+": %w", err)
-			strError := err.Error()
-			logger.GetLogger().Debugf("%s", strError)
-		}
-	}
-	return
-}
-
-// Сохраняем в куках данные пользователя
-func SetCookiesUserData(userData UserDataCookies, res http.ResponseWriter) {
-	bytesCookiesLinksUsers, _ := json.Marshal(&userData)
-	encodenCookiesLinksUsers := base64.StdEncoding.EncodeToString(bytesCookiesLinksUsers)
-	cookie := &http.Cookie{
-		Name:   NameCookiesUserData,
-		Value:  encodenCookiesLinksUsers,
-		Path:   "/",
-		MaxAge: 30000,
-		Secure: false,
-	}
-	http.SetCookie(res, cookie)
-}
-
-// Запоминаем ссылки, которые запрашивал пользователь
-func AddListFullURLToUser(listFullURL []string, res http.ResponseWriter, req *http.Request) (err error) {
-
-	userData, err := GetCookiesUserData(req)
-
-	if err != nil {
-		return err
-	}
-
-	for _, fullURL := range listFullURL {
-		isNewLink := true
-		for _, value := range userData.ListFullURL {
-			if value == fullURL {
-				isNewLink = false
-			}
-		}
-
-		if isNewLink {
-			userData.ListFullURL = append(userData.ListFullURL, fullURL)
-		}
-	}
-
-	SetCookiesUserData(userData, res)
-	return
-}
+package usercookies
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-url-shortener/internal/logger"
+	modelsWorkspace "go-url-shortener/internal/models/workspace"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// название cookie в которой храняться данные пользователя
+var NameCookiesUserData = "USER_DATA"
+
+// название cookie с идентификатором пользователя, по нему проверяется участие в рабочих пространствах
+var NameCookiesUserID = "USER_ID"
+
+// срок жизни cookie с идентификатором пользователя - год
+// по идентификатору определяются роли в рабочих пространствах, поэтому он не должен меняться каждые несколько часов
+const maxAgeCookiesUserID = 365 * 24 * 60 * 60
+
+// случайный ключ подписи идентификатора пользователя, если ключ не задан в настройке USER_ID_SECRET
+var (
+	onceProcessSecret sync.Once
+	processSecret     []byte
+)
+
+// тип который описывает данные пользователя, хранящиеся в куках
+type UserDataCookies struct {
+	// ссылки общего рабочего пространства
+	ListFullURL []string
+	// ссылки остальных рабочих пространств, ключ - идентификатор рабочего пространства
+	ListWorkspaceFullURL map[string][]string `json:",omitempty"`
+}
+
+// Ссылки, которые пользователь запрашивал в рабочем пространстве
+func (userData UserDataCookies) GetListFullURL(workspaceID string) []string {
+	if workspaceID == modelsWorkspace.DefaultWorkspaceID {
+		return userData.ListFullURL
+	}
+	return userData.ListWorkspaceFullURL[workspaceID]
+}
+
+// Установка ссылок, которые пользователь запрашивал в рабочем пространстве
+func (userData *UserDataCookies) setListFullURL(workspaceID string, listFullURL []string) {
+	if workspaceID == modelsWorkspace.DefaultWorkspaceID {
+		userData.ListFullURL = listFullURL
+		return
+	}
+	if userData.ListWorkspaceFullURL == nil {
+		userData.ListWorkspaceFullURL = make(map[string][]string)
+	}
+	userData.ListWorkspaceFullURL[workspaceID] = listFullURL
+}
+
+// Получаем из кук данне пользователя
+func GetCookiesUserData(req *http.Request) (userData UserDataCookies, err error) {
+
+	if valueCookie, err := req.Cookie(NameCookiesUserData); err == nil {
+
+		cookiesLinksUsers := valueCookie.Value
+
+		bytesCookiesLinksUsers, err := base64.StdEncoding.DecodeString(cookiesLinksUsers)
+		if err != nil {
+			err = fmt.Errorf("ошибка: не получилось декодировать куки пользователя: %w", err)
+			strError := err.Error()
+			logger.GetLogger().Debug(strError)
+			return userData, err
+		}
+
+		err = json.Unmarshal(bytesCookiesLinksUsers, &userData)
+		if err != nil {
+			err = fmt.Errorf("ошибка десериализации куков "+NameCookiesUserData+": %w", err)
+			strError := err.Error()
+			logger.GetLogger().Debugf("%s", strError)
+		}
+	}
+	return
+}
+
+// Сохраняем в куках данные пользователя
+func SetCookiesUserData(userData UserDataCookies, res http.ResponseWriter) {
+	bytesCookiesLinksUsers, _ := json.Marshal(&userData)
+	encodenCookiesLinksUsers := base64.StdEncoding.EncodeToString(bytesCookiesLinksUsers)
+	cookie := &http.Cookie{
+		Name:   NameCookiesUserData,
+		Value:  encodenCookiesLinksUsers,
+		Path:   "/",
+		MaxAge: 30000,
+		Secure: false,
+	}
+	http.SetCookie(res, cookie)
+}
+
+// Запоминаем ссылки, которые запрашивал пользователь
+func AddListFullURLToUser(listFullURL []string, res http.ResponseWriter, req *http.Request) (err error) {
+
+	userData, err := GetCookiesUserData(req)
+
+	if err != nil {
+		return err
+	}
+
+	// ссылки запоминаем в рабочем пространстве запроса
+	workspaceID := modelsWorkspace.GetWorkspaceID(req.Context())
+	listUserFullURL := userData.GetListFullURL(workspaceID)
+	for _, fullURL := range listFullURL {
+		isNewLink := true
+		for _, value := range listUserFullURL {
+			if value == fullURL {
+				isNewLink = false
+			}
+		}
+
+		if isNewLink {
+			listUserFullURL = append(listUserFullURL, fullURL)
+		}
+	}
+	userData.setListFullURL(workspaceID, listUserFullURL)
+
+	SetCookiesUserData(userData, res)
+	return
+}
+
+// Получаем из кук идентификатор пользователя
+// Пусто - пользователь еще не получил идентификатор или подпись идентификатора неверна
+func GetCookiesUserID(req *http.Request, secret string) (userID string) {
+
+	valueCookie, err := req.Cookie(NameCookiesUserID)
+	if err != nil {
+		return
+	}
+
+	index := strings.LastIndex(valueCookie.Value, ".")
+	if index < 0 {
+		logger.GetLogger().Debugf("ошибка: у куки %s нет подписи", NameCookiesUserID)
+		return
+	}
+	userIDCookie, signCookie := valueCookie.Value[:index], valueCookie.Value[index+1:]
+
+	sign, isSigned := getSignUserID(userIDCookie, secret)
+	if !isSigned || !hmac.Equal([]byte(signCookie), []byte(sign)) {
+		logger.GetLogger().Debugf("ошибка: неверная подпись куки %s", NameCookiesUserID)
+		return
+	}
+	return userIDCookie
+}
+
+// Сохраняем в куках идентификатор пользователя с подписью
+func SetCookiesUserID(userID, secret string, res http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     NameCookiesUserID,
+		Value:    SignUserID(userID, secret),
+		Path:     "/",
+		MaxAge:   maxAgeCookiesUserID,
+		Secure:   false,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(res, cookie)
+}
+
+// Значение куки с идентификатором пользователя: идентификатор и его подпись HMAC-SHA256 через точку
+// Без подписи идентификатор любого участника рабочего пространства можно было бы просто вписать в куку
+func SignUserID(userID, secret string) string {
+	sign, _ := getSignUserID(userID, secret)
+	return userID + "." + sign
+}
+
+// Подпись идентификатора пользователя, isSigned = false, если нет ключа подписи
+func getSignUserID(userID, secret string) (sign string, isSigned bool) {
+
+	key := []byte(secret)
+	if secret == "" {
+		key = getProcessSecret()
+	}
+	if len(key) == 0 {
+		return "", false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), true
+}
+
+// Случайный ключ подписи, действует до перезапуска процесса
+func getProcessSecret() []byte {
+	onceProcessSecret.Do(func() {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			logger.GetLogger().Error("ошибка: не удалось сгенерировать ключ подписи идентификатора пользователя: " + err.Error())
+			return
+		}
+		processSecret = key
+	})
+	return processSecret
+}
+
+// Новый случайный идентификатор пользователя
+func NewUserID() (userID string, err error) {
+	bytesUserID := make([]byte, 16)
+	_, err = rand.Read(bytesUserID)
+	if err != nil {
+		err = fmt.Errorf("ошибка: не удалось сгенерировать идентификатор пользователя: %w", err)
+		return
+	}
+	return hex.EncodeToString(bytesUserID), nil
+}
@@ -0,0 +1,180 @@
+package workspaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/logger"
+	modelsWorkspace "go-url-shortener/internal/models/workspace"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// идентификатор рабочего пространства хранится в БД и в ключах хранилищ, поэтому ограничиваем его
+var regexpWorkspaceID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// префикс пути: один или несколько сегментов из допустимых символов
+var regexpPathPrefix = regexp.MustCompile(`^(/[A-Za-z0-9_.~-]+)+$`)
+
+// Набор рабочих пространств с поиском по домену и префиксу пути
+type Registry struct {
+	listWorkspaces []modelsWorkspace.Workspace
+	byID           map[string]int
+	byDomain       map[string]int
+}
+
+// Создание набора рабочих пространств с проверкой описаний
+func NewRegistry(listWorkspaces []modelsWorkspace.Workspace) (registry *Registry, err error) {
+
+	registry = &Registry{
+		byID:     make(map[string]int, len(listWorkspaces)),
+		byDomain: make(map[string]int, len(listWorkspaces)),
+	}
+	byPathPrefix := make(map[string]bool, len(listWorkspaces))
+
+	for _, ws := range listWorkspaces {
+		if !regexpWorkspaceID.MatchString(ws.ID) {
+			return nil, fmt.Errorf("ошибка: недопустимый идентификатор рабочего пространства %q, допустимы строчные латинские буквы, цифры, _ и -, не более 64 символов", ws.ID)
+		}
+		if _, ok := registry.byID[ws.ID]; ok {
+			return nil, fmt.Errorf("ошибка: рабочее пространство %s описано несколько раз", ws.ID)
+		}
+		if ws.Domain == "" && ws.PathPrefix == "" {
+			return nil, fmt.Errorf("ошибка: у рабочего пространства %s не указан ни домен, ни префикс пути", ws.ID)
+		}
+
+		ws.Domain = strings.ToLower(strings.TrimSpace(ws.Domain))
+		if ws.Domain != "" {
+			if _, ok := registry.byDomain[ws.Domain]; ok {
+				return nil, fmt.Errorf("ошибка: домен %s указан у нескольких рабочих пространств", ws.Domain)
+			}
+		}
+
+		ws.PathPrefix = strings.TrimSuffix(strings.TrimSpace(ws.PathPrefix), "/")
+		if ws.PathPrefix != "" {
+			if !regexpPathPrefix.MatchString(ws.PathPrefix) {
+				return nil, fmt.Errorf("ошибка: недопустимый префикс пути %q у рабочего пространства %s", ws.PathPrefix, ws.ID)
+			}
+			if byPathPrefix[ws.PathPrefix] {
+				return nil, fmt.Errorf("ошибка: префикс пути %s указан у нескольких рабочих пространств", ws.PathPrefix)
+			}
+			byPathPrefix[ws.PathPrefix] = true
+		}
+
+		for _, member := range ws.Members {
+			if member.UserID == "" {
+				return nil, fmt.Errorf("ошибка: у участника рабочего пространства %s не указан user_id", ws.ID)
+			}
+			if member.Role != modelsWorkspace.RoleAdmin && member.Role != modelsWorkspace.RoleMember {
+				return nil, fmt.Errorf("ошибка: неизвестная роль %q участника рабочего пространства %s, допустимы admin и member", member.Role, ws.ID)
+			}
+		}
+
+		index := len(registry.listWorkspaces)
+		registry.listWorkspaces = append(registry.listWorkspaces, ws)
+		registry.byID[ws.ID] = index
+		if ws.Domain != "" {
+			registry.byDomain[ws.Domain] = index
+		}
+	}
+
+	return registry, nil
+}
+
+// Чтение рабочих пространств из JSON файла
+// Формат файла: [{"id": "team", "name": "...", "domain": "...", "path_prefix": "/team",
+// "members": [{"user_id": "...", "role": "admin"}]}]
+func LoadRegistry(pathFile string) (registry *Registry, err error) {
+
+	if pathFile == "" {
+		return NewRegistry(nil)
+	}
+
+	dataFile, err := os.ReadFile(pathFile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка: не удалось прочитать файл рабочих пространств: %w", err)
+	}
+
+	listWorkspaces := []modelsWorkspace.Workspace{}
+	err = json.Unmarshal(dataFile, &listWorkspaces)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка: не удалось разобрать файл рабочих пространств: %w", err)
+	}
+
+	return NewRegistry(listWorkspaces)
+}
+
+// Рабочее пространство по идентификатору
+func (registry *Registry) Get(workspaceID string) (ws modelsWorkspace.Workspace, ok bool) {
+	index, ok := registry.byID[workspaceID]
+	if !ok {
+		return
+	}
+	return registry.listWorkspaces[index], true
+}
+
+// Есть ли рабочие пространства с участниками
+func (registry *Registry) isRestricted() bool {
+	for _, ws := range registry.listWorkspaces {
+		if ws.IsRestricted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Все рабочие пространства
+func (registry *Registry) GetList() []modelsWorkspace.Workspace {
+	return registry.listWorkspaces
+}
+
+// Поиск рабочего пространства запроса
+// Сначала ищем по домену, потом по префиксу пути. Для префикса возвращаем путь без префикса.
+func (registry *Registry) Resolve(host, path string) (ws modelsWorkspace.Workspace, restPath string, ok bool) {
+
+	restPath = path
+	if len(registry.listWorkspaces) == 0 {
+		return
+	}
+
+	host = strings.ToLower(host)
+	index, ok := registry.byDomain[host]
+	if !ok {
+		// домен рабочего пространства может быть указан без порта
+		if hostname, _, errSplit := net.SplitHostPort(host); errSplit == nil {
+			index, ok = registry.byDomain[hostname]
+		}
+	}
+	if ok {
+		return registry.listWorkspaces[index], path, true
+	}
+
+	for _, wsPrefix := range registry.listWorkspaces {
+		prefix := wsPrefix.PathPrefix
+		if prefix == "" {
+			continue
+		}
+		if path == prefix {
+			return wsPrefix, "/", true
+		}
+		if strings.HasPrefix(path, prefix+"/") {
+			return wsPrefix, strings.TrimPrefix(path, prefix), true
+		}
+	}
+	return modelsWorkspace.Workspace{}, path, false
+}
+
+// Рабочие пространства из файла конфигурации WORKSPACES_FILE
+func LoadRegistryFromConfig(configApp config.ConfigTypeInterface) (registry *Registry, err error) {
+
+	registry, err = LoadRegistry(configApp.GetWorkspacesFile())
+	if err != nil {
+		return
+	}
+	if registry.isRestricted() && configApp.GetUserIDSecret() == "" {
+		logger.GetLogger().Warn("Не задан USER_ID_SECRET: после перезапуска участники рабочих пространств получат новые идентификаторы и потеряют роли")
+	}
+	return
+}